@@ -3,16 +3,38 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 )
 
+// Access token verification modes of JWTAuth.
+const (
+	// AuthModeLocal verifies signatures against the JWKS and asks
+	// service-users only whether the token was revoked.
+	AuthModeLocal = "local"
+	// AuthModeIntrospection hands every token to the RFC 7662 introspection
+	// endpoint of service-users.
+	AuthModeIntrospection = "introspection"
+)
+
 type Config struct {
-	Addr             string
-	UsersServiceURL  string
-	OrdersServiceURL string
-	JWTSecret        string
+	Addr               string
+	UsersServiceURL    string
+	OrdersServiceURL   string
+	JWKSURL            string
+	JWKSCacheTTL       time.Duration
+	RevocationCacheTTL time.Duration
+	AuthMode           string
+	IntrospectionURL   string
+	// The gateway's own OAuth client, used to call the introspection endpoint.
+	OAuthClientID     string
+	OAuthClientSecret string
+	// InternalAPISecret authenticates the gateway to the internal API of
+	// service-users.
+	InternalAPISecret string
 }
 
 func Load() *Config {
@@ -27,7 +49,40 @@ func Load() *Config {
 		Addr:             ":" + getEnv("GATEWAY_PORT", "8080"),
 		UsersServiceURL:  getEnv("USERS_SERVICE_URL", "http://service-users:8082"),
 		OrdersServiceURL: getEnv("ORDERS_SERVICE_URL", "http://service-orders:8081"),
-		JWTSecret:        getEnv("TOKEN_SECRET", "your-default-secret"),
+	}
+	cfg.JWKSURL = getEnv("JWKS_URL", cfg.UsersServiceURL+"/.well-known/jwks.json")
+
+	jwksSeconds, err := strconv.Atoi(getEnv("JWKS_CACHE_SECONDS", "300"))
+	if err != nil || jwksSeconds <= 0 {
+		log.Printf("Warning: JWKS_CACHE_SECONDS is invalid, using 300 seconds")
+		jwksSeconds = 300
+	}
+	cfg.JWKSCacheTTL = time.Duration(jwksSeconds) * time.Second
+
+	cacheSeconds, err := strconv.Atoi(getEnv("REVOCATION_CACHE_SECONDS", "10"))
+	if err != nil || cacheSeconds < 0 {
+		log.Printf("Warning: REVOCATION_CACHE_SECONDS is invalid, using 10 seconds")
+		cacheSeconds = 10
+	}
+	cfg.RevocationCacheTTL = time.Duration(cacheSeconds) * time.Second
+
+	cfg.AuthMode = getEnv("AUTH_MODE", AuthModeLocal)
+	cfg.IntrospectionURL = getEnv("INTROSPECTION_URL", cfg.UsersServiceURL+"/oauth/introspect")
+	cfg.OAuthClientID = getEnv("GATEWAY_CLIENT_ID", "")
+	cfg.OAuthClientSecret = getEnv("GATEWAY_CLIENT_SECRET", "")
+	switch cfg.AuthMode {
+	case AuthModeLocal:
+	case AuthModeIntrospection:
+		if cfg.OAuthClientID == "" || cfg.OAuthClientSecret == "" {
+			log.Fatalf("AUTH_MODE=%s requires GATEWAY_CLIENT_ID and GATEWAY_CLIENT_SECRET", cfg.AuthMode)
+		}
+	default:
+		log.Fatalf("Unknown AUTH_MODE %q, expected %q or %q", cfg.AuthMode, AuthModeLocal, AuthModeIntrospection)
+	}
+
+	cfg.InternalAPISecret = getEnv("INTERNAL_API_SECRET", "")
+	if cfg.InternalAPISecret == "" {
+		log.Printf("Warning: INTERNAL_API_SECRET is not set, token checks against service-users will fail")
 	}
 
 	if cfg.Addr == ":" || cfg.UsersServiceURL == "" || cfg.OrdersServiceURL == "" {
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,13 +15,13 @@ func SwaggerHandler(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		usersSwagger, err := fetchSwagger(cfg.UsersServiceURL + "/swagger/doc.json")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users swagger: " + err.Error()})
+			problems.Write(c.Writer, c.Request, http.StatusBadGateway, "upstream_unavailable", "Failed to fetch users swagger: "+err.Error())
 			return
 		}
 
 		ordersSwagger, err := fetchSwagger(cfg.OrdersServiceURL + "/swagger/doc.json")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders swagger: " + err.Error()})
+			problems.Write(c.Writer, c.Request, http.StatusBadGateway, "upstream_unavailable", "Failed to fetch orders swagger: "+err.Error())
 			return
 		}
 
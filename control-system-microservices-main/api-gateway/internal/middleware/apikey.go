@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// apiKeyIdentity is what service-users resolves an API key to.
+type apiKeyIdentity struct {
+	UserID      uint     `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	KeyID       uint     `json:"key_id"`
+}
+
+// apiKeyCache remembers resolved keys for cfg.RevocationCacheTTL, which also
+// bounds how long a revoked key keeps working. Keys are stored by hash, and a
+// nil identity records a key that was rejected.
+type apiKeyCache struct {
+	mu      sync.Mutex
+	entries map[string]apiKeyEntry
+}
+
+type apiKeyEntry struct {
+	identity  *apiKeyIdentity
+	expiresAt time.Time
+}
+
+var apiKeys = &apiKeyCache{entries: make(map[string]apiKeyEntry)}
+
+func (kc *apiKeyCache) get(key string) (*apiKeyIdentity, bool) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	entry, ok := kc.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.identity, true
+}
+
+func (kc *apiKeyCache) set(key string, identity *apiKeyIdentity, ttl time.Duration) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	now := time.Now()
+	if len(kc.entries) > 10000 {
+		for k, e := range kc.entries {
+			if now.After(e.expiresAt) {
+				delete(kc.entries, k)
+			}
+		}
+	}
+	kc.entries[key] = apiKeyEntry{identity: identity, expiresAt: now.Add(ttl)}
+}
+
+// apiKeyFromRequest returns the key from "Authorization: ApiKey <key>" or the
+// X-API-Key header, and removes both so the key is not sent upstream.
+func apiKeyFromRequest(c *gin.Context) string {
+	key := strings.TrimSpace(c.GetHeader("X-API-Key"))
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "ApiKey ") {
+		key = strings.TrimSpace(strings.TrimPrefix(auth, "ApiKey "))
+		c.Request.Header.Del("Authorization")
+	}
+	c.Request.Header.Del("X-API-Key")
+	return key
+}
+
+// resolveAPIKey returns nil without an error when service-users rejects the key.
+func resolveAPIKey(key string) (*apiKeyIdentity, error) {
+	sum := sha256.Sum256([]byte(key))
+	cacheKey := hex.EncodeToString(sum[:])
+	if identity, ok := apiKeys.get(cacheKey); ok {
+		return identity, nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"key": key})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := callInternal(http.MethodPost, "/internal/api-keys/resolve", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var identity *apiKeyIdentity
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var body struct {
+			Data apiKeyIdentity `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		identity = &body.Data
+	case http.StatusUnauthorized, http.StatusBadRequest:
+	default:
+		return nil, fmt.Errorf("api key resolve: %v", resp.StatusCode)
+	}
+
+	if cfg.RevocationCacheTTL > 0 {
+		apiKeys.set(cacheKey, identity, cfg.RevocationCacheTTL)
+	}
+	return identity, nil
+}
+
+// authenticateAPIKey is the API key branch of JWTAuth. It forwards the same
+// identity headers as a bearer token does.
+func authenticateAPIKey(c *gin.Context, key string) {
+	identity, err := resolveAPIKey(key)
+	if err != nil {
+		logger.Error("API key resolution failed", zap.Error(err))
+		problems.Abort(c, http.StatusServiceUnavailable, "service_unavailable", "Unable to verify API key")
+		return
+	}
+	if identity == nil {
+		problems.Abort(c, http.StatusUnauthorized, "unauthorized", "Invalid API key")
+		return
+	}
+
+	userIDStr := strconv.FormatUint(uint64(identity.UserID), 10)
+	c.Request.Header.Set("X-User-ID", userIDStr)
+	if len(identity.Roles) > 0 {
+		c.Request.Header.Set("X-User-Roles", strings.Join(identity.Roles, ","))
+	}
+	if len(identity.Permissions) > 0 {
+		c.Request.Header.Set("X-User-Permissions", strings.Join(identity.Permissions, ","))
+	}
+
+	logger.Info("Authenticated request",
+		zap.String("user_id", userIDStr),
+		zap.Uint("api_key_id", identity.KeyID),
+		zap.Strings("roles", identity.Roles),
+		zap.String("path", c.Request.URL.Path),
+	)
+	c.Next()
+}
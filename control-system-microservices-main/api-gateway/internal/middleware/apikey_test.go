@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTAuth_APIKey(t *testing.T) {
+	identity := apiKeyIdentity{UserID: 42, Roles: []string{"service"}, Permissions: []string{"orders:read"}, KeyID: 3}
+
+	t.Run("ключ из X-API-Key", func(t *testing.T) {
+		r, users := setupGateway(t)
+		users.apiKeys["csk_valid"] = identity
+
+		status, _, forwarded := call(r, map[string]string{
+			"X-API-Key":          "csk_valid",
+			"X-User-Roles":       "superadmin",
+			"X-User-Permissions": "users:manage_roles",
+			"X-Session-ID":       "forged",
+		})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, echoResponse{UserID: "42", Roles: "service", Permissions: "orders:read"}, forwarded)
+	})
+
+	t.Run("ключ из Authorization: ApiKey", func(t *testing.T) {
+		r, users := setupGateway(t)
+		users.apiKeys["csk_valid"] = identity
+
+		status, _, forwarded := call(r, map[string]string{"Authorization": "ApiKey csk_valid"})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "42", forwarded.UserID)
+	})
+
+	t.Run("ключ без прав не получает чужих заголовков", func(t *testing.T) {
+		r, users := setupGateway(t)
+		users.apiKeys["csk_bare"] = apiKeyIdentity{UserID: 43, KeyID: 4}
+
+		status, _, forwarded := call(r, map[string]string{
+			"X-API-Key":          "csk_bare",
+			"X-User-Roles":       "superadmin",
+			"X-User-Permissions": "users:manage_roles",
+		})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, echoResponse{UserID: "43"}, forwarded)
+	})
+
+	t.Run("отклонённый ключ кешируется", func(t *testing.T) {
+		r, users := setupGateway(t)
+
+		for i := 0; i < 2; i++ {
+			status, code, _ := call(r, map[string]string{"X-API-Key": "csk_unknown"})
+			assert.Equal(t, http.StatusUnauthorized, status)
+			assert.Equal(t, "unauthorized", code)
+		}
+		assert.Equal(t, 1, users.callCount("/internal/api-keys/resolve"))
+	})
+
+	t.Run("ошибка service-users не выдаётся за неверный ключ", func(t *testing.T) {
+		r, users := setupGateway(t)
+		users.apiKeys["csk_valid"] = identity
+		users.apiKeyStatus = http.StatusInternalServerError
+
+		status, code, _ := call(r, map[string]string{"X-API-Key": "csk_valid"})
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, "service_unavailable", code)
+		assert.Empty(t, apiKeys.entries)
+	})
+
+	t.Run("service-users недоступен", func(t *testing.T) {
+		r, users := setupGateway(t)
+		users.apiKeys["csk_valid"] = identity
+		cfg.UsersServiceURL = "http://127.0.0.1:1"
+
+		status, code, _ := call(r, map[string]string{"X-API-Key": "csk_valid"})
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, "service_unavailable", code)
+	})
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// introspectionResult is the part of an RFC 7662 response the gateway uses.
+type introspectionResult struct {
+	Active      bool     `json:"active"`
+	Revoked     bool     `json:"revoked"`
+	Sub         string   `json:"sub"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Exp         int64    `json:"exp"`
+	SessionID   string   `json:"sid"`
+}
+
+// introspectionCache remembers answers by token hash for
+// cfg.RevocationCacheTTL, but never past the token's expiry.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[string]introspectionEntry
+}
+
+type introspectionEntry struct {
+	result    introspectionResult
+	expiresAt time.Time
+}
+
+var introspections = &introspectionCache{entries: make(map[string]introspectionEntry)}
+
+func (ic *introspectionCache) get(key string) (introspectionResult, bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	entry, ok := ic.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return introspectionResult{}, false
+	}
+	return entry.result, true
+}
+
+func (ic *introspectionCache) set(key string, result introspectionResult, expiresAt time.Time) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	now := time.Now()
+	if len(ic.entries) > 10000 {
+		for k, e := range ic.entries {
+			if now.After(e.expiresAt) {
+				delete(ic.entries, k)
+			}
+		}
+	}
+	ic.entries[key] = introspectionEntry{result: result, expiresAt: expiresAt}
+}
+
+func introspectToken(token string) (introspectionResult, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	if result, ok := introspections.get(key); ok {
+		return result, nil
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	req, err := http.NewRequest(http.MethodPost, cfg.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return introspectionResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(cfg.OAuthClientID, cfg.OAuthClientSecret)
+
+	resp, err := usersClient.Do(req)
+	if err != nil {
+		return introspectionResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return introspectionResult{}, fmt.Errorf("token introspection: %v", resp.StatusCode)
+	}
+
+	var result introspectionResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return introspectionResult{}, err
+	}
+
+	if cfg.RevocationCacheTTL > 0 {
+		expiresAt := time.Now().Add(cfg.RevocationCacheTTL)
+		if result.Active && time.Unix(result.Exp, 0).Before(expiresAt) {
+			expiresAt = time.Unix(result.Exp, 0)
+		}
+		introspections.set(key, result, expiresAt)
+	}
+	return result, nil
+}
+
+// authenticateIntrospection is the AUTH_MODE=introspection branch of JWTAuth.
+// It forwards the same identity headers as local verification does.
+func authenticateIntrospection(c *gin.Context, token string) {
+	result, err := introspectToken(token)
+	if err != nil {
+		logger.Error("Token introspection failed", zap.Error(err))
+		problems.Abort(c, http.StatusServiceUnavailable, "service_unavailable", "Unable to verify token")
+		return
+	}
+	if _, err := strconv.ParseUint(result.Sub, 10, 64); !result.Active || err != nil {
+		code, message := "unauthorized", "Invalid token"
+		if result.Revoked {
+			code, message = "token_revoked", "Token has been revoked"
+		}
+		problems.Abort(c, http.StatusUnauthorized, code, message)
+		return
+	}
+
+	c.Request.Header.Set("X-User-ID", result.Sub)
+	if len(result.Roles) > 0 {
+		c.Request.Header.Set("X-User-Roles", strings.Join(result.Roles, ","))
+	}
+	if len(result.Permissions) > 0 {
+		c.Request.Header.Set("X-User-Permissions", strings.Join(result.Permissions, ","))
+	}
+	if result.SessionID != "" {
+		c.Request.Header.Set("X-Session-ID", result.SessionID)
+	}
+
+	logger.Info("Authenticated request",
+		zap.String("user_id", result.Sub),
+		zap.Strings("roles", result.Roles),
+		zap.String("path", c.Request.URL.Path),
+	)
+	c.Next()
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupIntrospection(t *testing.T, result introspectionResult) (*gin.Engine, *fakeUsers) {
+	r, users := setupGateway(t)
+	cfg.AuthMode = config.AuthModeIntrospection
+	users.introspection = result
+	return r, users
+}
+
+func TestJWTAuth_Introspection(t *testing.T) {
+	active := introspectionResult{
+		Active:      true,
+		Sub:         "7",
+		Roles:       []string{"engineer"},
+		Permissions: []string{"orders:read"},
+		SessionID:   "session-1",
+		Exp:         time.Now().Add(time.Hour).Unix(),
+	}
+	bearer := map[string]string{"Authorization": "Bearer opaque-token"}
+
+	t.Run("активный токен", func(t *testing.T) {
+		r, users := setupIntrospection(t, active)
+
+		status, _, forwarded := call(r, map[string]string{
+			"Authorization":      "Bearer opaque-token",
+			"X-User-Permissions": "users:manage_roles",
+		})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, echoResponse{UserID: "7", Roles: "engineer", Permissions: "orders:read", SessionID: "session-1"}, forwarded)
+		assert.Equal(t, 0, users.callCount("/.well-known/jwks.json"))
+	})
+
+	t.Run("неактивный токен", func(t *testing.T) {
+		r, _ := setupIntrospection(t, introspectionResult{Active: false})
+
+		status, code, _ := call(r, bearer)
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "unauthorized", code)
+	})
+
+	t.Run("токен отозванного клиента", func(t *testing.T) {
+		r, _ := setupIntrospection(t, introspectionResult{Active: false, Revoked: true})
+
+		status, code, _ := call(r, bearer)
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "token_revoked", code)
+	})
+
+	t.Run("активный токен без пользователя", func(t *testing.T) {
+		result := active
+		result.Sub = "client:reports"
+		r, _ := setupIntrospection(t, result)
+
+		status, code, _ := call(r, bearer)
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "unauthorized", code)
+	})
+
+	t.Run("ответ кешируется", func(t *testing.T) {
+		r, users := setupIntrospection(t, active)
+
+		call(r, bearer)
+		call(r, bearer)
+
+		assert.Equal(t, 1, users.callCount("/oauth/introspect"))
+	})
+
+	t.Run("кеш не переживает срок действия токена", func(t *testing.T) {
+		result := active
+		result.Exp = time.Now().Add(-time.Second).Unix()
+		r, users := setupIntrospection(t, result)
+
+		call(r, bearer)
+		call(r, bearer)
+
+		assert.Equal(t, 2, users.callCount("/oauth/introspect"))
+	})
+
+	t.Run("неверные учётные данные шлюза", func(t *testing.T) {
+		r, _ := setupIntrospection(t, active)
+		cfg.OAuthClientSecret = "wrong"
+
+		status, code, _ := call(r, bearer)
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, "service_unavailable", code)
+	})
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minJWKSRefresh limits how often an unknown kid may trigger a refetch, so
+// tokens with random kids cannot be used to hammer service-users.
+const minJWKSRefresh = 10 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+type verificationKey struct {
+	alg string
+	key interface{}
+}
+
+// jwksCache keeps the public keys published by service-users. Keys are
+// refreshed after cfg.JWKSCacheTTL, or earlier when a token names a kid we
+// have not seen yet (which is what happens right after a key rotation).
+type jwksCache struct {
+	mu        sync.Mutex
+	keys      map[string]verificationKey
+	fetchedAt time.Time
+	// fetching is closed when the fetch in flight finishes; nil when none is.
+	fetching chan struct{}
+}
+
+var jwks = &jwksCache{}
+
+func (jc *jwksCache) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid")
+	}
+
+	key, ok, err := jc.get(kid)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.key, nil
+}
+
+// get looks kid up, refreshing the keys first when they are stale. The fetch
+// runs without holding mu, so a slow service-users does not stall tokens
+// whose keys are cached; callers that need new keys while a fetch is in
+// flight wait for it instead of starting another.
+func (jc *jwksCache) get(kid string) (verificationKey, bool, error) {
+	jc.mu.Lock()
+	key, ok := jc.keys[kid]
+	if !ok && jc.fetching != nil {
+		fetching := jc.fetching
+		jc.mu.Unlock()
+		<-fetching
+		return jc.cached(kid)
+	}
+
+	stale := time.Since(jc.fetchedAt) > cfg.JWKSCacheTTL
+	if !ok && time.Since(jc.fetchedAt) > minJWKSRefresh {
+		stale = true
+	}
+	if !stale || jc.fetching != nil {
+		jc.mu.Unlock()
+		return key, ok, nil
+	}
+	fetching := make(chan struct{})
+	jc.fetching = fetching
+	jc.fetchedAt = time.Now()
+	jc.mu.Unlock()
+
+	keys, err := fetchJWKS()
+
+	jc.mu.Lock()
+	if err == nil {
+		jc.keys = keys
+	}
+	jc.fetching = nil
+	close(fetching)
+	haveKeys := jc.keys != nil
+	key, ok = jc.keys[kid]
+	jc.mu.Unlock()
+
+	if err != nil {
+		if !haveKeys {
+			return verificationKey{}, false, err
+		}
+		logger.Warn("Using cached JWKS after refresh failure")
+	}
+	return key, ok, nil
+}
+
+func (jc *jwksCache) cached(kid string) (verificationKey, bool, error) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	if jc.keys == nil {
+		return verificationKey{}, false, fmt.Errorf("jwks: no keys available")
+	}
+	key, ok := jc.keys[kid]
+	return key, ok, nil
+}
+
+func fetchJWKS() (map[string]verificationKey, error) {
+	resp, err := usersClient.Get(cfg.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: status %v", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]verificationKey, len(body.Keys))
+	for _, k := range body.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			logger.Warn("Skipping unsupported JWK: " + err.Error())
+			continue
+		}
+		keys[k.Kid] = verificationKey{alg: k.Alg, key: key}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch {
+	case k.Kty == "RSA" && k.Alg == jwt.SigningMethodRS256.Alg():
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == jwt.SigningMethodEdDSA.Alg():
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("kid %q: unsupported key type %s/%s", k.Kid, k.Kty, k.Alg)
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// backdateJWKS makes the cached keys old enough for an unknown kid to
+// trigger a refetch.
+func backdateJWKS(age time.Duration) {
+	jwks.mu.Lock()
+	jwks.fetchedAt = time.Now().Add(-age)
+	jwks.mu.Unlock()
+}
+
+func TestJWTAuth_KeyRotation(t *testing.T) {
+	t.Run("неизвестный kid запрашивает JWKS заново", func(t *testing.T) {
+		r, users := setupGateway(t)
+		oldKey := newSigningKey(t, users, "key-1")
+
+		status, _, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", oldKey, accessClaims())})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1, users.callCount("/.well-known/jwks.json"))
+
+		newKey := newSigningKey(t, users, "key-2")
+		backdateJWKS(minJWKSRefresh + time.Second)
+
+		status, _, _ = call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-2", newKey, accessClaims())})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2, users.callCount("/.well-known/jwks.json"))
+
+		// Tokens signed before the rotation keep working.
+		status, _, _ = call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", oldKey, accessClaims())})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2, users.callCount("/.well-known/jwks.json"))
+	})
+
+	t.Run("неизвестный kid не запрашивает JWKS чаще minJWKSRefresh", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())})
+
+		for i := 0; i < 3; i++ {
+			status, code, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "random", key, accessClaims())})
+			assert.Equal(t, http.StatusUnauthorized, status)
+			assert.Equal(t, "unauthorized", code)
+		}
+		assert.Equal(t, 1, users.callCount("/.well-known/jwks.json"))
+	})
+
+	t.Run("снятый с публикации ключ перестаёт приниматься после TTL", func(t *testing.T) {
+		r, users := setupGateway(t)
+		oldKey := newSigningKey(t, users, "key-1")
+		call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", oldKey, accessClaims())})
+
+		users.mu.Lock()
+		delete(users.keys, "key-1")
+		users.mu.Unlock()
+		backdateJWKS(cfg.JWKSCacheTTL + time.Second)
+
+		status, _, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", oldKey, accessClaims())})
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, 2, users.callCount("/.well-known/jwks.json"))
+	})
+
+	t.Run("при ошибке обновления используются прежние ключи", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())})
+
+		users.mu.Lock()
+		users.jwksStatus = http.StatusInternalServerError
+		users.mu.Unlock()
+		backdateJWKS(cfg.JWKSCacheTTL + time.Second)
+
+		status, _, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2, users.callCount("/.well-known/jwks.json"))
+	})
+
+	t.Run("без ключей запрос отклоняется", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		users.jwksStatus = http.StatusInternalServerError
+
+		status, _, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())})
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("алгоритм токена должен совпадать с алгоритмом ключа", func(t *testing.T) {
+		r, users := setupGateway(t)
+		newSigningKey(t, users, "key-1")
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims())
+		token.Header["kid"] = "key-1"
+		signed, _ := token.SignedString([]byte("guessed"))
+
+		status, _, _ := call(r, map[string]string{"Authorization": "Bearer " + signed})
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, 0, users.callCount("/internal/tokens/status"))
+	})
+}
@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -24,30 +26,33 @@ func InitConfig(c *config.Config) {
 
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Request.Header.Del("X-User-ID")
+		c.Request.Header.Del("X-User-Roles")
+		c.Request.Header.Del("X-User-Permissions")
+		c.Request.Header.Del("X-Session-ID")
+
+		if key := apiKeyFromRequest(c); key != "" {
+			authenticateAPIKey(c, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   gin.H{"code": "unauthorized", "message": "Missing or invalid Bearer token"},
-			})
-			c.Abort()
+			problems.Abort(c, http.StatusUnauthorized, "unauthorized", "Missing or invalid Bearer token or API key")
 			return
 		}
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(cfg.JWTSecret), nil
-		})
+		if cfg.AuthMode == config.AuthModeIntrospection {
+			authenticateIntrospection(c, tokenStr)
+			return
+		}
+
+		token, err := jwt.Parse(tokenStr, jwks.keyfunc,
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"error":   gin.H{"code": "unauthorized", "message": "Invalid token"},
-			})
-			c.Abort()
+			problems.Abort(c, http.StatusUnauthorized, "unauthorized", "Invalid token")
 			return
 		}
 
@@ -57,32 +62,41 @@ func JWTAuth() gin.HandlerFunc {
 				userIDStr = fmt.Sprintf("%v", idv)
 			}
 
-			var roles []string
-			switch raw := claims["roles"].(type) {
-			case []interface{}:
-				for _, r := range raw {
-					if s, ok := r.(string); ok && s != "" {
-						roles = append(roles, s)
-					}
-				}
-			case []string:
-				roles = raw
-			case string:
-				for _, s := range strings.Split(raw, ",") {
-					s = strings.TrimSpace(s)
-					if s != "" {
-						roles = append(roles, s)
-					}
-				}
-			default:
+			jti, _ := claims["jti"].(string)
+			version := fmt.Sprintf("%v", claims["ver"])
+			sessionID, _ := claims["sid"].(string)
+			clientID, _ := claims["cid"].(string)
+			if jti == "" || userIDStr == "" {
+				problems.Abort(c, http.StatusUnauthorized, "unauthorized", "Invalid token")
+				return
+			}
+
+			revoked, err := isTokenRevoked(jti, userIDStr, version, sessionID, clientID)
+			if err != nil {
+				logger.Error("Token status check failed", zap.Error(err))
+				problems.Abort(c, http.StatusServiceUnavailable, "service_unavailable", "Unable to verify token")
+				return
+			}
+			if revoked {
+				problems.Abort(c, http.StatusUnauthorized, "token_revoked", "Token has been revoked")
+				return
 			}
 
+			roles := stringListClaim(claims["roles"])
+			permissions := stringListClaim(claims["permissions"])
+
 			if userIDStr != "" {
 				c.Request.Header.Set("X-User-ID", userIDStr)
 			}
 			if len(roles) > 0 {
 				c.Request.Header.Set("X-User-Roles", strings.Join(roles, ","))
 			}
+			if len(permissions) > 0 {
+				c.Request.Header.Set("X-User-Permissions", strings.Join(permissions, ","))
+			}
+			if sessionID != "" {
+				c.Request.Header.Set("X-Session-ID", sessionID)
+			}
 
 			logger.Info("Authenticated request",
 				zap.String("user_id", userIDStr),
@@ -94,14 +108,41 @@ func JWTAuth() gin.HandlerFunc {
 	}
 }
 
+// stringListClaim accepts a JSON array or a comma-separated string.
+func stringListClaim(claim interface{}) []string {
+	var values []string
+	switch raw := claim.(type) {
+	case []interface{}:
+		for _, r := range raw {
+			if s, ok := r.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+	case []string:
+		values = raw
+	case string:
+		for _, s := range strings.Split(raw, ",") {
+			s = strings.TrimSpace(s)
+			if s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// RequestID gives every request an ID of its own. An X-Request-ID sent by the
+// client is replaced, since the services record the ID in audit and order
+// history and a client could otherwise put anything there.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		reqID := c.GetHeader("X-Request-ID")
-		if reqID == "" {
-			reqID = "req-" + time.Now().Format("20060102150405")
-		}
+		// Random rather than time-based, so that requests arriving in the
+		// same instant are still told apart.
+		reqID := "req-" + rand.Text()
 		c.Set("X-Request-ID", reqID)
 		c.Header("X-Request-ID", reqID)
+		// Forwarded so that upstream services can tie their records to it.
+		c.Request.Header.Set("X-Request-ID", reqID)
 		c.Next()
 	}
 }
@@ -119,20 +160,12 @@ func RateLimiterManual() gin.HandlerFunc {
 
 		result, err := lim.Get(c.Request.Context(), key)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "internal server error",
-			})
+			problems.Abort(c, http.StatusInternalServerError, "internal_error", "Internal server error")
 			return
 		}
 
 		if result.Reached {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "rate_limit_exceeded",
-					"message": "Too many requests",
-				},
-			})
+			problems.Abort(c, http.StatusTooManyRequests, "rate_limit_exceeded", "Too many requests")
 			return
 		}
 
@@ -157,8 +190,8 @@ func Logging() gin.HandlerFunc {
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, X-API-Key, X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
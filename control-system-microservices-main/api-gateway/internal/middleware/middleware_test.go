@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInternalSecret = "test-internal-secret"
+
+// fakeUsers stands in for service-users: it publishes a JWKS, answers token
+// status, API key and introspection requests, and counts the calls it gets.
+type fakeUsers struct {
+	mu            sync.Mutex
+	keys          map[string]ed25519.PublicKey
+	jwksStatus    int
+	revoked       bool
+	apiKeys       map[string]apiKeyIdentity
+	apiKeyStatus  int
+	introspection introspectionResult
+	calls         map[string]int
+	queries       []string
+}
+
+func (f *fakeUsers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[r.URL.Path]++
+
+	if r.URL.Path != "/.well-known/jwks.json" && r.URL.Path != "/oauth/introspect" &&
+		r.Header.Get(internalTokenHeader) != testInternalSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/.well-known/jwks.json":
+		if f.jwksStatus != 0 {
+			w.WriteHeader(f.jwksStatus)
+			return
+		}
+		keys := []jwk{}
+		for kid, key := range f.keys {
+			keys = append(keys, jwk{Kty: "OKP", Crv: "Ed25519", Alg: "EdDSA", Kid: kid, X: base64.RawURLEncoding.EncodeToString(key)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	case "/internal/tokens/status":
+		f.queries = append(f.queries, r.URL.RawQuery)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]bool{"revoked": f.revoked}})
+	case "/internal/api-keys/resolve":
+		if f.apiKeyStatus != 0 {
+			w.WriteHeader(f.apiKeyStatus)
+			return
+		}
+		var body struct {
+			Key string `json:"key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		identity, ok := f.apiKeys[body.Key]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": identity})
+	case "/oauth/introspect":
+		if id, secret, ok := r.BasicAuth(); !ok || id != "gateway" || secret != "gateway-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.introspection)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeUsers) callCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[path]
+}
+
+// setupGateway points the middleware at a fake service-users, clears every
+// cache and returns a router that echoes the identity headers JWTAuth
+// forwards.
+func setupGateway(t *testing.T) (*gin.Engine, *fakeUsers) {
+	gin.SetMode(gin.TestMode)
+
+	users := &fakeUsers{
+		keys:    make(map[string]ed25519.PublicKey),
+		apiKeys: make(map[string]apiKeyIdentity),
+		calls:   make(map[string]int),
+	}
+	server := httptest.NewServer(users)
+	t.Cleanup(server.Close)
+
+	InitConfig(&config.Config{
+		UsersServiceURL:    server.URL,
+		JWKSURL:            server.URL + "/.well-known/jwks.json",
+		JWKSCacheTTL:       5 * time.Minute,
+		RevocationCacheTTL: 10 * time.Second,
+		AuthMode:           config.AuthModeLocal,
+		IntrospectionURL:   server.URL + "/oauth/introspect",
+		OAuthClientID:      "gateway",
+		OAuthClientSecret:  "gateway-secret",
+		InternalAPISecret:  testInternalSecret,
+	})
+	jwks = &jwksCache{}
+	revocations = &revocationCache{entries: make(map[string]revocationEntry)}
+	apiKeys = &apiKeyCache{entries: make(map[string]apiKeyEntry)}
+	introspections = &introspectionCache{entries: make(map[string]introspectionEntry)}
+
+	r := gin.New()
+	r.Use(JWTAuth())
+	r.GET("/echo", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":     c.GetHeader("X-User-ID"),
+			"roles":       c.GetHeader("X-User-Roles"),
+			"permissions": c.GetHeader("X-User-Permissions"),
+			"session_id":  c.GetHeader("X-Session-ID"),
+		})
+	})
+	return r, users
+}
+
+// newSigningKey publishes a fresh Ed25519 key under kid.
+func newSigningKey(t *testing.T, users *fakeUsers, kid string) ed25519.PrivateKey {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	users.mu.Lock()
+	users.keys[kid] = public
+	users.mu.Unlock()
+	return private
+}
+
+func signToken(t *testing.T, kid string, key ed25519.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func accessClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"id":          7,
+		"jti":         "jti-1",
+		"ver":         1,
+		"sid":         "session-1",
+		"roles":       []string{"engineer"},
+		"permissions": []string{"orders:read"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+type echoResponse struct {
+	UserID      string `json:"user_id"`
+	Roles       string `json:"roles"`
+	Permissions string `json:"permissions"`
+	SessionID   string `json:"session_id"`
+}
+
+// call sends a request through the router and returns the status, the
+// problem code and the forwarded identity.
+func call(r *gin.Engine, headers map[string]string) (int, string, echoResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/echo", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Code string `json:"code"`
+		echoResponse
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Code, body.echoResponse
+}
+
+func TestJWTAuth_StripsClientIdentityHeaders(t *testing.T) {
+	forged := map[string]string{
+		"X-User-ID":          "1",
+		"X-User-Roles":       "superadmin",
+		"X-User-Permissions": "users:manage_roles",
+		"X-Session-ID":       "forged",
+	}
+
+	t.Run("без токена заголовки не помогают", func(t *testing.T) {
+		r, _ := setupGateway(t)
+
+		status, code, _ := call(r, forged)
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "unauthorized", code)
+	})
+
+	t.Run("заголовки заменяются данными токена", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		claims := accessClaims()
+		delete(claims, "sid")
+		claims["roles"] = []string{}
+		claims["permissions"] = []string{}
+
+		headers := map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, claims)}
+		for name, value := range forged {
+			headers[name] = value
+		}
+		status, _, identity := call(r, headers)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, echoResponse{UserID: "7"}, identity)
+	})
+}
+
+func TestJWTAuth_Revocation(t *testing.T) {
+	t.Run("отозванный токен отклоняется", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		users.revoked = true
+		claims := accessClaims()
+		claims["cid"] = "client-1"
+
+		status, code, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, claims)})
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "token_revoked", code)
+		require.Len(t, users.queries, 1)
+		assert.Contains(t, users.queries[0], "cid=client-1")
+		assert.Contains(t, users.queries[0], "sid=session-1")
+	})
+
+	t.Run("ответ кешируется до истечения TTL", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		headers := map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())}
+
+		status, _, identity := call(r, headers)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, echoResponse{UserID: "7", Roles: "engineer", Permissions: "orders:read", SessionID: "session-1"}, identity)
+		assert.Equal(t, 1, users.callCount("/internal/tokens/status"))
+
+		// Revoked upstream, but the cached answer still stands.
+		users.mu.Lock()
+		users.revoked = true
+		users.mu.Unlock()
+		status, _, _ = call(r, headers)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1, users.callCount("/internal/tokens/status"))
+
+		revocations.mu.Lock()
+		for k, e := range revocations.entries {
+			e.expiresAt = time.Now().Add(-time.Second)
+			revocations.entries[k] = e
+		}
+		revocations.mu.Unlock()
+
+		status, code, _ := call(r, headers)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "token_revoked", code)
+		assert.Equal(t, 2, users.callCount("/internal/tokens/status"))
+	})
+
+	t.Run("без TTL каждый запрос спрашивает service-users", func(t *testing.T) {
+		r, users := setupGateway(t)
+		cfg.RevocationCacheTTL = 0
+		key := newSigningKey(t, users, "key-1")
+		headers := map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())}
+
+		call(r, headers)
+		call(r, headers)
+
+		assert.Equal(t, 2, users.callCount("/internal/tokens/status"))
+	})
+
+	t.Run("новая версия токена не берётся из кеша", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		claims := accessClaims()
+
+		call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, claims)})
+		claims["ver"] = 2
+		call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, claims)})
+
+		assert.Equal(t, 2, users.callCount("/internal/tokens/status"))
+	})
+
+	t.Run("недоступный service-users", func(t *testing.T) {
+		r, users := setupGateway(t)
+		key := newSigningKey(t, users, "key-1")
+		cfg.InternalAPISecret = "wrong"
+
+		status, code, _ := call(r, map[string]string{"Authorization": "Bearer " + signToken(t, "key-1", key, accessClaims())})
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, "service_unavailable", code)
+	})
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// revocationCache remembers token status answers from service-users so that
+// the gateway does not have to ask on every request. Entries live for
+// cfg.RevocationCacheTTL, which bounds how long a revoked token keeps working.
+type revocationCache struct {
+	mu      sync.Mutex
+	entries map[string]revocationEntry
+}
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+var revocations = &revocationCache{entries: make(map[string]revocationEntry)}
+
+var usersClient = &http.Client{Timeout: 3 * time.Second}
+
+// internalTokenHeader carries the secret service-users requires on its
+// internal API.
+const internalTokenHeader = "X-Internal-Token"
+
+// callInternal sends a request to the internal API of service-users.
+func callInternal(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, cfg.UsersServiceURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set(internalTokenHeader, cfg.InternalAPISecret)
+	return usersClient.Do(req)
+}
+
+func (rc *revocationCache) get(key string) (bool, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry, ok := rc.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+func (rc *revocationCache) set(key string, revoked bool, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := time.Now()
+	if len(rc.entries) > 10000 {
+		for k, e := range rc.entries {
+			if now.After(e.expiresAt) {
+				delete(rc.entries, k)
+			}
+		}
+	}
+	rc.entries[key] = revocationEntry{revoked: revoked, expiresAt: now.Add(ttl)}
+}
+
+func isTokenRevoked(jti, userID, version, sessionID, clientID string) (bool, error) {
+	key := jti + ":" + version
+	if revoked, ok := revocations.get(key); ok {
+		return revoked, nil
+	}
+
+	query := url.Values{}
+	query.Set("jti", jti)
+	query.Set("user_id", userID)
+	query.Set("version", version)
+	if sessionID != "" {
+		query.Set("sid", sessionID)
+	}
+	if clientID != "" {
+		query.Set("cid", clientID)
+	}
+
+	resp, err := callInternal(http.MethodGet, "/internal/tokens/status?"+query.Encode(), "", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("token status: %v", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Revoked bool `json:"revoked"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, err
+	}
+
+	if cfg.RevocationCacheTTL > 0 {
+		revocations.set(key, body.Data.Revoked, cfg.RevocationCacheTTL)
+	}
+	return body.Data.Revoked, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/config"
 	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/handlers"
 	"github.com/SpiritFoxo/control-system-microservices/api-gateway/internal/middleware"
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -16,10 +17,24 @@ import (
 func Setup(r *gin.Engine, cfg *config.Config) {
 	usersProxy := setupProxy(cfg.UsersServiceURL)
 	r.POST("/api/v1/auth/login", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
-	r.Any("/api/v1/admin/*path", middleware.JWTAuth(), middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/login/mfa", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/login/mfa/enroll", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/refresh", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/password/forgot", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/password/reset", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/invite/accept", middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.POST("/api/v1/auth/logout", middleware.RequestID(), middleware.JWTAuth(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.Any("/api/v1/auth/me", middleware.RequestID(), middleware.JWTAuth(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.Any("/api/v1/auth/me/*path", middleware.RequestID(), middleware.JWTAuth(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+	r.Any("/api/v1/admin/*path", middleware.RequestID(), middleware.JWTAuth(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), usersProxy)
+
+	// The provisioning client authenticates with service-users itself.
+	r.Any("/scim/v2/*path", middleware.RequestID(), middleware.Logging(), middleware.RateLimiterManual(), usersProxy)
 
 	ordersProxy := setupProxy(cfg.OrdersServiceURL)
-	r.Any("/api/v1/orders/*path", middleware.JWTAuth(), middleware.RequestID(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), ordersProxy)
+	r.Any("/api/v1/orders/*path", middleware.RequestID(), middleware.JWTAuth(), middleware.Logging(), middleware.CORS(), middleware.RateLimiterManual(), ordersProxy)
+
+	r.GET("/.well-known/jwks.json", usersProxy)
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "API Gateway is running"})
@@ -47,8 +62,7 @@ func setupProxy(target string) gin.HandlerFunc {
 	}
 
 	proxy.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, err error) {
-		writer.WriteHeader(http.StatusBadGateway)
-		writer.Write([]byte("Service unavailable"))
+		problems.Write(writer, request, http.StatusBadGateway, "upstream_unavailable", "Service unavailable")
 	}
 
 	return func(c *gin.Context) {
@@ -20,6 +20,135 @@ const docTemplate = `{
     "basePath": "{{.BasePath}}",
     "paths": {
         "/orders": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves a paginated list of orders. Callers without orders:read_all get their own orders, and asking for another user's is forbidden",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Orders"
+                ],
+                "summary": "Get list of orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of records per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by user ID",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "enum": [
+                                "Created",
+                                "Accepted",
+                                "Processed",
+                                "Closed",
+                                "Canceled"
+                            ],
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Statuses, repeated or comma-separated",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created on or after, RFC 3339 or YYYY-MM-DD",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created on or before, RFC 3339 or YYYY-MM-DD",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum cost, inclusive",
+                        "name": "cost_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum cost, inclusive",
+                        "name": "cost_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Item name, case-insensitive",
+                        "name": "item",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created_at or cost; by ID when omitted",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Switches to keyset pagination: empty for the first page, then the next_cursor of the previous one. page is ignored",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count the matching orders in keyset pagination",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user"
+                        ],
+                        "type": "string",
+                        "description": "Set to user to embed the users the orders were placed for",
+                        "name": "include",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of orders with pagination",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
             "post": {
                 "security": [
                     {
@@ -58,6 +187,51 @@ const docTemplate = `{
                 }
             }
         },
+        "/orders/cancel/{orderId}": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancels an existing order by ID and records the change in its history",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Orders"
+                ],
+                "summary": "Cancels an order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "orderId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Optional comment on the change",
+                        "name": "order",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateOrderInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated order",
+                        "schema": {
+                            "$ref": "#/definitions/services.OrderResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/orders/{orderId}": {
             "get": {
                 "security": [
@@ -65,7 +239,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Gets order by ID",
+                "description": "Gets order by ID. Callers without orders:read_all may only get their own orders",
                 "consumes": [
                     "application/json"
                 ],
@@ -83,6 +257,15 @@ const docTemplate = `{
                         "name": "orderId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "user"
+                        ],
+                        "type": "string",
+                        "description": "Set to user to embed the user the order was placed for",
+                        "name": "include",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -135,7 +318,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Updates an existing order by ID",
+                "description": "Moves an order to its next status and records the change in its history",
                 "consumes": [
                     "application/json"
                 ],
@@ -155,10 +338,9 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Order update data",
+                        "description": "Optional comment on the change",
                         "name": "order",
                         "in": "body",
-                        "required": true,
                         "schema": {
                             "$ref": "#/definitions/services.UpdateOrderInput"
                         }
@@ -174,58 +356,38 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/orders": {
+        "/orders/{orderId}/history": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves a paginated list of orders with optional userId and status filters",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Lists every status change of the order, oldest first. Callers without orders:read_all may only see their own orders",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Orders"
                 ],
-                "summary": "Get list of orders",
+                "summary": "Gets the status history of an order",
                 "parameters": [
                     {
                         "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Number of records per page",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Filter by user ID",
-                        "name": "userId",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by order status",
-                        "name": "status",
-                        "in": "query"
+                        "description": "Order ID",
+                        "name": "orderId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of orders with pagination",
+                        "description": "Status changes",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.OrderStatusChangeResponse"
+                            }
                         }
                     }
                 }
@@ -233,6 +395,23 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "models.OrderStatus": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4
+            ],
+            "x-enum-varnames": [
+                "StatusCreated",
+                "StatusAccepted",
+                "StatusProcessed",
+                "StatusClosed",
+                "StatusCanceled"
+            ]
+        },
         "services.CreateOrderInput": {
             "type": "object",
             "required": [
@@ -254,13 +433,7 @@ const docTemplate = `{
                     }
                 },
                 "status": {
-                    "type": "string",
-                    "enum": [
-                        "created",
-                        "in_progress",
-                        "completed",
-                        "cancelled"
-                    ]
+                    "$ref": "#/definitions/models.OrderStatus"
                 },
                 "user_id": {
                     "type": "integer"
@@ -300,6 +473,10 @@ const docTemplate = `{
         "services.OrderResponse": {
             "type": "object",
             "properties": {
+                "changed_at": {
+                    "description": "ChangedAt is when the order got its current status.",
+                    "type": "string"
+                },
                 "cost": {
                     "type": "integer"
                 },
@@ -313,27 +490,64 @@ const docTemplate = `{
                     }
                 },
                 "status": {
-                    "type": "string"
+                    "$ref": "#/definitions/models.OrderStatus"
+                },
+                "user": {
+                    "description": "User is only filled in when the caller asks for it.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.OrderUser"
+                        }
+                    ]
                 },
                 "user_id": {
                     "type": "integer"
                 }
             }
         },
+        "services.OrderStatusChangeResponse": {
+            "type": "object",
+            "properties": {
+                "actor_id": {
+                    "type": "integer"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "from": {
+                    "$ref": "#/definitions/models.OrderStatus"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "to": {
+                    "$ref": "#/definitions/models.OrderStatus"
+                }
+            }
+        },
+        "services.OrderUser": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
         "services.UpdateOrderInput": {
             "type": "object",
-            "required": [
-                "status"
-            ],
             "properties": {
-                "status": {
+                "comment": {
                     "type": "string",
-                    "enum": [
-                        "created",
-                        "in_progress",
-                        "completed",
-                        "cancelled"
-                    ]
+                    "maxLength": 500
                 }
             }
         }
@@ -354,7 +568,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/api/v1",
 	Schemes:          []string{},
 	Title:            "Orders Service API",
-	Description:      "API для управления заказами в системе",
+	Description:      "API for order management",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 }
@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/clients/users.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/clients/users.go -destination=./internal/clients/mocks/mock_users_client.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	clients "github.com/SpiritFoxo/control-system-microservices/service-orders/internal/clients"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUsersClientInterface is a mock of UsersClientInterface interface.
+type MockUsersClientInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockUsersClientInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockUsersClientInterfaceMockRecorder is the mock recorder for MockUsersClientInterface.
+type MockUsersClientInterfaceMockRecorder struct {
+	mock *MockUsersClientInterface
+}
+
+// NewMockUsersClientInterface creates a new mock instance.
+func NewMockUsersClientInterface(ctrl *gomock.Controller) *MockUsersClientInterface {
+	mock := &MockUsersClientInterface{ctrl: ctrl}
+	mock.recorder = &MockUsersClientInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUsersClientInterface) EXPECT() *MockUsersClientInterfaceMockRecorder {
+	return m.recorder
+}
+
+// GetUsers mocks base method.
+func (m *MockUsersClientInterface) GetUsers(ids []uint) (map[uint]clients.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", ids)
+	ret0, _ := ret[0].(map[uint]clients.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockUsersClientInterfaceMockRecorder) GetUsers(ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockUsersClientInterface)(nil).GetUsers), ids)
+}
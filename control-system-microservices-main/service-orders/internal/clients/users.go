@@ -0,0 +1,149 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
+)
+
+// maxLookupIDs is the largest batch service-users answers in one request.
+const maxLookupIDs = 100
+
+// User is what service-users tells other services about a user.
+type User struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Active bool   `json:"active"`
+}
+
+type UsersClientInterface interface {
+	// GetUsers returns the users that exist among ids, keyed by ID.
+	GetUsers(ids []uint) (map[uint]User, error)
+}
+
+// UsersClient looks users up through the internal API of service-users.
+// Answers are cached for ttl, which bounds how long a renamed or deactivated
+// user is shown the old way. Unknown IDs are not cached, so a user created a
+// moment ago is found right away.
+type UsersClient struct {
+	baseURL string
+	secret  string
+	client  *http.Client
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[uint]cachedUser
+}
+
+type cachedUser struct {
+	user      User
+	expiresAt time.Time
+}
+
+func NewUsersClient(baseURL, secret string, timeout, ttl time.Duration) *UsersClient {
+	return &UsersClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  secret,
+		client:  &http.Client{Timeout: timeout},
+		ttl:     ttl,
+		cache:   make(map[uint]cachedUser),
+	}
+}
+
+func (c *UsersClient) GetUsers(ids []uint) (map[uint]User, error) {
+	users := make(map[uint]User, len(ids))
+	var missing []uint
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if user, ok := c.cached(id); ok {
+			users[id] = user
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	for start := 0; start < len(missing); start += maxLookupIDs {
+		end := min(start+maxLookupIDs, len(missing))
+		fetched, err := c.fetch(missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range fetched {
+			users[user.ID] = user
+			c.store(user)
+		}
+	}
+	return users, nil
+}
+
+func (c *UsersClient) fetch(ids []uint) ([]User, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	query := url.Values{"ids": {strings.Join(values, ",")}}
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/internal/users?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(middleware.InternalTokenHeader, c.secret)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user lookup: %v", resp.StatusCode)
+	}
+
+	var body struct {
+		Data []User `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Data, nil
+}
+
+func (c *UsersClient) cached(id uint) (User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[id]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return User{}, false
+	}
+	return entry.user, true
+}
+
+func (c *UsersClient) store(user User) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.cache) > 10000 {
+		for id, entry := range c.cache {
+			if now.After(entry.expiresAt) {
+				delete(c.cache, id)
+			}
+		}
+	}
+	c.cache[user.ID] = cachedUser{user: user, expiresAt: now.Add(c.ttl)}
+}
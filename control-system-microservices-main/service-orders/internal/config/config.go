@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -16,6 +19,14 @@ type Config struct {
 	PostgresPassword string
 	PostgresPort     string
 	DBSSLMode        string
+
+	// UsersServiceURL is where the internal user lookup API of service-users
+	// is reached. It must not go through the api-gateway.
+	UsersServiceURL string
+	UsersTimeout    time.Duration
+	UsersCacheTTL   time.Duration
+	// InternalAPISecret authenticates service-orders to that API.
+	InternalAPISecret string
 }
 
 func Load() *Config {
@@ -31,6 +42,27 @@ func Load() *Config {
 		PostgresPassword: getEnv("POSTGRES_PASSWORD", "password"),
 		PostgresPort:     getEnv("POSTGRES_PORT", "5432"),
 		DBSSLMode:        getEnv("DB_SSLMODE", "disable"),
+		UsersServiceURL:  getEnv("USERS_SERVICE_URL", "http://service-users:8082"),
+
+		InternalAPISecret: getEnv("INTERNAL_API_SECRET", ""),
+	}
+
+	timeoutSeconds, err := strconv.Atoi(getEnv("USERS_SERVICE_TIMEOUT_SECONDS", "3"))
+	if err != nil || timeoutSeconds <= 0 {
+		log.Printf("Warning: USERS_SERVICE_TIMEOUT_SECONDS is invalid, using 3 seconds")
+		timeoutSeconds = 3
+	}
+	cfg.UsersTimeout = time.Duration(timeoutSeconds) * time.Second
+
+	cacheSeconds, err := strconv.Atoi(getEnv("USERS_CACHE_SECONDS", "60"))
+	if err != nil || cacheSeconds < 0 {
+		log.Printf("Warning: USERS_CACHE_SECONDS is invalid, using 60 seconds")
+		cacheSeconds = 60
+	}
+	cfg.UsersCacheTTL = time.Duration(cacheSeconds) * time.Second
+
+	if cfg.InternalAPISecret == "" {
+		log.Printf("Warning: INTERNAL_API_SECRET is not set, user lookups will be rejected")
 	}
 
 	return cfg
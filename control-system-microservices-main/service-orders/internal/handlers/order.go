@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/services"
-	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
+	"github.com/SpiritFoxo/control-system-microservices/shared/pagination"
+	"github.com/SpiritFoxo/control-system-microservices/shared/permissions"
+	"github.com/SpiritFoxo/control-system-microservices/shared/queryparams"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,13 +24,37 @@ func NewOrderHandler(service *services.OrderService) *OrderHandler {
 	return &OrderHandler{service: service}
 }
 
+// includeUser tells whether the caller asked for ?include=user.
+func includeUser(c *gin.Context) bool {
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		if strings.TrimSpace(include) == "user" {
+			return true
+		}
+	}
+	return false
+}
+
+// ownOrdersOnly returns the caller's ID when they may only see their own
+// orders, or 0 when orders:read_all lets them see any.
+func ownOrdersOnly(c *gin.Context) (uint, error) {
+	if middleware.HasPermission(c, permissions.OrdersReadAll) {
+		return 0, nil
+	}
+	userID, err := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 32)
+	if err != nil || userID == 0 {
+		return 0, errUnauthenticated
+	}
+	return uint(userID), nil
+}
+
 // GetOrderById
 // @Summary Gets order by ID
-// @Description Gets order by ID
+// @Description Gets order by ID. Callers without orders:read_all may only get their own orders
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param orderId path int true "Order ID"
+// @Param include query string false "Set to user to embed the user the order was placed for" Enums(user)
 // @Success 200 {object} services.OrderResponse "Order data"
 // @Security BearerAuth
 // @Router /orders/{orderId} [get]
@@ -33,105 +62,147 @@ func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 	id := c.Param("orderId")
 	var orderID uint
 	if _, err := fmt.Sscanf(id, "%d", &orderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		problem(c, invalidParam("orderId", "invalid order ID"))
+		return
+	}
+	ownerID, err := ownOrdersOnly(c)
+	if err != nil {
+		problem(c, err)
 		return
 	}
-	order, err := h.service.GetOrderByID(orderID)
+	order, err := h.service.GetOrderByID(orderID, ownerID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		problem(c, err)
 		return
 	}
+	if includeUser(c) {
+		h.service.AttachUsers(order)
+	}
 	c.JSON(http.StatusOK, order)
 }
 
 // GetAllOrders
 // @Summary Get list of orders
-// @Description Retrieves a paginated list of orders with optional userId and status filters
+// @Description Retrieves a paginated list of orders. Callers without orders:read_all get their own orders, and asking for another user's is forbidden
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param page query int false "Page number" default(1)
-// @Param limit query int false "Number of records per page" default(10)
+// @Param limit query int false "Number of records per page, at most 100" default(10)
 // @Param userId query int false "Filter by user ID"
-// @Param status query string false "Filter by order status"
+// @Param status query []string false "Statuses, repeated or comma-separated" collectionFormat(multi) Enums(Created, Accepted, Processed, Closed, Canceled)
+// @Param created_from query string false "Created on or after, RFC 3339 or YYYY-MM-DD"
+// @Param created_to query string false "Created on or before, RFC 3339 or YYYY-MM-DD"
+// @Param cost_min query int false "Minimum cost, inclusive"
+// @Param cost_max query int false "Maximum cost, inclusive"
+// @Param item query string false "Item name, case-insensitive"
+// @Param sort query string false "created_at or cost; by ID when omitted"
+// @Param order query string false "asc (default) or desc"
+// @Param cursor query string false "Switches to keyset pagination: empty for the first page, then the next_cursor of the previous one. page is ignored"
+// @Param include_total query bool false "Count the matching orders in keyset pagination"
+// @Param include query string false "Set to user to embed the users the orders were placed for" Enums(user)
 // @Success 200 {object} map[string]interface{} "List of orders with pagination"
 // @Security BearerAuth
-// @Router /v1/orders [get]
+// @Router /orders [get]
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
-	rolesHeader := c.GetHeader("X-User-Roles")
-	if rolesHeader == "" {
-		if v, exists := c.Get("roles"); exists {
-			if rs, ok := v.([]string); ok && len(rs) > 0 {
-				rolesHeader = strings.Join(rs, ",")
-			}
-		}
-	}
-
-	userIdHeader := c.GetHeader("X-User-ID")
-	var tokenUserID uint
-	if userIdHeader != "" {
-		if idInt, err := strconv.Atoi(userIdHeader); err == nil && idInt > 0 {
-			tokenUserID = uint(idInt)
-		}
-	}
-	roles := strings.Split(rolesHeader, ",")
-	for i := range roles {
-		roles[i] = strings.TrimSpace(roles[i])
-	}
-
 	pageStr := c.DefaultQuery("page", "1")
 	limitStr := c.DefaultQuery("limit", "10")
 	userIDStr := c.Query("userId")
-	statusFilter := c.Query("status")
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		problem(c, invalidParam("page", "invalid page number"))
 		return
 	}
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit value"})
+		problem(c, invalidParam("limit", "invalid limit value"))
 		return
 	}
 
-	isAllowedToViewAllOrders := false
-	for _, role := range roles {
-		if role != userroles.RoleEngineer {
-			isAllowedToViewAllOrders = true
-			break
-		}
-	}
-
 	var userID uint
 	if userIDStr != "" {
 		userIDInt, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+			problem(c, invalidParam("userId", "invalid userId"))
 			return
 		}
 		userID = uint(userIDInt)
 	}
 
-	if !isAllowedToViewAllOrders {
-		if userID == 0 {
-			userID = tokenUserID
+	// Without orders:read_all the list is always the caller's own.
+	ownerID, err := ownOrdersOnly(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	if ownerID != 0 {
+		if userID != 0 && userID != ownerID {
+			problem(c, services.ErrOrderForbidden)
+			return
 		}
+		userID = ownerID
 	}
 
 	input := services.OrderListInput{
-		Page:   page,
-		Limit:  limit,
-		UserID: userID,
-		Status: statusFilter,
+		Page:      page,
+		Limit:     limit,
+		UserID:    userID,
+		Statuses:  queryparams.List(c, "status"),
+		Item:      c.Query("item"),
+		SortBy:    c.Query("sort"),
+		SortOrder: c.Query("order"),
+	}
+	if input.CreatedFrom, err = queryparams.Time(c, "created_from", false); err != nil {
+		problem(c, invalidParam("created_from", "invalid created_from"))
+		return
+	}
+	if input.CreatedTo, err = queryparams.Time(c, "created_to", true); err != nil {
+		problem(c, invalidParam("created_to", "invalid created_to"))
+		return
+	}
+	if input.CostMin, err = queryInt(c, "cost_min"); err != nil {
+		problem(c, err)
+		return
+	}
+	if input.CostMax, err = queryInt(c, "cost_max"); err != nil {
+		problem(c, err)
+		return
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		input.Cursor = cursor
+		if input.IncludeTotal, err = queryparams.Bool(c, "include_total"); err != nil {
+			problem(c, invalidParam("include_total", "invalid include_total"))
+			return
+		}
+		result, err := h.service.GetOrdersByCursor(input)
+		if err != nil {
+			problem(c, err)
+			return
+		}
+		if includeUser(c) {
+			h.service.AttachUsers(result.Orders...)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"orders":     result.Orders,
+				"pagination": pagination.Response(result.Limit, result.NextCursor, result.Total),
+			},
+		})
+		return
 	}
 
 	result, err := h.service.GetOrders(input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem(c, err)
 		return
 	}
+	if includeUser(c) {
+		h.service.AttachUsers(result.Orders...)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -147,6 +218,19 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	})
 }
 
+// queryInt reads an optional integer parameter.
+func queryInt(c *gin.Context, key string) (*int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, invalidParam(key, "invalid "+key)
+	}
+	return &value, nil
+}
+
 // CreateOrder
 // @Summary Create a new order
 // @Description Creates a new order with provided data
@@ -160,25 +244,43 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var input services.CreateOrderInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem(c, invalidBody(err))
 		return
 	}
 	order, err := h.service.CreateOrder(&input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, order)
 }
 
+// statusChange reads who changes the status of an order and the optional
+// comment in the request body.
+func statusChange(c *gin.Context) (services.StatusChange, error) {
+	userID, err := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 32)
+	if err != nil || userID == 0 {
+		return services.StatusChange{}, errUnauthenticated
+	}
+	var input services.UpdateOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		return services.StatusChange{}, invalidBody(err)
+	}
+	return services.StatusChange{
+		ActorID:   uint(userID),
+		Comment:   input.Comment,
+		RequestID: c.GetHeader("X-Request-ID"),
+	}, nil
+}
+
 // UpdateOrderStatus
 // @Summary Update an order
-// @Description Updates an existing order by ID
+// @Description Moves an order to its next status and records the change in its history
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param orderId path int true "Order ID"
-// @Param order body services.UpdateOrderInput true "Order update data"
+// @Param order body services.UpdateOrderInput false "Optional comment on the change"
 // @Success 200 {object} services.OrderResponse "Updated order"
 // @Security BearerAuth
 // @Router /orders/{orderId} [patch]
@@ -186,13 +288,19 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	id := c.Param("orderId")
 	var orderID uint
 	if _, err := fmt.Sscanf(id, "%d", &orderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		problem(c, invalidParam("orderId", "invalid order ID"))
+		return
+	}
+
+	change, err := statusChange(c)
+	if err != nil {
+		problem(c, err)
 		return
 	}
 
-	order, err := h.service.UpdateOrder(orderID)
+	order, err := h.service.UpdateOrder(orderID, change)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, order)
@@ -200,12 +308,12 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 
 // CancelOrder
 // @Summary Cancels an order
-// @Description Cancels an existing order by ID
+// @Description Cancels an existing order by ID and records the change in its history
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param orderId path int true "Order ID"
-// @Param order body services.UpdateOrderInput true "Order update data"
+// @Param order body services.UpdateOrderInput false "Optional comment on the change"
 // @Success 200 {object} services.OrderResponse "Updated order"
 // @Security BearerAuth
 // @Router /orders/cancel/{orderId} [patch]
@@ -213,37 +321,51 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	id := c.Param("orderId")
 	var orderID uint
 	if _, err := fmt.Sscanf(id, "%d", &orderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		problem(c, invalidParam("orderId", "invalid order ID"))
 		return
 	}
-	userIDStr := c.Request.Header.Get("X-User-ID")
-	if userIDStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{"message": "missing X-User-ID"},
-		})
+	change, err := statusChange(c)
+	if err != nil {
+		problem(c, err)
 		return
 	}
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+
+	anyOrder := middleware.HasPermission(c, permissions.OrdersCancelAll)
+	order, err := h.service.CancelOrder(orderID, change, anyOrder)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{"message": "broken X-User-ID"},
-		})
+		problem(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, order)
+}
 
-	rolesStr := c.Request.Header.Get("X-User-Roles")
-	if rolesStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{"code": "MISSING_HEADER", "message": "Отсутствует заголовок X-User-Roles"},
-		})
+// GetOrderHistory
+// @Summary Gets the status history of an order
+// @Description Lists every status change of the order, oldest first. Callers without orders:read_all may only see their own orders
+// @Tags Orders
+// @Produce json
+// @Param orderId path int true "Order ID"
+// @Success 200 {array} services.OrderStatusChangeResponse "Status changes"
+// @Security BearerAuth
+// @Router /orders/{orderId}/history [get]
+func (h *OrderHandler) GetOrderHistory(c *gin.Context) {
+	id := c.Param("orderId")
+	var orderID uint
+	if _, err := fmt.Sscanf(id, "%d", &orderID); err != nil {
+		problem(c, invalidParam("orderId", "invalid order ID"))
 		return
 	}
-	order, err := h.service.CancelOrder(orderID, uint(userID), rolesStr)
+	ownerID, err := ownOrdersOnly(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, order)
+	history, err := h.service.GetOrderHistory(orderID, ownerID)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
 }
 
 // DeleteOrder
@@ -259,11 +381,11 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 	id := c.Param("orderId")
 	var orderID uint
 	if _, err := fmt.Sscanf(id, "%d", &orderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		problem(c, invalidParam("orderId", "invalid order ID"))
 		return
 	}
 	if err := h.service.DeleteOrder(orderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{})
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	clientmocks "github.com/SpiritFoxo/control-system-microservices/service-orders/internal/clients/mocks"
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories"
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories/mocks"
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/services"
+	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
+	"github.com/SpiritFoxo/control-system-microservices/shared/permissions"
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+)
+
+func setupHandlerTest(t *testing.T) (*gin.Engine, *mocks.MockOrderRepositoryInterface, func()) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockOrderRepositoryInterface(ctrl)
+	mockUsers := clientmocks.NewMockUsersClientInterface(ctrl)
+	handler := NewOrderHandler(services.NewOrderService(mockRepo, mockUsers, &config.Config{}))
+
+	r := gin.New()
+	r.GET("/orders", middleware.PermissionMiddleware(permissions.OrdersRead), handler.GetAllOrders)
+	r.GET("/orders/:orderId/history", middleware.PermissionMiddleware(permissions.OrdersRead), handler.GetOrderHistory)
+	return r, mockRepo, ctrl.Finish
+}
+
+func TestOrderHandler_GetAllOrders_Access(t *testing.T) {
+	r, mockRepo, finish := setupHandlerTest(t)
+	defer finish()
+
+	expectUser := func(userID uint) func() {
+		return func() {
+			mockRepo.EXPECT().
+				GetOrders(1, 10, repositories.OrderFilter{UserID: userID}, repositories.OrderSort{}).
+				Return(nil, int64(0), nil)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		headers      map[string]string
+		setupMock    func()
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:         "без orders:read_all видны только свои заказы",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersRead, "X-User-ID": "100"},
+			setupMock:    expectUser(100),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "явный запрос своих заказов",
+			query:        "?userId=100",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersRead, "X-User-ID": "100"},
+			setupMock:    expectUser(100),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "запрос чужих заказов без orders:read_all",
+			query:        "?userId=200",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersRead, "X-User-ID": "100"},
+			setupMock:    func() {},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "access_forbidden",
+		},
+		{
+			name:         "нет orders:read",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersCreate, "X-User-ID": "100"},
+			setupMock:    func() {},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "missing_permission",
+		},
+		{
+			name:         "нет X-User-ID",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersRead},
+			setupMock:    func() {},
+			expectedCode: http.StatusUnauthorized,
+			expectedErr:  "unauthenticated",
+		},
+		{
+			name:         "у пользователя нет разрешений",
+			query:        "?userId=200",
+			headers:      map[string]string{"X-User-ID": "100"},
+			setupMock:    func() {},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "missing_permission",
+		},
+		{
+			name:         "нет ни пользователя, ни разрешений",
+			headers:      map[string]string{"X-User-Permissions": " , "},
+			setupMock:    func() {},
+			expectedCode: http.StatusUnauthorized,
+			expectedErr:  "unauthenticated",
+		},
+		{
+			name:         "с orders:read_all видны заказы любого пользователя",
+			query:        "?userId=200",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersRead + "," + permissions.OrdersReadAll, "X-User-ID": "100"},
+			setupMock:    expectUser(200),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "с orders:read_all без userId видны все заказы",
+			headers:      map[string]string{"X-User-Permissions": permissions.OrdersRead + "," + permissions.OrdersReadAll, "X-User-ID": "100"},
+			setupMock:    expectUser(0),
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			req := httptest.NewRequest(http.MethodGet, "/orders"+tt.query, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedErr != "" {
+				var body problems.Problem
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.expectedErr, body.Code)
+			}
+		})
+	}
+}
+
+func TestOrderHandler_GetOrderHistory_Access(t *testing.T) {
+	r, mockRepo, finish := setupHandlerTest(t)
+	defer finish()
+
+	order := &models.Order{Model: gorm.Model{ID: 1}, UserId: 100, Status: models.StatusCreated}
+
+	tests := []struct {
+		name         string
+		permissions  string
+		userID       string
+		setupMock    func()
+		expectedCode int
+	}{
+		{
+			name:        "своя история",
+			permissions: permissions.OrdersRead,
+			userID:      "100",
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(order, nil)
+				mockRepo.EXPECT().GetStatusHistory(uint(1)).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:        "история чужого заказа без orders:read_all",
+			permissions: permissions.OrdersRead,
+			userID:      "200",
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(order, nil)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:        "история чужого заказа с orders:read_all",
+			permissions: permissions.OrdersRead + "," + permissions.OrdersReadAll,
+			userID:      "200",
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(order, nil)
+				mockRepo.EXPECT().GetStatusHistory(uint(1)).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			req := httptest.NewRequest(http.MethodGet, "/orders/1/history", nil)
+			req.Header.Set("X-User-Permissions", tt.permissions)
+			req.Header.Set("X-User-ID", tt.userID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
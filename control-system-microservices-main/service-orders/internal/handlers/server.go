@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/clients"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/config"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/services"
@@ -17,7 +18,8 @@ type Server struct {
 
 func NewServer(db *gorm.DB, cfg *config.Config) *Server {
 	orderRepository := repositories.NewOrderRepository(db)
-	orderService := services.NewOrderService(orderRepository, cfg)
+	usersClient := clients.NewUsersClient(cfg.UsersServiceURL, cfg.InternalAPISecret, cfg.UsersTimeout, cfg.UsersCacheTTL)
+	orderService := services.NewOrderService(orderRepository, usersClient, cfg)
 	orderHandler := NewOrderHandler(orderService)
 	return &Server{
 		db:           db,
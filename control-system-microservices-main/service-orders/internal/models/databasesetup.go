@@ -28,9 +28,29 @@ func Setup(cfg *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&Order{}, &OrderItem{}); err != nil {
+	if err := db.AutoMigrate(&Order{}, &OrderItem{}, &OrderStatusHistory{}); err != nil {
 		return nil, err
 	}
 
+	createListIndexes(db)
+
 	return db, nil
 }
+
+// createListIndexes adds the indexes the order list filters and its keyset
+// pagination need. Without them listing still works, only slower, so a
+// failure is logged rather than fatal.
+func createListIndexes(db *gorm.DB) {
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_orders_user_id_id ON orders (user_id, id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders (created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_cost_id ON orders (cost, id)",
+		"CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items (order_id)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("Failed to create order list index: %v", err)
+			return
+		}
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -32,12 +33,30 @@ func (s OrderStatus) String() string {
 	return statusNames[s]
 }
 
+// ParseOrderStatus looks a status up by its name, as stored in the database.
+func ParseOrderStatus(name string) (OrderStatus, bool) {
+	for i, v := range statusNames {
+		if v == name {
+			return OrderStatus(i), true
+		}
+	}
+	return 0, false
+}
+
+// StatusNames lists the names of every status, in order.
+func StatusNames() []string {
+	return append([]string(nil), statusNames[:]...)
+}
+
 type Order struct {
 	gorm.Model
 	UserId uint        `gorm:"not null"`
 	Status OrderStatus `gorm:"type:order_status;not null;default:'Created'"`
 	Cost   int         `gorm:"not null"`
 	Items  []OrderItem
+
+	// StatusChangedAt is when Status last changed; nil until it first does.
+	StatusChangedAt *time.Time
 }
 
 func (o *Order) NextStatus() error {
@@ -61,13 +80,12 @@ func (s *OrderStatus) Scan(value interface{}) error {
 	if !ok {
 		return fmt.Errorf("cannot scan OrderStatus from %T", value)
 	}
-	for i, v := range statusNames {
-		if v == str {
-			*s = OrderStatus(i)
-			return nil
-		}
+	status, ok := ParseOrderStatus(str)
+	if !ok {
+		return fmt.Errorf("invalid OrderStatus value: %s", str)
 	}
-	return fmt.Errorf("invalid OrderStatus value: %s", str)
+	*s = status
+	return nil
 }
 
 func (s OrderStatus) Value() (driver.Value, error) {
@@ -84,3 +102,21 @@ type OrderItem struct {
 	Quantity int    `gorm:"not null"`
 	Name     string `gorm:"not null"`
 }
+
+// OrderStatusHistory records one status change of an order. Rows are only
+// ever inserted.
+type OrderStatusHistory struct {
+	ID         uint        `gorm:"primarykey"`
+	OrderId    uint        `gorm:"index;not null"`
+	FromStatus OrderStatus `gorm:"type:order_status;not null"`
+	ToStatus   OrderStatus `gorm:"type:order_status;not null"`
+	// ActorID is the user who changed the status.
+	ActorID   uint      `gorm:"not null"`
+	Comment   string    `gorm:"not null;default:''"`
+	RequestID string    `gorm:"not null;default:''"`
+	ChangedAt time.Time `gorm:"not null"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -1,11 +1,52 @@
 package repositories
 
-import "github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+import (
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+)
+
+// OrderFilter narrows the order list. Zero values mean no restriction.
+type OrderFilter struct {
+	UserID   uint
+	Statuses []models.OrderStatus
+	// Date and cost ranges are inclusive; nil leaves that side open.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	CostMin     *int
+	CostMax     *int
+	// Item matches orders with an item whose name contains it, ignoring case.
+	Item string
+}
+
+// Sort fields accepted by OrderSort.Field. Ties, and an empty field, are
+// ordered by ID.
+const (
+	OrderSortCreatedAt = "created_at"
+	OrderSortCost      = "cost"
+)
+
+type OrderSort struct {
+	Field string
+	Desc  bool
+}
+
+// OrderCursor is the position a keyset page starts after: the sort key and
+// ID of the last order of the previous page. Value is a time.Time when
+// sorting by created_at, an int for cost and unused when sorting by ID.
+type OrderCursor struct {
+	ID    uint
+	Value interface{}
+}
 
 type OrderRepositoryInterface interface {
 	GetOrderByID(id uint) (*models.Order, error)
 	CreateOrder(order *models.Order) error
 	UpdateOrder(order *models.Order) error
+	UpdateOrderStatus(order *models.Order, entry *models.OrderStatusHistory) error
+	GetStatusHistory(orderID uint) ([]models.OrderStatusHistory, error)
 	DeleteOrder(order *models.Order) error
-	GetOrders(page, limit int, userID uint, status string) ([]models.Order, int64, error)
+	GetOrders(page, limit int, filter OrderFilter, sort OrderSort) ([]models.Order, int64, error)
+	GetOrdersAfter(after *OrderCursor, limit int, filter OrderFilter, sort OrderSort) ([]models.Order, error)
+	CountOrders(filter OrderFilter) (int64, error)
 }
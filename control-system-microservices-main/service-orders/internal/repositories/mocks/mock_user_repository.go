@@ -13,6 +13,7 @@ import (
 	reflect "reflect"
 
 	models "github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+	repositories "github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,6 +41,21 @@ func (m *MockOrderRepositoryInterface) EXPECT() *MockOrderRepositoryInterfaceMoc
 	return m.recorder
 }
 
+// CountOrders mocks base method.
+func (m *MockOrderRepositoryInterface) CountOrders(filter repositories.OrderFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOrders", filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOrders indicates an expected call of CountOrders.
+func (mr *MockOrderRepositoryInterfaceMockRecorder) CountOrders(filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrders", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).CountOrders), filter)
+}
+
 // CreateOrder mocks base method.
 func (m *MockOrderRepositoryInterface) CreateOrder(order *models.Order) error {
 	m.ctrl.T.Helper()
@@ -84,9 +100,9 @@ func (mr *MockOrderRepositoryInterfaceMockRecorder) GetOrderByID(id any) *gomock
 }
 
 // GetOrders mocks base method.
-func (m *MockOrderRepositoryInterface) GetOrders(page, limit int, userID uint, status string) ([]models.Order, int64, error) {
+func (m *MockOrderRepositoryInterface) GetOrders(page, limit int, filter repositories.OrderFilter, sort repositories.OrderSort) ([]models.Order, int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrders", page, limit, userID, status)
+	ret := m.ctrl.Call(m, "GetOrders", page, limit, filter, sort)
 	ret0, _ := ret[0].([]models.Order)
 	ret1, _ := ret[1].(int64)
 	ret2, _ := ret[2].(error)
@@ -94,9 +110,39 @@ func (m *MockOrderRepositoryInterface) GetOrders(page, limit int, userID uint, s
 }
 
 // GetOrders indicates an expected call of GetOrders.
-func (mr *MockOrderRepositoryInterfaceMockRecorder) GetOrders(page, limit, userID, status any) *gomock.Call {
+func (mr *MockOrderRepositoryInterfaceMockRecorder) GetOrders(page, limit, filter, sort any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).GetOrders), page, limit, filter, sort)
+}
+
+// GetOrdersAfter mocks base method.
+func (m *MockOrderRepositoryInterface) GetOrdersAfter(after *repositories.OrderCursor, limit int, filter repositories.OrderFilter, sort repositories.OrderSort) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersAfter", after, limit, filter, sort)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersAfter indicates an expected call of GetOrdersAfter.
+func (mr *MockOrderRepositoryInterfaceMockRecorder) GetOrdersAfter(after, limit, filter, sort any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).GetOrders), page, limit, userID, status)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersAfter", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).GetOrdersAfter), after, limit, filter, sort)
+}
+
+// GetStatusHistory mocks base method.
+func (m *MockOrderRepositoryInterface) GetStatusHistory(orderID uint) ([]models.OrderStatusHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusHistory", orderID)
+	ret0, _ := ret[0].([]models.OrderStatusHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusHistory indicates an expected call of GetStatusHistory.
+func (mr *MockOrderRepositoryInterfaceMockRecorder) GetStatusHistory(orderID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusHistory", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).GetStatusHistory), orderID)
 }
 
 // UpdateOrder mocks base method.
@@ -112,3 +158,17 @@ func (mr *MockOrderRepositoryInterfaceMockRecorder) UpdateOrder(order any) *gomo
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).UpdateOrder), order)
 }
+
+// UpdateOrderStatus mocks base method.
+func (m *MockOrderRepositoryInterface) UpdateOrderStatus(order *models.Order, entry *models.OrderStatusHistory) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatus", order, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrderStatus indicates an expected call of UpdateOrderStatus.
+func (mr *MockOrderRepositoryInterfaceMockRecorder) UpdateOrderStatus(order, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockOrderRepositoryInterface)(nil).UpdateOrderStatus), order, entry)
+}
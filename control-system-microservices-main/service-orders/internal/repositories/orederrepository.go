@@ -4,9 +4,17 @@ import (
 	"errors"
 
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/shared/search"
 	"gorm.io/gorm"
 )
 
+// ErrOrderNotFound is returned when no order has the requested ID.
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrStatusChanged is returned when the status of an order changed after it
+// was read.
+var ErrStatusChanged = errors.New("order status changed concurrently")
+
 type OrderRepository struct {
 	db *gorm.DB
 }
@@ -24,7 +32,7 @@ func (r *OrderRepository) GetOrderByID(id uint) (*models.Order, error) {
 	result := r.db.Preload("Items").First(&order, id)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
+			return nil, ErrOrderNotFound
 		}
 		return nil, result.Error
 	}
@@ -35,27 +43,114 @@ func (r *OrderRepository) UpdateOrder(order *models.Order) error {
 	return r.db.Save(order).Error
 }
 
+// UpdateOrderStatus stores the new status of an order and its history entry
+// in one transaction. The update only applies while the order is still in
+// entry.FromStatus, so two concurrent changes can't both be recorded.
+func (r *OrderRepository) UpdateOrderStatus(order *models.Order, entry *models.OrderStatusHistory) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(order).Where("status = ?", entry.FromStatus).Updates(map[string]interface{}{
+			"status":            order.Status,
+			"status_changed_at": order.StatusChangedAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+		return tx.Create(entry).Error
+	})
+}
+
+// GetStatusHistory returns the status changes of an order, oldest first.
+func (r *OrderRepository) GetStatusHistory(orderID uint) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	err := r.db.Where("order_id = ?", orderID).Order("changed_at, id").Find(&history).Error
+	return history, err
+}
+
 func (r *OrderRepository) DeleteOrder(order *models.Order) error {
 	return r.db.Delete(order).Error
 }
 
-func (r *OrderRepository) GetOrders(page, limit int, userID uint, status string) ([]models.Order, int64, error) {
-	var orders []models.Order
-	var total int64
+func applyOrderFilter(query *gorm.DB, filter OrderFilter) *gorm.DB {
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if filter.CostMin != nil {
+		query = query.Where("cost >= ?", *filter.CostMin)
+	}
+	if filter.CostMax != nil {
+		query = query.Where("cost <= ?", *filter.CostMax)
+	}
+	if filter.Item != "" {
+		query = query.Where(
+			"EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.deleted_at IS NULL AND LOWER(order_items.name) LIKE ? "+search.LikeEscape+")",
+			search.Contains(filter.Item),
+		)
+	}
+	return query
+}
 
-	query := r.db.Model(&models.Order{})
+// orderSortColumn is the column a sort field orders by; empty means by ID.
+func orderSortColumn(field string) string {
+	switch field {
+	case OrderSortCreatedAt:
+		return "created_at"
+	case OrderSortCost:
+		return "cost"
+	}
+	return ""
+}
 
-	if userID > 0 {
-		query = query.Where("user_id = ?", userID)
+// orderOrder turns a sort into an ORDER BY clause. Only known columns get
+// there, so the field can never inject SQL.
+func orderOrder(sort OrderSort) string {
+	column := orderSortColumn(sort.Field)
+	if column == "" {
+		return "id"
 	}
-	if status != "" {
-		query = query.Where("status = ?", status)
+	direction := "ASC"
+	if sort.Desc {
+		direction = "DESC"
 	}
+	return column + " " + direction + ", id " + direction
+}
+
+// orderKeyset limits a query to the orders that come after the cursor in the
+// order orderOrder gives.
+func orderKeyset(query *gorm.DB, after *OrderCursor, sort OrderSort) *gorm.DB {
+	column := orderSortColumn(sort.Field)
+	if column == "" {
+		return query.Where("id > ?", after.ID)
+	}
+	op := ">"
+	if sort.Desc {
+		op = "<"
+	}
+	return query.Where("("+column+", id) "+op+" (?, ?)", after.Value, after.ID)
+}
+
+func (r *OrderRepository) GetOrders(page, limit int, filter OrderFilter, sort OrderSort) ([]models.Order, int64, error) {
+	var orders []models.Order
+	var total int64
+
+	query := applyOrderFilter(r.db.Model(&models.Order{}), filter)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 	err := query.
+		Order(orderOrder(sort)).
 		Offset((page - 1) * limit).
 		Limit(limit).
 		Preload("Items").
@@ -67,3 +162,33 @@ func (r *OrderRepository) GetOrders(page, limit int, userID uint, status string)
 
 	return orders, total, nil
 }
+
+// GetOrdersAfter returns up to limit orders that follow the cursor, or the
+// first ones when it is nil. Unlike GetOrders it neither skips rows with an
+// offset nor counts them, so its cost does not grow with the page number and
+// orders created meanwhile never shift the pages.
+func (r *OrderRepository) GetOrdersAfter(after *OrderCursor, limit int, filter OrderFilter, sort OrderSort) ([]models.Order, error) {
+	var orders []models.Order
+
+	query := applyOrderFilter(r.db.Model(&models.Order{}), filter)
+	if after != nil {
+		query = orderKeyset(query, after, sort)
+	}
+	err := query.
+		Order(orderOrder(sort)).
+		Limit(limit).
+		Preload("Items").
+		Find(&orders).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *OrderRepository) CountOrders(filter OrderFilter) (int64, error) {
+	var total int64
+	err := applyOrderFilter(r.db.Model(&models.Order{}), filter).Count(&total).Error
+	return total, err
+}
@@ -3,16 +3,18 @@ package routers
 import (
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/handlers"
 	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
-	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/SpiritFoxo/control-system-microservices/shared/permissions"
 	"github.com/gin-gonic/gin"
 )
 
 func SetupOrdersRoutes(r *gin.RouterGroup, s *handlers.Server) {
 	h := s.OrderHandler
 
-	r.GET("/:orderId", h.GetOrderByID)
-	r.POST("/", middleware.RoleMiddleware(userroles.RoleEngineer, userroles.RoleObserver), h.CreateOrder)
-	r.PATCH("/:orderId", middleware.RoleMiddleware(userroles.RoleManager), h.UpdateOrderStatus)
-	r.PATCH("/cancel/:orderId", middleware.RoleMiddleware(userroles.RoleEngineer, userroles.RoleManager), h.CancelOrder)
-	r.DELETE("/:orderId", middleware.RoleMiddleware(userroles.RoleManager), h.DeleteOrder)
+	r.GET("/", middleware.PermissionMiddleware(permissions.OrdersRead), h.GetAllOrders)
+	r.GET("/:orderId", middleware.PermissionMiddleware(permissions.OrdersRead), h.GetOrderByID)
+	r.GET("/:orderId/history", middleware.PermissionMiddleware(permissions.OrdersRead), h.GetOrderHistory)
+	r.POST("/", middleware.PermissionMiddleware(permissions.OrdersCreate), h.CreateOrder)
+	r.PATCH("/:orderId", middleware.PermissionMiddleware(permissions.OrdersUpdateStatus), h.UpdateOrderStatus)
+	r.PATCH("/cancel/:orderId", middleware.PermissionMiddleware(permissions.OrdersCancel), h.CancelOrder)
+	r.DELETE("/:orderId", middleware.PermissionMiddleware(permissions.OrdersDelete), h.DeleteOrder)
 }
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+)
+
+// ErrorKind classifies domain errors. Handlers turn the kind into an HTTP
+// status, so services never need to know about HTTP.
+type ErrorKind int
+
+const (
+	// KindInternal is the kind of every error that is not an *Error.
+	KindInternal ErrorKind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	// KindUnavailable means a service this one depends on did not answer.
+	KindUnavailable
+)
+
+// Error is a domain error with a stable, machine-readable code. The message
+// is meant for people and may change; clients should look at the code.
+// errors.Is compares errors by identity, so sentinels that share a code
+// remain distinct.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	// Fields names the input fields that failed validation, if any.
+	Fields []FieldError
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// KindOf reports the kind of err; plain errors are internal.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+var (
+	ErrOrderNotFound       = NewError(KindNotFound, "order_not_found", "order not found")
+	ErrOrderClosed         = NewError(KindConflict, "order_closed", "order is already closed")
+	ErrOrderNotCancelable  = NewError(KindConflict, "order_not_cancelable", "order cannot be canceled")
+	ErrOrderStatusConflict = NewError(KindConflict, "order_status_conflict", "order status was changed by another request")
+	ErrOrderForbidden      = NewError(KindForbidden, "access_forbidden", "access forbidden")
+	ErrEmptyOrder          = &Error{Kind: KindInvalid, Code: "empty_order", Message: "order must contain at least one item", Fields: []FieldError{{Field: "order_items", Message: "must contain at least one item"}}}
+	ErrInvalidPage         = NewError(KindInvalid, "invalid_page", "invalid page number")
+	ErrInvalidLimit        = NewError(KindInvalid, "invalid_limit", "invalid limit value")
+	ErrInvalidStatusFilter = &Error{Kind: KindInvalid, Code: "invalid_status_filter", Message: "invalid status filter", Fields: []FieldError{{Field: "status", Message: "must be one of " + strings.Join(models.StatusNames(), ", ")}}}
+	ErrInvalidDateRange    = NewError(KindInvalid, "invalid_date_range", "invalid date range")
+	ErrInvalidCostRange    = NewError(KindInvalid, "invalid_cost_range", "invalid cost range")
+	ErrInvalidSort         = NewError(KindInvalid, "invalid_sort", "invalid sort")
+	ErrInvalidCursor       = &Error{Kind: KindInvalid, Code: "invalid_cursor", Message: "invalid cursor", Fields: []FieldError{{Field: "cursor", Message: "must be a next_cursor returned for the same sort"}}}
+	ErrUnknownUser         = &Error{Kind: KindInvalid, Code: "unknown_user", Message: "user does not exist", Fields: []FieldError{{Field: "user_id", Message: "user does not exist"}}}
+	ErrInactiveUser        = &Error{Kind: KindInvalid, Code: "inactive_user", Message: "user is deactivated", Fields: []FieldError{{Field: "user_id", Message: "user is deactivated"}}}
+	ErrUsersUnavailable    = NewError(KindUnavailable, "users_unavailable", "user service is unavailable")
+)
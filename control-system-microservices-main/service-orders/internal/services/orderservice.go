@@ -2,23 +2,27 @@ package services
 
 import (
 	"errors"
-	"fmt"
+	"log"
 	"strings"
+	"time"
 
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/clients"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/config"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories"
-	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/SpiritFoxo/control-system-microservices/shared/pagination"
 )
 
 type OrderService struct {
 	orderRepo repositories.OrderRepositoryInterface
+	users     clients.UsersClientInterface
 	cfg       *config.Config
 }
 
-func NewOrderService(orderRepo repositories.OrderRepositoryInterface, cfg *config.Config) *OrderService {
+func NewOrderService(orderRepo repositories.OrderRepositoryInterface, users clients.UsersClientInterface, cfg *config.Config) *OrderService {
 	return &OrderService{
 		orderRepo: orderRepo,
+		users:     users,
 		cfg:       cfg,
 	}
 }
@@ -29,6 +33,16 @@ type OrderResponse struct {
 	Status     models.OrderStatus  `json:"status"`
 	Cost       int                 `json:"cost"`
 	OrderItems []OrderItemResponse `json:"order_items"`
+	// ChangedAt is when the order got its current status.
+	ChangedAt time.Time `json:"changed_at"`
+	// User is only filled in when the caller asks for it.
+	User *OrderUser `json:"user,omitempty"`
+}
+
+type OrderUser struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 type OrderItemResponse struct {
@@ -45,10 +59,23 @@ type CreateOrderInput struct {
 }
 
 type OrderListInput struct {
-	Page   int    `form:"page" json:"page"`
-	Limit  int    `form:"limit" json:"limit"`
-	UserID uint   `form:"userId" json:"userId"`
-	Status string `form:"status" json:"status"`
+	Page   int  `form:"page" json:"page"`
+	Limit  int  `form:"limit" json:"limit"`
+	UserID uint `form:"userId" json:"userId"`
+	// Statuses lists orders in any of the statuses, by name.
+	Statuses    []string   `form:"status" json:"status"`
+	CreatedFrom *time.Time `form:"created_from" json:"created_from"`
+	CreatedTo   *time.Time `form:"created_to" json:"created_to"`
+	CostMin     *int       `form:"cost_min" json:"cost_min"`
+	CostMax     *int       `form:"cost_max" json:"cost_max"`
+	// Item matches orders with an item whose name contains it.
+	Item string `form:"item" json:"item"`
+	// SortBy is created_at or cost; SortOrder is asc or desc.
+	SortBy    string `form:"sort" json:"sort"`
+	SortOrder string `form:"order" json:"order"`
+	// Cursor and IncludeTotal only apply to GetOrdersByCursor.
+	Cursor       string `form:"cursor" json:"cursor"`
+	IncludeTotal bool   `form:"include_total" json:"include_total"`
 }
 
 type OrderListResponse struct {
@@ -59,6 +86,37 @@ type OrderListResponse struct {
 	TotalPages int              `json:"totalPages"`
 }
 
+// OrderCursorResponse is a page of a keyset-paginated order list.
+type OrderCursorResponse struct {
+	Orders []*OrderResponse `json:"orders"`
+	Limit  int              `json:"limit"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is only counted when asked for.
+	Total *int64 `json:"total,omitempty"`
+}
+
+// UpdateOrderInput is the optional body of a status change.
+type UpdateOrderInput struct {
+	Comment string `json:"comment" binding:"max=500"`
+}
+
+// StatusChange tells who changes the status of an order and why.
+type StatusChange struct {
+	ActorID   uint
+	Comment   string
+	RequestID string
+}
+
+type OrderStatusChangeResponse struct {
+	From      models.OrderStatus `json:"from"`
+	To        models.OrderStatus `json:"to"`
+	ActorID   uint               `json:"actor_id"`
+	Comment   string             `json:"comment,omitempty"`
+	RequestID string             `json:"request_id,omitempty"`
+	ChangedAt time.Time          `json:"changed_at"`
+}
+
 type OrderItemInput struct {
 	Name     string `json:"name" binding:"required"`
 	Quantity int    `json:"quantity" binding:"required,min=1"`
@@ -73,43 +131,83 @@ func toOrderResponse(order *models.Order) *OrderResponse {
 			Quantity: item.Quantity,
 		}
 	}
+	changedAt := order.CreatedAt
+	if order.StatusChangedAt != nil {
+		changedAt = *order.StatusChangedAt
+	}
 	return &OrderResponse{
 		ID:         order.ID,
 		UserID:     order.UserId,
 		Status:     order.Status,
 		Cost:       order.Cost,
 		OrderItems: items,
+		ChangedAt:  changedAt,
 	}
 }
 
-func (s *OrderService) GetOrderByID(id uint) (*OrderResponse, error) {
-	order, err := s.orderRepo.GetOrderByID(id)
-	if err != nil {
-		return nil, err
+func isValidSort(field, order string) bool {
+	switch field {
+	case "", repositories.OrderSortCreatedAt, repositories.OrderSortCost:
+	default:
+		return false
 	}
+	return order == "" || order == "asc" || order == "desc"
+}
 
-	orderResponse := &OrderResponse{
-		ID:         order.ID,
-		UserID:     order.UserId,
-		Status:     order.Status,
-		Cost:       order.Cost,
-		OrderItems: make([]OrderItemResponse, len(order.Items)),
+func isValidDateRange(from, to *time.Time) bool {
+	return from == nil || to == nil || !from.After(*to)
+}
+
+func isValidCostRange(min, max *int) bool {
+	if (min != nil && *min < 0) || (max != nil && *max < 0) {
+		return false
 	}
+	return min == nil || max == nil || *min <= *max
+}
 
-	for i, item := range order.Items {
-		orderResponse.OrderItems[i] = OrderItemResponse{
-			ID:       item.ID,
-			Name:     item.Name,
-			Quantity: item.Quantity,
+// parseStatuses turns status names into statuses, so that only known values
+// reach the database.
+func parseStatuses(names []string) ([]models.OrderStatus, error) {
+	var statuses []models.OrderStatus
+	for _, name := range names {
+		status, ok := models.ParseOrderStatus(name)
+		if !ok {
+			return nil, ErrInvalidStatusFilter
 		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// getOrder loads an order, reporting a missing one as ErrOrderNotFound.
+func (s *OrderService) getOrder(id uint) (*models.Order, error) {
+	order, err := s.orderRepo.GetOrderByID(id)
+	if errors.Is(err, repositories.ErrOrderNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// GetOrderByID returns an order. A non-zero ownerID limits the lookup to that
+// user's orders.
+func (s *OrderService) GetOrderByID(id, ownerID uint) (*OrderResponse, error) {
+	order, err := s.getOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != 0 && order.UserId != ownerID {
+		return nil, ErrOrderForbidden
 	}
 
-	return orderResponse, nil
+	return toOrderResponse(order), nil
 }
 
 func (s *OrderService) CreateOrder(input *CreateOrderInput) (*OrderResponse, error) {
 	if len(input.OrderItems) == 0 {
-		return nil, errors.New("order must contain at least one item")
+		return nil, ErrEmptyOrder
+	}
+	if err := s.checkUser(input.UserID); err != nil {
+		return nil, err
 	}
 
 	orderItems := make([]models.OrderItem, len(input.OrderItems))
@@ -134,21 +232,83 @@ func (s *OrderService) CreateOrder(input *CreateOrderInput) (*OrderResponse, err
 	return toOrderResponse(order), nil
 }
 
-func (s *OrderService) UpdateOrder(id uint) (*OrderResponse, error) {
-	order, err := s.orderRepo.GetOrderByID(id)
+// checkUser makes sure an order is placed for an existing, active user.
+func (s *OrderService) checkUser(id uint) error {
+	users, err := s.users.GetUsers([]uint{id})
+	if err != nil {
+		log.Printf("Failed to look up user %d: %v", id, err)
+		return ErrUsersUnavailable
+	}
+	user, ok := users[id]
+	if !ok {
+		return ErrUnknownUser
+	}
+	if !user.Active {
+		return ErrInactiveUser
+	}
+	return nil
+}
+
+// AttachUsers fills in the users the orders were placed for. It is best
+// effort: when service-users cannot be reached the orders are returned as
+// they are.
+func (s *OrderService) AttachUsers(orders ...*OrderResponse) {
+	ids := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.UserID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	users, err := s.users.GetUsers(ids)
+	if err != nil {
+		log.Printf("Failed to look up users of orders: %v", err)
+		return
+	}
+	for _, order := range orders {
+		if user, ok := users[order.UserID]; ok {
+			order.User = &OrderUser{ID: user.ID, Name: user.Name, Email: user.Email}
+		}
+	}
+}
+
+// changeStatus stores the status an order was moved to from the given one,
+// together with the history entry of the change.
+func (s *OrderService) changeStatus(order *models.Order, from models.OrderStatus, change StatusChange) error {
+	now := time.Now()
+	order.StatusChangedAt = &now
+	err := s.orderRepo.UpdateOrderStatus(order, &models.OrderStatusHistory{
+		OrderId:    order.ID,
+		FromStatus: from,
+		ToStatus:   order.Status,
+		ActorID:    change.ActorID,
+		Comment:    strings.TrimSpace(change.Comment),
+		RequestID:  change.RequestID,
+		ChangedAt:  now,
+	})
+	if errors.Is(err, repositories.ErrStatusChanged) {
+		return ErrOrderStatusConflict
+	}
+	return err
+}
+
+func (s *OrderService) UpdateOrder(id uint, change StatusChange) (*OrderResponse, error) {
+	order, err := s.getOrder(id)
 	if err != nil {
 		return nil, err
 	}
 
 	if order.Status == models.StatusClosed || order.Status == models.StatusCanceled {
-		return nil, errors.New("order is already closed")
+		return nil, ErrOrderClosed
 	}
 
+	from := order.Status
 	if err := order.NextStatus(); err != nil {
 		return nil, err
 	}
 
-	if err := s.orderRepo.UpdateOrder(order); err != nil {
+	if err := s.changeStatus(order, from, change); err != nil {
 		return nil, err
 	}
 
@@ -160,42 +320,63 @@ func (s *OrderService) UpdateOrder(id uint) (*OrderResponse, error) {
 	return toOrderResponse(updated), nil
 }
 
-func (s *OrderService) CancelOrder(id uint, userID uint, rolesStr string) (*OrderResponse, error) {
-	order, err := s.orderRepo.GetOrderByID(id)
+// CancelOrder cancels the order. Unless anyOrder is set the actor may only
+// cancel orders placed for themselves.
+func (s *OrderService) CancelOrder(id uint, change StatusChange, anyOrder bool) (*OrderResponse, error) {
+	order, err := s.getOrder(id)
 	if err != nil {
 		return nil, err
 	}
-	roles := strings.Split(rolesStr, ",")
-	for i := range roles {
-		roles[i] = strings.TrimSpace(roles[i])
+
+	if !anyOrder && order.UserId != change.ActorID {
+		return nil, ErrOrderForbidden
 	}
 
-	isEngineer := false
-	for _, role := range roles {
-		if role == userroles.RoleEngineer {
-			isEngineer = true
-			break
-		}
+	from := order.Status
+	if err := order.Cancel(); err != nil {
+		return nil, ErrOrderNotCancelable
 	}
 
-	if isEngineer && order.UserId != userID {
-		return nil, fmt.Errorf("access forbidden")
+	if err := s.changeStatus(order, from, change); err != nil {
+		return nil, err
 	}
 
-	if err := order.Cancel(); err != nil {
+	return toOrderResponse(order), nil
+}
+
+// GetOrderHistory lists the status changes of an order, oldest first. A
+// non-zero ownerID limits it to that user's orders, as in GetOrderByID.
+func (s *OrderService) GetOrderHistory(id, ownerID uint) ([]OrderStatusChangeResponse, error) {
+	order, err := s.getOrder(id)
+	if err != nil {
 		return nil, err
 	}
+	if ownerID != 0 && order.UserId != ownerID {
+		return nil, ErrOrderForbidden
+	}
 
-	if err := s.orderRepo.UpdateOrder(order); err != nil {
+	history, err := s.orderRepo.GetStatusHistory(id)
+	if err != nil {
 		return nil, err
 	}
 
-	return toOrderResponse(order), nil
+	response := make([]OrderStatusChangeResponse, 0, len(history))
+	for _, entry := range history {
+		response = append(response, OrderStatusChangeResponse{
+			From:      entry.FromStatus,
+			To:        entry.ToStatus,
+			ActorID:   entry.ActorID,
+			Comment:   entry.Comment,
+			RequestID: entry.RequestID,
+			ChangedAt: entry.ChangedAt,
+		})
+	}
+	return response, nil
 }
 
 func (s *OrderService) DeleteOrder(id uint) error {
 
-	order, err := s.orderRepo.GetOrderByID(id)
+	order, err := s.getOrder(id)
 	if err != nil {
 		return err
 	}
@@ -207,46 +388,152 @@ func (s *OrderService) DeleteOrder(id uint) error {
 	return nil
 }
 
-func (s *OrderService) GetOrders(input OrderListInput) (*OrderListResponse, error) {
+// orderListQuery checks the filters and sort of an order list.
+func orderListQuery(input OrderListInput) (repositories.OrderFilter, repositories.OrderSort, error) {
+	statuses, err := parseStatuses(input.Statuses)
+	if err != nil {
+		return repositories.OrderFilter{}, repositories.OrderSort{}, err
+	}
+	if !isValidDateRange(input.CreatedFrom, input.CreatedTo) {
+		return repositories.OrderFilter{}, repositories.OrderSort{}, ErrInvalidDateRange
+	}
+	if !isValidCostRange(input.CostMin, input.CostMax) {
+		return repositories.OrderFilter{}, repositories.OrderSort{}, ErrInvalidCostRange
+	}
+	if !isValidSort(input.SortBy, input.SortOrder) {
+		return repositories.OrderFilter{}, repositories.OrderSort{}, ErrInvalidSort
+	}
+
+	filter := repositories.OrderFilter{
+		UserID:      input.UserID,
+		Statuses:    statuses,
+		CreatedFrom: input.CreatedFrom,
+		CreatedTo:   input.CreatedTo,
+		CostMin:     input.CostMin,
+		CostMax:     input.CostMax,
+		Item:        strings.TrimSpace(input.Item),
+	}
+	// The ID order ignores the direction.
+	sort := repositories.OrderSort{Field: input.SortBy, Desc: input.SortBy != "" && input.SortOrder == "desc"}
+	return filter, sort, nil
+}
+
+func toOrderResponses(orders []models.Order) []*OrderResponse {
+	response := make([]*OrderResponse, 0, len(orders))
+	for i := range orders {
+		response = append(response, toOrderResponse(&orders[i]))
+	}
+	return response
+}
 
+// GetOrders lists orders a page at a time. Like GetOrdersByCursor it serves
+// at most pagination.MaxLimit orders per page.
+func (s *OrderService) GetOrders(input OrderListInput) (*OrderListResponse, error) {
 	if input.Page < 1 {
-		return nil, errors.New("invalid page number")
+		return nil, ErrInvalidPage
 	}
 	if input.Limit < 1 {
-		return nil, errors.New("invalid limit value")
+		return nil, ErrInvalidLimit
 	}
+	input.Limit = min(input.Limit, pagination.MaxLimit)
 
-	orders, total, err := s.orderRepo.GetOrders(input.Page, input.Limit, input.UserID, input.Status)
+	filter, sort, err := orderListQuery(input)
 	if err != nil {
 		return nil, err
 	}
 
-	response := make([]*OrderResponse, 0, len(orders))
-	for _, order := range orders {
-		orderResponse := &OrderResponse{
-			ID:         order.ID,
-			UserID:     order.UserId,
-			Status:     order.Status,
-			Cost:       order.Cost,
-			OrderItems: make([]OrderItemResponse, len(order.Items)),
-		}
-		for i, item := range order.Items {
-			orderResponse.OrderItems[i] = OrderItemResponse{
-				ID:       item.ID,
-				Name:     item.Name,
-				Quantity: item.Quantity,
-			}
-		}
-		response = append(response, orderResponse)
+	orders, total, err := s.orderRepo.GetOrders(input.Page, input.Limit, filter, sort)
+	if err != nil {
+		return nil, err
 	}
 
 	totalPages := int((total + int64(input.Limit) - 1) / int64(input.Limit))
 
 	return &OrderListResponse{
-		Orders:     response,
+		Orders:     toOrderResponses(orders),
 		Total:      total,
 		Page:       input.Page,
 		Limit:      input.Limit,
 		TotalPages: totalPages,
 	}, nil
 }
+
+// orderSortKey is the value of the sort column of an order, as kept in
+// cursors.
+func orderSortKey(order *models.Order, field string) interface{} {
+	switch field {
+	case repositories.OrderSortCreatedAt:
+		return order.CreatedAt
+	case repositories.OrderSortCost:
+		return order.Cost
+	}
+	return nil
+}
+
+// orderCursor turns a cursor back into a position in the order list.
+func orderCursor(raw string, sort repositories.OrderSort) (*repositories.OrderCursor, error) {
+	cursor, err := pagination.DecodeCursor(raw, sort.Field, sort.Desc)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	after := &repositories.OrderCursor{ID: cursor.ID}
+	switch sort.Field {
+	case repositories.OrderSortCreatedAt:
+		var value time.Time
+		err = cursor.ReadKey(&value)
+		after.Value = value
+	case repositories.OrderSortCost:
+		var value int
+		err = cursor.ReadKey(&value)
+		after.Value = value
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return after, nil
+}
+
+// GetOrdersByCursor lists orders with keyset pagination. An empty cursor
+// starts at the first page; the total is only counted when asked for.
+func (s *OrderService) GetOrdersByCursor(input OrderListInput) (*OrderCursorResponse, error) {
+	if input.Limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+	input.Limit = min(input.Limit, pagination.MaxLimit)
+
+	filter, sort, err := orderListQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *repositories.OrderCursor
+	if input.Cursor != "" {
+		if after, err = orderCursor(input.Cursor, sort); err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra row tells whether there is a next page.
+	orders, err := s.orderRepo.GetOrdersAfter(after, input.Limit+1, filter, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OrderCursorResponse{Limit: input.Limit}
+	if len(orders) > input.Limit {
+		orders = orders[:input.Limit]
+		last := &orders[len(orders)-1]
+		result.NextCursor = pagination.EncodeCursor(sort.Field, sort.Desc, last.ID, orderSortKey(last, sort.Field))
+	}
+	result.Orders = toOrderResponses(orders)
+
+	if input.IncludeTotal {
+		total, err := s.orderRepo.CountOrders(filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
@@ -2,11 +2,15 @@ package services
 
 import (
 	"testing"
+	"time"
 
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/clients"
+	clientmocks "github.com/SpiritFoxo/control-system-microservices/service-orders/internal/clients/mocks"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/config"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories"
 	"github.com/SpiritFoxo/control-system-microservices/service-orders/internal/repositories/mocks"
-	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/SpiritFoxo/control-system-microservices/shared/pagination"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"gorm.io/gorm"
@@ -30,12 +34,22 @@ func newTestOrderItem(id uint, name string, quantity int) models.OrderItem {
 	}
 }
 
+var activeUser = clients.User{ID: 100, Name: "Test User", Email: "test@example.com", Active: true}
+
 func setupOrderTest(t *testing.T) (*OrderService, *mocks.MockOrderRepositoryInterface, func()) {
+	service, mockRepo, _, finish := setupOrderUsersTest(t)
+	return service, mockRepo, finish
+}
+
+// setupOrderUsersTest also returns the users client, for tests that look
+// users up.
+func setupOrderUsersTest(t *testing.T) (*OrderService, *mocks.MockOrderRepositoryInterface, *clientmocks.MockUsersClientInterface, func()) {
 	ctrl := gomock.NewController(t)
 	mockRepo := mocks.NewMockOrderRepositoryInterface(ctrl)
+	mockUsers := clientmocks.NewMockUsersClientInterface(ctrl)
 	cfg := &config.Config{}
-	service := NewOrderService(mockRepo, cfg)
-	return service, mockRepo, ctrl.Finish
+	service := NewOrderService(mockRepo, mockUsers, cfg)
+	return service, mockRepo, mockUsers, ctrl.Finish
 }
 
 func TestOrderService_GetOrderByID(t *testing.T) {
@@ -46,6 +60,7 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 	tests := []struct {
 		name        string
 		id          uint
+		ownerID     uint
 		setupMock   func()
 		expected    *OrderResponse
 		expectedErr string
@@ -66,6 +81,32 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "свой заказ",
+			id:      1,
+			ownerID: 100,
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(order, nil)
+			},
+			expected: &OrderResponse{
+				ID:     1,
+				UserID: 100,
+				Status: models.StatusCreated,
+				Cost:   2000,
+				OrderItems: []OrderItemResponse{
+					{ID: 1, Name: "Laptop", Quantity: 2},
+				},
+			},
+		},
+		{
+			name:    "чужой заказ",
+			id:      1,
+			ownerID: 200,
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(order, nil)
+			},
+			expectedErr: "access forbidden",
+		},
 		{
 			name: "не найден",
 			id:   999,
@@ -78,7 +119,7 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
-			got, err := service.GetOrderByID(tt.id)
+			got, err := service.GetOrderByID(tt.id, tt.ownerID)
 			if tt.expectedErr != "" {
 				assert.ErrorContains(t, err, tt.expectedErr)
 			} else {
@@ -90,7 +131,7 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 }
 
 func TestOrderService_CreateOrder(t *testing.T) {
-	service, mockRepo, finish := setupOrderTest(t)
+	service, mockRepo, mockUsers, finish := setupOrderUsersTest(t)
 	defer finish()
 	input := &CreateOrderInput{
 		UserID: 100,
@@ -111,6 +152,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			name:  "успешно",
 			input: input,
 			setupMock: func() {
+				mockUsers.EXPECT().GetUsers([]uint{100}).Return(map[uint]clients.User{100: activeUser}, nil)
 				mockRepo.EXPECT().CreateOrder(gomock.Any()).DoAndReturn(func(o *models.Order) error {
 					o.ID = 1
 					o.Items[0].ID = 1
@@ -136,7 +178,31 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				OrderItems: []OrderItemInput{},
 			},
 			setupMock:   func() {},
-			expectedErr: "binding",
+			expectedErr: "order must contain at least one item",
+		},
+		{
+			name:  "пользователь не существует",
+			input: input,
+			setupMock: func() {
+				mockUsers.EXPECT().GetUsers([]uint{100}).Return(map[uint]clients.User{}, nil)
+			},
+			expectedErr: "user does not exist",
+		},
+		{
+			name:  "пользователь деактивирован",
+			input: input,
+			setupMock: func() {
+				mockUsers.EXPECT().GetUsers([]uint{100}).Return(map[uint]clients.User{100: {ID: 100, Name: "Old User", Active: false}}, nil)
+			},
+			expectedErr: "user is deactivated",
+		},
+		{
+			name:  "service-users недоступен",
+			input: input,
+			setupMock: func() {
+				mockUsers.EXPECT().GetUsers([]uint{100}).Return(nil, assert.AnError)
+			},
+			expectedErr: "user service is unavailable",
 		},
 	}
 	for _, tt := range tests {
@@ -144,7 +210,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			tt.setupMock()
 			got, err := service.CreateOrder(tt.input)
 			if tt.expectedErr != "" {
-				assert.Error(t, err)
+				assert.EqualError(t, err, tt.expectedErr)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expected, got)
@@ -172,7 +238,21 @@ func TestOrderService_UpdateOrder(t *testing.T) {
 				updatedOrder.Status = models.StatusAccepted
 				gomock.InOrder(
 					mockRepo.EXPECT().GetOrderByID(uint(1)).Return(initialOrder, nil),
-					mockRepo.EXPECT().UpdateOrder(gomock.Any()).Return(nil),
+					mockRepo.EXPECT().UpdateOrderStatus(initialOrder, gomock.Any()).DoAndReturn(
+						func(order *models.Order, entry *models.OrderStatusHistory) error {
+							assert.Equal(t, models.StatusAccepted, order.Status)
+							assert.NotNil(t, order.StatusChangedAt)
+							assert.Equal(t, models.OrderStatusHistory{
+								OrderId:    1,
+								FromStatus: models.StatusCreated,
+								ToStatus:   models.StatusAccepted,
+								ActorID:    7,
+								Comment:    "принят в работу",
+								RequestID:  "req-1",
+								ChangedAt:  *order.StatusChangedAt,
+							}, *entry)
+							return nil
+						}),
 					mockRepo.EXPECT().GetOrderByID(uint(1)).Return(&updatedOrder, nil),
 				)
 			},
@@ -185,7 +265,7 @@ func TestOrderService_UpdateOrder(t *testing.T) {
 			setupMock: func(initialOrder *models.Order) {
 				gomock.InOrder(
 					mockRepo.EXPECT().GetOrderByID(uint(2)).Return(initialOrder, nil),
-					mockRepo.EXPECT().UpdateOrder(gomock.Any()).Return(nil),
+					mockRepo.EXPECT().UpdateOrderStatus(initialOrder, gomock.Any()).Return(nil),
 					mockRepo.EXPECT().GetOrderByID(uint(2)).Return(initialOrder, nil),
 				)
 			},
@@ -198,12 +278,22 @@ func TestOrderService_UpdateOrder(t *testing.T) {
 			setupMock: func(initialOrder *models.Order) {
 				gomock.InOrder(
 					mockRepo.EXPECT().GetOrderByID(uint(3)).Return(initialOrder, nil),
-					mockRepo.EXPECT().UpdateOrder(gomock.Any()).Return(nil),
+					mockRepo.EXPECT().UpdateOrderStatus(initialOrder, gomock.Any()).Return(nil),
 					mockRepo.EXPECT().GetOrderByID(uint(3)).Return(initialOrder, nil),
 				)
 			},
 			expected: models.StatusClosed,
 		},
+		{
+			name:          "статус уже изменён другим запросом",
+			id:            6,
+			initialStatus: models.StatusCreated,
+			setupMock: func(initialOrder *models.Order) {
+				mockRepo.EXPECT().GetOrderByID(uint(6)).Return(initialOrder, nil)
+				mockRepo.EXPECT().UpdateOrderStatus(initialOrder, gomock.Any()).Return(repositories.ErrStatusChanged)
+			},
+			expectedErr: "order status was changed by another request",
+		},
 		{
 			name:          "Closed → ошибка",
 			id:            4,
@@ -227,7 +317,7 @@ func TestOrderService_UpdateOrder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			initialOrder := newTestOrder(tt.id, 100, tt.initialStatus, 2000, newTestOrderItem(1, "Laptop", 2))
 			tt.setupMock(initialOrder)
-			resp, err := service.UpdateOrder(tt.id)
+			resp, err := service.UpdateOrder(tt.id, StatusChange{ActorID: 7, Comment: " принят в работу ", RequestID: "req-1"})
 			if tt.expectedErr != "" {
 				assert.ErrorContains(t, err, tt.expectedErr)
 				assert.Nil(t, resp)
@@ -248,7 +338,7 @@ func TestOrderService_CancelOrder(t *testing.T) {
 		name          string
 		id            uint
 		userID        uint
-		rolesStr      string
+		anyOrder      bool
 		initialStatus models.OrderStatus
 		setupMock     func(initialOrder *models.Order)
 		expected      models.OrderStatus
@@ -258,11 +348,10 @@ func TestOrderService_CancelOrder(t *testing.T) {
 			name:          "инженер отменяет свой заказ",
 			id:            1,
 			userID:        100,
-			rolesStr:      userroles.RoleEngineer,
 			initialStatus: models.StatusCreated,
 			setupMock: func(initialOrder *models.Order) {
 				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(initialOrder, nil)
-				mockRepo.EXPECT().UpdateOrder(gomock.Any()).Return(nil)
+				mockRepo.EXPECT().UpdateOrderStatus(initialOrder, gomock.Any()).Return(nil)
 			},
 			expected: models.StatusCanceled,
 		},
@@ -270,7 +359,6 @@ func TestOrderService_CancelOrder(t *testing.T) {
 			name:          "инженер пытается отменить чужой заказ",
 			id:            1,
 			userID:        999,
-			rolesStr:      userroles.RoleEngineer,
 			initialStatus: models.StatusCreated,
 			setupMock: func(initialOrder *models.Order) {
 				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(initialOrder, nil)
@@ -281,11 +369,11 @@ func TestOrderService_CancelOrder(t *testing.T) {
 			name:          "менеджер отменяет любой заказ",
 			id:            1,
 			userID:        999,
-			rolesStr:      userroles.RoleManager,
+			anyOrder:      true,
 			initialStatus: models.StatusCreated,
 			setupMock: func(initialOrder *models.Order) {
 				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(initialOrder, nil)
-				mockRepo.EXPECT().UpdateOrder(gomock.Any()).Return(nil)
+				mockRepo.EXPECT().UpdateOrderStatus(initialOrder, gomock.Any()).Return(nil)
 			},
 			expected: models.StatusCanceled,
 		},
@@ -293,7 +381,7 @@ func TestOrderService_CancelOrder(t *testing.T) {
 			name:          "менеджер не может отменить закрытый заказ",
 			id:            3,
 			userID:        999,
-			rolesStr:      userroles.RoleManager,
+			anyOrder:      true,
 			initialStatus: models.StatusClosed,
 			setupMock: func(initialOrder *models.Order) {
 				mockRepo.EXPECT().GetOrderByID(uint(3)).Return(initialOrder, nil)
@@ -304,21 +392,31 @@ func TestOrderService_CancelOrder(t *testing.T) {
 			name:          "нельзя отменить уже отменённый заказ",
 			id:            4,
 			userID:        100,
-			rolesStr:      userroles.RoleEngineer,
 			initialStatus: models.StatusCanceled,
 			setupMock: func(initialOrder *models.Order) {
 				mockRepo.EXPECT().GetOrderByID(uint(4)).Return(initialOrder, nil)
 			},
 			expectedErr: "order cannot be canceled",
 		},
+		{
+			name:     "заказ не найден",
+			id:       5,
+			userID:   100,
+			anyOrder: true,
+			setupMock: func(*models.Order) {
+				mockRepo.EXPECT().GetOrderByID(uint(5)).Return((*models.Order)(nil), repositories.ErrOrderNotFound)
+			},
+			expectedErr: "order not found",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			initialOrder := newTestOrder(tt.id, 100, tt.initialStatus, 2000)
 			tt.setupMock(initialOrder)
-			resp, err := service.CancelOrder(tt.id, tt.userID, tt.rolesStr)
+			resp, err := service.CancelOrder(tt.id, StatusChange{ActorID: tt.userID}, tt.anyOrder)
 			if tt.expectedErr != "" {
 				assert.ErrorContains(t, err, tt.expectedErr)
+				assert.NotEqual(t, KindInternal, KindOf(err), "доменная ошибка должна быть типизированной")
 				assert.Nil(t, resp)
 			} else {
 				assert.NoError(t, err)
@@ -329,6 +427,85 @@ func TestOrderService_CancelOrder(t *testing.T) {
 	}
 }
 
+func TestOrderService_GetOrderHistory(t *testing.T) {
+	service, mockRepo, finish := setupOrderTest(t)
+	defer finish()
+	changedAt := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		id          uint
+		ownerID     uint
+		setupMock   func()
+		expected    []OrderStatusChangeResponse
+		expectedErr string
+	}{
+		{
+			name: "успешно",
+			id:   1,
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(1)).Return(newTestOrder(1, 100, models.StatusAccepted, 2000), nil)
+				mockRepo.EXPECT().GetStatusHistory(uint(1)).Return([]models.OrderStatusHistory{{
+					ID: 1, OrderId: 1, FromStatus: models.StatusCreated, ToStatus: models.StatusAccepted,
+					ActorID: 7, Comment: "ok", RequestID: "req-1", ChangedAt: changedAt,
+				}}, nil)
+			},
+			expected: []OrderStatusChangeResponse{{
+				From: models.StatusCreated, To: models.StatusAccepted, ActorID: 7, Comment: "ok", RequestID: "req-1", ChangedAt: changedAt,
+			}},
+		},
+		{
+			name: "статус ещё не менялся",
+			id:   2,
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(2)).Return(newTestOrder(2, 100, models.StatusCreated, 2000), nil)
+				mockRepo.EXPECT().GetStatusHistory(uint(2)).Return(nil, nil)
+			},
+			expected: []OrderStatusChangeResponse{},
+		},
+		{
+			name:    "история чужого заказа",
+			id:      2,
+			ownerID: 200,
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(2)).Return(newTestOrder(2, 100, models.StatusCreated, 2000), nil)
+			},
+			expectedErr: "access forbidden",
+		},
+		{
+			name: "заказ не найден",
+			id:   3,
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrderByID(uint(3)).Return((*models.Order)(nil), repositories.ErrOrderNotFound)
+			},
+			expectedErr: "order not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			history, err := service.GetOrderHistory(tt.id, tt.ownerID)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, history)
+			}
+		})
+	}
+}
+
+func TestToOrderResponse_ChangedAt(t *testing.T) {
+	created := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	changed := created.Add(time.Hour)
+
+	order := newTestOrder(1, 100, models.StatusCreated, 2000)
+	order.CreatedAt = created
+	assert.Equal(t, created, toOrderResponse(order).ChangedAt, "без смены статуса берётся время создания")
+
+	order.StatusChangedAt = &changed
+	assert.Equal(t, changed, toOrderResponse(order).ChangedAt)
+}
+
 func TestOrderService_DeleteOrder(t *testing.T) {
 	service, mockRepo, finish := setupOrderTest(t)
 	defer finish()
@@ -375,6 +552,9 @@ func TestOrderService_GetOrders(t *testing.T) {
 	order1 := newTestOrder(1, 100, models.StatusCreated, 2000, newTestOrderItem(1, "Laptop", 2))
 	order2 := newTestOrder(2, 101, models.StatusAccepted, 3000, newTestOrderItem(2, "Mouse", 5))
 	orders := []models.Order{*order1, *order2}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	costMin, costMax, negative := 1000, 5000, -1
 	tests := []struct {
 		name        string
 		input       OrderListInput
@@ -386,7 +566,7 @@ func TestOrderService_GetOrders(t *testing.T) {
 			name:  "успешно",
 			input: OrderListInput{Page: 1, Limit: 10},
 			setupMock: func() {
-				mockRepo.EXPECT().GetOrders(1, 10, uint(0), "").Return(orders, int64(2), nil)
+				mockRepo.EXPECT().GetOrders(1, 10, repositories.OrderFilter{}, repositories.OrderSort{}).Return(orders, int64(2), nil)
 			},
 			expectedLen: 2,
 		},
@@ -394,18 +574,83 @@ func TestOrderService_GetOrders(t *testing.T) {
 			name:  "фильтр по пользователю",
 			input: OrderListInput{Page: 1, Limit: 10, UserID: 100},
 			setupMock: func() {
-				mockRepo.EXPECT().GetOrders(1, 10, uint(100), "").Return([]models.Order{*order1}, int64(1), nil)
+				mockRepo.EXPECT().GetOrders(1, 10, repositories.OrderFilter{UserID: 100}, repositories.OrderSort{}).Return([]models.Order{*order1}, int64(1), nil)
 			},
 			expectedLen: 1,
 		},
 		{
 			name:  "фильтр по статусу",
-			input: OrderListInput{Page: 1, Limit: 10, Status: "Accepted"},
+			input: OrderListInput{Page: 1, Limit: 10, Statuses: []string{"Accepted", "Canceled"}},
 			setupMock: func() {
-				mockRepo.EXPECT().GetOrders(1, 10, uint(0), "Accepted").Return([]models.Order{*order2}, int64(1), nil)
+				mockRepo.EXPECT().GetOrders(1, 10, repositories.OrderFilter{
+					Statuses: []models.OrderStatus{models.StatusAccepted, models.StatusCanceled},
+				}, repositories.OrderSort{}).Return([]models.Order{*order2}, int64(1), nil)
 			},
 			expectedLen: 1,
 		},
+		{
+			name: "фильтры по дате, стоимости и позиции с сортировкой",
+			input: OrderListInput{
+				Page: 1, Limit: 10, CreatedFrom: &from, CreatedTo: &to, CostMin: &costMin, CostMax: &costMax,
+				Item: "  Laptop ", SortBy: "cost", SortOrder: "desc",
+			},
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrders(1, 10, repositories.OrderFilter{
+					CreatedFrom: &from, CreatedTo: &to, CostMin: &costMin, CostMax: &costMax, Item: "Laptop",
+				}, repositories.OrderSort{Field: "cost", Desc: true}).Return([]models.Order{*order1}, int64(1), nil)
+			},
+			expectedLen: 1,
+		},
+		{
+			name:        "неизвестный статус",
+			input:       OrderListInput{Page: 1, Limit: 10, Statuses: []string{"Created", "Created'; DROP TABLE orders; --"}},
+			setupMock:   func() {},
+			expectedErr: "invalid status filter",
+		},
+		{
+			name:        "статус в нижнем регистре",
+			input:       OrderListInput{Page: 1, Limit: 10, Statuses: []string{"created"}},
+			setupMock:   func() {},
+			expectedErr: "invalid status filter",
+		},
+		{
+			name:        "перевёрнутый диапазон дат",
+			input:       OrderListInput{Page: 1, Limit: 10, CreatedFrom: &to, CreatedTo: &from},
+			setupMock:   func() {},
+			expectedErr: "invalid date range",
+		},
+		{
+			name:        "перевёрнутый диапазон стоимости",
+			input:       OrderListInput{Page: 1, Limit: 10, CostMin: &costMax, CostMax: &costMin},
+			setupMock:   func() {},
+			expectedErr: "invalid cost range",
+		},
+		{
+			name:        "отрицательная стоимость",
+			input:       OrderListInput{Page: 1, Limit: 10, CostMin: &negative},
+			setupMock:   func() {},
+			expectedErr: "invalid cost range",
+		},
+		{
+			name:        "неизвестное поле сортировки",
+			input:       OrderListInput{Page: 1, Limit: 10, SortBy: "user_id"},
+			setupMock:   func() {},
+			expectedErr: "invalid sort",
+		},
+		{
+			name:        "неизвестное направление сортировки",
+			input:       OrderListInput{Page: 1, Limit: 10, SortBy: "cost", SortOrder: "up"},
+			setupMock:   func() {},
+			expectedErr: "invalid sort",
+		},
+		{
+			name:  "лимит больше максимального",
+			input: OrderListInput{Page: 2, Limit: 1000000},
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrders(2, pagination.MaxLimit, repositories.OrderFilter{}, repositories.OrderSort{}).Return(orders, int64(2), nil)
+			},
+			expectedLen: 2,
+		},
 		{
 			name:        "невалидная страница",
 			input:       OrderListInput{Page: 0, Limit: 10},
@@ -427,3 +672,132 @@ func TestOrderService_GetOrders(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderService_GetOrdersByCursor(t *testing.T) {
+	service, mockRepo, finish := setupOrderTest(t)
+	defer finish()
+	orders := []models.Order{
+		*newTestOrder(1, 100, models.StatusCreated, 3000),
+		*newTestOrder(2, 100, models.StatusAccepted, 2000),
+		*newTestOrder(3, 101, models.StatusCreated, 1000),
+	}
+	byCost := repositories.OrderSort{Field: repositories.OrderSortCost, Desc: true}
+	costCursor := pagination.EncodeCursor(repositories.OrderSortCost, true, 2, 2000)
+	total := int64(3)
+	tests := []struct {
+		name          string
+		input         OrderListInput
+		setupMock     func()
+		expectedIDs   []uint
+		expectedNext  string
+		expectedTotal *int64
+		expectedErr   string
+	}{
+		{
+			name:  "первая страница",
+			input: OrderListInput{Limit: 2, SortBy: "cost", SortOrder: "desc"},
+			setupMock: func() {
+				mockRepo.EXPECT().GetOrdersAfter(nil, 3, repositories.OrderFilter{}, byCost).Return(orders, nil)
+			},
+			expectedIDs:  []uint{1, 2},
+			expectedNext: costCursor,
+		},
+		{
+			name:  "следующая страница по курсору",
+			input: OrderListInput{Limit: 2, SortBy: "cost", SortOrder: "desc", Cursor: costCursor},
+			setupMock: func() {
+				mockRepo.EXPECT().
+					GetOrdersAfter(&repositories.OrderCursor{ID: 2, Value: 2000}, 3, repositories.OrderFilter{}, byCost).
+					Return(orders[2:], nil)
+			},
+			expectedIDs: []uint{3},
+		},
+		{
+			name:  "по ID с фильтром и подсчётом",
+			input: OrderListInput{Limit: 5, UserID: 100, Cursor: pagination.EncodeCursor("", false, 1, nil), IncludeTotal: true},
+			setupMock: func() {
+				filter := repositories.OrderFilter{UserID: 100}
+				mockRepo.EXPECT().GetOrdersAfter(&repositories.OrderCursor{ID: 1}, 6, filter, repositories.OrderSort{}).Return(orders[1:2], nil)
+				mockRepo.EXPECT().CountOrders(filter).Return(total, nil)
+			},
+			expectedIDs:   []uint{2},
+			expectedTotal: &total,
+		},
+		{
+			name:        "испорченный курсор",
+			input:       OrderListInput{Limit: 2, Cursor: "%%%"},
+			setupMock:   func() {},
+			expectedErr: "invalid cursor",
+		},
+		{
+			name:        "курсор другого направления",
+			input:       OrderListInput{Limit: 2, SortBy: "cost", Cursor: costCursor},
+			setupMock:   func() {},
+			expectedErr: "invalid cursor",
+		},
+		{
+			name:        "невалидный статус",
+			input:       OrderListInput{Limit: 2, Statuses: []string{"Lost"}},
+			setupMock:   func() {},
+			expectedErr: "invalid status filter",
+		},
+		{
+			name:        "невалидный лимит",
+			input:       OrderListInput{Limit: 0},
+			setupMock:   func() {},
+			expectedErr: "invalid limit value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			result, err := service.GetOrdersByCursor(tt.input)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]uint, 0, len(result.Orders))
+			for _, order := range result.Orders {
+				ids = append(ids, order.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, tt.expectedNext, result.NextCursor)
+			assert.Equal(t, tt.expectedTotal, result.Total)
+		})
+	}
+}
+
+func TestOrderService_AttachUsers(t *testing.T) {
+	service, _, mockUsers, finish := setupOrderUsersTest(t)
+	defer finish()
+
+	tests := []struct {
+		name      string
+		setupMock func()
+		expected  []*OrderUser
+	}{
+		{
+			name: "пользователи подставляются",
+			setupMock: func() {
+				mockUsers.EXPECT().GetUsers([]uint{100, 200}).Return(map[uint]clients.User{100: activeUser}, nil)
+			},
+			expected: []*OrderUser{{ID: 100, Name: "Test User", Email: "test@example.com"}, nil},
+		},
+		{
+			name: "service-users недоступен",
+			setupMock: func() {
+				mockUsers.EXPECT().GetUsers([]uint{100, 200}).Return(nil, assert.AnError)
+			},
+			expected: []*OrderUser{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			orders := []*OrderResponse{{ID: 1, UserID: 100}, {ID: 2, UserID: 200}}
+			service.AttachUsers(orders...)
+			assert.Equal(t, tt.expected, []*OrderUser{orders[0].User, orders[1].User})
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/handlers"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/passwords"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/routers"
+	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -26,11 +28,31 @@ func DbInit(cfg *config.Config) *gorm.DB {
 func SetupRouter() *gin.Engine {
 	r := gin.Default()
 	cfg := config.Load()
+
+	// Must be in place before the database seeds the superadmin password.
+	policy, err := passwords.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+	passwords.SetDefault(policy)
+
 	db := DbInit(cfg)
 	server := handlers.NewServer(db, cfg)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	wellKnown := r.Group("/.well-known")
+	routers.RegisterWellKnownRoutes(wellKnown, server)
+
+	oauth := r.Group("/oauth")
+	routers.RegisterOAuthRoutes(oauth, server)
+
+	scim := r.Group("/scim/v2")
+	routers.RegisterSCIMRoutes(scim, server)
+
+	internal := r.Group("/internal", middleware.InternalAuth(cfg.InternalAPISecret))
+	routers.RegisterInternalRoutes(internal, server)
+
 	api := r.Group("api/v1")
 
 	admin := api.Group("/admin")
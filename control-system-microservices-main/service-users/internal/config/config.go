@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -16,11 +18,63 @@ type Config struct {
 	PostgresPassword         string
 	PostgresPort             string
 	DBSSLMode                string
-	TokenSecret              string
+	JWTSigningAlg            string
+	JWTKeysDir               string
+	JWTActiveKid             string
 	RefreshTokenSecret       string
 	TokenMinuteLifespan      string
 	RefreshTokenHourLifespan string
 	SuperadminPassword       string
+
+	LoginMaxAttempts       string
+	LoginLockoutMinutes    string
+	LoginLockoutMaxMinutes string
+
+	MFARequiredForPrivileged string
+	MFAIssuer                string
+	MFATokenMinutes          string
+	// MFATokenSecret signs the MFA challenges handed out between the password
+	// and the second factor. It is kept apart from the other token secrets.
+	MFATokenSecret string
+
+	PasswordHasher    string
+	Argon2MemoryKB    string
+	Argon2Iterations  string
+	Argon2Parallelism string
+	BcryptCost        string
+
+	MailDriver   string
+	MailFrom     string
+	MailLogFile  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	PasswordResetURL          string
+	PasswordResetTokenMinutes string
+	// PasswordResetThrottleMinutes is how long an account that was just sent
+	// a reset link waits before another one is sent.
+	PasswordResetThrottleMinutes string
+
+	InviteURL        string
+	InviteTokenHours string
+	// InviteTokenSecret signs invite links. It is kept apart from
+	// RefreshTokenSecret so that neither kind of token verifies as the other.
+	InviteTokenSecret string
+
+	// SessionIdleTimeoutMinutes ends sessions that have not refreshed their
+	// tokens for this long. Zero disables the idle timeout.
+	SessionIdleTimeoutMinutes string
+
+	// OIDCIssuer is the public base URL of service-users as seen by browsers
+	// and OIDC clients. It is the iss of ID tokens and the prefix of every
+	// endpoint in the discovery document.
+	OIDCIssuer string
+
+	// InternalAPISecret guards the /internal endpoints. The api-gateway and
+	// service-orders send it in X-Internal-Token.
+	InternalAPISecret string
 }
 
 func Load() *Config {
@@ -36,11 +90,54 @@ func Load() *Config {
 		PostgresPassword:         getEnv("POSTGRES_PASSWORD", "password"),
 		PostgresPort:             getEnv("POSTGRES_PORT", "5432"),
 		DBSSLMode:                getEnv("DB_SSLMODE", "disable"),
-		TokenSecret:              getEnv("TOKEN_SECRET", "default-token-secret"),
+		JWTSigningAlg:            getEnv("JWT_SIGNING_ALG", "RS256"),
+		JWTKeysDir:               getEnv("JWT_KEYS_DIR", ""),
+		JWTActiveKid:             getEnv("JWT_ACTIVE_KID", ""),
 		RefreshTokenSecret:       getEnv("REFRESH_TOKEN_SECRET", "default-refresh-token-secret"),
 		TokenMinuteLifespan:      getEnv("TOKEN_MINUTE_LIFESPAN", "15"),
 		RefreshTokenHourLifespan: getEnv("REFRESH_TOKEN_HOUR_LIFESPAN", "24"),
 		SuperadminPassword:       getEnv("SUPERADMIN_PASSWORD", "default-superadmin-password"),
+
+		LoginMaxAttempts:       getEnv("LOGIN_MAX_ATTEMPTS", "5"),
+		LoginLockoutMinutes:    getEnv("LOGIN_LOCKOUT_MINUTES", "5"),
+		LoginLockoutMaxMinutes: getEnv("LOGIN_LOCKOUT_MAX_MINUTES", "1440"),
+
+		MFARequiredForPrivileged: getEnv("MFA_REQUIRED_FOR_PRIVILEGED", "false"),
+		MFAIssuer:                getEnv("MFA_ISSUER", "Control System"),
+		MFATokenMinutes:          getEnv("MFA_TOKEN_MINUTES", "5"),
+		MFATokenSecret:           getEnv("MFA_TOKEN_SECRET", "default-mfa-token-secret"),
+
+		PasswordHasher:    getEnv("PASSWORD_HASHER", "argon2id"),
+		Argon2MemoryKB:    getEnv("ARGON2_MEMORY_KB", "65536"),
+		Argon2Iterations:  getEnv("ARGON2_ITERATIONS", "3"),
+		Argon2Parallelism: getEnv("ARGON2_PARALLELISM", "2"),
+		BcryptCost:        getEnv("BCRYPT_COST", "10"),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@controlsystem.ru"),
+		MailLogFile:  getEnv("MAIL_LOG_FILE", ""),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "25"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		PasswordResetURL:             getEnv("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+		PasswordResetTokenMinutes:    getEnv("PASSWORD_RESET_TOKEN_MINUTES", "30"),
+		PasswordResetThrottleMinutes: getEnv("PASSWORD_RESET_THROTTLE_MINUTES", "5"),
+
+		InviteURL:         getEnv("INVITE_URL", "http://localhost:8080/accept-invite"),
+		InviteTokenHours:  getEnv("INVITE_TOKEN_HOURS", "72"),
+		InviteTokenSecret: getEnv("INVITE_TOKEN_SECRET", "default-invite-token-secret"),
+
+		SessionIdleTimeoutMinutes: getEnv("SESSION_IDLE_TIMEOUT_MINUTES", "0"),
+
+		OIDCIssuer: strings.TrimSuffix(getEnv("OIDC_ISSUER", "http://localhost:8082"), "/"),
+
+		InternalAPISecret: getEnv("INTERNAL_API_SECRET", ""),
+	}
+
+	if cfg.InternalAPISecret == "" {
+		log.Printf("Warning: INTERNAL_API_SECRET is not set, the internal API rejects every request")
 	}
 
 	return cfg
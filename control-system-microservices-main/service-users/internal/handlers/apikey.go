@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateServiceAccount
+// @Summary Creates a service account
+// @Description Creates a user without a password that authenticates with API keys
+// @Tags Service accounts
+// @Accept json
+// @Produce json
+// @Param account body services.CreateServiceAccountInput true "Service account data"
+// @Success 201 {object} services.UserResponse "Created service account"
+// @Security BearerAuth
+// @Router /admin/service-accounts [post]
+func (h *UserHandler) CreateServiceAccount(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.CreateServiceAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.CreateServiceAccount(actor, input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusCreated, true, user)
+}
+
+// CreateAPIKey
+// @Summary Issues an API key to a service account
+// @Description The key is only returned in this response; store it right away
+// @Tags Service accounts
+// @Accept json
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param key body services.CreateAPIKeyInput true "Key data"
+// @Success 201 {object} services.CreatedAPIKey "Issued key"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/api-keys [post]
+func (h *UserHandler) CreateAPIKey(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	var input services.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(actor, uint(id), input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusCreated, true, key)
+}
+
+// ListAPIKeys
+// @Summary Lists the API keys of a service account
+// @Tags Service accounts
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {array} services.APIKeyResponse "Keys"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/api-keys [get]
+func (h *UserHandler) ListAPIKeys(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, keys)
+}
+
+// RevokeAPIKey
+// @Summary Revokes an API key
+// @Tags Service accounts
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param keyId path int true "Key ID"
+// @Success 200 {object} map[string]interface{} "Key revoked"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/api-keys/{keyId} [delete]
+func (h *UserHandler) RevokeAPIKey(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+	keyID, err := strconv.Atoi(c.Param("keyId"))
+	if err != nil {
+		problem(c, invalidParam("keyId", "invalid key ID"))
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(actor, uint(id), uint(keyID)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// ResolveAPIKey is consulted by the api-gateway for requests that present an
+// API key instead of a bearer token.
+func (h *UserHandler) ResolveAPIKey(c *gin.Context) {
+	var input services.ResolveAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	identity, err := h.service.ResolveAPIKey(input.Key)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, identity)
+}
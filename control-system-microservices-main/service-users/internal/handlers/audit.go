@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEntries
+// @Summary Lists the audit log of user changes
+// @Description Returns the newest entries first
+// @Tags Audit
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Number of records per page"
+// @Param actor_id query int false "Only changes made by this user"
+// @Param target_id query int false "Only changes made to this user"
+// @Param from query string false "RFC 3339 timestamp, inclusive"
+// @Param to query string false "RFC 3339 timestamp, exclusive"
+// @Success 200 {object} services.AuditListResult "Audit entries"
+// @Security BearerAuth
+// @Router /admin/audit [get]
+func (h *UserHandler) ListAuditEntries(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil {
+		problem(c, invalidParam("page", "invalid page number"))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		problem(c, invalidParam("limit", "invalid limit value"))
+		return
+	}
+
+	input := services.AuditListInput{Page: page, Limit: limit}
+	if v := c.Query("actor_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			problem(c, invalidParam("actor_id", "invalid actor_id"))
+			return
+		}
+		input.ActorID = uint(id)
+	}
+	if v := c.Query("target_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			problem(c, invalidParam("target_id", "invalid target_id"))
+			return
+		}
+		input.TargetUserID = uint(id)
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			problem(c, invalidParam("from", "invalid from timestamp"))
+			return
+		}
+		input.From = &from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			problem(c, invalidParam("to", "invalid to timestamp"))
+			return
+		}
+		input.To = &to
+	}
+
+	result, err := h.service.ListAuditEntries(input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, gin.H{
+		"entries": result.Entries,
+		"pagination": gin.H{
+			"page":       result.Page,
+			"limit":      result.Limit,
+			"total":      result.Total,
+			"totalPages": result.TotalPages,
+		},
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const maxImportBytes = 10 << 20
+
+// ImportUsers
+// @Summary Imports users from CSV or JSON
+// @Description Validates every row like /admin/users/register and inserts all rows in one transaction.
+// @Description CSV needs a header with at least email and name; roles are separated by semicolons.
+// @Description Rows without a password are created pending and receive an invitation.
+// @Description Nothing is inserted when any row is invalid or dry_run is set.
+// @Tags Users
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param format query string false "csv or json; defaults to the request Content-Type"
+// @Param dry_run query bool false "Only validate the file"
+// @Success 200 {object} services.ImportResult "Import report"
+// @Failure 422 {object} services.ImportResult "Per-row validation errors"
+// @Security BearerAuth
+// @Router /admin/users/import [post]
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = services.FormatCSV
+		case "application/json":
+			format = services.FormatJSON
+		}
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	result, err := h.service.ImportUsers(actor, body, format, dryRun)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		response(c, http.StatusUnprocessableEntity, false, result)
+		return
+	}
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	response(c, status, true, result)
+}
+
+// ExportUsers
+// @Summary Exports users as CSV or NDJSON
+// @Description Streams every user matching the filters
+// @Tags Users
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv (default) or ndjson"
+// @Param email query string false "Email filter"
+// @Param role query string false "Role filter"
+// @Param state query string false "active, inactive, pending, deleted or all; deleted users are hidden by default"
+// @Success 200 {file} file "User export"
+// @Security BearerAuth
+// @Router /admin/users/export [get]
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	format := c.DefaultQuery("format", services.FormatCSV)
+	input := services.UserExportInput{
+		EmailFilter: c.Query("email"),
+		RoleFilter:  c.Query("role"),
+		StateFilter: c.Query("state"),
+	}
+	if err := services.ValidateExportInput(input, format); err != nil {
+		problem(c, err)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == services.FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename=users."+format)
+	c.Status(http.StatusOK)
+
+	if err := h.service.ExportUsers(input, format, c.Writer); err != nil {
+		// Headers are already sent, so the client only sees a truncated body.
+		log.Printf("User export aborted: %v", err)
+		c.Abort()
+	}
+}
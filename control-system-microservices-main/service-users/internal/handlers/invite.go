@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// InviteUser
+// @Summary Invites a new user
+// @Description Creates a pending user and emails an invitation link for setting the password
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param user body services.InviteUserInput true "User data"
+// @Success 201 {object} services.UserResponse "Invited user"
+// @Security BearerAuth
+// @Router /admin/users/invite [post]
+func (h *UserHandler) InviteUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.InviteUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.InviteUser(actor, input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusCreated, true, user)
+}
+
+// ResendInvite
+// @Summary Resends the invitation of a pending user
+// @Description Links sent earlier stop working
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Invitation sent"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/invite/resend [post]
+func (h *UserHandler) ResendInvite(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	if err := h.service.ResendInvite(actor, uint(id)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// RevokeInvite
+// @Summary Revokes the invitation of a pending user
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Invitation revoked"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/invite [delete]
+func (h *UserHandler) RevokeInvite(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	if err := h.service.RevokeInvite(actor, uint(id)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// AcceptInvite
+// @Summary Accepts an invitation and sets the password
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param invite body services.AcceptInviteInput true "Invitation token and password"
+// @Success 200 {object} services.UserResponse "Activated user"
+// @Router /auth/invite/accept [post]
+func (h *UserHandler) AcceptInvite(c *gin.Context) {
+	var input services.AcceptInviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.AcceptInvite(input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, user)
+}
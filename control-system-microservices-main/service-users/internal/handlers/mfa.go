@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginMFA
+// @Summary Completes a login with a TOTP or recovery code
+// @Description Exchanges the mfa_token returned by /auth/login and a second factor for a token pair
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param mfa body services.MFALoginInput true "MFA token and code"
+// @Success 200 {object} map[string]interface{} "User auth data"
+// @Router /auth/login/mfa [post]
+func (h *UserHandler) LoginMFA(c *gin.Context) {
+	var input services.MFALoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	result, err := h.service.CompleteMFALogin(input, clientInfo(c))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	loginResponse(c, result)
+}
+
+// LoginMFAEnroll
+// @Summary Starts TOTP enrollment during login
+// @Description For accounts that must use MFA but have not set it up yet. Confirm with /auth/login/mfa.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param mfa body services.MFAEnrollInput true "MFA token"
+// @Success 200 {object} services.TOTPEnrollment "TOTP secret"
+// @Router /auth/login/mfa/enroll [post]
+func (h *UserHandler) LoginMFAEnroll(c *gin.Context) {
+	var input services.MFAEnrollInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	enrollment, err := h.service.EnrollMFAFromChallenge(input.MFAToken)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, enrollment)
+}
+
+// StartTOTP
+// @Summary Starts TOTP enrollment for the current user
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} services.TOTPEnrollment "TOTP secret"
+// @Security BearerAuth
+// @Router /auth/me/mfa/totp [post]
+func (h *UserHandler) StartTOTP(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	enrollment, err := h.service.StartTOTPEnrollment(id)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, enrollment)
+}
+
+// ConfirmTOTP
+// @Summary Confirms TOTP enrollment
+// @Description Enables MFA once a valid code is provided and returns one-time recovery codes
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param code body services.TOTPCodeInput true "TOTP code"
+// @Success 200 {object} services.RecoveryCodesResponse "Recovery codes"
+// @Security BearerAuth
+// @Router /auth/me/mfa/totp/confirm [post]
+func (h *UserHandler) ConfirmTOTP(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.TOTPCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTPEnrollment(id, input.Code)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, services.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP
+// @Summary Disables TOTP for the current user
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param code body services.TOTPCodeInput true "TOTP code"
+// @Success 200 {object} map[string]interface{} "MFA disabled"
+// @Security BearerAuth
+// @Router /auth/me/mfa/totp [delete]
+func (h *UserHandler) DisableTOTP(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.TOTPCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	if err := h.service.DisableTOTP(id, input.Code); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// RegenerateRecoveryCodes
+// @Summary Replaces the current user's recovery codes
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param code body services.TOTPCodeInput true "TOTP code"
+// @Success 200 {object} services.RecoveryCodesResponse "Recovery codes"
+// @Security BearerAuth
+// @Router /auth/me/mfa/recovery-codes [post]
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.TOTPCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(id, input.Code)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, services.RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// oauthError writes an RFC 6749 error response. Unlike the rest of the API the
+// OAuth endpoints do not use the success/data envelope, so that standard
+// clients can talk to them.
+func oauthError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "server_error"
+	switch err.Error() {
+	case services.OAuthInvalidClient:
+		status, code = http.StatusUnauthorized, services.OAuthInvalidClient
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	case services.OAuthInvalidRequest, services.OAuthInvalidGrant, services.OAuthInvalidScope,
+		services.OAuthUnsupportedGrantType:
+		status, code = http.StatusBadRequest, err.Error()
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": code})
+}
+
+// clientCredentials takes the client credentials from HTTP Basic
+// authentication, or from the form when the header is absent.
+func clientCredentials(c *gin.Context) (string, string) {
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		return id, secret
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret")
+}
+
+// OAuthToken is the RFC 6749 token endpoint for the client_credentials and
+// authorization_code grants. Client credentials go in HTTP Basic
+// authentication or in the form.
+func (h *UserHandler) OAuthToken(c *gin.Context) {
+	clientID, secret := clientCredentials(c)
+
+	token, err := h.service.IssueToken(services.OAuthTokenRequest{
+		GrantType:    c.PostForm("grant_type"),
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Scope:        c.PostForm("scope"),
+		Code:         c.PostForm("code"),
+		RedirectURI:  c.PostForm("redirect_uri"),
+		CodeVerifier: c.PostForm("code_verifier"),
+	})
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, token)
+}
+
+// OAuthIntrospect is the RFC 7662 introspection endpoint for registered
+// clients, the api-gateway among them.
+func (h *UserHandler) OAuthIntrospect(c *gin.Context) {
+	clientID, secret := clientCredentials(c)
+
+	result, err := h.service.Introspect(clientID, secret, c.PostForm("token"))
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateOAuthClient
+// @Summary Registers an OAuth client for a service account
+// @Description The client secret is only returned in this response; store it right away
+// @Tags Service accounts
+// @Accept json
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param client body services.CreateOAuthClientInput true "Client data"
+// @Success 201 {object} services.CreatedOAuthClient "Registered client"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/oauth-clients [post]
+func (h *UserHandler) CreateOAuthClient(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	var input services.CreateOAuthClientInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	client, err := h.service.CreateOAuthClient(actor, uint(id), input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusCreated, true, client)
+}
+
+// ListOAuthClients
+// @Summary Lists the OAuth clients of a service account
+// @Tags Service accounts
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {array} services.OAuthClientResponse "Clients"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/oauth-clients [get]
+func (h *UserHandler) ListOAuthClients(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	clients, err := h.service.ListOAuthClients(uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, clients)
+}
+
+// RevokeOAuthClient
+// @Summary Revokes an OAuth client
+// @Tags Service accounts
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param clientId path string true "Client ID"
+// @Success 200 {object} map[string]interface{} "Client revoked"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/oauth-clients/{clientId} [delete]
+func (h *UserHandler) RevokeOAuthClient(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	if err := h.service.RevokeOAuthClient(actor, uint(id), c.Param("clientId")); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// loginPage is the sign-in form of the authorization endpoint. The request
+// parameters travel in hidden fields so that the POST can validate them
+// again.
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+<style>
+body{font-family:sans-serif;background:#f4f5f7;display:flex;justify-content:center;padding-top:10vh}
+form{background:#fff;padding:2em;border-radius:6px;box-shadow:0 1px 4px rgba(0,0,0,.15);width:20em}
+label{display:block;margin-top:1em}input[type=email],input[type=password],input[type=text]{width:100%;box-sizing:border-box;padding:.5em}
+button{margin-top:1.5em;width:100%;padding:.6em}.error{color:#b00020}
+</style>
+</head>
+<body>
+<form method="post" action="authorize">
+<h2>Sign in{{if .ClientName}} to {{.ClientName}}{{end}}</h2>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Fatal}}{{else}}
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<label>Email<input type="email" name="email" value="{{.Email}}" required autofocus></label>
+<label>Password<input type="password" name="password" required></label>
+{{if .AskOTP}}<label>Authentication code<input type="text" name="otp" inputmode="numeric" autocomplete="one-time-code"></label>{{end}}
+<button type="submit">Sign in</button>
+{{end}}
+</form>
+</body>
+</html>
+`))
+
+type loginPageData struct {
+	ClientName string
+	Request    services.AuthorizeRequest
+	Email      string
+	Error      string
+	AskOTP     bool
+	CSRFToken  string
+	// Fatal hides the form, for requests that cannot be completed at all.
+	Fatal bool
+}
+
+// The sign-in form is protected with a double-submit token: the form has to
+// send back the value of a cookie that another site can neither read nor,
+// being SameSite=Strict, have the browser attach to its own form.
+const (
+	csrfCookie = "oidc_csrf"
+	csrfField  = "csrf_token"
+)
+
+// csrfToken returns the token of the browser's cookie, setting a new cookie
+// when there is none.
+func csrfToken(c *gin.Context) (string, error) {
+	if token, err := c.Cookie(csrfCookie); err == nil && token != "" {
+		return token, nil
+	}
+	token, err := utils.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(csrfCookie, token, 0, c.Request.URL.Path, "", c.Request.TLS != nil, true)
+	return token, nil
+}
+
+// validCSRF tells whether the form sent back the token of the cookie.
+func validCSRF(c *gin.Context) bool {
+	cookie, err := c.Cookie(csrfCookie)
+	form := c.PostForm(csrfField)
+	return err == nil && cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(form)) == 1
+}
+
+func renderLoginPage(c *gin.Context, status int, data loginPageData) {
+	if !data.Fatal {
+		token, err := csrfToken(c)
+		if err != nil {
+			data = loginPageData{Error: "sign-in failed, please try again later", Fatal: true}
+			status = http.StatusInternalServerError
+		}
+		data.CSRFToken = token
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = loginPage.Execute(c.Writer, data)
+}
+
+// authorizeError handles an authorization request that failed validation.
+// Errors about the client or its redirect URI are shown to the user, every
+// other error is sent back to the client as RFC 6749 section 4.1.2.1 asks.
+func authorizeError(c *gin.Context, req services.AuthorizeRequest, err error) {
+	switch err.Error() {
+	case services.AuthorizeUnknownClient, services.AuthorizeInvalidRedirectURI:
+		renderLoginPage(c, http.StatusBadRequest, loginPageData{Error: err.Error(), Fatal: true})
+	default:
+		c.Redirect(http.StatusFound, services.AuthorizationRedirect(req.RedirectURI, url.Values{
+			"error": {err.Error()},
+			"state": {req.State},
+		}))
+	}
+}
+
+// loginErrorMessage tells which sign-in errors can be shown on the page.
+func loginErrorMessage(err error) (string, int) {
+	switch {
+	case errors.Is(err, services.ErrMFACodeRequired):
+		return "enter the code from your authenticator app", http.StatusOK
+	case errors.Is(err, services.ErrInvalidCredentials) || errors.Is(err, services.ErrInvalidMFACode):
+		return err.Error(), http.StatusUnauthorized
+	case errors.Is(err, services.ErrAccountLocked) || errors.Is(err, services.ErrAccountDeactivated) ||
+		errors.Is(err, services.ErrAccountPending) || errors.Is(err, services.ErrMFAEnrollmentRequired):
+		return err.Error(), http.StatusForbidden
+	default:
+		return "sign-in failed, please try again later", http.StatusInternalServerError
+	}
+}
+
+// Authorize is the OpenID Connect authorization endpoint. It shows the
+// sign-in page for a valid request.
+func (h *UserHandler) Authorize(c *gin.Context) {
+	var req services.AuthorizeRequest
+	_ = c.ShouldBindQuery(&req)
+
+	client, err := h.service.ValidateAuthorizeRequest(req)
+	if err != nil {
+		authorizeError(c, req, err)
+		return
+	}
+
+	renderLoginPage(c, http.StatusOK, loginPageData{ClientName: client.Name, Request: req})
+}
+
+// AuthorizeLogin handles the sign-in form and redirects back to the client
+// with an authorization code.
+func (h *UserHandler) AuthorizeLogin(c *gin.Context) {
+	var req services.AuthorizeRequest
+	_ = c.ShouldBind(&req)
+
+	client, err := h.service.ValidateAuthorizeRequest(req)
+	if err != nil {
+		authorizeError(c, req, err)
+		return
+	}
+
+	email := c.PostForm("email")
+	if !validCSRF(c) {
+		renderLoginPage(c, http.StatusForbidden, loginPageData{
+			ClientName: client.Name,
+			Request:    req,
+			Email:      email,
+			Error:      "the sign-in form has expired, please try again",
+		})
+		return
+	}
+
+	redirect, err := h.service.AuthorizeWithPassword(req, email, c.PostForm("password"), c.PostForm("otp"))
+	if err != nil {
+		message, status := loginErrorMessage(err)
+		renderLoginPage(c, status, loginPageData{
+			ClientName: client.Name,
+			Request:    req,
+			Email:      email,
+			Error:      message,
+			AskOTP: c.PostForm("otp") != "" || errors.Is(err, services.ErrMFACodeRequired) ||
+				errors.Is(err, services.ErrInvalidMFACode),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// UserInfo is the OpenID Connect userinfo endpoint.
+func (h *UserHandler) UserInfo(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || token == c.GetHeader("Authorization") {
+		token = c.PostForm("access_token")
+	}
+
+	info, err := h.service.UserInfo(token)
+	if err != nil {
+		if err.Error() == "invalid_token" {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+			return
+		}
+		oauthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// OpenIDConfiguration publishes the OpenID Provider Metadata.
+func (h *UserHandler) OpenIDConfiguration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.Discovery())
+}
+
+// CreateOIDCClient
+// @Summary Registers an OpenID Connect client
+// @Description Public clients get no secret. The client secret is only returned in this response; store it right away
+// @Tags OIDC
+// @Accept json
+// @Produce json
+// @Param client body services.CreateOIDCClientInput true "Client data"
+// @Success 201 {object} services.CreatedOIDCClient "Registered client"
+// @Security BearerAuth
+// @Router /admin/oidc-clients [post]
+func (h *UserHandler) CreateOIDCClient(c *gin.Context) {
+	var input services.CreateOIDCClientInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	client, err := h.service.CreateOIDCClient(input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusCreated, true, client)
+}
+
+// ListOIDCClients
+// @Summary Lists the OpenID Connect clients
+// @Tags OIDC
+// @Produce json
+// @Success 200 {array} services.OIDCClientResponse "Clients"
+// @Security BearerAuth
+// @Router /admin/oidc-clients [get]
+func (h *UserHandler) ListOIDCClients(c *gin.Context) {
+	clients, err := h.service.ListOIDCClients()
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, clients)
+}
+
+// RevokeOIDCClient
+// @Summary Revokes an OpenID Connect client
+// @Tags OIDC
+// @Produce json
+// @Param clientId path string true "Client ID"
+// @Success 200 {object} map[string]interface{} "Client revoked"
+// @Security BearerAuth
+// @Router /admin/oidc-clients/{clientId} [delete]
+func (h *UserHandler) RevokeOIDCClient(c *gin.Context) {
+	if err := h.service.RevokeOIDCClient(c.Param("clientId")); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
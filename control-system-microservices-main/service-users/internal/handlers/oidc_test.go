@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/passwords"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories/mocks"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+)
+
+const (
+	testClientID     = "cli_8899aabbccddeeff"
+	testClientSecret = "client-secret"
+	testRedirectURI  = "http://localhost:3000/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-verifier"
+)
+
+func newTestOIDCClient() *models.OAuthClient {
+	return &models.OAuthClient{
+		ID:           3,
+		ClientID:     testClientID,
+		SecretHash:   utils.HashToken(testClientSecret),
+		Name:         "grafana",
+		RedirectURIs: []string{testRedirectURI},
+	}
+}
+
+func testAuthorizeParams() url.Values {
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {testClientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"openid email"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// setupOIDCTest also returns what it takes to sign a regular API token.
+func setupOIDCTest(t *testing.T) (*gin.Engine, *utils.KeySet, *config.Config, *mocks.MockUserRepositoryInterface, *mocks.MockTokenRepositoryInterface, func()) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockUserRepositoryInterface(ctrl)
+	mockTokenRepo := mocks.NewMockTokenRepositoryInterface(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepositoryInterface(ctrl)
+	mockAuditRepo := mocks.NewMockAuditRepositoryInterface(ctrl)
+
+	cfg := &config.Config{
+		RefreshTokenSecret:       "test-refresh-secret",
+		TokenMinuteLifespan:      "5",
+		RefreshTokenHourLifespan: "24",
+		OIDCIssuer:               "http://localhost:8082",
+	}
+	key, err := utils.GenerateSigningKey("test", "RS256")
+	assert.NoError(t, err)
+	keys, err := utils.NewKeySet(key.ID, key)
+	assert.NoError(t, err)
+
+	service := services.NewUserService(mockRepo, mockTokenRepo, mockRoleRepo, mockAuditRepo, keys, mailer.NewLogMailer(""), cfg)
+	handler := NewUserHandler(service)
+
+	r := gin.New()
+	oauth := r.Group("/oauth")
+	oauth.GET("/authorize", handler.Authorize)
+	oauth.POST("/authorize", handler.AuthorizeLogin)
+	oauth.POST("/token", handler.OAuthToken)
+	oauth.GET("/userinfo", handler.UserInfo)
+	return r, keys, cfg, mockRepo, mockTokenRepo, ctrl.Finish
+}
+
+func TestOIDCHandlers_AuthorizationCodeFlow(t *testing.T) {
+	r, keys, cfg, mockRepo, mockTokenRepo, finish := setupOIDCTest(t)
+	defer finish()
+
+	hashed, err := passwords.Default().Hash("password123")
+	assert.NoError(t, err)
+	user := &models.User{
+		Model:    gorm.Model{ID: 1},
+		Email:    "test@example.com",
+		Name:     "Test User",
+		Password: hashed,
+		Roles:    []string{userroles.RoleEngineer},
+		Active:   true,
+	}
+	params := testAuthorizeParams()
+
+	var cookie *http.Cookie
+	t.Run("страница входа выдаёт CSRF-токен", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(testClientID).Return(newTestOIDCClient(), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			cookie = cookies[0]
+		}
+		assert.Equal(t, csrfCookie, cookie.Name)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+		assert.Contains(t, w.Body.String(), `name="csrf_token" value="`+cookie.Value+`"`)
+	})
+
+	t.Run("неизвестный клиент", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID("cli_unknown").Return((*models.OAuthClient)(nil), assert.AnError)
+
+		unknown := testAuthorizeParams()
+		unknown.Set("client_id", "cli_unknown")
+		req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+unknown.Encode(), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	login := func(csrfCookieValue, csrfFormValue string) *httptest.ResponseRecorder {
+		form := testAuthorizeParams()
+		form.Set("email", "test@example.com")
+		form.Set("password", "password123")
+		form.Set(csrfField, csrfFormValue)
+		req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if csrfCookieValue != "" {
+			req.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrfCookieValue})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	csrfTests := []struct {
+		name   string
+		cookie string
+		form   string
+	}{
+		{name: "вход без CSRF-cookie", form: "forged"},
+		{name: "вход с чужим CSRF-токеном", cookie: "cookie-token", form: "forged"},
+		{name: "вход без CSRF-токена в форме", cookie: "cookie-token"},
+	}
+	for _, tt := range csrfTests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTokenRepo.EXPECT().GetOAuthClientByClientID(testClientID).Return(newTestOIDCClient(), nil)
+
+			w := login(tt.cookie, tt.form)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Contains(t, w.Body.String(), "the sign-in form has expired")
+		})
+	}
+
+	var code string
+	t.Run("вход выдаёт код", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(testClientID).Return(newTestOIDCClient(), nil).Times(2)
+		mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+		mockTokenRepo.EXPECT().CreateAuthorizationCode(gomock.Any()).DoAndReturn(func(c *models.AuthorizationCode) error {
+			mockTokenRepo.EXPECT().GetAuthorizationCode(c.CodeHash).Return(c, nil)
+			return nil
+		})
+
+		w := login(cookie.Value, cookie.Value)
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+		code = location.Query().Get("code")
+		assert.NotEmpty(t, code)
+	})
+
+	var accessToken string
+	t.Run("обмен кода на токены", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(testClientID).Return(newTestOIDCClient(), nil)
+		mockTokenRepo.EXPECT().MarkAuthorizationCodeUsed(gomock.Any()).Return(true, nil)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {testCodeVerifier},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(testClientID, testClientSecret)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		var body services.OAuthTokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "Bearer", body.TokenType)
+		assert.Equal(t, "openid email", body.Scope)
+		assert.NotEmpty(t, body.IDToken)
+		accessToken = body.AccessToken
+	})
+
+	userInfo := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("userinfo отдаёт только выданные claims", func(t *testing.T) {
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(false, nil)
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(testClientID).Return(newTestOIDCClient(), nil)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(2)
+
+		w := userInfo(accessToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"sub":"1","email":"test@example.com"}`, w.Body.String())
+	})
+
+	t.Run("userinfo с токеном API", func(t *testing.T) {
+		token, err := utils.GenerateToken(*user, nil, "", keys, cfg)
+		assert.NoError(t, err)
+
+		w := userInfo(token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("userinfo после отзыва клиента", func(t *testing.T) {
+		revoked := newTestOIDCClient()
+		revokedAt := time.Now()
+		revoked.RevokedAt = &revokedAt
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(false, nil)
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(testClientID).Return(revoked, nil)
+
+		w := userInfo(accessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
+	"github.com/gin-gonic/gin"
+)
+
+var kindStatus = map[services.ErrorKind]int{
+	services.KindInvalid:      http.StatusBadRequest,
+	services.KindUnauthorized: http.StatusUnauthorized,
+	services.KindForbidden:    http.StatusForbidden,
+	services.KindNotFound:     http.StatusNotFound,
+	services.KindConflict:     http.StatusConflict,
+	services.KindLocked:       http.StatusLocked,
+}
+
+var (
+	errInternal        = services.NewError(services.KindInternal, "internal_error", "internal server error")
+	errUnauthenticated = services.NewError(services.KindUnauthorized, "unauthenticated", "missing or invalid X-User-ID")
+)
+
+// problem writes err as a problem response. Domain errors keep their code and
+// message; anything else is logged and reported as an internal error, so that
+// database and library messages never reach the client.
+func problem(c *gin.Context, err error) {
+	var domain *services.Error
+	if !errors.As(err, &domain) || domain.Kind == services.KindInternal {
+		log.Printf("%s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+		domain = errInternal
+	}
+
+	status, ok := kindStatus[domain.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	fields := make([]problems.FieldError, len(domain.Fields))
+	for i, field := range domain.Fields {
+		fields[i] = problems.FieldError(field)
+	}
+	problems.Abort(c, status, domain.Code, domain.Message, fields...)
+}
+
+// invalidParam reports a path or query parameter that could not be parsed.
+func invalidParam(field, message string) error {
+	return &services.Error{
+		Kind:    services.KindInvalid,
+		Code:    "invalid_parameter",
+		Message: message,
+		Fields:  []services.FieldError{{Field: field, Message: message}},
+	}
+}
+
+// invalidBody reports a request body that could not be bound.
+func invalidBody(err error) error {
+	code, message, fields := problems.Binding(err)
+	result := &services.Error{Kind: services.KindInvalid, Code: code, Message: message}
+	for _, field := range fields {
+		result.Fields = append(result.Fields, services.FieldError(field))
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListRoles
+// @Summary Lists roles
+// @Description Lists every role with the permissions it grants
+// @Tags Roles
+// @Produce json
+// @Success 200 {array} services.RoleResponse "Roles"
+// @Security BearerAuth
+// @Router /admin/roles [get]
+func (h *UserHandler) ListRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles()
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, roles)
+}
+
+// GetRole
+// @Summary Gets a role
+// @Tags Roles
+// @Produce json
+// @Param role path string true "Role name"
+// @Success 200 {object} services.RoleResponse "Role"
+// @Security BearerAuth
+// @Router /admin/roles/{role} [get]
+func (h *UserHandler) GetRole(c *gin.Context) {
+	role, err := h.service.GetRole(c.Param("role"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, role)
+}
+
+// CreateRole
+// @Summary Creates a role
+// @Description Role names are lowercase letters, digits, "-" and "_"; permissions must exist
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Param role body services.CreateRoleInput true "Role data"
+// @Success 201 {object} services.RoleResponse "Created role"
+// @Security BearerAuth
+// @Router /admin/roles [post]
+func (h *UserHandler) CreateRole(c *gin.Context) {
+	var input services.CreateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	role, err := h.service.CreateRole(input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusCreated, true, role)
+}
+
+// UpdateRole
+// @Summary Updates a role description
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Param role path string true "Role name"
+// @Param input body services.UpdateRoleInput true "Role data"
+// @Success 200 {object} services.RoleResponse "Updated role"
+// @Security BearerAuth
+// @Router /admin/roles/{role} [patch]
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	var input services.UpdateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	role, err := h.service.UpdateRole(c.Param("role"), input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, role)
+}
+
+// SetRolePermissions
+// @Summary Replaces the permissions of a role
+// @Description Users pick up the change when their access token is next refreshed
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Param role path string true "Role name"
+// @Param input body services.RolePermissionsInput true "Permission names"
+// @Success 200 {object} services.RoleResponse "Updated role"
+// @Security BearerAuth
+// @Router /admin/roles/{role}/permissions [put]
+func (h *UserHandler) SetRolePermissions(c *gin.Context) {
+	var input services.RolePermissionsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	role, err := h.service.SetRolePermissions(c.Param("role"), input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, role)
+}
+
+// DeleteRole
+// @Summary Deletes a role
+// @Description System roles and roles that are still assigned to users cannot be deleted
+// @Tags Roles
+// @Produce json
+// @Param role path string true "Role name"
+// @Success 200 {object} map[string]interface{} "Role deleted"
+// @Security BearerAuth
+// @Router /admin/roles/{role} [delete]
+func (h *UserHandler) DeleteRole(c *gin.Context) {
+	if err := h.service.DeleteRole(c.Param("role")); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// ListPermissions
+// @Summary Lists permissions
+// @Description The permission catalog is fixed; roles map onto it
+// @Tags Roles
+// @Produce json
+// @Success 200 {array} services.PermissionResponse "Permissions"
+// @Security BearerAuth
+// @Router /admin/permissions [get]
+func (h *UserHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.service.ListPermissions()
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, permissions)
+}
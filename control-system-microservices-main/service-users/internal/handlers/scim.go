@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scimContentType = "application/scim+json"
+	scimActorKey    = "scimActor"
+)
+
+var (
+	errMissingBearer         = services.NewError(services.KindUnauthorized, "unauthenticated", "missing bearer token")
+	errProvisioningForbidden = services.NewError(services.KindForbidden, "insufficient_privileges", "insufficient privileges for provisioning")
+)
+
+// scimResponse writes a SCIM resource. Like the OAuth endpoints, SCIM does
+// not use the success/data envelope, so that standard clients can talk to it.
+func scimResponse(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// scimError writes an RFC 7644 section 3.12 error. The status comes from the
+// error kind, as for problem responses; internal errors are logged and not
+// described to the client.
+func scimError(c *gin.Context, err error) {
+	var domain *services.Error
+	if !errors.As(err, &domain) || domain.Kind == services.KindInternal {
+		log.Printf("%s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+		domain = errInternal
+	}
+	status, ok := kindStatus[domain.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	body := gin.H{
+		"schemas": []string{services.SCIMErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  domain.Message,
+	}
+	if scimType := scimErrorType(domain); scimType != "" {
+		body["scimType"] = scimType
+	}
+	c.Header("Content-Type", scimContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// scimErrorType maps error codes to the scimType values of RFC 7644
+// section 3.12.
+func scimErrorType(err *services.Error) string {
+	switch err.Code {
+	case services.ErrEmailExists.Code:
+		return "uniqueness"
+	case services.ErrUserNameImmutable.Code:
+		return "mutability"
+	case services.ErrUnsupportedFilter.Code:
+		return "invalidFilter"
+	case services.ErrInvalidPatchPath.Code:
+		return "invalidPath"
+	case services.ErrInvalidPatchOperation.Code, "invalid_body":
+		return "invalidSyntax"
+	}
+	if err.Kind == services.KindInvalid {
+		return "invalidValue"
+	}
+	return ""
+}
+
+// SCIMAuth authenticates the provisioning client. It sends the API key of a
+// service account as a bearer token, and the account needs the users:read and
+// users:write permissions. What it may do to a user is then limited by its
+// roles, like for any admin.
+func (h *UserHandler) SCIMAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(key) == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			scimError(c, errMissingBearer)
+			return
+		}
+
+		identity, err := h.service.ResolveAPIKey(strings.TrimSpace(key))
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			}
+			scimError(c, err)
+			return
+		}
+		if !slices.Contains(identity.Permissions, models.PermissionUsersRead) ||
+			!slices.Contains(identity.Permissions, models.PermissionUsersWrite) {
+			scimError(c, errProvisioningForbidden)
+			return
+		}
+
+		c.Set(scimActorKey, services.Actor{
+			ID:        identity.UserID,
+			Roles:     identity.Roles,
+			RequestID: c.GetHeader("X-Request-ID"),
+		})
+		c.Next()
+	}
+}
+
+func scimActor(c *gin.Context) services.Actor {
+	actor, _ := c.MustGet(scimActorKey).(services.Actor)
+	return actor
+}
+
+// scimUserID reads the resource id. An id that is not a number cannot name a
+// user, so it is reported as not found.
+func scimUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		scimError(c, services.ErrUserNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// SCIMServiceProviderConfig tells clients which optional SCIM features are
+// supported.
+func (h *UserHandler) SCIMServiceProviderConfig(c *gin.Context) {
+	scimResponse(c, http.StatusOK, gin.H{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": 200},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Service account API key",
+			"description": "The API key of a service account with the users:read and users:write permissions",
+		}},
+	})
+}
+
+// SCIMListUsers lists users, optionally filtered by userName or active.
+func (h *UserHandler) SCIMListUsers(c *gin.Context) {
+	input := services.SCIMListInput{Filter: c.Query("filter"), StartIndex: 1}
+	if raw := c.Query("startIndex"); raw != "" {
+		startIndex, err := strconv.Atoi(raw)
+		if err != nil {
+			scimError(c, invalidParam("startIndex", "invalid startIndex"))
+			return
+		}
+		input.StartIndex = startIndex
+	}
+	if raw := c.Query("count"); raw != "" {
+		count, err := strconv.Atoi(raw)
+		if err != nil {
+			scimError(c, invalidParam("count", "invalid count"))
+			return
+		}
+		input.Count = &count
+	}
+
+	result, err := h.service.SCIMListUsers(input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimResponse(c, http.StatusOK, result)
+}
+
+func (h *UserHandler) SCIMGetUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.SCIMGetUser(id)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimResponse(c, http.StatusOK, user)
+}
+
+func (h *UserHandler) SCIMCreateUser(c *gin.Context) {
+	var input services.SCIMUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.SCIMCreateUser(scimActor(c), input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimResponse(c, http.StatusCreated, user)
+}
+
+func (h *UserHandler) SCIMReplaceUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+	var input services.SCIMUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.SCIMReplaceUser(scimActor(c), id, input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimResponse(c, http.StatusOK, user)
+}
+
+func (h *UserHandler) SCIMPatchUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+	var input services.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.SCIMPatchUser(scimActor(c), id, input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimResponse(c, http.StatusOK, user)
+}
+
+// SCIMDeleteUser deprovisions the user. The account is deactivated, not
+// deleted.
+func (h *UserHandler) SCIMDeleteUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.SCIMDeleteUser(scimActor(c), id); err != nil {
+		scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
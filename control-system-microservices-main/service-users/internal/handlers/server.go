@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"log"
+
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
 	"gorm.io/gorm"
 )
 
@@ -17,7 +21,18 @@ type Server struct {
 
 func NewServer(db *gorm.DB, cfg *config.Config) *Server {
 	userRepository := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepository, cfg)
+	tokenRepository := repositories.NewTokenRepository(db)
+	roleRepository := repositories.NewRoleRepository(db)
+	auditRepository := repositories.NewAuditRepository(db)
+	keys, err := utils.LoadKeySet(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	userService := services.NewUserService(userRepository, tokenRepository, roleRepository, auditRepository, keys, mail, cfg)
 	userHandler := NewUserHandler(userService)
 	return &Server{
 		db:          db,
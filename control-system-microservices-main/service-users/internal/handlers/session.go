@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMySessions
+// @Summary Lists the current user's sessions
+// @Description Every device that is signed in, most recently used first
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} services.SessionResponse "Sessions"
+// @Security BearerAuth
+// @Router /auth/me/sessions [get]
+func (h *UserHandler) ListMySessions(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(id, c.GetHeader("X-Session-ID"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, sessions)
+}
+
+// RevokeMySession
+// @Summary Signs out one of the current user's devices
+// @Tags Auth
+// @Produce json
+// @Param sessionId path int true "Session ID"
+// @Success 200 {object} map[string]interface{} "Session revoked"
+// @Security BearerAuth
+// @Router /auth/me/sessions/{sessionId} [delete]
+func (h *UserHandler) RevokeMySession(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	sessionID, err := strconv.Atoi(c.Param("sessionId"))
+	if err != nil {
+		problem(c, invalidParam("sessionId", "invalid session ID"))
+		return
+	}
+
+	if err := h.service.RevokeSession(id, uint(sessionID)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// ListUserSessions
+// @Summary Lists a user's sessions
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {array} services.SessionResponse "Sessions"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/sessions [get]
+func (h *UserHandler) ListUserSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+	if _, err := h.service.GetUserByID(uint(id)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(uint(id), "")
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, sessions)
+}
+
+// RevokeUserSession
+// @Summary Signs out one of a user's devices
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param sessionId path int true "Session ID"
+// @Success 200 {object} map[string]interface{} "Session revoked"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/sessions/{sessionId} [delete]
+func (h *UserHandler) RevokeUserSession(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+	sessionID, err := strconv.Atoi(c.Param("sessionId"))
+	if err != nil {
+		problem(c, invalidParam("sessionId", "invalid session ID"))
+		return
+	}
+
+	if err := h.service.RevokeUserSession(actor, uint(id), uint(sessionID)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/services"
+	"github.com/SpiritFoxo/control-system-microservices/shared/pagination"
+	"github.com/SpiritFoxo/control-system-microservices/shared/queryparams"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,17 +22,50 @@ func NewUserHandler(service *services.UserService) *UserHandler {
 	return &UserHandler{service: service}
 }
 
-func response(c *gin.Context, status int, success bool, data interface{}, err error) {
+// currentUserID reads the caller's ID forwarded by the api-gateway.
+func currentUserID(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 32)
+	if err != nil || id == 0 {
+		return 0, errUnauthenticated
+	}
+	return uint(id), nil
+}
+
+// currentActor reads the caller's ID, roles and request ID forwarded by the
+// api-gateway.
+func currentActor(c *gin.Context) (services.Actor, error) {
+	id, err := currentUserID(c)
 	if err != nil {
-		c.JSON(status, gin.H{
-			"success": success,
-			"error": gin.H{
-				"code":    status,
-				"message": err.Error(),
-			},
-		})
-		return
+		return services.Actor{}, err
+	}
+	var roles []string
+	for _, role := range strings.Split(c.GetHeader("X-User-Roles"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
 	}
+	return services.Actor{ID: id, Roles: roles, RequestID: c.GetHeader("X-Request-ID")}, nil
+}
+
+// clientInfo describes the caller's device. The api-gateway's reverse proxy
+// appends the address it saw to X-Forwarded-For, so only the last entry can
+// be trusted; earlier ones are whatever the client sent.
+func clientInfo(c *gin.Context) services.ClientInfo {
+	ip := c.RemoteIP()
+	if forwarded := c.GetHeader("X-Forwarded-For"); forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		if last := strings.TrimSpace(parts[len(parts)-1]); last != "" {
+			ip = last
+		}
+	}
+	userAgent := c.GetHeader("User-Agent")
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	return services.ClientInfo{UserAgent: userAgent, IP: ip}
+}
+
+func response(c *gin.Context, status int, success bool, data interface{}) {
 	c.JSON(status, gin.H{
 		"success": success,
 		"data":    data,
@@ -46,30 +84,30 @@ func response(c *gin.Context, status int, success bool, data interface{}, err er
 // @Security BearerAuth
 // @Router /admin/users/register [post]
 func (h *UserHandler) RegisterUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
 	var input services.RegisterUserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		response(c, http.StatusBadRequest, false, nil, err)
+		problem(c, invalidBody(err))
 		return
 	}
 
-	user, err := h.service.RegisterUser(input)
+	user, err := h.service.RegisterUser(actor, input)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "email already exists" || err.Error() == "invalid email format" ||
-			err.Error() == "password must be at least 8 characters" ||
-			err.Error() == "email, password, and name are required" ||
-			err.Error()[:12] == "invalid role" {
-			status = http.StatusBadRequest
-		}
-		response(c, status, false, nil, err)
+		problem(c, err)
 		return
 	}
 
-	response(c, http.StatusCreated, true, user, nil)
+	response(c, http.StatusCreated, true, user)
 }
 
 // LoginUser
 // @Summary performs login
+// @Description Returns a token pair, or an mfa_token when the account has to pass a second factor
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -80,20 +118,222 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 
 	var input services.LoginInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		response(c, http.StatusBadRequest, false, nil, err)
+		problem(c, invalidBody(err))
 		return
 	}
 
-	token, user, err := h.service.LoginUser(input.Email, input.Password)
+	result, err := h.service.LoginUser(input.Email, input.Password, clientInfo(c))
 	if err != nil {
-		response(c, http.StatusUnauthorized, false, nil, err)
+		problem(c, err)
 		return
 	}
 
-	response(c, http.StatusOK, true, gin.H{
-		"token": token,
-		"user":  user,
-	}, nil)
+	loginResponse(c, result)
+}
+
+// loginResponse answers with either the MFA challenge or the issued tokens.
+func loginResponse(c *gin.Context, result *services.LoginResult) {
+	if result.MFA != nil {
+		response(c, http.StatusOK, true, gin.H{
+			"mfa_required":        true,
+			"mfa_token":           result.MFA.MFAToken,
+			"enrollment_required": result.MFA.EnrollmentRequired,
+		})
+		return
+	}
+
+	data := gin.H{
+		"token":         result.Tokens.AccessToken,
+		"refresh_token": result.Tokens.RefreshToken,
+		"user":          result.User,
+	}
+	if len(result.RecoveryCodes) > 0 {
+		data["recovery_codes"] = result.RecoveryCodes
+	}
+	response(c, http.StatusOK, true, data)
+}
+
+// RefreshToken
+// @Summary Exchanges a refresh token for a new token pair
+// @Description Rotates the refresh token. Reusing an already rotated token revokes the whole session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token body services.RefreshTokenInput true "Refresh token"
+// @Success 200 {object} services.AuthTokens "New token pair"
+// @Router /auth/refresh [post]
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var input services.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	tokens, err := h.service.RefreshToken(input.RefreshToken, clientInfo(c))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, tokens)
+}
+
+// Logout
+// @Summary Logs out the current session
+// @Description Revokes the presented access token and, if given, the refresh token of the same session
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token body services.LogoutInput false "Refresh token to revoke"
+// @Success 200 {object} map[string]interface{} "Logged out"
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	var input services.LogoutInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	accessToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if err := h.service.Logout(accessToken, input.RefreshToken); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// GetMe
+// @Summary Gets the current user
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} services.UserResponse "Current user"
+// @Security BearerAuth
+// @Router /auth/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	user, err := h.service.GetUserByID(id)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, user)
+}
+
+// UpdateMe
+// @Summary Updates the current user's profile
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param user body services.UpdateProfileInput true "Profile data"
+// @Success 200 {object} services.UserResponse "Updated user"
+// @Security BearerAuth
+// @Router /auth/me [patch]
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	user, err := h.service.UpdateProfile(id, input)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, user)
+}
+
+// ChangePassword
+// @Summary Changes the current user's password
+// @Description Verifies the old password, stores the new one and revokes all other sessions
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param password body services.ChangePasswordInput true "Old and new password"
+// @Success 200 {object} services.AuthTokens "New token pair"
+// @Security BearerAuth
+// @Router /auth/me/password [post]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	id, err := currentUserID(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	var input services.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	tokens, err := h.service.ChangePassword(id, input, clientInfo(c), c.GetHeader("X-Request-ID"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, tokens)
+}
+
+// ForgotPassword
+// @Summary Requests a password reset email
+// @Description Always answers the same way, whether or not the account exists
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param email body services.ForgotPasswordInput true "Account email"
+// @Success 202 {object} map[string]interface{} "Request accepted"
+// @Router /auth/password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var input services.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	h.service.ForgotPassword(input.Email)
+
+	response(c, http.StatusAccepted, true, gin.H{
+		"message": "If the account exists, a password reset link has been sent",
+	})
+}
+
+// ResetPassword
+// @Summary Sets a new password using a reset token
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param reset body services.ResetPasswordInput true "Reset token and new password"
+// @Success 200 {object} map[string]interface{} "Password changed"
+// @Router /auth/password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var input services.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	if err := h.service.ResetPassword(input, c.GetHeader("X-Request-ID")); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
 }
 
 // GetUserById
@@ -110,17 +350,17 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 	idStr := c.Param("userId")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		response(c, http.StatusBadRequest, false, nil, errors.New("invalid user ID"))
+		problem(c, invalidParam("userId", "invalid user ID"))
 		return
 	}
 
 	user, err := h.service.GetUserByID(uint(id))
 	if err != nil {
-		response(c, http.StatusNotFound, false, nil, err)
+		problem(c, err)
 		return
 	}
 
-	response(c, http.StatusOK, true, user, nil)
+	response(c, http.StatusOK, true, user)
 }
 
 // UpdateUser
@@ -135,32 +375,32 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 // @Security BearerAuth
 // @Router /admin/users/{userId} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
 	idStr := c.Param("userId")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		response(c, http.StatusBadRequest, false, nil, errors.New("invalid user ID"))
+		problem(c, invalidParam("userId", "invalid user ID"))
 		return
 	}
 
 	var input services.EditUserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		response(c, http.StatusBadRequest, false, nil, err)
+		problem(c, invalidBody(err))
 		return
 	}
 
-	user, err := h.service.UpdateUser(uint(id), input)
+	user, err := h.service.UpdateUser(actor, uint(id), input)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "user not found" {
-			status = http.StatusNotFound
-		} else if err.Error()[:12] == "invalid role" {
-			status = http.StatusBadRequest
-		}
-		response(c, status, false, nil, err)
+		problem(c, err)
 		return
 	}
 
-	response(c, http.StatusOK, true, user, nil)
+	response(c, http.StatusOK, true, user)
 }
 
 // GetUsers
@@ -172,6 +412,19 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Param page query int false "Page number"
 // @Param limit query int false "Number of records per page"
 // @Param email query string false "Email filter"
+// @Param name query string false "Name filter, case-insensitive"
+// @Param role query []string false "Roles, repeated or comma-separated" collectionFormat(multi)
+// @Param role_match query string false "any (default) or all of the roles"
+// @Param active query bool false "Active flag; pending users count as active"
+// @Param state query string false "active, inactive, pending, deleted or all; deleted users are hidden by default"
+// @Param created_from query string false "Created on or after, RFC 3339 or YYYY-MM-DD"
+// @Param created_to query string false "Created on or before, RFC 3339 or YYYY-MM-DD"
+// @Param updated_from query string false "Updated on or after, RFC 3339 or YYYY-MM-DD"
+// @Param updated_to query string false "Updated on or before, RFC 3339 or YYYY-MM-DD"
+// @Param sort query string false "name, email or created_at; by ID when omitted"
+// @Param order query string false "asc (default) or desc"
+// @Param cursor query string false "Switches to keyset pagination: empty for the first page, then the next_cursor of the previous one. page is ignored"
+// @Param include_total query bool false "Count the matching users in keyset pagination"
 // @Success 200 {object} services.UserListResult "List of users"
 // @Security BearerAuth
 // @Router /admin/users [get]
@@ -179,17 +432,17 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 	pageStr := c.DefaultQuery("page", "1")
 	limitStr := c.DefaultQuery("limit", "10")
 	emailFilter := c.Query("email")
-	roleFilter := c.Query("role")
+	stateFilter := c.Query("state")
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil {
-		response(c, http.StatusBadRequest, false, nil, errors.New("invalid page number"))
+		problem(c, invalidParam("page", "invalid page number"))
 		return
 	}
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		response(c, http.StatusBadRequest, false, nil, errors.New("invalid limit value"))
+		problem(c, invalidParam("limit", "invalid limit value"))
 		return
 	}
 
@@ -197,12 +450,59 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		Page:        page,
 		Limit:       limit,
 		EmailFilter: emailFilter,
-		RoleFilter:  roleFilter,
+		StateFilter: stateFilter,
+		NameFilter:  c.Query("name"),
+		RolesFilter: queryparams.List(c, "role"),
+		RoleMatch:   c.Query("role_match"),
+		SortBy:      c.Query("sort"),
+		SortOrder:   c.Query("order"),
+	}
+	if raw := c.Query("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			problem(c, invalidParam("active", "invalid active filter"))
+			return
+		}
+		input.ActiveFilter = &active
+	}
+	for _, bound := range []struct {
+		param  string
+		target **time.Time
+	}{
+		{"created_from", &input.CreatedFrom},
+		{"created_to", &input.CreatedTo},
+		{"updated_from", &input.UpdatedFrom},
+		{"updated_to", &input.UpdatedTo},
+	} {
+		value, err := queryparams.Time(c, bound.param, strings.HasSuffix(bound.param, "_to"))
+		if err != nil {
+			problem(c, invalidParam(bound.param, "invalid "+bound.param))
+			return
+		}
+		*bound.target = value
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		input.Cursor = cursor
+		if input.IncludeTotal, err = queryparams.Bool(c, "include_total"); err != nil {
+			problem(c, invalidParam("include_total", "invalid include_total"))
+			return
+		}
+		result, err := h.service.GetUsersByCursor(input)
+		if err != nil {
+			problem(c, err)
+			return
+		}
+		response(c, http.StatusOK, true, gin.H{
+			"users":      result.Users,
+			"pagination": pagination.Response(result.Limit, result.NextCursor, result.Total),
+		})
+		return
 	}
 
 	result, err := h.service.GetUsers(input)
 	if err != nil {
-		response(c, http.StatusInternalServerError, false, nil, err)
+		problem(c, err)
 		return
 	}
 
@@ -214,5 +514,278 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 			"total":      result.Total,
 			"totalPages": result.TotalPages,
 		},
-	}, nil)
+	})
+}
+
+// RevokeUserSessions
+// @Summary Revokes all sessions of a user
+// @Description Invalidates every access and refresh token issued to the user
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Sessions revoked"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/revoke-sessions [post]
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	if err := h.service.RevokeAllSessions(actor, uint(id)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// LookupUsers is consulted by service-orders to check and describe the users
+// that orders refer to. Unknown IDs are simply missing from the result.
+func (h *UserHandler) LookupUsers(c *gin.Context) {
+	var ids []uint
+	for _, raw := range queryparams.List(c, "ids") {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || id == 0 {
+			problem(c, invalidParam("ids", "invalid user ID: "+raw))
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+
+	users, err := h.service.LookupUsers(ids)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, users)
+}
+
+// TokenStatus is consulted by the api-gateway for every authenticated request.
+func (h *UserHandler) TokenStatus(c *gin.Context) {
+	jti := c.Query("jti")
+	userID, err := strconv.Atoi(c.Query("user_id"))
+	if err != nil || jti == "" {
+		problem(c, &services.Error{
+			Kind:    services.KindInvalid,
+			Code:    "required",
+			Message: "jti and user_id are required",
+			Fields: []services.FieldError{
+				{Field: "jti", Message: "is required"},
+				{Field: "user_id", Message: "is required"},
+			},
+		})
+		return
+	}
+	version, err := strconv.Atoi(c.DefaultQuery("version", "0"))
+	if err != nil {
+		problem(c, invalidParam("version", "invalid version"))
+		return
+	}
+
+	revoked, err := h.service.IsAccessTokenRevoked(jti, uint(userID), version, c.Query("sid"), c.Query("cid"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, gin.H{"revoked": revoked})
+}
+
+// JWKS publishes the public halves of the signing keys so that the gateway and
+// other services can verify access tokens without sharing a secret.
+func (h *UserHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+// GetUserLockStatus
+// @Summary Gets the login lock state of a user
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} services.LockStatus "Lock state"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/lock [get]
+func (h *UserHandler) GetUserLockStatus(c *gin.Context) {
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	status, err := h.service.GetLockStatus(uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, status)
+}
+
+// UnlockUser
+// @Summary Unlocks a user locked out after failed logins
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} services.LockStatus "Lock state"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	status, err := h.service.UnlockUser(actor, uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, status)
+}
+
+// DeactivateUser
+// @Summary Deactivates a user
+// @Description The user can no longer log in and all of their tokens are revoked
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} services.UserResponse "User data"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/deactivate [post]
+func (h *UserHandler) DeactivateUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	user, err := h.service.DeactivateUser(actor, uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, user)
+}
+
+// ActivateUser
+// @Summary Activates a previously deactivated user
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} services.UserResponse "User data"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/activate [post]
+func (h *UserHandler) ActivateUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	user, err := h.service.ActivateUser(actor, uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, user)
+}
+
+// DeleteUser
+// @Summary Deletes a user
+// @Description Soft-deletes the user and revokes all of their tokens. The user can be restored later.
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]interface{} "User deleted"
+// @Security BearerAuth
+// @Router /admin/users/{userId} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	if err := h.service.DeleteUser(actor, uint(id)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, nil)
+}
+
+// RestoreUser
+// @Summary Restores a deleted user
+// @Description Fails with 409 email_exists when another user has taken the email since the deletion
+// @Tags Users
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} services.UserResponse "User data"
+// @Security BearerAuth
+// @Router /admin/users/{userId}/restore [post]
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	actor, err := currentActor(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	idStr := c.Param("userId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem(c, invalidParam("userId", "invalid user ID"))
+		return
+	}
+
+	user, err := h.service.RestoreUser(actor, uint(id))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	response(c, http.StatusOK, true, user)
 }
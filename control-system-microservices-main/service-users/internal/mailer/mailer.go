@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"fmt"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as password reset links.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New picks the implementation configured by MAIL_DRIVER.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "", "log":
+		return NewLogMailer(cfg.MailLogFile), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER: %s", cfg.MailDriver)
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey authenticates a service account. Only the SHA-256 hash of the key is
+// stored; Prefix is the public part of the key and is used to look it up.
+type APIKey struct {
+	ID         uint           `gorm:"primarykey"`
+	UserID     uint           `gorm:"index;not null"`
+	Name       string         `gorm:"not null"`
+	Prefix     string         `gorm:"uniqueIndex;not null"`
+	KeyHash    string         `gorm:"not null"`
+	Scopes     pq.StringArray `gorm:"type:text[];default:'{}'"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditEntry records one administrative change to a user. Entries are only
+// ever inserted; the repository exposes no way to edit or remove them.
+type AuditEntry struct {
+	ID uint `gorm:"primarykey"`
+	// ActorID is the user who made the change. For a password reset it is the
+	// account owner, who proved their identity with the emailed token.
+	ActorID      uint         `gorm:"index;not null"`
+	TargetUserID uint         `gorm:"index;not null"`
+	Action       string       `gorm:"index;not null"`
+	Changes      AuditChanges `gorm:"type:jsonb;not null;default:'{}'"`
+	RequestID    string       `gorm:"not null;default:''"`
+	CreatedAt    time.Time    `gorm:"index"`
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps a field name to its value before and after the change.
+type AuditChanges map[string]FieldChange
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *AuditChanges) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*c = AuditChanges{}
+		return nil
+	default:
+		return errors.New("unsupported type for AuditChanges")
+	}
+	return json.Unmarshal(data, c)
+}
@@ -17,7 +17,17 @@ func Setup(cfg *config.Config) (*gorm.DB, error) {
 		log.Fatal("Can not connect to the database:", err)
 	}
 
-	if err := db.AutoMigrate(&User{}); err != nil {
+	if err := db.AutoMigrate(&User{}, &RefreshToken{}, &RevokedToken{}, &PasswordResetToken{}, &RecoveryCode{}, &Invitation{}, &MFAChallenge{},
+		&Role{}, &Permission{}, &AuditEntry{}, &Session{}, &APIKey{}, &OAuthClient{}, &AuthorizationCode{}); err != nil {
+		return nil, err
+	}
+
+	if err := dropPlainEmailIndexes(db); err != nil {
+		return nil, err
+	}
+	createSearchIndexes(db)
+
+	if err := seedRoles(db); err != nil {
 		return nil, err
 	}
 
@@ -45,3 +55,93 @@ func Setup(cfg *config.Config) (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// dropPlainEmailIndexes removes the unique constraint and index that older
+// versions put on users.email. They also covered deleted users, which the
+// partial index idx_users_email_active now leaves out.
+func dropPlainEmailIndexes(db *gorm.DB) error {
+	statements := []string{
+		"ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email",
+		"DROP INDEX IF EXISTS idx_users_email",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSearchIndexes adds the indexes of the admin user search and its
+// keyset pagination that gorm tags can't describe. Without them search still
+// works, only slower, so a failure is logged rather than fatal.
+func createSearchIndexes(db *gorm.DB) {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING gin (LOWER(name) gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_users_roles ON users USING gin (roles)",
+		"CREATE INDEX IF NOT EXISTS idx_users_name_id ON users (LOWER(name), id)",
+		"CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at, id)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("Failed to create user search index: %v", err)
+			return
+		}
+	}
+}
+
+// seedRoles adds missing permissions and built-in roles. Roles that already
+// exist are left alone so that permission changes made by admins survive
+// restarts; only a permission that did not exist before is granted to the
+// built-in roles that have it by default, so that routes which start to
+// require it keep working for them.
+func seedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		byName := make(map[string]Permission, len(DefaultPermissions))
+		added := make(map[string]bool)
+		for _, p := range DefaultPermissions {
+			permission := p
+			result := tx.Where(Permission{Name: p.Name}).Attrs(Permission{Description: p.Description}).
+				FirstOrCreate(&permission)
+			if result.Error != nil {
+				return result.Error
+			}
+			byName[permission.Name] = permission
+			added[permission.Name] = result.RowsAffected > 0
+		}
+
+		for name, permissionNames := range DefaultRolePermissions {
+			var role Role
+			err := tx.Where("name = ?", name).First(&role).Error
+			if err == nil {
+				var grant []Permission
+				for _, permissionName := range permissionNames {
+					if added[permissionName] {
+						grant = append(grant, byName[permissionName])
+					}
+				}
+				if len(grant) > 0 {
+					if err := tx.Model(&role).Association("Permissions").Append(grant); err != nil {
+						return err
+					}
+					log.Printf("Granted new permissions to role %s", name)
+				}
+				continue
+			}
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+
+			role = Role{Name: name, System: true}
+			for _, permissionName := range permissionNames {
+				role.Permissions = append(role.Permissions, byName[permissionName])
+			}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+			log.Printf("Seeded role %s", name)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OAuthClient is a registered OAuth client. Only the SHA-256 hash of the
+// client secret is stored.
+//
+// A client either belongs to a service account (UserID) and uses the
+// client_credentials grant, or is an OpenID Connect login client with
+// RedirectURIs and a zero UserID, which signs users in with the
+// authorization code flow.
+type OAuthClient struct {
+	ID         uint           `gorm:"primarykey"`
+	ClientID   string         `gorm:"uniqueIndex;not null"`
+	SecretHash string         `gorm:"not null"`
+	Name       string         `gorm:"not null"`
+	UserID     uint           `gorm:"index;not null"`
+	Scopes     pq.StringArray `gorm:"type:text[];default:'{}'"`
+	// RedirectURIs are matched exactly against the redirect_uri of an
+	// authorization request.
+	RedirectURIs pq.StringArray `gorm:"type:text[];default:'{}'"`
+	// Public clients, such as single-page apps, cannot keep a secret and
+	// rely on PKCE alone.
+	Public    bool `gorm:"not null;default:false"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// IsOIDC reports whether the client signs users in with the authorization
+// code flow.
+func (c *OAuthClient) IsOIDC() bool {
+	return len(c.RedirectURIs) > 0
+}
+
+// AuthorizationCode is a single-use code of the authorization code flow.
+// Only its SHA-256 hash is stored, together with the PKCE challenge the code
+// has to be redeemed with.
+type AuthorizationCode struct {
+	ID            uint   `gorm:"primarykey"`
+	CodeHash      string `gorm:"uniqueIndex;not null"`
+	ClientID      string `gorm:"index;not null"`
+	UserID        uint   `gorm:"index;not null"`
+	RedirectURI   string `gorm:"not null"`
+	Scope         string `gorm:"not null;default:''"`
+	Nonce         string `gorm:"not null;default:''"`
+	CodeChallenge string `gorm:"not null"`
+	// AuthTime is when the user entered their credentials.
+	AuthTime  time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/shared/permissions"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+)
+
+// Role is referenced from User.Roles by name, so the name never changes once
+// the role exists.
+type Role struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string `gorm:"not null;default:''"`
+	// System roles are seeded at startup and cannot be deleted; their
+	// permissions can still be edited.
+	System      bool         `gorm:"not null;default:false"`
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Permission names are checked by the services, so the catalog is defined in
+// code and seeded; only the role mappings are editable at runtime.
+type Permission struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string `gorm:"not null;default:''"`
+}
+
+// The permission names live in shared/permissions so that every service
+// checks the same ones.
+const (
+	PermissionUsersRead          = permissions.UsersRead
+	PermissionUsersWrite         = permissions.UsersWrite
+	PermissionRolesManage        = permissions.RolesManage
+	PermissionClientsManage      = permissions.ClientsManage
+	PermissionAuditRead          = permissions.AuditRead
+	PermissionOrdersRead         = permissions.OrdersRead
+	PermissionOrdersReadAll      = permissions.OrdersReadAll
+	PermissionOrdersCreate       = permissions.OrdersCreate
+	PermissionOrdersUpdateStatus = permissions.OrdersUpdateStatus
+	PermissionOrdersCancel       = permissions.OrdersCancel
+	PermissionOrdersCancelAll    = permissions.OrdersCancelAll
+	PermissionOrdersDelete       = permissions.OrdersDelete
+)
+
+var DefaultPermissions = []Permission{
+	{Name: PermissionUsersRead, Description: "View users"},
+	{Name: PermissionUsersWrite, Description: "Create, edit and deactivate users"},
+	{Name: PermissionRolesManage, Description: "Manage roles and their permissions"},
+	{Name: PermissionClientsManage, Description: "Register and revoke OpenID Connect clients"},
+	{Name: PermissionAuditRead, Description: "View the audit log"},
+	{Name: PermissionOrdersRead, Description: "View own orders"},
+	{Name: PermissionOrdersReadAll, Description: "View the orders of every user"},
+	{Name: PermissionOrdersCreate, Description: "Create orders"},
+	{Name: PermissionOrdersUpdateStatus, Description: "Change order status"},
+	{Name: PermissionOrdersCancel, Description: "Cancel own orders"},
+	{Name: PermissionOrdersCancelAll, Description: "Cancel the orders of every user"},
+	{Name: PermissionOrdersDelete, Description: "Delete orders"},
+}
+
+// DefaultRolePermissions mirrors the access the built-in roles had before
+// roles moved into the database, so existing users keep the same rights.
+var DefaultRolePermissions = map[string][]string{
+	userroles.RoleEngineer: {PermissionOrdersRead, PermissionOrdersCreate, PermissionOrdersCancel},
+	userroles.RoleObserver: {PermissionOrdersRead, PermissionOrdersCreate},
+	userroles.RoleManager: {PermissionOrdersRead, PermissionOrdersReadAll, PermissionOrdersUpdateStatus,
+		PermissionOrdersCancel, PermissionOrdersCancelAll, PermissionOrdersDelete},
+	userroles.RoleAdmin: {PermissionUsersRead, PermissionUsersWrite, PermissionClientsManage, PermissionAuditRead,
+		PermissionOrdersRead, PermissionOrdersReadAll, PermissionOrdersCreate, PermissionOrdersUpdateStatus,
+		PermissionOrdersCancel, PermissionOrdersCancelAll, PermissionOrdersDelete},
+	userroles.RoleSuperadmin: {PermissionUsersRead, PermissionUsersWrite, PermissionRolesManage,
+		PermissionClientsManage, PermissionAuditRead, PermissionOrdersRead, PermissionOrdersReadAll,
+		PermissionOrdersCreate, PermissionOrdersUpdateStatus, PermissionOrdersCancel, PermissionOrdersCancelAll,
+		PermissionOrdersDelete},
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// RefreshToken is a single issued refresh token. Tokens obtained from the same
+// login share a FamilyID; every refresh rotates the token inside its family.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	FamilyID  string    `gorm:"index;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken is a denylisted access token. Rows are only needed until the
+// token would have expired on its own.
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// PasswordResetToken stores only the SHA-256 hash of the emailed token.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RecoveryCode is a hashed single-use MFA fallback code.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Invitation tracks an emailed invite link. The link carries a signed token
+// whose JTI is stored here, so resending or revoking an invite can invalidate
+// links that were already sent.
+type Invitation struct {
+	ID         uint      `gorm:"primarykey"`
+	UserID     uint      `gorm:"index;not null"`
+	JTI        string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	AcceptedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// MFAChallenge records an issued MFA challenge token by its JTI. A challenge
+// can start TOTP enrollment once and complete a login once.
+type MFAChallenge struct {
+	ID         uint      `gorm:"primarykey"`
+	UserID     uint      `gorm:"index;not null"`
+	JTI        string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	EnrolledAt *time.Time
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+}
+
+// Session is one signed-in device. It owns a refresh token family, and the
+// family ID travels in the sid claim of every access token issued for it.
+type Session struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	FamilyID  string `gorm:"uniqueIndex;not null"`
+	UserAgent string `gorm:"not null;default:''"`
+	// IP is the client address seen by the api-gateway on the latest login
+	// or refresh.
+	IP         string    `gorm:"not null;default:''"`
+	LastUsedAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
@@ -1,34 +1,88 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/passwords"
 	"github.com/lib/pq"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Email    string         `gorm:"unique;uniqueIndex;not null"`
+	// Email is unique among users that are not deleted, so the address of a
+	// deleted user can be used again.
+	Email    string         `gorm:"not null;uniqueIndex:idx_users_email_active,where:deleted_at IS NULL"`
 	Password string         `gorm:"not null"`
 	Name     string         `gorm:"not null"`
 	Roles    pq.StringArray `gorm:"type:text[];default:'{}'"`
+	Phone    string         `gorm:"not null;default:''"`
+	Position string         `gorm:"not null;default:''"`
+	// Active users can log in. Deactivation keeps the account visible to
+	// admins, unlike deletion, which soft-deletes the row.
+	Active bool `gorm:"not null;default:true"`
+	// Pending users were invited but have not set a password yet.
+	Pending bool `gorm:"not null;default:false"`
+	// ServiceAccount users have no password and authenticate with API keys.
+	ServiceAccount bool `gorm:"not null;default:false"`
+	// TokenVersion is embedded in every access token; bumping it invalidates
+	// all tokens issued to the user before the bump.
+	TokenVersion int `gorm:"not null;default:0"`
+
+	FailedLoginAttempts int `gorm:"not null;default:0"`
+	// LockoutCount is the number of consecutive lockouts; each one doubles
+	// the next lock duration until a successful login resets it.
+	LockoutCount int `gorm:"not null;default:0"`
+	LockedUntil  *time.Time
+
+	// TOTPSecret is set as soon as enrollment starts; TOTPEnabled flips only
+	// after the user proves they can generate codes.
+	TOTPSecret       string `gorm:"not null;default:''"`
+	TOTPEnabled      bool   `gorm:"not null;default:false"`
+	TOTPLastUsedStep int64  `gorm:"not null;default:0"`
+}
+
+func (user *User) IsLocked() bool {
+	return user.LockedUntil != nil && time.Now().Before(*user.LockedUntil)
+}
+
+func (user *User) HasRole(role string) bool {
+	for _, r := range user.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
 func (user *User) HashPassword() error {
 	user.Password = strings.TrimSpace(user.Password)
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := passwords.Default().Hash(user.Password)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %v", err)
 	}
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 	return nil
 
 }
 
 func (user *User) VerifyPassword(password string) error {
 	password = strings.TrimSpace(password)
-	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	ok, err := passwords.Default().Verify(user.Password, password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("password mismatch")
+	}
+	return nil
+}
+
+// PasswordNeedsRehash reports whether the stored hash uses an older algorithm
+// or weaker parameters than currently configured.
+func (user *User) PasswordNeedsRehash() bool {
+	return passwords.Default().NeedsRehash(user.Password)
 }
@@ -0,0 +1,134 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher is one password hashing algorithm. Hashes are self-describing, so a
+// hasher can tell its own hashes apart and read the parameters they were
+// made with.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) (bool, error)
+	// Recognizes reports whether encoded was produced by this algorithm.
+	Recognizes(encoded string) bool
+	// NeedsRehash reports whether encoded uses weaker parameters than the
+	// hasher is configured with.
+	NeedsRehash(encoded string) bool
+}
+
+// Policy hashes new passwords with the preferred hasher while still verifying
+// hashes made by any other known algorithm, so stored hashes can be upgraded
+// one login at a time.
+type Policy struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+func NewPolicy(preferred Hasher, others ...Hasher) *Policy {
+	return &Policy{preferred: preferred, hashers: append([]Hasher{preferred}, others...)}
+}
+
+func (p *Policy) Hash(password string) (string, error) {
+	return p.preferred.Hash(password)
+}
+
+func (p *Policy) Verify(encoded, password string) (bool, error) {
+	for _, h := range p.hashers {
+		if h.Recognizes(encoded) {
+			return h.Verify(encoded, password)
+		}
+	}
+	return false, ErrUnknownHash
+}
+
+// NeedsRehash reports whether encoded should be replaced by a fresh hash of
+// the same password, either because it uses another algorithm or because the
+// preferred one has since been tuned up.
+func (p *Policy) NeedsRehash(encoded string) bool {
+	if !p.preferred.Recognizes(encoded) {
+		return true
+	}
+	return p.preferred.NeedsRehash(encoded)
+}
+
+// New builds the policy selected by PASSWORD_HASHER. Both algorithms remain
+// available for verification whichever one is preferred.
+func New(cfg *config.Config) (*Policy, error) {
+	params := DefaultArgon2Params
+	var err error
+	if params.Memory, err = uintSetting("ARGON2_MEMORY_KB", cfg.Argon2MemoryKB, params.Memory); err != nil {
+		return nil, err
+	}
+	if params.Iterations, err = uintSetting("ARGON2_ITERATIONS", cfg.Argon2Iterations, params.Iterations); err != nil {
+		return nil, err
+	}
+	parallelism, err := uintSetting("ARGON2_PARALLELISM", cfg.Argon2Parallelism, uint32(params.Parallelism))
+	if err != nil {
+		return nil, err
+	}
+	if parallelism > 255 {
+		return nil, fmt.Errorf("invalid ARGON2_PARALLELISM: %d", parallelism)
+	}
+	params.Parallelism = uint8(parallelism)
+
+	cost, err := uintSetting("BCRYPT_COST", cfg.BcryptCost, uint32(bcrypt.DefaultCost))
+	if err != nil {
+		return nil, err
+	}
+	if int(cost) < bcrypt.MinCost || int(cost) > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid BCRYPT_COST: %d", cost)
+	}
+
+	argon := NewArgon2idHasher(params)
+	bcryptHasher := NewBcryptHasher(int(cost))
+
+	switch strings.ToLower(cfg.PasswordHasher) {
+	case "", "argon2id":
+		return NewPolicy(argon, bcryptHasher), nil
+	case "bcrypt":
+		return NewPolicy(bcryptHasher, argon), nil
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASHER: %s", cfg.PasswordHasher)
+	}
+}
+
+func uintSetting(name, value string, fallback uint32) (uint32, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, value)
+	}
+	return uint32(n), nil
+}
+
+var (
+	mu            sync.RWMutex
+	defaultPolicy = NewPolicy(NewArgon2idHasher(DefaultArgon2Params), NewBcryptHasher(bcrypt.DefaultCost))
+)
+
+// Default returns the policy used by models.User. It is replaced at startup
+// with the configured one.
+func Default() *Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultPolicy
+}
+
+func SetDefault(p *Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultPolicy = p
+}
@@ -0,0 +1,106 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2Params)
+
+	encoded, err := h.Hash("password123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, h.Recognizes(encoded))
+	assert.False(t, h.NeedsRehash(encoded))
+
+	ok, err := h.Verify(encoded, "password123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify(encoded, "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	assert.True(t, NewArgon2idHasher(stronger).NeedsRehash(encoded))
+
+	_, err = h.Verify("$argon2id$v=19$broken", "password123")
+	assert.Error(t, err)
+}
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy(NewArgon2idHasher(testArgon2Params), NewBcryptHasher(bcrypt.MinCost))
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		encoded     string
+		password    string
+		wantMatch   bool
+		wantRehash  bool
+		expectedErr error
+	}{
+		{
+			name:       "устаревший bcrypt хэш",
+			encoded:    string(legacy),
+			password:   "password123",
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:       "неверный пароль для bcrypt",
+			encoded:    string(legacy),
+			password:   "wrong",
+			wantRehash: true,
+		},
+		{
+			name:        "неизвестный формат",
+			encoded:     "plaintext",
+			password:    "plaintext",
+			wantRehash:  true,
+			expectedErr: ErrUnknownHash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := policy.Verify(tt.encoded, tt.password)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantMatch, ok)
+			assert.Equal(t, tt.wantRehash, policy.NeedsRehash(tt.encoded))
+		})
+	}
+
+	fresh, err := policy.Hash("password123")
+	assert.NoError(t, err)
+	assert.False(t, policy.NeedsRehash(fresh))
+}
+
+func TestNew(t *testing.T) {
+	policy, err := New(&config.Config{PasswordHasher: "bcrypt", BcryptCost: "4"})
+	assert.NoError(t, err)
+
+	encoded, err := policy.Hash("password123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$2a$04$"))
+
+	_, err = New(&config.Config{PasswordHasher: "md5"})
+	assert.EqualError(t, err, "unknown PASSWORD_HASHER: md5")
+
+	_, err = New(&config.Config{Argon2Iterations: "zero"})
+	assert.Error(t, err)
+}
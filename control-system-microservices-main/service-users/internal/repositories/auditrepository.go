@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// createAuditEntry writes entry inside the transaction of the change it
+// records. A nil entry means the change is not audited.
+func createAuditEntry(tx *gorm.DB, entry *models.AuditEntry) error {
+	if entry == nil {
+		return nil
+	}
+	return tx.Create(entry).Error
+}
+
+// GetAuditEntries returns the newest entries first.
+func (r *AuditRepository) GetAuditEntries(page, limit int, filter AuditFilter) ([]models.AuditEntry, int64, error) {
+	var entries []models.AuditEntry
+	var total int64
+
+	query := r.db.Model(&models.AuditEntry{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetUserID != 0 {
+		query = query.Where("target_user_id = ?", filter.TargetUserID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %v", err)
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch audit entries: %v", err)
+	}
+
+	return entries, total, nil
+}
@@ -1,11 +1,161 @@
 package repositories
 
-import "github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+import (
+	"errors"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+)
+
+// ErrLastSuperadmin is returned by guarded updates and deletes that would
+// leave no active superadmin.
+var ErrLastSuperadmin = errors.New("last active superadmin")
+
+// User states accepted by UserFilter.State. An empty state lists every user
+// that has not been deleted.
+const (
+	UserStateActive   = "active"
+	UserStateInactive = "inactive"
+	UserStatePending  = "pending"
+	UserStateDeleted  = "deleted"
+	UserStateAll      = "all"
+)
+
+// Role matching modes for UserFilter.Roles.
+const (
+	RoleMatchAny = "any"
+	RoleMatchAll = "all"
+)
+
+type UserFilter struct {
+	Email string
+	Role  string
+	State string
+	// ExactEmail matches the whole address, unlike Email, which matches any
+	// part of it.
+	ExactEmail string
+	// Active filters on the active flag alone; pending users count as active.
+	Active *bool
+	// Name matches any part of the name, ignoring case.
+	Name string
+	// Roles matches users with any of the roles, or with all of them when
+	// RoleMatch is RoleMatchAll.
+	Roles     []string
+	RoleMatch string
+	// Date ranges are inclusive; nil leaves that side open.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+}
+
+// Sort fields accepted by UserSort.Field. Ties, and an empty field, are
+// ordered by ID.
+const (
+	UserSortName      = "name"
+	UserSortEmail     = "email"
+	UserSortCreatedAt = "created_at"
+)
+
+type UserSort struct {
+	Field string
+	Desc  bool
+}
+
+// UserCursor is the position a keyset page starts after: the sort key and ID
+// of the last user of the previous page. Value is a string when sorting by
+// name or email, a time.Time for created_at and unused when sorting by ID.
+type UserCursor struct {
+	ID    uint
+	Value interface{}
+}
 
 type UserRepositoryInterface interface {
 	GetUserByEmail(email string) (*models.User, error)
 	GetUserByID(id uint) (*models.User, error)
-	CreateUser(user *models.User) error
+	GetUsersByIDs(ids []uint) ([]models.User, error)
+	CreateUser(user *models.User, entry *models.AuditEntry) error
 	UpdateUser(user *models.User, updates map[string]interface{}) error
-	GetUsers(page, limit int, emailFilter, roleFilter string) ([]models.User, int64, error)
+	UpdateUserAudited(user *models.User, updates map[string]interface{}, entry *models.AuditEntry, keepSuperadmin bool) error
+	IncrementTokenVersion(user *models.User) error
+	IncrementFailedLoginAttempts(user *models.User) error
+	DeleteUser(user *models.User, entry *models.AuditEntry, keepSuperadmin bool) error
+	GetDeletedUserByID(id uint) (*models.User, error)
+	RestoreUser(user *models.User, entry *models.AuditEntry) error
+	GetUsers(offset, limit int, filter UserFilter, sort UserSort) ([]models.User, int64, error)
+	GetUsersAfter(after *UserCursor, limit int, filter UserFilter, sort UserSort) ([]models.User, error)
+	CountUsers(filter UserFilter) (int64, error)
+	EachUserBatch(filter UserFilter, batchSize int, fn func(users []models.User) error) error
+	FindExistingEmails(emails []string) ([]string, error)
+	CreateUsers(users []models.User) error
+}
+
+type TokenRepositoryInterface interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	GetRefreshTokenByJTI(jti string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(token *models.RefreshToken) (bool, error)
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeUserRefreshTokens(userID uint) error
+	RevokeAccessToken(token *models.RevokedToken) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	CreatePasswordResetToken(token *models.PasswordResetToken, since time.Time) (bool, error)
+	GetPasswordResetTokenByHash(tokenHash string) (*models.PasswordResetToken, error)
+	MarkPasswordResetTokenUsed(token *models.PasswordResetToken) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CreateInvitation(invitation *models.Invitation) error
+	GetInvitationByJTI(jti string) (*models.Invitation, error)
+	AcceptInvitation(invitation *models.Invitation, user *models.User, updates map[string]interface{}) (bool, error)
+	RevokeUserInvitations(userID uint) error
+	CreateMFAChallenge(challenge *models.MFAChallenge) error
+	GetMFAChallengeByJTI(jti string) (*models.MFAChallenge, error)
+	MarkMFAChallengeEnrolled(challenge *models.MFAChallenge) (bool, error)
+	MarkMFAChallengeUsed(challenge *models.MFAChallenge) (bool, error)
+	CreateSession(session *models.Session) error
+	GetSessionByID(id uint) (*models.Session, error)
+	GetSessionByFamilyID(familyID string) (*models.Session, error)
+	TouchSession(session *models.Session) error
+	GetUserSessions(userID uint) ([]models.Session, error)
+	RevokeSession(session *models.Session) error
+	CreateAPIKey(key *models.APIKey, entry *models.AuditEntry) error
+	GetAPIKeyByID(id uint) (*models.APIKey, error)
+	GetAPIKeyByPrefix(prefix string) (*models.APIKey, error)
+	GetUserAPIKeys(userID uint) ([]models.APIKey, error)
+	RevokeAPIKey(key *models.APIKey, entry *models.AuditEntry) error
+	TouchAPIKey(key *models.APIKey, usedAt time.Time) error
+	CreateOAuthClient(client *models.OAuthClient, entry *models.AuditEntry) error
+	GetOAuthClientByClientID(clientID string) (*models.OAuthClient, error)
+	GetUserOAuthClients(userID uint) ([]models.OAuthClient, error)
+	RevokeOAuthClient(client *models.OAuthClient, entry *models.AuditEntry) error
+	GetOIDCClients() ([]models.OAuthClient, error)
+	CreateAuthorizationCode(code *models.AuthorizationCode) error
+	GetAuthorizationCode(codeHash string) (*models.AuthorizationCode, error)
+	MarkAuthorizationCodeUsed(code *models.AuthorizationCode) (bool, error)
+}
+
+type RoleRepositoryInterface interface {
+	GetRoles() ([]models.Role, error)
+	GetRoleByName(name string) (*models.Role, error)
+	CreateRole(role *models.Role) error
+	UpdateRole(role *models.Role, updates map[string]interface{}) error
+	DeleteRole(role *models.Role) error
+	SetRolePermissions(role *models.Role, permissions []models.Permission) error
+	CountUsersWithRole(name string) (int64, error)
+	FindExistingRoles(names []string) ([]string, error)
+	GetPermissions() ([]models.Permission, error)
+	GetPermissionsByNames(names []string) ([]models.Permission, error)
+	GetPermissionsForRoles(roles []string) ([]string, error)
+}
+
+// AuditFilter narrows the audit log. Zero values mean no restriction; the
+// time range is inclusive of From and exclusive of To.
+type AuditFilter struct {
+	ActorID      uint
+	TargetUserID uint
+	From         *time.Time
+	To           *time.Time
+}
+
+type AuditRepositoryInterface interface {
+	GetAuditEntries(page, limit int, filter AuditFilter) ([]models.AuditEntry, int64, error)
 }
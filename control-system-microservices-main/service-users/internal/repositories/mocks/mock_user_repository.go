@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: .\internal\repositories\interfaces.go
+// Source: ./internal/repositories/interfaces.go
 //
 // Generated by this command:
 //
-//	mockgen -source=.\internal\repositories\interfaces.go -destination=.\internal\repositories\mocks\mock_user_repository.go -package=mocks
+//	mockgen -source=./internal/repositories/interfaces.go -destination=./internal/repositories/mocks/mock_user_repository.go -package=mocks
 //
 
 // Package mocks is a generated GoMock package.
@@ -11,8 +11,10 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	models "github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	repositories "github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,18 +42,105 @@ func (m *MockUserRepositoryInterface) EXPECT() *MockUserRepositoryInterfaceMockR
 	return m.recorder
 }
 
+// CountUsers mocks base method.
+func (m *MockUserRepositoryInterface) CountUsers(filter repositories.UserFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUsers", filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUsers indicates an expected call of CountUsers.
+func (mr *MockUserRepositoryInterfaceMockRecorder) CountUsers(filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUsers", reflect.TypeOf((*MockUserRepositoryInterface)(nil).CountUsers), filter)
+}
+
 // CreateUser mocks base method.
-func (m *MockUserRepositoryInterface) CreateUser(user *models.User) error {
+func (m *MockUserRepositoryInterface) CreateUser(user *models.User, entry *models.AuditEntry) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", user)
+	ret := m.ctrl.Call(m, "CreateUser", user, entry)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockUserRepositoryInterfaceMockRecorder) CreateUser(user any) *gomock.Call {
+func (mr *MockUserRepositoryInterfaceMockRecorder) CreateUser(user, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).CreateUser), user, entry)
+}
+
+// CreateUsers mocks base method.
+func (m *MockUserRepositoryInterface) CreateUsers(users []models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUsers", users)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUsers indicates an expected call of CreateUsers.
+func (mr *MockUserRepositoryInterfaceMockRecorder) CreateUsers(users any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUsers", reflect.TypeOf((*MockUserRepositoryInterface)(nil).CreateUsers), users)
+}
+
+// DeleteUser mocks base method.
+func (m *MockUserRepositoryInterface) DeleteUser(user *models.User, entry *models.AuditEntry, keepSuperadmin bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", user, entry, keepSuperadmin)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserRepositoryInterfaceMockRecorder) DeleteUser(user, entry, keepSuperadmin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).DeleteUser), user, entry, keepSuperadmin)
+}
+
+// EachUserBatch mocks base method.
+func (m *MockUserRepositoryInterface) EachUserBatch(filter repositories.UserFilter, batchSize int, fn func([]models.User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EachUserBatch", filter, batchSize, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EachUserBatch indicates an expected call of EachUserBatch.
+func (mr *MockUserRepositoryInterfaceMockRecorder) EachUserBatch(filter, batchSize, fn any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).CreateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EachUserBatch", reflect.TypeOf((*MockUserRepositoryInterface)(nil).EachUserBatch), filter, batchSize, fn)
+}
+
+// FindExistingEmails mocks base method.
+func (m *MockUserRepositoryInterface) FindExistingEmails(emails []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindExistingEmails", emails)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindExistingEmails indicates an expected call of FindExistingEmails.
+func (mr *MockUserRepositoryInterfaceMockRecorder) FindExistingEmails(emails any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindExistingEmails", reflect.TypeOf((*MockUserRepositoryInterface)(nil).FindExistingEmails), emails)
+}
+
+// GetDeletedUserByID mocks base method.
+func (m *MockUserRepositoryInterface) GetDeletedUserByID(id uint) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedUserByID", id)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletedUserByID indicates an expected call of GetDeletedUserByID.
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetDeletedUserByID(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedUserByID", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetDeletedUserByID), id)
 }
 
 // GetUserByEmail mocks base method.
@@ -85,9 +174,9 @@ func (mr *MockUserRepositoryInterfaceMockRecorder) GetUserByID(id any) *gomock.C
 }
 
 // GetUsers mocks base method.
-func (m *MockUserRepositoryInterface) GetUsers(page, limit int, emailFilter, roleFilter string) ([]models.User, int64, error) {
+func (m *MockUserRepositoryInterface) GetUsers(offset, limit int, filter repositories.UserFilter, sort repositories.UserSort) ([]models.User, int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", page, limit, emailFilter, roleFilter)
+	ret := m.ctrl.Call(m, "GetUsers", offset, limit, filter, sort)
 	ret0, _ := ret[0].([]models.User)
 	ret1, _ := ret[1].(int64)
 	ret2, _ := ret[2].(error)
@@ -95,9 +184,81 @@ func (m *MockUserRepositoryInterface) GetUsers(page, limit int, emailFilter, rol
 }
 
 // GetUsers indicates an expected call of GetUsers.
-func (mr *MockUserRepositoryInterfaceMockRecorder) GetUsers(page, limit, emailFilter, roleFilter any) *gomock.Call {
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetUsers(offset, limit, filter, sort any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUsers), offset, limit, filter, sort)
+}
+
+// GetUsersAfter mocks base method.
+func (m *MockUserRepositoryInterface) GetUsersAfter(after *repositories.UserCursor, limit int, filter repositories.UserFilter, sort repositories.UserSort) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersAfter", after, limit, filter, sort)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersAfter indicates an expected call of GetUsersAfter.
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetUsersAfter(after, limit, filter, sort any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersAfter", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUsersAfter), after, limit, filter, sort)
+}
+
+// GetUsersByIDs mocks base method.
+func (m *MockUserRepositoryInterface) GetUsersByIDs(ids []uint) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ids)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIDs indicates an expected call of GetUsersByIDs.
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetUsersByIDs(ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUsersByIDs), ids)
+}
+
+// IncrementFailedLoginAttempts mocks base method.
+func (m *MockUserRepositoryInterface) IncrementFailedLoginAttempts(user *models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementFailedLoginAttempts", user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementFailedLoginAttempts indicates an expected call of IncrementFailedLoginAttempts.
+func (mr *MockUserRepositoryInterfaceMockRecorder) IncrementFailedLoginAttempts(user any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementFailedLoginAttempts", reflect.TypeOf((*MockUserRepositoryInterface)(nil).IncrementFailedLoginAttempts), user)
+}
+
+// IncrementTokenVersion mocks base method.
+func (m *MockUserRepositoryInterface) IncrementTokenVersion(user *models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementTokenVersion", user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementTokenVersion indicates an expected call of IncrementTokenVersion.
+func (mr *MockUserRepositoryInterfaceMockRecorder) IncrementTokenVersion(user any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementTokenVersion", reflect.TypeOf((*MockUserRepositoryInterface)(nil).IncrementTokenVersion), user)
+}
+
+// RestoreUser mocks base method.
+func (m *MockUserRepositoryInterface) RestoreUser(user *models.User, entry *models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", user, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockUserRepositoryInterfaceMockRecorder) RestoreUser(user, entry any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUsers), page, limit, emailFilter, roleFilter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).RestoreUser), user, entry)
 }
 
 // UpdateUser mocks base method.
@@ -113,3 +274,849 @@ func (mr *MockUserRepositoryInterfaceMockRecorder) UpdateUser(user, updates any)
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).UpdateUser), user, updates)
 }
+
+// UpdateUserAudited mocks base method.
+func (m *MockUserRepositoryInterface) UpdateUserAudited(user *models.User, updates map[string]any, entry *models.AuditEntry, keepSuperadmin bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserAudited", user, updates, entry, keepSuperadmin)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserAudited indicates an expected call of UpdateUserAudited.
+func (mr *MockUserRepositoryInterfaceMockRecorder) UpdateUserAudited(user, updates, entry, keepSuperadmin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserAudited", reflect.TypeOf((*MockUserRepositoryInterface)(nil).UpdateUserAudited), user, updates, entry, keepSuperadmin)
+}
+
+// MockTokenRepositoryInterface is a mock of TokenRepositoryInterface interface.
+type MockTokenRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenRepositoryInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockTokenRepositoryInterfaceMockRecorder is the mock recorder for MockTokenRepositoryInterface.
+type MockTokenRepositoryInterfaceMockRecorder struct {
+	mock *MockTokenRepositoryInterface
+}
+
+// NewMockTokenRepositoryInterface creates a new mock instance.
+func NewMockTokenRepositoryInterface(ctrl *gomock.Controller) *MockTokenRepositoryInterface {
+	mock := &MockTokenRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockTokenRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenRepositoryInterface) EXPECT() *MockTokenRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// AcceptInvitation mocks base method.
+func (m *MockTokenRepositoryInterface) AcceptInvitation(invitation *models.Invitation, user *models.User, updates map[string]any) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptInvitation", invitation, user, updates)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptInvitation indicates an expected call of AcceptInvitation.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) AcceptInvitation(invitation, user, updates any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptInvitation", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).AcceptInvitation), invitation, user, updates)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockTokenRepositoryInterface) CreateAPIKey(key *models.APIKey, entry *models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", key, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateAPIKey(key, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateAPIKey), key, entry)
+}
+
+// CreateAuthorizationCode mocks base method.
+func (m *MockTokenRepositoryInterface) CreateAuthorizationCode(code *models.AuthorizationCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuthorizationCode", code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAuthorizationCode indicates an expected call of CreateAuthorizationCode.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateAuthorizationCode(code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuthorizationCode", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateAuthorizationCode), code)
+}
+
+// CreateInvitation mocks base method.
+func (m *MockTokenRepositoryInterface) CreateInvitation(invitation *models.Invitation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInvitation", invitation)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateInvitation indicates an expected call of CreateInvitation.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateInvitation(invitation any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInvitation", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateInvitation), invitation)
+}
+
+// CreateMFAChallenge mocks base method.
+func (m *MockTokenRepositoryInterface) CreateMFAChallenge(challenge *models.MFAChallenge) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMFAChallenge", challenge)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMFAChallenge indicates an expected call of CreateMFAChallenge.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateMFAChallenge(challenge any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMFAChallenge", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateMFAChallenge), challenge)
+}
+
+// CreateOAuthClient mocks base method.
+func (m *MockTokenRepositoryInterface) CreateOAuthClient(client *models.OAuthClient, entry *models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthClient", client, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthClient indicates an expected call of CreateOAuthClient.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateOAuthClient(client, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthClient", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateOAuthClient), client, entry)
+}
+
+// CreatePasswordResetToken mocks base method.
+func (m *MockTokenRepositoryInterface) CreatePasswordResetToken(token *models.PasswordResetToken, since time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordResetToken", token, since)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordResetToken indicates an expected call of CreatePasswordResetToken.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreatePasswordResetToken(token, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetToken", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreatePasswordResetToken), token, since)
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockTokenRepositoryInterface) CreateRefreshToken(token *models.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateRefreshToken(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateRefreshToken), token)
+}
+
+// CreateSession mocks base method.
+func (m *MockTokenRepositoryInterface) CreateSession(session *models.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) CreateSession(session any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).CreateSession), session)
+}
+
+// GetAPIKeyByID mocks base method.
+func (m *MockTokenRepositoryInterface) GetAPIKeyByID(id uint) (*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByID", id)
+	ret0, _ := ret[0].(*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByID indicates an expected call of GetAPIKeyByID.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetAPIKeyByID(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByID", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetAPIKeyByID), id)
+}
+
+// GetAPIKeyByPrefix mocks base method.
+func (m *MockTokenRepositoryInterface) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByPrefix", prefix)
+	ret0, _ := ret[0].(*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByPrefix indicates an expected call of GetAPIKeyByPrefix.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetAPIKeyByPrefix(prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByPrefix", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetAPIKeyByPrefix), prefix)
+}
+
+// GetAuthorizationCode mocks base method.
+func (m *MockTokenRepositoryInterface) GetAuthorizationCode(codeHash string) (*models.AuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorizationCode", codeHash)
+	ret0, _ := ret[0].(*models.AuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuthorizationCode indicates an expected call of GetAuthorizationCode.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetAuthorizationCode(codeHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizationCode", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetAuthorizationCode), codeHash)
+}
+
+// GetInvitationByJTI mocks base method.
+func (m *MockTokenRepositoryInterface) GetInvitationByJTI(jti string) (*models.Invitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInvitationByJTI", jti)
+	ret0, _ := ret[0].(*models.Invitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInvitationByJTI indicates an expected call of GetInvitationByJTI.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetInvitationByJTI(jti any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvitationByJTI", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetInvitationByJTI), jti)
+}
+
+// GetMFAChallengeByJTI mocks base method.
+func (m *MockTokenRepositoryInterface) GetMFAChallengeByJTI(jti string) (*models.MFAChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMFAChallengeByJTI", jti)
+	ret0, _ := ret[0].(*models.MFAChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMFAChallengeByJTI indicates an expected call of GetMFAChallengeByJTI.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetMFAChallengeByJTI(jti any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMFAChallengeByJTI", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetMFAChallengeByJTI), jti)
+}
+
+// GetOAuthClientByClientID mocks base method.
+func (m *MockTokenRepositoryInterface) GetOAuthClientByClientID(clientID string) (*models.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClientByClientID", clientID)
+	ret0, _ := ret[0].(*models.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClientByClientID indicates an expected call of GetOAuthClientByClientID.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetOAuthClientByClientID(clientID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClientByClientID", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetOAuthClientByClientID), clientID)
+}
+
+// GetOIDCClients mocks base method.
+func (m *MockTokenRepositoryInterface) GetOIDCClients() ([]models.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOIDCClients")
+	ret0, _ := ret[0].([]models.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOIDCClients indicates an expected call of GetOIDCClients.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetOIDCClients() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOIDCClients", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetOIDCClients))
+}
+
+// GetPasswordResetTokenByHash mocks base method.
+func (m *MockTokenRepositoryInterface) GetPasswordResetTokenByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordResetTokenByHash", tokenHash)
+	ret0, _ := ret[0].(*models.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordResetTokenByHash indicates an expected call of GetPasswordResetTokenByHash.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetPasswordResetTokenByHash(tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordResetTokenByHash", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetPasswordResetTokenByHash), tokenHash)
+}
+
+// GetRefreshTokenByJTI mocks base method.
+func (m *MockTokenRepositoryInterface) GetRefreshTokenByJTI(jti string) (*models.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshTokenByJTI", jti)
+	ret0, _ := ret[0].(*models.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshTokenByJTI indicates an expected call of GetRefreshTokenByJTI.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetRefreshTokenByJTI(jti any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokenByJTI", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetRefreshTokenByJTI), jti)
+}
+
+// GetSessionByFamilyID mocks base method.
+func (m *MockTokenRepositoryInterface) GetSessionByFamilyID(familyID string) (*models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByFamilyID", familyID)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByFamilyID indicates an expected call of GetSessionByFamilyID.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetSessionByFamilyID(familyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByFamilyID", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetSessionByFamilyID), familyID)
+}
+
+// GetSessionByID mocks base method.
+func (m *MockTokenRepositoryInterface) GetSessionByID(id uint) (*models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByID", id)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByID indicates an expected call of GetSessionByID.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetSessionByID(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByID", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetSessionByID), id)
+}
+
+// GetUserAPIKeys mocks base method.
+func (m *MockTokenRepositoryInterface) GetUserAPIKeys(userID uint) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAPIKeys", userID)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAPIKeys indicates an expected call of GetUserAPIKeys.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetUserAPIKeys(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAPIKeys", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetUserAPIKeys), userID)
+}
+
+// GetUserOAuthClients mocks base method.
+func (m *MockTokenRepositoryInterface) GetUserOAuthClients(userID uint) ([]models.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserOAuthClients", userID)
+	ret0, _ := ret[0].([]models.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserOAuthClients indicates an expected call of GetUserOAuthClients.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetUserOAuthClients(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOAuthClients", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetUserOAuthClients), userID)
+}
+
+// GetUserSessions mocks base method.
+func (m *MockTokenRepositoryInterface) GetUserSessions(userID uint) ([]models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserSessions", userID)
+	ret0, _ := ret[0].([]models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserSessions indicates an expected call of GetUserSessions.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) GetUserSessions(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserSessions", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).GetUserSessions), userID)
+}
+
+// IsAccessTokenRevoked mocks base method.
+func (m *MockTokenRepositoryInterface) IsAccessTokenRevoked(jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAccessTokenRevoked", jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsAccessTokenRevoked indicates an expected call of IsAccessTokenRevoked.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) IsAccessTokenRevoked(jti any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAccessTokenRevoked", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).IsAccessTokenRevoked), jti)
+}
+
+// MarkAuthorizationCodeUsed mocks base method.
+func (m *MockTokenRepositoryInterface) MarkAuthorizationCodeUsed(code *models.AuthorizationCode) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkAuthorizationCodeUsed", code)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkAuthorizationCodeUsed indicates an expected call of MarkAuthorizationCodeUsed.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) MarkAuthorizationCodeUsed(code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkAuthorizationCodeUsed", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).MarkAuthorizationCodeUsed), code)
+}
+
+// MarkMFAChallengeEnrolled mocks base method.
+func (m *MockTokenRepositoryInterface) MarkMFAChallengeEnrolled(challenge *models.MFAChallenge) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkMFAChallengeEnrolled", challenge)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkMFAChallengeEnrolled indicates an expected call of MarkMFAChallengeEnrolled.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) MarkMFAChallengeEnrolled(challenge any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkMFAChallengeEnrolled", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).MarkMFAChallengeEnrolled), challenge)
+}
+
+// MarkMFAChallengeUsed mocks base method.
+func (m *MockTokenRepositoryInterface) MarkMFAChallengeUsed(challenge *models.MFAChallenge) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkMFAChallengeUsed", challenge)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkMFAChallengeUsed indicates an expected call of MarkMFAChallengeUsed.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) MarkMFAChallengeUsed(challenge any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkMFAChallengeUsed", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).MarkMFAChallengeUsed), challenge)
+}
+
+// MarkPasswordResetTokenUsed mocks base method.
+func (m *MockTokenRepositoryInterface) MarkPasswordResetTokenUsed(token *models.PasswordResetToken) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkPasswordResetTokenUsed", token)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkPasswordResetTokenUsed indicates an expected call of MarkPasswordResetTokenUsed.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) MarkPasswordResetTokenUsed(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkPasswordResetTokenUsed", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).MarkPasswordResetTokenUsed), token)
+}
+
+// MarkRefreshTokenUsed mocks base method.
+func (m *MockTokenRepositoryInterface) MarkRefreshTokenUsed(token *models.RefreshToken) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRefreshTokenUsed", token)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkRefreshTokenUsed indicates an expected call of MarkRefreshTokenUsed.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) MarkRefreshTokenUsed(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRefreshTokenUsed", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).MarkRefreshTokenUsed), token)
+}
+
+// ReplaceRecoveryCodes mocks base method.
+func (m *MockTokenRepositoryInterface) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceRecoveryCodes", userID, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceRecoveryCodes indicates an expected call of ReplaceRecoveryCodes.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) ReplaceRecoveryCodes(userID, codes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceRecoveryCodes", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).ReplaceRecoveryCodes), userID, codes)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeAPIKey(key *models.APIKey, entry *models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", key, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeAPIKey(key, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeAPIKey), key, entry)
+}
+
+// RevokeAccessToken mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeAccessToken(token *models.RevokedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAccessToken", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAccessToken indicates an expected call of RevokeAccessToken.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeAccessToken(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAccessToken", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeAccessToken), token)
+}
+
+// RevokeOAuthClient mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeOAuthClient(client *models.OAuthClient, entry *models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOAuthClient", client, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeOAuthClient indicates an expected call of RevokeOAuthClient.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeOAuthClient(client, entry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOAuthClient", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeOAuthClient), client, entry)
+}
+
+// RevokeRefreshTokenFamily mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeRefreshTokenFamily(familyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshTokenFamily", familyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshTokenFamily indicates an expected call of RevokeRefreshTokenFamily.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeRefreshTokenFamily(familyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshTokenFamily", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeRefreshTokenFamily), familyID)
+}
+
+// RevokeSession mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeSession(session *models.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeSession(session any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeSession), session)
+}
+
+// RevokeUserInvitations mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeUserInvitations(userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserInvitations", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserInvitations indicates an expected call of RevokeUserInvitations.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeUserInvitations(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserInvitations", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeUserInvitations), userID)
+}
+
+// RevokeUserRefreshTokens mocks base method.
+func (m *MockTokenRepositoryInterface) RevokeUserRefreshTokens(userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserRefreshTokens", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserRefreshTokens indicates an expected call of RevokeUserRefreshTokens.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) RevokeUserRefreshTokens(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserRefreshTokens", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).RevokeUserRefreshTokens), userID)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockTokenRepositoryInterface) TouchAPIKey(key *models.APIKey, usedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", key, usedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) TouchAPIKey(key, usedAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).TouchAPIKey), key, usedAt)
+}
+
+// TouchSession mocks base method.
+func (m *MockTokenRepositoryInterface) TouchSession(session *models.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) TouchSession(session any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).TouchSession), session)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockTokenRepositoryInterface) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", userID, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockTokenRepositoryInterfaceMockRecorder) UseRecoveryCode(userID, codeHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTokenRepositoryInterface)(nil).UseRecoveryCode), userID, codeHash)
+}
+
+// MockRoleRepositoryInterface is a mock of RoleRepositoryInterface interface.
+type MockRoleRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoleRepositoryInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockRoleRepositoryInterfaceMockRecorder is the mock recorder for MockRoleRepositoryInterface.
+type MockRoleRepositoryInterfaceMockRecorder struct {
+	mock *MockRoleRepositoryInterface
+}
+
+// NewMockRoleRepositoryInterface creates a new mock instance.
+func NewMockRoleRepositoryInterface(ctrl *gomock.Controller) *MockRoleRepositoryInterface {
+	mock := &MockRoleRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockRoleRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRoleRepositoryInterface) EXPECT() *MockRoleRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// CountUsersWithRole mocks base method.
+func (m *MockRoleRepositoryInterface) CountUsersWithRole(name string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUsersWithRole", name)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUsersWithRole indicates an expected call of CountUsersWithRole.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) CountUsersWithRole(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUsersWithRole", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).CountUsersWithRole), name)
+}
+
+// CreateRole mocks base method.
+func (m *MockRoleRepositoryInterface) CreateRole(role *models.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRole", role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRole indicates an expected call of CreateRole.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) CreateRole(role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRole", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).CreateRole), role)
+}
+
+// DeleteRole mocks base method.
+func (m *MockRoleRepositoryInterface) DeleteRole(role *models.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRole", role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRole indicates an expected call of DeleteRole.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) DeleteRole(role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRole", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).DeleteRole), role)
+}
+
+// FindExistingRoles mocks base method.
+func (m *MockRoleRepositoryInterface) FindExistingRoles(names []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindExistingRoles", names)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindExistingRoles indicates an expected call of FindExistingRoles.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) FindExistingRoles(names any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindExistingRoles", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).FindExistingRoles), names)
+}
+
+// GetPermissions mocks base method.
+func (m *MockRoleRepositoryInterface) GetPermissions() ([]models.Permission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPermissions")
+	ret0, _ := ret[0].([]models.Permission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPermissions indicates an expected call of GetPermissions.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) GetPermissions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPermissions", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).GetPermissions))
+}
+
+// GetPermissionsByNames mocks base method.
+func (m *MockRoleRepositoryInterface) GetPermissionsByNames(names []string) ([]models.Permission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPermissionsByNames", names)
+	ret0, _ := ret[0].([]models.Permission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPermissionsByNames indicates an expected call of GetPermissionsByNames.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) GetPermissionsByNames(names any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPermissionsByNames", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).GetPermissionsByNames), names)
+}
+
+// GetPermissionsForRoles mocks base method.
+func (m *MockRoleRepositoryInterface) GetPermissionsForRoles(roles []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPermissionsForRoles", roles)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPermissionsForRoles indicates an expected call of GetPermissionsForRoles.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) GetPermissionsForRoles(roles any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPermissionsForRoles", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).GetPermissionsForRoles), roles)
+}
+
+// GetRoleByName mocks base method.
+func (m *MockRoleRepositoryInterface) GetRoleByName(name string) (*models.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoleByName", name)
+	ret0, _ := ret[0].(*models.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoleByName indicates an expected call of GetRoleByName.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) GetRoleByName(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoleByName", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).GetRoleByName), name)
+}
+
+// GetRoles mocks base method.
+func (m *MockRoleRepositoryInterface) GetRoles() ([]models.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoles")
+	ret0, _ := ret[0].([]models.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoles indicates an expected call of GetRoles.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) GetRoles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoles", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).GetRoles))
+}
+
+// SetRolePermissions mocks base method.
+func (m *MockRoleRepositoryInterface) SetRolePermissions(role *models.Role, permissions []models.Permission) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRolePermissions", role, permissions)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRolePermissions indicates an expected call of SetRolePermissions.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) SetRolePermissions(role, permissions any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRolePermissions", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).SetRolePermissions), role, permissions)
+}
+
+// UpdateRole mocks base method.
+func (m *MockRoleRepositoryInterface) UpdateRole(role *models.Role, updates map[string]any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRole", role, updates)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRole indicates an expected call of UpdateRole.
+func (mr *MockRoleRepositoryInterfaceMockRecorder) UpdateRole(role, updates any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRole", reflect.TypeOf((*MockRoleRepositoryInterface)(nil).UpdateRole), role, updates)
+}
+
+// MockAuditRepositoryInterface is a mock of AuditRepositoryInterface interface.
+type MockAuditRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditRepositoryInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockAuditRepositoryInterfaceMockRecorder is the mock recorder for MockAuditRepositoryInterface.
+type MockAuditRepositoryInterfaceMockRecorder struct {
+	mock *MockAuditRepositoryInterface
+}
+
+// NewMockAuditRepositoryInterface creates a new mock instance.
+func NewMockAuditRepositoryInterface(ctrl *gomock.Controller) *MockAuditRepositoryInterface {
+	mock := &MockAuditRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockAuditRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditRepositoryInterface) EXPECT() *MockAuditRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// GetAuditEntries mocks base method.
+func (m *MockAuditRepositoryInterface) GetAuditEntries(page, limit int, filter repositories.AuditFilter) ([]models.AuditEntry, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEntries", page, limit, filter)
+	ret0, _ := ret[0].([]models.AuditEntry)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetAuditEntries indicates an expected call of GetAuditEntries.
+func (mr *MockAuditRepositoryInterfaceMockRecorder) GetAuditEntries(page, limit, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEntries", reflect.TypeOf((*MockAuditRepositoryInterface)(nil).GetAuditEntries), page, limit, filter)
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name")
+	}).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *RoleRepository) GetRoleByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name")
+	}).Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CreateRole inserts the role together with its permission mappings. The
+// permissions themselves must already exist.
+func (r *RoleRepository) CreateRole(role *models.Role) error {
+	return r.db.Omit("Permissions.*").Create(role).Error
+}
+
+func (r *RoleRepository) UpdateRole(role *models.Role, updates map[string]interface{}) error {
+	return r.db.Model(role).Updates(updates).Error
+}
+
+func (r *RoleRepository) DeleteRole(role *models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+func (r *RoleRepository) SetRolePermissions(role *models.Role, permissions []models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Permissions.*").Model(role).Association("Permissions").Replace(permissions); err != nil {
+			return err
+		}
+		return tx.Model(role).Update("updated_at", gorm.Expr("NOW()")).Error
+	})
+}
+
+// CountUsersWithRole includes deleted users, since a restored user would
+// otherwise come back holding a role that no longer exists.
+func (r *RoleRepository) CountUsersWithRole(name string) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.User{}).Where("? = ANY(roles)", name).Count(&count).Error
+	return count, err
+}
+
+func (r *RoleRepository) FindExistingRoles(names []string) ([]string, error) {
+	var existing []string
+	if len(names) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&models.Role{}).Where("name IN ?", names).Pluck("name", &existing).Error
+	return existing, err
+}
+
+func (r *RoleRepository) GetPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *RoleRepository) GetPermissionsByNames(names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	err := r.db.Where("name IN ?", names).Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetPermissionsForRoles returns the union of the permissions granted by the
+// given roles, sorted by name.
+func (r *RoleRepository) GetPermissionsForRoles(roles []string) ([]string, error) {
+	permissions := []string{}
+	if len(roles) == 0 {
+		return permissions, nil
+	}
+	err := r.db.Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name IN ?", roles).
+		Order("permissions.name").
+		Pluck("permissions.name", &permissions).Error
+	return permissions, err
+}
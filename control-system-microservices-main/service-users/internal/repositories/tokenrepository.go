@@ -0,0 +1,459 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *TokenRepository) GetRefreshTokenByJTI(jti string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("jti = ?", jti).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed flags the token as rotated. It reports false when the
+// token had already been used, which callers must treat as a reuse attempt.
+func (r *TokenRepository) MarkRefreshTokenUsed(token *models.RefreshToken) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	token.UsedAt = &now
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily also ends the session that owns the family.
+func (r *TokenRepository) RevokeRefreshTokenFamily(familyID string) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeUserRefreshTokens also ends every session of the user.
+func (r *TokenRepository) RevokeUserRefreshTokens(userID uint) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
+
+func (r *TokenRepository) RevokeAccessToken(token *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreatePasswordResetToken stores the token and revokes the user's
+// outstanding ones. It stores nothing and returns false when the user was
+// issued a token after since.
+func (r *TokenRepository) CreatePasswordResetToken(token *models.PasswordResetToken, since time.Time) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the user lets only one of two concurrent requests for the
+		// same account pass the check below.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&models.User{}, token.UserID).Error; err != nil {
+			return err
+		}
+		var recent int64
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND created_at > ?", token.UserID, since).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent > 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func (r *TokenRepository) GetPasswordResetTokenByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkPasswordResetTokenUsed consumes the token; false means it was already used.
+func (r *TokenRepository) MarkPasswordResetTokenUsed(token *models.PasswordResetToken) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	token.UsedAt = &now
+	return true, nil
+}
+
+// ReplaceRecoveryCodes drops all previous codes of the user and stores the new set.
+func (r *TokenRepository) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode consumes a matching unused code and reports whether one existed.
+func (r *TokenRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateInvitation stores a new invite and revokes every earlier open invite
+// of the same user, so only the latest link works.
+func (r *TokenRepository) CreateInvitation(invitation *models.Invitation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Invitation{}).
+			Where("user_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.UserID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(invitation).Error
+	})
+}
+
+func (r *TokenRepository) GetInvitationByJTI(jti string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Where("jti = ?", jti).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// errInvitationStale rolls back AcceptInvitation when the user was activated
+// in the meantime.
+var errInvitationStale = errors.New("user is no longer pending")
+
+// AcceptInvitation consumes the invite and applies the updates to the still
+// pending user in one transaction, so an invite is never used up without the
+// account being activated. false means the invite was already accepted or
+// revoked, or the user is no longer pending; nothing is changed then.
+func (r *TokenRepository) AcceptInvitation(invitation *models.Invitation, user *models.User, updates map[string]interface{}) (bool, error) {
+	now := time.Now()
+	accepted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+			Update("accepted_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		result = tx.Model(user).Where("pending = ?", true).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvitationStale
+		}
+		accepted = true
+		return nil
+	})
+	if errors.Is(err, errInvitationStale) {
+		return false, nil
+	}
+	if err != nil || !accepted {
+		return false, err
+	}
+	invitation.AcceptedAt = &now
+	return true, nil
+}
+
+func (r *TokenRepository) RevokeUserInvitations(userID uint) error {
+	return r.db.Model(&models.Invitation{}).
+		Where("user_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *TokenRepository) CreateMFAChallenge(challenge *models.MFAChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+func (r *TokenRepository) GetMFAChallengeByJTI(jti string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	err := r.db.Where("jti = ?", jti).First(&challenge).Error
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// MarkMFAChallengeEnrolled records that the challenge started a TOTP
+// enrollment; false means it already did or was used for a login.
+func (r *TokenRepository) MarkMFAChallengeEnrolled(challenge *models.MFAChallenge) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.MFAChallenge{}).
+		Where("id = ? AND enrolled_at IS NULL AND used_at IS NULL", challenge.ID).
+		Update("enrolled_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	challenge.EnrolledAt = &now
+	return true, nil
+}
+
+// MarkMFAChallengeUsed consumes the challenge; false means it was already used.
+func (r *TokenRepository) MarkMFAChallengeUsed(challenge *models.MFAChallenge) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	challenge.UsedAt = &now
+	return true, nil
+}
+
+func (r *TokenRepository) CreateSession(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *TokenRepository) GetSessionByID(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *TokenRepository) GetSessionByFamilyID(familyID string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// TouchSession saves the session's last use, expiry and IP.
+func (r *TokenRepository) TouchSession(session *models.Session) error {
+	return r.db.Model(session).Updates(map[string]interface{}{
+		"last_used_at": session.LastUsedAt,
+		"expires_at":   session.ExpiresAt,
+		"ip":           session.IP,
+	}).Error
+}
+
+// GetUserSessions returns the sessions that are neither revoked nor expired,
+// most recently used first.
+func (r *TokenRepository) GetUserSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *TokenRepository) RevokeSession(session *models.Session) error {
+	return r.RevokeRefreshTokenFamily(session.FamilyID)
+}
+
+// CreateAPIKey stores the key and its audit entry in one transaction.
+func (r *TokenRepository) CreateAPIKey(key *models.APIKey, entry *models.AuditEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
+}
+
+func (r *TokenRepository) GetAPIKeyByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *TokenRepository) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetUserAPIKeys includes revoked and expired keys so that admins can see the
+// full history of a service account.
+func (r *TokenRepository) GetUserAPIKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *TokenRepository) RevokeAPIKey(key *models.APIKey, entry *models.AuditEntry) error {
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(key).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
+	if err != nil {
+		return err
+	}
+	key.RevokedAt = &now
+	return nil
+}
+
+func (r *TokenRepository) TouchAPIKey(key *models.APIKey, usedAt time.Time) error {
+	return r.db.Model(key).Update("last_used_at", usedAt).Error
+}
+
+// CreateOAuthClient stores the client and, unless entry is nil, its audit
+// entry in one transaction.
+func (r *TokenRepository) CreateOAuthClient(client *models.OAuthClient, entry *models.AuditEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(client).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
+}
+
+func (r *TokenRepository) GetOAuthClientByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *TokenRepository) GetUserOAuthClients(userID uint) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&clients).Error
+	return clients, err
+}
+
+func (r *TokenRepository) RevokeOAuthClient(client *models.OAuthClient, entry *models.AuditEntry) error {
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(client).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
+	if err != nil {
+		return err
+	}
+	client.RevokedAt = &now
+	return nil
+}
+
+func (r *TokenRepository) GetOIDCClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.Where("cardinality(redirect_uris) > 0").Order("created_at DESC").Find(&clients).Error
+	return clients, err
+}
+
+func (r *TokenRepository) CreateAuthorizationCode(code *models.AuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *TokenRepository) GetAuthorizationCode(codeHash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	if err := r.db.Where("code_hash = ?", codeHash).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// MarkAuthorizationCodeUsed redeems the code. It reports false when the code
+// had already been redeemed.
+func (r *TokenRepository) MarkAuthorizationCodeUsed(code *models.AuthorizationCode) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.AuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	code.UsedAt = &now
+	return true, nil
+}
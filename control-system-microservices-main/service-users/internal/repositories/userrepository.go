@@ -5,7 +5,11 @@ import (
 	"strings"
 
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/shared/search"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository struct {
@@ -16,8 +20,18 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) CreateUser(user *models.User) error {
-	return r.db.Create(user).Error
+// CreateUser stores the user and, unless entry is nil, its audit entry in one
+// transaction. The entry's target is set to the new user's ID.
+func (r *UserRepository) CreateUser(user *models.User, entry *models.AuditEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if entry != nil {
+			entry.TargetUserID = user.ID
+		}
+		return createAuditEntry(tx, entry)
+	})
 }
 
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
@@ -38,36 +52,283 @@ func (r *UserRepository) GetUserByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUsersByIDs returns the users that exist among ids, in no particular
+// order. Deleted users are left out.
+func (r *UserRepository) GetUsersByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
 func (r *UserRepository) UpdateUser(user *models.User, updates map[string]interface{}) error {
 	return r.db.Model(user).Updates(updates).Error
 }
 
-func (r *UserRepository) DeleteUser(user *models.User) error {
-	return r.db.Delete(user).Error
+// UpdateUserAudited applies the updates and writes entry in one transaction,
+// so the change is never stored without its audit record. A nil entry
+// applies the updates alone. With keepSuperadmin set the update fails with
+// ErrLastSuperadmin when the user is the only active superadmin left.
+func (r *UserRepository) UpdateUserAudited(user *models.User, updates map[string]interface{}, entry *models.AuditEntry, keepSuperadmin bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if keepSuperadmin {
+			if err := lockOtherSuperadmin(tx, user); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
 }
 
-func (r *UserRepository) GetUsers(page, limit int, emailFilter, roleFilter string) ([]models.User, int64, error) {
-	var users []models.User
-	var total int64
+func (r *UserRepository) IncrementTokenVersion(user *models.User) error {
+	if err := r.db.Model(user).Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	return r.db.Select("token_version").First(user, user.ID).Error
+}
+
+func (r *UserRepository) IncrementFailedLoginAttempts(user *models.User) error {
+	if err := r.db.Model(user).Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1")).Error; err != nil {
+		return err
+	}
+	return r.db.Select("failed_login_attempts", "lockout_count").First(user, user.ID).Error
+}
+
+// DeleteUser soft-deletes the user and writes entry in one transaction. With
+// keepSuperadmin set it fails with ErrLastSuperadmin when the user is the
+// only active superadmin left.
+func (r *UserRepository) DeleteUser(user *models.User, entry *models.AuditEntry, keepSuperadmin bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if keepSuperadmin {
+			if err := lockOtherSuperadmin(tx, user); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
+}
+
+// lockOtherSuperadmin locks the rows of every active superadmin and fails
+// with ErrLastSuperadmin unless one of them is not the given user. Holding
+// the locks until the transaction ends keeps two concurrent demotions from
+// each counting the other and removing the last two superadmins together.
+func lockOtherSuperadmin(tx *gorm.DB, user *models.User) error {
+	var ids []uint
+	query := applyUserFilter(tx.Model(&models.User{}), UserFilter{
+		Role:  userroles.RoleSuperadmin,
+		State: UserStateActive,
+	})
+	err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id != user.ID {
+			return nil
+		}
+	}
+	return ErrLastSuperadmin
+}
+
+func (r *UserRepository) GetDeletedUserByID(id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&user, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RestoreUser undoes a soft delete and writes entry in one transaction.
+func (r *UserRepository) RestoreUser(user *models.User, entry *models.AuditEntry) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return createAuditEntry(tx, entry)
+	})
+	if err != nil {
+		return err
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+func applyUserFilter(query *gorm.DB, filter UserFilter) *gorm.DB {
+	switch filter.State {
+	case UserStateActive:
+		query = query.Where("active = ? AND pending = ?", true, false)
+	case UserStatePending:
+		query = query.Where("pending = ?", true)
+	case UserStateInactive:
+		query = query.Where("active = ?", false)
+	case UserStateDeleted:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	case UserStateAll:
+		query = query.Unscoped()
+	}
+
+	if filter.Email != "" {
+		query = query.Where("LOWER(email) LIKE ? "+search.LikeEscape, search.Contains(filter.Email))
+	}
+
+	if filter.ExactEmail != "" {
+		query = query.Where("email = ?", strings.ToLower(filter.ExactEmail))
+	}
+
+	if filter.Role != "" {
+		query = query.Where("? = ANY(roles)", filter.Role)
+	}
+
+	if filter.Active != nil {
+		query = query.Where("active = ?", *filter.Active)
+	}
 
-	query := r.db.Model(&models.User{})
+	if filter.Name != "" {
+		// Matches the expression of the trigram index on users.
+		query = query.Where("LOWER(name) LIKE ? "+search.LikeEscape, search.Contains(filter.Name))
+	}
+
+	if len(filter.Roles) > 0 {
+		if filter.RoleMatch == RoleMatchAll {
+			query = query.Where("roles @> ?", pq.StringArray(filter.Roles))
+		} else {
+			query = query.Where("roles && ?", pq.StringArray(filter.Roles))
+		}
+	}
+
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if filter.UpdatedFrom != nil {
+		query = query.Where("updated_at >= ?", *filter.UpdatedFrom)
+	}
+	if filter.UpdatedTo != nil {
+		query = query.Where("updated_at <= ?", *filter.UpdatedTo)
+	}
+	return query
+}
 
-	if emailFilter != "" {
-		query = query.Where("LOWER(email) LIKE ?", "%"+strings.ToLower(emailFilter)+"%")
+// userSortColumn is the column a sort field orders by; empty means by ID.
+func userSortColumn(field string) string {
+	switch field {
+	case UserSortName:
+		return "LOWER(name)"
+	case UserSortEmail:
+		return "email"
+	case UserSortCreatedAt:
+		return "created_at"
 	}
+	return ""
+}
 
-	if roleFilter != "" {
-		query = query.Where("? = ANY(roles)", roleFilter)
+// userOrder turns a sort into an ORDER BY clause. Only known columns get
+// there, so the field can never inject SQL.
+func userOrder(sort UserSort) string {
+	column := userSortColumn(sort.Field)
+	if column == "" {
+		return "id"
 	}
+	direction := "ASC"
+	if sort.Desc {
+		direction = "DESC"
+	}
+	return column + " " + direction + ", id " + direction
+}
+
+// userKeyset limits a query to the users that come after the cursor in the
+// order userOrder gives.
+func userKeyset(query *gorm.DB, after *UserCursor, sort UserSort) *gorm.DB {
+	column := userSortColumn(sort.Field)
+	if column == "" {
+		return query.Where("id > ?", after.ID)
+	}
+	op := ">"
+	if sort.Desc {
+		op = "<"
+	}
+	value := "?"
+	if sort.Field == UserSortName {
+		value = "LOWER(?)"
+	}
+	return query.Where("("+column+", id) "+op+" ("+value+", ?)", after.Value, after.ID)
+}
+
+// GetUsers returns up to limit users after skipping offset of them, along
+// with the total number that match the filter.
+func (r *UserRepository) GetUsers(offset, limit int, filter UserFilter, sort UserSort) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := applyUserFilter(r.db.Model(&models.User{}), filter)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count users: %v", err)
 	}
 
-	offset := (page - 1) * limit
-	if err := query.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+	if err := query.Order(userOrder(sort)).Offset(offset).Limit(limit).Find(&users).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to fetch users: %v", err)
 	}
 
 	return users, total, nil
 }
+
+// GetUsersAfter returns up to limit users that follow the cursor, or the
+// first ones when it is nil. Unlike GetUsers it neither skips rows with an
+// offset nor counts them, so its cost does not grow with the page number.
+func (r *UserRepository) GetUsersAfter(after *UserCursor, limit int, filter UserFilter, sort UserSort) ([]models.User, error) {
+	var users []models.User
+
+	query := applyUserFilter(r.db.Model(&models.User{}), filter)
+	if after != nil {
+		query = userKeyset(query, after, sort)
+	}
+	if err := query.Order(userOrder(sort)).Limit(limit).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %v", err)
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) CountUsers(filter UserFilter) (int64, error) {
+	var total int64
+	err := applyUserFilter(r.db.Model(&models.User{}), filter).Count(&total).Error
+	return total, err
+}
+
+// EachUserBatch walks every user matching the filter in ID order, handing
+// them to fn in batches so that large exports never sit in memory at once.
+func (r *UserRepository) EachUserBatch(filter UserFilter, batchSize int, fn func(users []models.User) error) error {
+	var batch []models.User
+	query := applyUserFilter(r.db.Model(&models.User{}), filter)
+	return query.Order("id").FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// FindExistingEmails returns which of the given emails are already taken,
+// including by soft-deleted users, since the unique index still covers them.
+func (r *UserRepository) FindExistingEmails(emails []string) ([]string, error) {
+	var existing []string
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	err := r.db.Unscoped().Model(&models.User{}).Where("email IN ?", emails).Pluck("email", &existing).Error
+	return existing, err
+}
+
+// CreateUsers inserts all users in one transaction; either every row is
+// stored or none is.
+func (r *UserRepository) CreateUsers(users []models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&users, 100).Error
+	})
+}
@@ -3,14 +3,51 @@ package routers
 import (
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/handlers"
 	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
+	"github.com/SpiritFoxo/control-system-microservices/shared/permissions"
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterAdminRoutes(r *gin.RouterGroup, s *handlers.Server) {
 	h := s.UserHandler
 
-	r.POST("/users/register", middleware.RoleMiddleware(), h.RegisterUser)
-	r.GET("/users/:userId", middleware.RoleMiddleware(), h.GetUserByID)
-	r.PUT("/users/:userId", middleware.RoleMiddleware(), h.UpdateUser)
-	r.GET("/users", middleware.RoleMiddleware(), h.GetUsers)
+	r.POST("/users/register", middleware.PermissionMiddleware(permissions.UsersWrite), h.RegisterUser)
+	r.POST("/users/invite", middleware.PermissionMiddleware(permissions.UsersWrite), h.InviteUser)
+	r.POST("/users/import", middleware.PermissionMiddleware(permissions.UsersWrite), h.ImportUsers)
+	r.GET("/users/export", middleware.PermissionMiddleware(permissions.UsersRead), h.ExportUsers)
+	r.GET("/users/:userId", middleware.PermissionMiddleware(permissions.UsersRead), h.GetUserByID)
+	r.PUT("/users/:userId", middleware.PermissionMiddleware(permissions.UsersWrite), h.UpdateUser)
+	r.DELETE("/users/:userId", middleware.PermissionMiddleware(permissions.UsersWrite), h.DeleteUser)
+	r.GET("/users", middleware.PermissionMiddleware(permissions.UsersRead), h.GetUsers)
+	r.POST("/users/:userId/revoke-sessions", middleware.PermissionMiddleware(permissions.UsersWrite), h.RevokeUserSessions)
+	r.GET("/users/:userId/lock", middleware.PermissionMiddleware(permissions.UsersRead), h.GetUserLockStatus)
+	r.POST("/users/:userId/unlock", middleware.PermissionMiddleware(permissions.UsersWrite), h.UnlockUser)
+	r.POST("/users/:userId/deactivate", middleware.PermissionMiddleware(permissions.UsersWrite), h.DeactivateUser)
+	r.POST("/users/:userId/activate", middleware.PermissionMiddleware(permissions.UsersWrite), h.ActivateUser)
+	r.POST("/users/:userId/restore", middleware.PermissionMiddleware(permissions.UsersWrite), h.RestoreUser)
+	r.POST("/users/:userId/invite/resend", middleware.PermissionMiddleware(permissions.UsersWrite), h.ResendInvite)
+	r.DELETE("/users/:userId/invite", middleware.PermissionMiddleware(permissions.UsersWrite), h.RevokeInvite)
+	r.GET("/users/:userId/sessions", middleware.PermissionMiddleware(permissions.UsersRead), h.ListUserSessions)
+	r.DELETE("/users/:userId/sessions/:sessionId", middleware.PermissionMiddleware(permissions.UsersWrite), h.RevokeUserSession)
+	r.POST("/users/:userId/api-keys", middleware.PermissionMiddleware(permissions.UsersWrite), h.CreateAPIKey)
+	r.GET("/users/:userId/api-keys", middleware.PermissionMiddleware(permissions.UsersRead), h.ListAPIKeys)
+	r.DELETE("/users/:userId/api-keys/:keyId", middleware.PermissionMiddleware(permissions.UsersWrite), h.RevokeAPIKey)
+	r.POST("/users/:userId/oauth-clients", middleware.PermissionMiddleware(permissions.UsersWrite), h.CreateOAuthClient)
+	r.GET("/users/:userId/oauth-clients", middleware.PermissionMiddleware(permissions.UsersRead), h.ListOAuthClients)
+	r.DELETE("/users/:userId/oauth-clients/:clientId", middleware.PermissionMiddleware(permissions.UsersWrite), h.RevokeOAuthClient)
+
+	r.POST("/service-accounts", middleware.PermissionMiddleware(permissions.UsersWrite), h.CreateServiceAccount)
+
+	r.POST("/oidc-clients", middleware.PermissionMiddleware(permissions.ClientsManage), h.CreateOIDCClient)
+	r.GET("/oidc-clients", middleware.PermissionMiddleware(permissions.ClientsManage), h.ListOIDCClients)
+	r.DELETE("/oidc-clients/:clientId", middleware.PermissionMiddleware(permissions.ClientsManage), h.RevokeOIDCClient)
+
+	r.GET("/roles", middleware.PermissionMiddleware(permissions.RolesManage), h.ListRoles)
+	r.POST("/roles", middleware.PermissionMiddleware(permissions.RolesManage), h.CreateRole)
+	r.GET("/roles/:role", middleware.PermissionMiddleware(permissions.RolesManage), h.GetRole)
+	r.PATCH("/roles/:role", middleware.PermissionMiddleware(permissions.RolesManage), h.UpdateRole)
+	r.DELETE("/roles/:role", middleware.PermissionMiddleware(permissions.RolesManage), h.DeleteRole)
+	r.PUT("/roles/:role/permissions", middleware.PermissionMiddleware(permissions.RolesManage), h.SetRolePermissions)
+	r.GET("/permissions", middleware.PermissionMiddleware(permissions.RolesManage), h.ListPermissions)
+
+	r.GET("/audit", middleware.PermissionMiddleware(permissions.AuditRead), h.ListAuditEntries)
 }
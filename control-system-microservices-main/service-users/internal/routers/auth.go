@@ -9,4 +9,21 @@ func RegisterAuthRoutes(r *gin.RouterGroup, s *handlers.Server) {
 	h := s.UserHandler
 
 	r.POST("/login", h.LoginUser)
+	r.POST("/login/mfa", h.LoginMFA)
+	r.POST("/login/mfa/enroll", h.LoginMFAEnroll)
+	r.POST("/refresh", h.RefreshToken)
+	r.POST("/logout", h.Logout)
+	r.POST("/password/forgot", h.ForgotPassword)
+	r.POST("/password/reset", h.ResetPassword)
+	r.POST("/invite/accept", h.AcceptInvite)
+
+	r.GET("/me", h.GetMe)
+	r.PATCH("/me", h.UpdateMe)
+	r.POST("/me/password", h.ChangePassword)
+	r.POST("/me/mfa/totp", h.StartTOTP)
+	r.POST("/me/mfa/totp/confirm", h.ConfirmTOTP)
+	r.DELETE("/me/mfa/totp", h.DisableTOTP)
+	r.POST("/me/mfa/recovery-codes", h.RegenerateRecoveryCodes)
+	r.GET("/me/sessions", h.ListMySessions)
+	r.DELETE("/me/sessions/:sessionId", h.RevokeMySession)
 }
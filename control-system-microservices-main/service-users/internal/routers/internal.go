@@ -0,0 +1,17 @@
+package routers
+
+import (
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterInternalRoutes registers service-to-service endpoints. They are not
+// proxied by the api-gateway, and the group they are registered on must
+// require the internal API secret.
+func RegisterInternalRoutes(r *gin.RouterGroup, s *handlers.Server) {
+	h := s.UserHandler
+
+	r.GET("/tokens/status", h.TokenStatus)
+	r.POST("/api-keys/resolve", h.ResolveAPIKey)
+	r.GET("/users", h.LookupUsers)
+}
@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterOAuthRoutes registers the OAuth 2.0 and OpenID Connect endpoints.
+// Token and introspection callers authenticate with the credentials of a
+// registered client; the authorization endpoint is opened in a browser.
+func RegisterOAuthRoutes(r *gin.RouterGroup, s *handlers.Server) {
+	h := s.UserHandler
+
+	r.POST("/token", h.OAuthToken)
+	r.POST("/introspect", h.OAuthIntrospect)
+	r.GET("/authorize", h.Authorize)
+	r.POST("/authorize", h.AuthorizeLogin)
+	r.GET("/userinfo", h.UserInfo)
+	r.POST("/userinfo", h.UserInfo)
+}
@@ -0,0 +1,22 @@
+package routers
+
+import (
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterSCIMRoutes registers the SCIM 2.0 provisioning endpoints. They
+// authenticate the provisioning client themselves instead of relying on the
+// gateway's headers.
+func RegisterSCIMRoutes(r *gin.RouterGroup, s *handlers.Server) {
+	h := s.UserHandler
+
+	r.Use(h.SCIMAuth())
+	r.GET("/ServiceProviderConfig", h.SCIMServiceProviderConfig)
+	r.GET("/Users", h.SCIMListUsers)
+	r.POST("/Users", h.SCIMCreateUser)
+	r.GET("/Users/:id", h.SCIMGetUser)
+	r.PUT("/Users/:id", h.SCIMReplaceUser)
+	r.PATCH("/Users/:id", h.SCIMPatchUser)
+	r.DELETE("/Users/:id", h.SCIMDeleteUser)
+}
@@ -0,0 +1,13 @@
+package routers
+
+import (
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterWellKnownRoutes(r *gin.RouterGroup, s *handlers.Server) {
+	h := s.UserHandler
+
+	r.GET("/jwks.json", h.JWKS)
+	r.GET("/openid-configuration", h.OpenIDConfiguration)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+)
+
+// DeactivateUser blocks the account from logging in and revokes its sessions,
+// so tokens that were already issued stop passing the gateway's status check.
+func (s *UserService) DeactivateUser(actor Actor, id uint) (*UserResponse, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, ErrUserAlreadyDeactivated
+	}
+	entry := auditEntry(actor, AuditUserDeactivated, user.ID, models.AuditChanges{
+		"active": {Before: true, After: false},
+	})
+	if err := s.userRepo.UpdateUserAudited(user, map[string]interface{}{"active": false}, entry, holdsSuperadmin(user)); err != nil {
+		if errors.Is(err, repositories.ErrLastSuperadmin) {
+			return nil, ErrLastSuperadmin
+		}
+		return nil, fmt.Errorf("failed to deactivate user: %v", err)
+	}
+	if err := s.revokeSessions(user); err != nil {
+		return nil, err
+	}
+
+	user.Active = false
+	return toUserResponse(user), nil
+}
+
+func (s *UserService) ActivateUser(actor Actor, id uint) (*UserResponse, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
+	}
+	if user.Active {
+		return nil, ErrUserAlreadyActive
+	}
+
+	entry := auditEntry(actor, AuditUserActivated, user.ID, models.AuditChanges{
+		"active": {Before: false, After: true},
+	})
+	if err := s.userRepo.UpdateUserAudited(user, map[string]interface{}{"active": true}, entry, false); err != nil {
+		return nil, fmt.Errorf("failed to activate user: %v", err)
+	}
+
+	user.Active = true
+	return toUserResponse(user), nil
+}
+
+// DeleteUser soft-deletes the account. The row is kept so that it can be
+// restored, but it disappears from lookups, logins and token checks.
+func (s *UserService) DeleteUser(actor Actor, id uint) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return err
+	}
+
+	entry := auditEntry(actor, AuditUserDeleted, user.ID, models.AuditChanges{})
+	if err := s.userRepo.DeleteUser(user, entry, holdsSuperadmin(user)); err != nil {
+		if errors.Is(err, repositories.ErrLastSuperadmin) {
+			return ErrLastSuperadmin
+		}
+		return fmt.Errorf("failed to delete user: %v", err)
+	}
+	// Access tokens of a deleted user already fail the token check, which no
+	// longer finds the user; only the refresh tokens need revoking.
+	if err := s.tokenRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	return nil
+}
+
+func (s *UserService) RestoreUser(actor Actor, id uint) (*UserResponse, error) {
+	user, err := s.userRepo.GetDeletedUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
+	}
+	// The address may have been given to a new user in the meantime.
+	if _, err := s.userRepo.GetUserByEmail(user.Email); err == nil {
+		return nil, ErrEmailExists
+	}
+
+	entry := auditEntry(actor, AuditUserRestored, user.ID, models.AuditChanges{})
+	if err := s.userRepo.RestoreUser(user, entry); err != nil {
+		return nil, fmt.Errorf("failed to restore user: %v", err)
+	}
+	return toUserResponse(user), nil
+}
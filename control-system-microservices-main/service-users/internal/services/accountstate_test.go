@@ -0,0 +1,168 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+)
+
+func TestUserService_DeactivateUser(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	tests := []struct {
+		name        string
+		prepare     func(user *models.User)
+		setupMock   func(user *models.User)
+		expectedErr string
+	}{
+		{
+			name: "успешная деактивация",
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().UpdateUserAudited(user, map[string]interface{}{"active": false}, gomock.Any(), false).Return(nil)
+				mockRepo.EXPECT().IncrementTokenVersion(user).Return(nil)
+				mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+			},
+		},
+		{
+			name: "уже деактивирован",
+			prepare: func(user *models.User) {
+				user.Active = false
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expectedErr: "user is already deactivated",
+		},
+		{
+			name: "не найден",
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return((*models.User)(nil), assert.AnError)
+			},
+			expectedErr: "user not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+			if tt.prepare != nil {
+				tt.prepare(user)
+			}
+			tt.setupMock(user)
+
+			got, err := service.DeactivateUser(testAdmin, 1)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, got.Active)
+			}
+		})
+	}
+}
+
+func TestUserService_ActivateUser(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	user.Active = false
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().
+		UpdateUserAudited(user, map[string]interface{}{"active": true}, gomock.Any(), false).
+		DoAndReturn(func(_ *models.User, _ map[string]interface{}, entry *models.AuditEntry, _ bool) error {
+			assert.Equal(t, AuditUserActivated, entry.Action)
+			assert.Equal(t, uint(1), entry.TargetUserID)
+			return nil
+		})
+
+	got, err := service.ActivateUser(testAdmin, 1)
+	assert.NoError(t, err)
+	assert.True(t, got.Active)
+}
+
+func TestUserService_DeleteUser(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().DeleteUser(user, &models.AuditEntry{
+		ActorID:      testAdmin.ID,
+		TargetUserID: 1,
+		Action:       AuditUserDeleted,
+		Changes:      models.AuditChanges{},
+	}, false).Return(nil)
+	mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+
+	assert.NoError(t, service.DeleteUser(testAdmin, 1))
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	tests := []struct {
+		name        string
+		setupMock   func()
+		expectedErr string
+	}{
+		{
+			name: "успешное восстановление",
+			setupMock: func() {
+				user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+				user.DeletedAt = gorm.DeletedAt{Valid: true}
+				mockRepo.EXPECT().GetDeletedUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().GetUserByEmail("test@example.com").Return((*models.User)(nil), gorm.ErrRecordNotFound)
+				mockRepo.EXPECT().
+					RestoreUser(user, gomock.Any()).
+					DoAndReturn(func(u *models.User, entry *models.AuditEntry) error {
+						assert.Equal(t, AuditUserRestored, entry.Action)
+						u.DeletedAt = gorm.DeletedAt{}
+						return nil
+					})
+			},
+		},
+		{
+			name: "адрес занят новым пользователем",
+			setupMock: func() {
+				user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+				user.DeletedAt = gorm.DeletedAt{Valid: true}
+				mockRepo.EXPECT().GetDeletedUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(newTestUser(5, "test@example.com", "New User", userroles.RoleEngineer), nil)
+			},
+			expectedErr: "email already exists",
+		},
+		{
+			name: "пользователь не удалён",
+			setupMock: func() {
+				mockRepo.EXPECT().GetDeletedUserByID(uint(1)).Return((*models.User)(nil), assert.AnError)
+			},
+			expectedErr: "user not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			got, err := service.RestoreUser(testAdmin, 1)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Nil(t, got.DeletedAt)
+			}
+		})
+	}
+}
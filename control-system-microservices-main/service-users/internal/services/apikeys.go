@@ -0,0 +1,353 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+)
+
+const (
+	AuditServiceAccountCreated = "service_account.created"
+	AuditAPIKeyCreated         = "api_key.created"
+	AuditAPIKeyRevoked         = "api_key.revoked"
+)
+
+// API keys look like csk_<prefix>_<secret>. The prefix is stored in clear so
+// that a key can be found without scanning, and is safe to show in listings.
+// It is long enough that two keys practically never share it; keys issued
+// with the shorter legacy prefix keep working.
+const (
+	apiKeyMarker            = "csk_"
+	apiKeyPrefixBytes       = 12
+	legacyAPIKeyPrefixChars = 8
+	serviceAccountDomain    = "service-accounts.local"
+	// lastUsedAt is only rewritten this often; the gateway caches resolved
+	// keys anyway, so the timestamp is approximate by nature.
+	apiKeyTouchInterval = time.Minute
+)
+
+type CreateServiceAccountInput struct {
+	Username string   `json:"username" binding:"required"`
+	Name     string   `json:"name" binding:"required"`
+	Roles    []string `json:"roles"`
+}
+
+type CreateAPIKeyInput struct {
+	Name string `json:"name" binding:"required"`
+	// Scopes narrow the key to a subset of the account's permissions, and to
+	// the roles that grant nothing beyond them. A key without scopes carries
+	// every role and permission of the account.
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ResolveAPIKeyInput struct {
+	Key string `json:"key" binding:"required"`
+}
+
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAPIKey is the only response that ever contains the full key.
+type CreatedAPIKey struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// APIKeyIdentity is what the api-gateway forwards for a request made with a key.
+type APIKeyIdentity struct {
+	UserID      uint     `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	KeyID       uint     `json:"key_id"`
+}
+
+func toAPIKeyResponse(key *models.APIKey) APIKeyResponse {
+	scopes := []string(key.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     apiKeyMarker + key.Prefix,
+		Scopes:     scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func generateAPIKey() (prefix, key string, err error) {
+	raw := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret, err := utils.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(raw)
+	return prefix, apiKeyMarker + prefix + "_" + secret, nil
+}
+
+// parseAPIKeyPrefix extracts the lookup prefix from a presented key.
+func parseAPIKeyPrefix(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyMarker)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || secret == "" {
+		return "", false
+	}
+	if len(prefix) != hex.EncodedLen(apiKeyPrefixBytes) && len(prefix) != legacyAPIKeyPrefixChars {
+		return "", false
+	}
+	return prefix, true
+}
+
+// CreateServiceAccount creates a user that has no password and can only
+// authenticate with API keys issued to it.
+func (s *UserService) CreateServiceAccount(actor Actor, input CreateServiceAccountInput) (*UserResponse, error) {
+	if !slugPattern.MatchString(input.Username) {
+		return nil, ErrInvalidServiceAccountName
+	}
+	roles, err := s.validateRoles(input.Roles)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrant(actor, roles); err != nil {
+		return nil, err
+	}
+
+	email := input.Username + "@" + serviceAccountDomain
+	if _, err := s.userRepo.GetUserByEmail(email); err == nil {
+		return nil, ErrServiceAccountExists
+	}
+
+	user := models.User{
+		Email:          email,
+		Name:           input.Name,
+		Roles:          roles,
+		Active:         true,
+		ServiceAccount: true,
+	}
+	entry := auditEntry(actor, AuditServiceAccountCreated, 0, profileChanges("", nil, user.Name, user.Roles))
+	if err := s.userRepo.CreateUser(&user, entry); err != nil {
+		return nil, fmt.Errorf("failed to create service account: %v", err)
+	}
+
+	return toUserResponse(&user), nil
+}
+
+// getServiceAccount loads a service account the actor is allowed to manage.
+func (s *UserService) getServiceAccount(actor Actor, userID uint) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.ServiceAccount {
+		return nil, ErrNotServiceAccount
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkScopes fails unless every scope is a permission of the account's roles.
+func (s *UserService) checkScopes(user *models.User, scopes []string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+	granted, err := s.roleRepo.GetPermissionsForRoles(user.Roles)
+	if err != nil {
+		return fmt.Errorf("failed to get permissions: %v", err)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return withValue(ErrScopeNotGranted, scope, "scopes")
+		}
+	}
+	return nil
+}
+
+// scopedPermissions is the account's current permissions narrowed to the
+// scopes, or all of them when there are none. Scopes are checked against the
+// roles again because the roles or their permissions may have changed since
+// the credential was issued.
+func (s *UserService) scopedPermissions(user *models.User, scopes []string) ([]string, error) {
+	permissions, err := s.roleRepo.GetPermissionsForRoles(user.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %v", err)
+	}
+	if len(scopes) > 0 {
+		permissions = slices.DeleteFunc(permissions, func(permission string) bool {
+			return !slices.Contains(scopes, permission)
+		})
+	}
+	return permissions, nil
+}
+
+// scopedRoles is the account's roles narrowed to those whose permissions all
+// lie within the scopes, or all of them when there are none. The services
+// still read roles for some decisions, such as who may manage whom, so a
+// scoped credential must not carry a role that grants more than its scopes.
+func (s *UserService) scopedRoles(user *models.User, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return user.Roles, nil
+	}
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		permissions, err := s.roleRepo.GetPermissionsForRoles([]string{role})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get permissions: %v", err)
+		}
+		if !slices.ContainsFunc(permissions, func(permission string) bool {
+			return !slices.Contains(scopes, permission)
+		}) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// CreateAPIKey issues a key to a service account. The plaintext key is
+// returned once and cannot be recovered afterwards.
+func (s *UserService) CreateAPIKey(actor Actor, userID uint, input CreateAPIKeyInput) (*CreatedAPIKey, error) {
+	user, err := s.getServiceAccount(actor, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, ErrExpiryInPast
+	}
+
+	if err := s.checkScopes(user, input.Scopes); err != nil {
+		return nil, err
+	}
+
+	prefix, plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %v", err)
+	}
+	key := models.APIKey{
+		UserID:    user.ID,
+		Name:      input.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(plaintext),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	entry := auditEntry(actor, AuditAPIKeyCreated, user.ID, models.AuditChanges{
+		"api_key": {Before: nil, After: apiKeyMarker + key.Prefix},
+	})
+	if err := s.tokenRepo.CreateAPIKey(&key, entry); err != nil {
+		return nil, fmt.Errorf("failed to create api key: %v", err)
+	}
+
+	return &CreatedAPIKey{APIKeyResponse: toAPIKeyResponse(&key), Key: plaintext}, nil
+}
+
+// ListAPIKeys returns every key of the account, revoked ones included.
+func (s *UserService) ListAPIKeys(userID uint) ([]APIKeyResponse, error) {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	keys, err := s.tokenRepo.GetUserAPIKeys(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %v", err)
+	}
+	response := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, toAPIKeyResponse(&keys[i]))
+	}
+	return response, nil
+}
+
+// RevokeAPIKey disables a key. The gateway may keep accepting it until its
+// cached resolution expires.
+func (s *UserService) RevokeAPIKey(actor Actor, userID, keyID uint) error {
+	user, err := s.getServiceAccount(actor, userID)
+	if err != nil {
+		return err
+	}
+	key, err := s.tokenRepo.GetAPIKeyByID(keyID)
+	if err != nil || key.UserID != user.ID || key.RevokedAt != nil {
+		return ErrAPIKeyNotFound
+	}
+	entry := auditEntry(actor, AuditAPIKeyRevoked, user.ID, models.AuditChanges{
+		"api_key": {Before: apiKeyMarker + key.Prefix, After: nil},
+	})
+	if err := s.tokenRepo.RevokeAPIKey(key, entry); err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+	return nil
+}
+
+// ResolveAPIKey is called by the api-gateway to turn a presented key into
+// the identity of its service account.
+func (s *UserService) ResolveAPIKey(plaintext string) (*APIKeyIdentity, error) {
+	invalid := ErrInvalidAPIKey
+
+	prefix, ok := parseAPIKeyPrefix(plaintext)
+	if !ok {
+		return nil, invalid
+	}
+	key, err := s.tokenRepo.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		return nil, invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(plaintext))) != 1 {
+		return nil, invalid
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) {
+		return nil, invalid
+	}
+
+	user, err := s.userRepo.GetUserByID(key.UserID)
+	if err != nil || !user.Active || !user.ServiceAccount {
+		return nil, invalid
+	}
+
+	permissions, err := s.scopedPermissions(user, key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.scopedRoles(user, key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.tokenRepo.TouchAPIKey(key, now); err != nil {
+			log.Printf("Failed to record use of api key %d: %v", key.ID, err)
+		}
+	}
+
+	return &APIKeyIdentity{
+		UserID:      user.ID,
+		Roles:       roles,
+		Permissions: permissions,
+		KeyID:       key.ID,
+	}, nil
+}
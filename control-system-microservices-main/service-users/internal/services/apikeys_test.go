@@ -0,0 +1,410 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/middleware"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestServiceAccount(id uint, roles ...string) *models.User {
+	user := newTestUser(id, "ci-bot@service-accounts.local", "CI bot", roles...)
+	user.Password = ""
+	user.ServiceAccount = true
+	return user
+}
+
+func TestUserService_CreateServiceAccount(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	tests := []struct {
+		name        string
+		actor       Actor
+		input       CreateServiceAccountInput
+		setupMock   func()
+		expectedErr string
+	}{
+		{
+			name:  "успешное создание",
+			actor: testAdmin,
+			input: CreateServiceAccountInput{Username: "ci-bot", Name: "CI bot", Roles: []string{userroles.RoleObserver}},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUserByEmail("ci-bot@service-accounts.local").Return(nil, errors.New("not found"))
+				mockRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(user *models.User, _ *models.AuditEntry) error {
+					assert.True(t, user.ServiceAccount)
+					assert.Empty(t, user.Password)
+					user.ID = 7
+					return nil
+				})
+			},
+		},
+		{
+			name:        "недопустимое имя",
+			actor:       testAdmin,
+			input:       CreateServiceAccountInput{Username: "CI Bot", Name: "CI bot"},
+			setupMock:   func() {},
+			expectedErr: "invalid service account username",
+		},
+		{
+			name:        "роль выше своей",
+			actor:       testAdmin,
+			input:       CreateServiceAccountInput{Username: "ci-bot", Name: "CI bot", Roles: []string{userroles.RoleSuperadmin}},
+			setupMock:   func() {},
+			expectedErr: "insufficient privileges to grant role: superadmin",
+		},
+		{
+			name:  "уже существует",
+			actor: testAdmin,
+			input: CreateServiceAccountInput{Username: "ci-bot", Name: "CI bot"},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUserByEmail("ci-bot@service-accounts.local").Return(newTestServiceAccount(7), nil)
+			},
+			expectedErr: "service account already exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			got, err := service.CreateServiceAccount(tt.actor, tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(7), got.ID)
+				assert.True(t, got.ServiceAccount)
+			}
+		})
+	}
+}
+
+func TestUserService_CreateAPIKey(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		user        *models.User
+		input       CreateAPIKeyInput
+		expectKey   bool
+		expectedErr string
+	}{
+		{
+			name:      "успешный выпуск",
+			user:      newTestServiceAccount(7, userroles.RoleObserver),
+			input:     CreateAPIKeyInput{Name: "deploy", Scopes: []string{models.PermissionOrdersRead}},
+			expectKey: true,
+		},
+		{
+			name:        "обычный пользователь",
+			user:        newTestUser(7, "user@example.com", "User", userroles.RoleObserver),
+			input:       CreateAPIKeyInput{Name: "deploy"},
+			expectedErr: "user is not a service account",
+		},
+		{
+			name:        "scope вне ролей",
+			user:        newTestServiceAccount(7, userroles.RoleObserver),
+			input:       CreateAPIKeyInput{Name: "deploy", Scopes: []string{models.PermissionOrdersDelete}},
+			expectedErr: "scope not granted to the service account: orders:delete",
+		},
+		{
+			name:        "срок в прошлом",
+			user:        newTestServiceAccount(7, userroles.RoleObserver),
+			input:       CreateAPIKeyInput{Name: "deploy", ExpiresAt: &past},
+			expectedErr: "expiry must be in the future",
+		},
+		{
+			name:        "аккаунт с ролью выше",
+			user:        newTestServiceAccount(7, userroles.RoleSuperadmin),
+			input:       CreateAPIKeyInput{Name: "deploy"},
+			expectedErr: "insufficient privileges to manage this user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetUserByID(uint(7)).Return(tt.user, nil)
+			var stored *models.APIKey
+			if tt.expectKey {
+				mockTokenRepo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).DoAndReturn(func(key *models.APIKey, _ *models.AuditEntry) error {
+					key.ID = 3
+					stored = key
+					return nil
+				})
+			}
+
+			got, err := service.CreateAPIKey(testAdmin, 7, tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(got.Key, got.Prefix+"_"))
+			assert.Equal(t, "csk_"+stored.Prefix, got.Prefix)
+			assert.Len(t, stored.Prefix, 24)
+			assert.Equal(t, utils.HashToken(got.Key), stored.KeyHash)
+		})
+	}
+}
+
+func TestUserService_ResolveAPIKey(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	const plaintext = "csk_0a1b2c3d4e5f60718293a4b5_secretsecretsecret"
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	recently := now.Add(-10 * time.Second)
+
+	newKey := func() *models.APIKey {
+		return &models.APIKey{ID: 3, UserID: 7, Prefix: "0a1b2c3d4e5f60718293a4b5", KeyHash: utils.HashToken(plaintext)}
+	}
+
+	tests := []struct {
+		name        string
+		presented   string
+		key         func() *models.APIKey
+		user        *models.User
+		expectTouch bool
+		expected    *APIKeyIdentity
+		expectedErr string
+	}{
+		{
+			name:        "ключ без scopes",
+			presented:   plaintext,
+			key:         newKey,
+			user:        newTestServiceAccount(7, userroles.RoleObserver),
+			expectTouch: true,
+			expected: &APIKeyIdentity{
+				UserID:      7,
+				Roles:       []string{userroles.RoleObserver},
+				Permissions: models.DefaultRolePermissions[userroles.RoleObserver],
+				KeyID:       3,
+			},
+		},
+		{
+			name:      "scopes сужают права",
+			presented: plaintext,
+			key: func() *models.APIKey {
+				key := newKey()
+				key.Scopes = []string{models.PermissionOrdersRead}
+				key.LastUsedAt = &recently
+				return key
+			},
+			user: newTestServiceAccount(7, userroles.RoleObserver),
+			expected: &APIKeyIdentity{
+				UserID:      7,
+				Roles:       []string{},
+				Permissions: []string{models.PermissionOrdersRead},
+				KeyID:       3,
+			},
+		},
+		{
+			name:      "роль остаётся, если scopes покрывают её права",
+			presented: plaintext,
+			key: func() *models.APIKey {
+				key := newKey()
+				key.Scopes = []string{models.PermissionOrdersRead, models.PermissionOrdersCreate}
+				key.LastUsedAt = &recently
+				return key
+			},
+			user: newTestServiceAccount(7, userroles.RoleObserver, userroles.RoleAdmin),
+			expected: &APIKeyIdentity{
+				UserID:      7,
+				Roles:       []string{userroles.RoleObserver},
+				Permissions: []string{models.PermissionOrdersRead, models.PermissionOrdersCreate},
+				KeyID:       3,
+			},
+		},
+		{
+			name:        "неверный секрет",
+			presented:   "csk_0a1b2c3d4e5f60718293a4b5_wrongsecret",
+			key:         newKey,
+			expectedErr: "invalid api key",
+		},
+		{
+			name:      "отозван",
+			presented: plaintext,
+			key: func() *models.APIKey {
+				key := newKey()
+				key.RevokedAt = &past
+				return key
+			},
+			expectedErr: "invalid api key",
+		},
+		{
+			name:      "истёк",
+			presented: plaintext,
+			key: func() *models.APIKey {
+				key := newKey()
+				key.ExpiresAt = &past
+				return key
+			},
+			expectedErr: "invalid api key",
+		},
+		{
+			name:      "аккаунт деактивирован",
+			presented: plaintext,
+			key:       newKey,
+			user: func() *models.User {
+				user := newTestServiceAccount(7, userroles.RoleObserver)
+				user.Active = false
+				return user
+			}(),
+			expectedErr: "invalid api key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTokenRepo.EXPECT().GetAPIKeyByPrefix("0a1b2c3d4e5f60718293a4b5").Return(tt.key(), nil)
+			if tt.user != nil {
+				mockRepo.EXPECT().GetUserByID(uint(7)).Return(tt.user, nil)
+			}
+			if tt.expectTouch {
+				mockTokenRepo.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			got, err := service.ResolveAPIKey(tt.presented)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("ключ с коротким префиксом прежнего формата", func(t *testing.T) {
+		const legacy = "csk_0a1b2c3d_secretsecretsecret"
+		mockTokenRepo.EXPECT().
+			GetAPIKeyByPrefix("0a1b2c3d").
+			Return(&models.APIKey{ID: 4, UserID: 7, Prefix: "0a1b2c3d", KeyHash: utils.HashToken(legacy), LastUsedAt: &recently}, nil)
+		mockRepo.EXPECT().GetUserByID(uint(7)).Return(newTestServiceAccount(7, userroles.RoleObserver), nil)
+
+		got, err := service.ResolveAPIKey(legacy)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(4), got.KeyID)
+	})
+
+	for _, presented := range []string{"Bearer something", "csk_0a1b2c_secret", "csk_0a1b2c3d4e5f60718293a4b5_"} {
+		t.Run("не ключ: "+presented, func(t *testing.T) {
+			got, err := service.ResolveAPIKey(presented)
+			assert.EqualError(t, err, "invalid api key")
+			assert.Nil(t, got)
+		})
+	}
+}
+
+// TestUserService_ResolveAPIKey_OutOfScope follows a scoped key the way the
+// gateway does: the resolved identity is forwarded in headers, and a route
+// needing a permission outside the scopes must reject it.
+func TestUserService_ResolveAPIKey_OutOfScope(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+	gin.SetMode(gin.TestMode)
+
+	const plaintext = "csk_0a1b2c3d_secretsecretsecret"
+	recently := time.Now().Add(-10 * time.Second)
+	mockTokenRepo.EXPECT().GetAPIKeyByPrefix("0a1b2c3d").Return(&models.APIKey{
+		ID: 3, UserID: 7, Prefix: "0a1b2c3d", KeyHash: utils.HashToken(plaintext),
+		Scopes: []string{models.PermissionUsersRead}, LastUsedAt: &recently,
+	}, nil)
+	mockRepo.EXPECT().GetUserByID(uint(7)).Return(newTestServiceAccount(7, userroles.RoleAdmin), nil)
+
+	identity, err := service.ResolveAPIKey(plaintext)
+	assert.NoError(t, err)
+	assert.Empty(t, identity.Roles)
+
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/users", middleware.PermissionMiddleware(models.PermissionUsersRead), ok)
+	r.POST("/users", middleware.PermissionMiddleware(models.PermissionUsersWrite), ok)
+
+	tests := []struct {
+		name         string
+		method       string
+		expectedCode int
+	}{
+		{name: "в пределах scope", method: http.MethodGet, expectedCode: http.StatusOK},
+		{name: "вне scope", method: http.MethodPost, expectedCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users", nil)
+			req.Header.Set("X-User-ID", strconv.FormatUint(uint64(identity.UserID), 10))
+			req.Header.Set("X-User-Roles", strings.Join(identity.Roles, ","))
+			req.Header.Set("X-User-Permissions", strings.Join(identity.Permissions, ","))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestUserService_RevokeAPIKey(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	tests := []struct {
+		name        string
+		key         *models.APIKey
+		expectedErr string
+	}{
+		{
+			name: "успешный отзыв",
+			key:  &models.APIKey{ID: 3, UserID: 7, Prefix: "0a1b2c3d"},
+		},
+		{
+			name:        "ключ другого аккаунта",
+			key:         &models.APIKey{ID: 3, UserID: 8, Prefix: "0a1b2c3d"},
+			expectedErr: "api key not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetUserByID(uint(7)).Return(newTestServiceAccount(7, userroles.RoleObserver), nil)
+			mockTokenRepo.EXPECT().GetAPIKeyByID(uint(3)).Return(tt.key, nil)
+			if tt.expectedErr == "" {
+				mockTokenRepo.EXPECT().RevokeAPIKey(tt.key, gomock.Any()).Return(nil)
+			}
+
+			err := service.RevokeAPIKey(testAdmin, 7, 3)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_LoginUser_ServiceAccount(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	mockRepo.EXPECT().GetUserByEmail("ci-bot@service-accounts.local").Return(newTestServiceAccount(7, userroles.RoleObserver), nil)
+
+	got, err := service.LoginUser("ci-bot@service-accounts.local", "", testClient)
+	assert.EqualError(t, err, "invalid email or password")
+	assert.Nil(t, got)
+}
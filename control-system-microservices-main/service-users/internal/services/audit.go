@@ -0,0 +1,126 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+)
+
+const (
+	AuditUserRegistered  = "user.registered"
+	AuditUserInvited     = "user.invited"
+	AuditUserUpdated     = "user.updated"
+	AuditUserDeactivated = "user.deactivated"
+	AuditUserActivated   = "user.activated"
+	AuditUserDeleted     = "user.deleted"
+	AuditUserRestored    = "user.restored"
+	AuditUserUnlocked    = "user.unlocked"
+	AuditPasswordReset   = "user.password_reset"
+	AuditPasswordChanged = "user.password_changed"
+)
+
+type AuditEntryResponse struct {
+	ID           uint                `json:"id"`
+	ActorID      uint                `json:"actor_id"`
+	TargetUserID uint                `json:"target_user_id"`
+	Action       string              `json:"action"`
+	Changes      models.AuditChanges `json:"changes"`
+	RequestID    string              `json:"request_id,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+type AuditListInput struct {
+	Page         int
+	Limit        int
+	ActorID      uint
+	TargetUserID uint
+	From         *time.Time
+	To           *time.Time
+}
+
+type AuditListResult struct {
+	Entries    []AuditEntryResponse `json:"entries"`
+	Total      int64                `json:"total"`
+	Page       int                  `json:"page"`
+	Limit      int                  `json:"limit"`
+	TotalPages int                  `json:"total_pages"`
+}
+
+// auditEntry builds the record of a change. The repositories write it in the
+// same transaction as the change, so neither is stored without the other.
+func auditEntry(actor Actor, action string, target uint, changes models.AuditChanges) *models.AuditEntry {
+	return &models.AuditEntry{
+		ActorID:      actor.ID,
+		TargetUserID: target,
+		Action:       action,
+		Changes:      changes,
+		RequestID:    actor.RequestID,
+	}
+}
+
+// profileChanges diffs the audited fields of a user.
+func profileChanges(beforeName string, beforeRoles []string, afterName string, afterRoles []string) models.AuditChanges {
+	changes := models.AuditChanges{}
+	if beforeName != afterName {
+		changes["name"] = models.FieldChange{Before: beforeName, After: afterName}
+	}
+	if !slices.Equal(beforeRoles, afterRoles) {
+		changes["roles"] = models.FieldChange{Before: beforeRoles, After: afterRoles}
+	}
+	return changes
+}
+
+// creationChanges records the profile of a new account, and that it starts
+// out deactivated if it does.
+func creationChanges(user *models.User) models.AuditChanges {
+	changes := profileChanges("", nil, user.Name, user.Roles)
+	if !user.Active {
+		changes["active"] = models.FieldChange{Before: nil, After: false}
+	}
+	return changes
+}
+
+func (s *UserService) ListAuditEntries(input AuditListInput) (*AuditListResult, error) {
+	if input.Page < 1 {
+		return nil, ErrInvalidPage
+	}
+	if input.Limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+	if input.From != nil && input.To != nil && !input.From.Before(*input.To) {
+		return nil, ErrInvalidTimeRange
+	}
+
+	entries, total, err := s.auditRepo.GetAuditEntries(input.Page, input.Limit, repositories.AuditFilter{
+		ActorID:      input.ActorID,
+		TargetUserID: input.TargetUserID,
+		From:         input.From,
+		To:           input.To,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]AuditEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, AuditEntryResponse{
+			ID:           entry.ID,
+			ActorID:      entry.ActorID,
+			TargetUserID: entry.TargetUserID,
+			Action:       entry.Action,
+			Changes:      entry.Changes,
+			RequestID:    entry.RequestID,
+			CreatedAt:    entry.CreatedAt,
+		})
+	}
+
+	return &AuditListResult{
+		Entries:    response,
+		Total:      total,
+		Page:       input.Page,
+		Limit:      input.Limit,
+		TotalPages: int((total + int64(input.Limit) - 1) / int64(input.Limit)),
+	}, nil
+}
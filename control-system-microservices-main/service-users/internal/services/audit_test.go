@@ -0,0 +1,110 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUserService_UpdateUser_Audit(t *testing.T) {
+	service, mockRepo, _, _, finish := setupAuditTest(t)
+	defer finish()
+
+	actor := Actor{ID: 100, Roles: []string{userroles.RoleAdmin}, RequestID: "req-1"}
+	name := "New Name"
+	roles := []string{userroles.RoleManager}
+
+	t.Run("изменения имени и ролей", func(t *testing.T) {
+		user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+		mockRepo.EXPECT().
+			UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).
+			DoAndReturn(func(_ *models.User, _ map[string]interface{}, entry *models.AuditEntry, _ bool) error {
+				assert.Equal(t, uint(100), entry.ActorID)
+				assert.Equal(t, uint(1), entry.TargetUserID)
+				assert.Equal(t, AuditUserUpdated, entry.Action)
+				assert.Equal(t, "req-1", entry.RequestID)
+				assert.Equal(t, models.AuditChanges{
+					"name":  {Before: "Test User", After: "New Name"},
+					"roles": {Before: []string{userroles.RoleEngineer}, After: []string{userroles.RoleManager}},
+				}, entry.Changes)
+				return nil
+			})
+
+		got, err := service.UpdateUser(actor, 1, EditUserInput{Name: &name, Roles: &roles})
+		assert.NoError(t, err)
+		assert.Equal(t, "New Name", got.Name)
+	})
+
+	t.Run("без фактических изменений", func(t *testing.T) {
+		user := newTestUser(1, "test@example.com", "New Name", userroles.RoleManager)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+		mockRepo.EXPECT().UpdateUserAudited(user, gomock.Any(), gomock.Nil(), false).Return(nil)
+
+		_, err := service.UpdateUser(actor, 1, EditUserInput{Name: &name, Roles: &roles})
+		assert.NoError(t, err)
+	})
+
+	t.Run("ошибка записи аудита отменяет обновление", func(t *testing.T) {
+		user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+		mockRepo.EXPECT().UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).Return(assert.AnError)
+
+		got, err := service.UpdateUser(actor, 1, EditUserInput{Name: &name})
+		assert.Error(t, err)
+		assert.Nil(t, got)
+		assert.Equal(t, "Test User", user.Name)
+	})
+}
+
+func TestUserService_DeactivateUser_Audit(t *testing.T) {
+	service, mockRepo, mockTokenRepo, _, finish := setupAuditTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().UpdateUserAudited(user, map[string]interface{}{"active": false}, &models.AuditEntry{
+		ActorID:      testAdmin.ID,
+		TargetUserID: 1,
+		Action:       AuditUserDeactivated,
+		Changes:      models.AuditChanges{"active": {Before: true, After: false}},
+	}, false).Return(nil)
+	mockRepo.EXPECT().IncrementTokenVersion(user).Return(nil)
+	mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+
+	_, err := service.DeactivateUser(testAdmin, 1)
+	assert.NoError(t, err)
+}
+
+func TestUserService_ListAuditEntries(t *testing.T) {
+	service, _, _, mockAuditRepo, finish := setupAuditTest(t)
+	defer finish()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 3, 0)
+
+	t.Run("фильтры передаются в репозиторий", func(t *testing.T) {
+		mockAuditRepo.EXPECT().
+			GetAuditEntries(1, 2, repositories.AuditFilter{ActorID: 100, From: &from, To: &to}).
+			Return([]models.AuditEntry{
+				{ID: 2, ActorID: 100, TargetUserID: 1, Action: AuditUserUpdated},
+				{ID: 1, ActorID: 100, TargetUserID: 1, Action: AuditUserRegistered},
+			}, int64(3), nil)
+
+		got, err := service.ListAuditEntries(AuditListInput{Page: 1, Limit: 2, ActorID: 100, From: &from, To: &to})
+		assert.NoError(t, err)
+		assert.Len(t, got.Entries, 2)
+		assert.Equal(t, AuditUserUpdated, got.Entries[0].Action)
+		assert.Equal(t, 2, got.TotalPages)
+	})
+
+	t.Run("неверный интервал", func(t *testing.T) {
+		_, err := service.ListAuditEntries(AuditListInput{Page: 1, Limit: 10, From: &to, To: &from})
+		assert.EqualError(t, err, "invalid time range")
+	})
+}
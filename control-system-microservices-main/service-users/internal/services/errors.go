@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies domain errors. Handlers turn the kind into an HTTP
+// status, so services never need to know about HTTP.
+type ErrorKind int
+
+const (
+	// KindInternal is the kind of every error that is not an *Error.
+	KindInternal ErrorKind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindLocked
+)
+
+// Error is a domain error with a stable, machine-readable code. The message
+// is meant for people and may change; clients should look at the code.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	// Fields names the input fields that failed validation, if any.
+	Fields []FieldError
+	// sentinel is the error this one was narrowed from, if any.
+	sentinel *Error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches the sentinel an error was narrowed from, so errors.Is(err,
+// ErrInvalidRole) also holds for an error that names the offending role.
+// Sentinels are told apart by identity, since some share a code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t == e.sentinel
+}
+
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// root is the sentinel e stands for: the one it was narrowed from, or e
+// itself.
+func (e *Error) root() *Error {
+	if e.sentinel != nil {
+		return e.sentinel
+	}
+	return e
+}
+
+// KindOf reports the kind of err; plain errors are internal.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// withValue returns a copy of the sentinel whose message names the value.
+func withValue(sentinel *Error, value interface{}, field string) *Error {
+	e := *sentinel
+	e.sentinel = sentinel.root()
+	e.Message = fmt.Sprintf("%s: %v", sentinel.Message, value)
+	if field != "" {
+		e.Fields = []FieldError{{Field: field, Message: e.Message}}
+	}
+	return &e
+}
+
+// requiredFields reports missing input. The message is kept as the services
+// always worded it; the fields list what exactly is missing.
+func requiredFields(message string, fields ...string) *Error {
+	e := NewError(KindInvalid, "required", message)
+	for _, field := range fields {
+		e.Fields = append(e.Fields, FieldError{Field: field, Message: "is required"})
+	}
+	return e
+}
+
+// missingFields narrows a requiredFields sentinel to the fields that are
+// actually empty. Values are given in the order the sentinel lists its fields.
+func missingFields(sentinel *Error, values ...string) error {
+	e := *sentinel
+	e.sentinel = sentinel.root()
+	e.Fields = nil
+	for i, value := range values {
+		if value == "" {
+			e.Fields = append(e.Fields, sentinel.Fields[i])
+		}
+	}
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return &e
+}
+
+// Users and accounts.
+var (
+	ErrUserNotFound           = NewError(KindNotFound, "user_not_found", "user not found")
+	ErrRegisterFieldsRequired = requiredFields("email, password, and name are required", "email", "password", "name")
+	ErrInviteFieldsRequired   = requiredFields("email and name are required", "email", "name")
+	ErrNameEmpty              = &Error{Kind: KindInvalid, Code: "invalid_name", Message: "name cannot be empty", Fields: []FieldError{{Field: "name", Message: "cannot be empty"}}}
+	ErrEmailExists            = NewError(KindConflict, "email_exists", "email already exists")
+	ErrInvalidEmail           = &Error{Kind: KindInvalid, Code: "invalid_email", Message: "invalid email format", Fields: []FieldError{{Field: "email", Message: "invalid email format"}}}
+	ErrPasswordTooShort       = &Error{Kind: KindInvalid, Code: "password_too_short", Message: "password must be at least 8 characters", Fields: []FieldError{{Field: "password", Message: "must be at least 8 characters"}}}
+	ErrInvalidOldPassword     = NewError(KindInvalid, "invalid_old_password", "invalid old password")
+	ErrInvalidCredentials     = NewError(KindUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrAccountLocked          = NewError(KindLocked, "account_locked", "account is locked")
+	ErrAccountDeactivated     = NewError(KindForbidden, "account_deactivated", "account is deactivated")
+	ErrAccountPending         = NewError(KindForbidden, "account_pending", "account is pending activation")
+	ErrUserAlreadyDeactivated = NewError(KindConflict, "user_already_deactivated", "user is already deactivated")
+	ErrUserAlreadyActive      = NewError(KindConflict, "user_already_active", "user is already active")
+	ErrLastSuperadmin         = NewError(KindConflict, "last_superadmin", "cannot remove the last active superadmin")
+	ErrCannotManageUser       = NewError(KindForbidden, "insufficient_privileges", "insufficient privileges to manage this user")
+	ErrCannotGrantRole        = NewError(KindForbidden, "insufficient_privileges", "insufficient privileges to grant role")
+	ErrInvalidRole            = NewError(KindInvalid, "invalid_role", "invalid role")
+)
+
+// Tokens and sessions.
+var (
+	ErrInvalidToken        = NewError(KindUnauthorized, "invalid_token", "invalid token provided")
+	ErrInvalidRefreshToken = NewError(KindUnauthorized, "invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenReused  = NewError(KindUnauthorized, "refresh_token_reused", "refresh token reuse detected")
+	ErrSessionExpired      = NewError(KindUnauthorized, "session_expired", "session expired")
+	ErrSessionNotFound     = NewError(KindNotFound, "session_not_found", "session not found")
+	ErrInvalidResetToken   = NewError(KindInvalid, "invalid_reset_token", "invalid or expired reset token")
+	ErrInvalidInvitation   = NewError(KindInvalid, "invalid_invitation", "invalid or expired invitation")
+	ErrNoPendingInvitation = NewError(KindConflict, "no_pending_invitation", "user has no pending invitation")
+)
+
+// Multi-factor authentication.
+var (
+	ErrInvalidMFAToken         = NewError(KindUnauthorized, "invalid_mfa_token", "invalid mfa token")
+	ErrInvalidMFACode          = NewError(KindUnauthorized, "invalid_mfa_code", "invalid mfa code")
+	ErrMFACodeRequired         = NewError(KindUnauthorized, "mfa_code_required", "mfa code required")
+	ErrMFAAlreadyEnabled       = NewError(KindConflict, "mfa_already_enabled", "mfa is already enabled")
+	ErrMFANotEnabled           = NewError(KindConflict, "mfa_not_enabled", "mfa is not enabled")
+	ErrMFAEnrollmentNotStarted = NewError(KindConflict, "mfa_enrollment_not_started", "mfa enrollment not started")
+	ErrMFARequiredForRole      = NewError(KindForbidden, "mfa_required", "mfa is required for your role")
+	ErrMFAEnrollmentRequired   = NewError(KindForbidden, "mfa_enrollment_required", "mfa enrollment required")
+)
+
+// Roles and permissions.
+var (
+	ErrRoleNotFound      = NewError(KindNotFound, "role_not_found", "role not found")
+	ErrRoleExists        = NewError(KindConflict, "role_exists", "role already exists")
+	ErrRoleInUse         = NewError(KindConflict, "role_in_use", "role is assigned to users")
+	ErrSystemRole        = NewError(KindConflict, "system_role", "system roles cannot be deleted")
+	ErrInvalidRoleName   = &Error{Kind: KindInvalid, Code: "invalid_role_name", Message: "invalid role name", Fields: []FieldError{{Field: "name", Message: "invalid role name"}}}
+	ErrUnknownPermission = NewError(KindInvalid, "unknown_permission", "unknown permission")
+)
+
+// Service accounts, API keys and OAuth clients.
+var (
+	ErrInvalidServiceAccountName = &Error{Kind: KindInvalid, Code: "invalid_username", Message: "invalid service account username", Fields: []FieldError{{Field: "username", Message: "invalid service account username"}}}
+	ErrServiceAccountExists      = NewError(KindConflict, "service_account_exists", "service account already exists")
+	ErrNotServiceAccount         = NewError(KindInvalid, "not_service_account", "user is not a service account")
+	ErrExpiryInPast              = &Error{Kind: KindInvalid, Code: "invalid_expiry", Message: "expiry must be in the future", Fields: []FieldError{{Field: "expires_at", Message: "must be in the future"}}}
+	ErrScopeNotGranted           = NewError(KindInvalid, "scope_not_granted", "scope not granted to the service account")
+	ErrAPIKeyNotFound            = NewError(KindNotFound, "api_key_not_found", "api key not found")
+	ErrInvalidAPIKey             = NewError(KindUnauthorized, "invalid_api_key", "invalid api key")
+	ErrOAuthClientNotFound       = NewError(KindNotFound, "oauth_client_not_found", "oauth client not found")
+	ErrRedirectURIRequired       = requiredFields("at least one redirect uri is required", "redirect_uris")
+	ErrInvalidRedirectURI        = NewError(KindInvalid, "invalid_redirect_uri", "invalid redirect uri")
+)
+
+// Listing, import and export.
+var (
+	ErrInvalidPage             = NewError(KindInvalid, "invalid_page", "invalid page number")
+	ErrInvalidLimit            = NewError(KindInvalid, "invalid_limit", "invalid limit value")
+	ErrInvalidStateFilter      = NewError(KindInvalid, "invalid_state_filter", "invalid state filter")
+	ErrInvalidRoleMatch        = NewError(KindInvalid, "invalid_role_match", "invalid role match")
+	ErrInvalidSort             = NewError(KindInvalid, "invalid_sort", "invalid sort")
+	ErrInvalidDateRange        = NewError(KindInvalid, "invalid_date_range", "invalid date range")
+	ErrInvalidCursor           = &Error{Kind: KindInvalid, Code: "invalid_cursor", Message: "invalid cursor", Fields: []FieldError{{Field: "cursor", Message: "must be a next_cursor returned for the same sort"}}}
+	ErrTooManyIDs              = &Error{Kind: KindInvalid, Code: "too_many_ids", Message: fmt.Sprintf("at most %d ids can be looked up at once", maxLookupIDs), Fields: []FieldError{{Field: "ids", Message: fmt.Sprintf("must not contain more than %d ids", maxLookupIDs)}}}
+	ErrInvalidTimeRange        = NewError(KindInvalid, "invalid_time_range", "invalid time range")
+	ErrUnsupportedImportFormat = NewError(KindInvalid, "unsupported_format", "unsupported import format")
+	ErrUnsupportedExportFormat = NewError(KindInvalid, "unsupported_format", "unsupported export format")
+	ErrEmptyImport             = NewError(KindInvalid, "empty_import", "import file contains no users")
+	ErrInvalidImportFile       = NewError(KindInvalid, "invalid_import_file", "invalid import file")
+	ErrImportTooLarge          = NewError(KindInvalid, "import_too_large", fmt.Sprintf("import file contains more than %d users", maxImportRows))
+)
+
+// SCIM.
+var (
+	ErrUnsupportedFilter     = NewError(KindInvalid, "invalid_filter", "unsupported filter")
+	ErrInvalidFilterValue    = NewError(KindInvalid, "invalid_filter", "invalid filter value")
+	ErrInvalidPatchPath      = NewError(KindInvalid, "invalid_path", "invalid patch path")
+	ErrInvalidPatchOperation = NewError(KindInvalid, "invalid_patch", "invalid patch operation")
+	ErrInvalidPatchValue     = NewError(KindInvalid, "invalid_patch", "invalid patch value")
+	ErrNoPatchOperations     = NewError(KindInvalid, "invalid_patch", "no patch operations")
+	ErrUserNameImmutable     = NewError(KindInvalid, "mutability", "userName is immutable")
+	ErrSCIMUserNameRequired  = requiredFields("userName is required", "userName")
+	ErrSCIMNameRequired      = requiredFields("name is required", "name")
+)
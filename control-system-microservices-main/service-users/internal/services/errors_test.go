@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		target   error
+		expected bool
+	}{
+		{
+			name:     "тот же sentinel",
+			err:      ErrUserNotFound,
+			target:   ErrUserNotFound,
+			expected: true,
+		},
+		{
+			name:     "ошибка с уточнением совпадает с sentinel",
+			err:      withValue(ErrInvalidRole, "pilot", "roles"),
+			target:   ErrInvalidRole,
+			expected: true,
+		},
+		{
+			name:     "обёрнутая ошибка",
+			err:      fmt.Errorf("scim: %w", ErrEmailExists),
+			target:   ErrEmailExists,
+			expected: true,
+		},
+		{
+			name:     "другой код",
+			err:      ErrRoleNotFound,
+			target:   ErrUserNotFound,
+			expected: false,
+		},
+		{
+			name:     "sentinel с тем же кодом",
+			err:      ErrCannotGrantRole,
+			target:   ErrCannotManageUser,
+			expected: false,
+		},
+		{
+			name:     "суженная ошибка не совпадает с другим sentinel того же кода",
+			err:      missingFields(ErrInviteFieldsRequired, "", "Jane"),
+			target:   ErrRegisterFieldsRequired,
+			expected: false,
+		},
+		{
+			name:     "суженная ошибка совпадает со своим sentinel",
+			err:      missingFields(ErrInviteFieldsRequired, "", "Jane"),
+			target:   ErrInviteFieldsRequired,
+			expected: true,
+		},
+		{
+			name:     "дважды суженная ошибка",
+			err:      withValue(withValue(ErrInvalidRole, "pilot", ""), "again", "roles"),
+			target:   ErrInvalidRole,
+			expected: true,
+		},
+		{
+			name:     "обычная ошибка с тем же текстом",
+			err:      errors.New("user not found"),
+			target:   ErrUserNotFound,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, errors.Is(tt.err, tt.target))
+		})
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{name: "не найдено", err: ErrUserNotFound, expected: KindNotFound},
+		{name: "конфликт", err: ErrEmailExists, expected: KindConflict},
+		{name: "обёрнутая ошибка", err: fmt.Errorf("import: %w", ErrAccountLocked), expected: KindLocked},
+		{name: "обычная ошибка", err: errors.New("connection refused"), expected: KindInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, KindOf(tt.err))
+		})
+	}
+}
+
+func TestWithValue(t *testing.T) {
+	err := withValue(ErrUnknownPermission, "orders:fly", "permissions")
+
+	assert.EqualError(t, err, "unknown permission: orders:fly")
+	assert.Equal(t, ErrUnknownPermission.Code, err.Code)
+	assert.Equal(t, []FieldError{{Field: "permissions", Message: "unknown permission: orders:fly"}}, err.Fields)
+	assert.Equal(t, "unknown permission", ErrUnknownPermission.Message, "sentinel не должен меняться")
+}
+
+func TestRegisterUser_RequiredFields(t *testing.T) {
+	service, _, finish := setupTest(t)
+	defer finish()
+
+	_, err := service.RegisterUser(testAdmin, RegisterUserInput{Email: "test@example.com"})
+
+	var domain *Error
+	assert.True(t, errors.As(err, &domain))
+	assert.Equal(t, KindInvalid, domain.Kind)
+	assert.Equal(t, []FieldError{
+		{Field: "password", Message: "is required"},
+		{Field: "name", Message: "is required"},
+	}, domain.Fields)
+	assert.Len(t, ErrRegisterFieldsRequired.Fields, 3, "sentinel не должен меняться")
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+
+	maxImportRows   = 5000
+	exportBatchSize = 500
+)
+
+// ImportUserRow is one user in an import file. Rows without a password are
+// created in the pending state and receive an invitation instead.
+type ImportUserRow struct {
+	Email    string   `json:"email"`
+	Name     string   `json:"name"`
+	Roles    []string `json:"roles"`
+	Phone    string   `json:"phone"`
+	Position string   `json:"position"`
+	Password string   `json:"password"`
+}
+
+type ImportRowError struct {
+	// Row is 1-based and counts data rows only, not the CSV header.
+	Row    int      `json:"row"`
+	Email  string   `json:"email,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+type ImportResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	Invited int              `json:"invited"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+type UserExportInput struct {
+	EmailFilter string
+	RoleFilter  string
+	StateFilter string
+}
+
+// ImportUsers validates every row with the same rules as RegisterUser and,
+// unless dryRun is set or a row is invalid, inserts all of them in a single
+// transaction. The returned report lists problems per row either way.
+func (s *UserService) ImportUsers(actor Actor, r io.Reader, format string, dryRun bool) (*ImportResult, error) {
+	var rows []ImportUserRow
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = parseImportCSV(r)
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&rows)
+	default:
+		return nil, ErrUnsupportedImportFormat
+	}
+	if err != nil {
+		return nil, withValue(ErrInvalidImportFile, err, "")
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+	if len(rows) > maxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	result := &ImportResult{DryRun: dryRun, Total: len(rows), Errors: []ImportRowError{}}
+
+	emails := make([]string, 0, len(rows))
+	for i := range rows {
+		rows[i].Email = strings.ToLower(strings.TrimSpace(rows[i].Email))
+		rows[i].Name = strings.TrimSpace(rows[i].Name)
+		emails = append(emails, rows[i].Email)
+	}
+	existing, err := s.userRepo.FindExistingEmails(emails)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing users: %v", err)
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, email := range existing {
+		taken[email] = true
+	}
+
+	var allRoles []string
+	for _, row := range rows {
+		allRoles = append(allRoles, row.Roles...)
+	}
+	known, err := s.knownRoles(allRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		var problems []string
+		if row.Email == "" || row.Name == "" {
+			problems = append(problems, "email and name are required")
+		} else if !isValidEmail(row.Email) {
+			problems = append(problems, "invalid email format")
+		}
+		if row.Password != "" && len(row.Password) < 8 {
+			problems = append(problems, "password must be at least 8 characters")
+		}
+		if len(row.Roles) == 0 {
+			rows[i].Roles = []string{userroles.RoleEngineer}
+		} else if err := unknownRole(row.Roles, known); err != nil {
+			problems = append(problems, err.Error())
+		} else if err := s.checkGrant(actor, row.Roles); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if row.Email != "" {
+			if taken[row.Email] {
+				problems = append(problems, "email already exists")
+			} else if first, ok := seen[row.Email]; ok {
+				problems = append(problems, fmt.Sprintf("duplicate email, first seen in row %d", first))
+			} else {
+				seen[row.Email] = i + 1
+			}
+		}
+		if len(problems) > 0 {
+			result.Errors = append(result.Errors, ImportRowError{Row: i + 1, Email: row.Email, Errors: problems})
+		}
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	users := make([]models.User, len(rows))
+	for i, row := range rows {
+		users[i] = models.User{
+			Email:    row.Email,
+			Name:     row.Name,
+			Roles:    row.Roles,
+			Phone:    row.Phone,
+			Position: row.Position,
+			Active:   true,
+			Pending:  row.Password == "",
+		}
+		if row.Password != "" {
+			users[i].Password = row.Password
+			if err := users[i].HashPassword(); err != nil {
+				return nil, fmt.Errorf("failed to hash password: %v", err)
+			}
+		}
+	}
+
+	if err := s.userRepo.CreateUsers(users); err != nil {
+		return nil, fmt.Errorf("failed to import users: %v", err)
+	}
+
+	for i := range users {
+		if !users[i].Pending {
+			result.Created++
+			continue
+		}
+		// The users are already committed; a failed invite can be resent
+		// from the admin API, so it must not fail the whole import.
+		if err := s.sendInvitation(&users[i]); err != nil {
+			log.Printf("Failed to invite imported user %s: %v", users[i].Email, err)
+		}
+		result.Invited++
+	}
+	return result, nil
+}
+
+// parseImportCSV expects a header row. Column order is free; roles are
+// separated by semicolons.
+func parseImportCSV(r io.Reader) ([]ImportUserRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("missing email column")
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("missing name column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []ImportUserRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) >= maxImportRows {
+			return nil, fmt.Errorf("more than %d users", maxImportRows)
+		}
+
+		row := ImportUserRow{
+			Email:    field(record, "email"),
+			Name:     field(record, "name"),
+			Phone:    field(record, "phone"),
+			Position: field(record, "position"),
+			Password: field(record, "password"),
+		}
+		for _, role := range strings.Split(field(record, "roles"), ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				row.Roles = append(row.Roles, role)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ValidateExportInput is checked before any output is written, because once
+// streaming has started the response status can no longer change.
+func ValidateExportInput(input UserExportInput, format string) error {
+	if format != FormatCSV && format != FormatNDJSON {
+		return ErrUnsupportedExportFormat
+	}
+	if !isValidStateFilter(input.StateFilter) {
+		return ErrInvalidStateFilter
+	}
+	return nil
+}
+
+var exportCSVHeader = []string{"id", "email", "name", "roles", "phone", "position", "active", "pending", "created_at", "deleted_at"}
+
+// ExportUsers streams every user matching the filters to w.
+func (s *UserService) ExportUsers(input UserExportInput, format string, w io.Writer) error {
+	if err := ValidateExportInput(input, format); err != nil {
+		return err
+	}
+
+	filter := repositories.UserFilter{
+		Email: input.EmailFilter,
+		Role:  input.RoleFilter,
+		State: input.StateFilter,
+	}
+
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(w)
+	if format == FormatCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(exportCSVHeader); err != nil {
+			return err
+		}
+	}
+
+	err := s.userRepo.EachUserBatch(filter, exportBatchSize, func(users []models.User) error {
+		for i := range users {
+			user := toUserResponse(&users[i])
+			if csvWriter == nil {
+				if err := encoder.Encode(user); err != nil {
+					return err
+				}
+				continue
+			}
+			deletedAt := ""
+			if user.DeletedAt != nil {
+				deletedAt = user.DeletedAt.Format(time.RFC3339)
+			}
+			if err := csvWriter.Write([]string{
+				strconv.FormatUint(uint64(user.ID), 10),
+				user.Email,
+				user.Name,
+				strings.Join(user.Roles, ";"),
+				user.Phone,
+				user.Position,
+				strconv.FormatBool(user.Active),
+				strconv.FormatBool(user.Pending),
+				users[i].CreatedAt.Format(time.RFC3339),
+				deletedAt,
+			}); err != nil {
+				return err
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		// Push each batch to the client instead of buffering the whole export.
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export users: %v", err)
+	}
+	return nil
+}
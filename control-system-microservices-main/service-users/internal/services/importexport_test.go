@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUserService_ImportUsers(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	service.mailer = make(chanMailer, 10)
+	service.cfg.InviteURL = "http://localhost/invite"
+
+	tests := []struct {
+		name          string
+		body          string
+		format        string
+		dryRun        bool
+		setupMock     func()
+		expected      *ImportResult
+		expectedErr   string
+		checkRowError func(t *testing.T, errs []ImportRowError)
+	}{
+		{
+			name:   "csv с паролем и приглашением",
+			format: FormatCSV,
+			body: "email,name,roles,password\n" +
+				"a@example.com,A,engineer;manager,password123\n" +
+				"B@Example.com,B,,\n",
+			setupMock: func() {
+				mockRepo.EXPECT().
+					FindExistingEmails([]string{"a@example.com", "b@example.com"}).
+					Return(nil, nil)
+				mockRepo.EXPECT().
+					CreateUsers(gomock.Any()).
+					DoAndReturn(func(users []models.User) error {
+						assert.Len(t, users, 2)
+						assert.Equal(t, []string{userroles.RoleEngineer, userroles.RoleManager}, []string(users[0].Roles))
+						assert.False(t, users[0].Pending)
+						assert.True(t, passwordMatches(t, users[0].Password, "password123"))
+						assert.Equal(t, []string{userroles.RoleEngineer}, []string(users[1].Roles))
+						assert.True(t, users[1].Pending)
+						users[0].ID, users[1].ID = 1, 2
+						return nil
+					})
+				mockTokenRepo.EXPECT().
+					CreateInvitation(gomock.Any()).
+					DoAndReturn(func(invitation *models.Invitation) error {
+						assert.Equal(t, uint(2), invitation.UserID)
+						return nil
+					})
+			},
+			expected: &ImportResult{Total: 2, Created: 1, Invited: 1, Errors: []ImportRowError{}},
+		},
+		{
+			name:   "dry-run не создаёт пользователей",
+			format: FormatJSON,
+			dryRun: true,
+			body:   `[{"email":"a@example.com","name":"A","password":"password123"}]`,
+			setupMock: func() {
+				mockRepo.EXPECT().FindExistingEmails([]string{"a@example.com"}).Return(nil, nil)
+			},
+			expected: &ImportResult{DryRun: true, Total: 1, Errors: []ImportRowError{}},
+		},
+		{
+			name:   "ошибки по строкам",
+			format: FormatJSON,
+			body: `[
+				{"email":"exists@example.com","name":"E"},
+				{"email":"bad","name":"B","password":"short"},
+				{"email":"dup@example.com","name":"D","roles":["pilot"]},
+				{"email":"dup@example.com","name":"D2"}
+			]`,
+			setupMock: func() {
+				mockRepo.EXPECT().
+					FindExistingEmails(gomock.Any()).
+					Return([]string{"exists@example.com"}, nil)
+			},
+			checkRowError: func(t *testing.T, errs []ImportRowError) {
+				assert.Equal(t, []ImportRowError{
+					{Row: 1, Email: "exists@example.com", Errors: []string{"email already exists"}},
+					{Row: 2, Email: "bad", Errors: []string{"invalid email format", "password must be at least 8 characters"}},
+					{Row: 3, Email: "dup@example.com", Errors: []string{"invalid role: pilot"}},
+					{Row: 4, Email: "dup@example.com", Errors: []string{"duplicate email, first seen in row 3"}},
+				}, errs)
+			},
+		},
+		{
+			name:        "csv без колонки email",
+			format:      FormatCSV,
+			body:        "name\nA\n",
+			setupMock:   func() {},
+			expectedErr: "invalid import file: missing email column",
+		},
+		{
+			name:        "неподдерживаемый формат",
+			format:      "xml",
+			body:        "<users/>",
+			setupMock:   func() {},
+			expectedErr: "unsupported import format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			result, err := service.ImportUsers(testAdmin, strings.NewReader(tt.body), tt.format, tt.dryRun)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, result)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.checkRowError != nil {
+				assert.Zero(t, result.Created)
+				tt.checkRowError(t, result.Errors)
+			} else {
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestUserService_ExportUsers(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	users := []models.User{
+		*newTestUser(1, "a@example.com", "A", userroles.RoleEngineer, userroles.RoleManager),
+		*newTestUser(2, "b@example.com", "B", userroles.RoleAdmin),
+	}
+	filter := repositories.UserFilter{Role: userroles.RoleEngineer, State: repositories.UserStateActive}
+	input := UserExportInput{RoleFilter: userroles.RoleEngineer, StateFilter: repositories.UserStateActive}
+
+	t.Run("csv", func(t *testing.T) {
+		mockRepo.EXPECT().
+			EachUserBatch(filter, exportBatchSize, gomock.Any()).
+			DoAndReturn(func(_ repositories.UserFilter, _ int, fn func([]models.User) error) error {
+				return fn(users)
+			})
+
+		var buf bytes.Buffer
+		assert.NoError(t, service.ExportUsers(input, FormatCSV, &buf))
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.Equal(t, exportCSVHeader, records[0])
+		assert.Equal(t, []string{"1", "a@example.com", "A", "engineer;manager", "", "", "true", "false"}, records[1][:8])
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		mockRepo.EXPECT().
+			EachUserBatch(filter, exportBatchSize, gomock.Any()).
+			DoAndReturn(func(_ repositories.UserFilter, _ int, fn func([]models.User) error) error {
+				return fn(users)
+			})
+
+		var buf bytes.Buffer
+		assert.NoError(t, service.ExportUsers(input, FormatNDJSON, &buf))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		var got UserResponse
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &got))
+		assert.Equal(t, "b@example.com", got.Email)
+	})
+
+	t.Run("неподдерживаемый формат", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.EqualError(t, service.ExportUsers(input, "xlsx", &buf), "unsupported export format")
+		assert.Zero(t, buf.Len())
+	})
+}
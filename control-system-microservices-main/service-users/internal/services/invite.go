@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type InviteUserInput struct {
+	Email string   `json:"email" binding:"required"`
+	Name  string   `json:"name" binding:"required"`
+	Roles []string `json:"roles"`
+}
+
+type AcceptInviteInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// InviteUser creates a pending account and emails the invitee a link to set
+// their own password. The account cannot log in until the invite is accepted.
+func (s *UserService) InviteUser(actor Actor, input InviteUserInput) (*UserResponse, error) {
+	return s.inviteUser(actor, input, true)
+}
+
+// inviteUser invites an account that is active or deactivated from the
+// start. A deactivated invitee can still set a password, but cannot log in
+// until the account is activated.
+func (s *UserService) inviteUser(actor Actor, input InviteUserInput, active bool) (*UserResponse, error) {
+	if err := missingFields(ErrInviteFieldsRequired, input.Email, input.Name); err != nil {
+		return nil, err
+	}
+	if !isValidEmail(input.Email) {
+		return nil, ErrInvalidEmail
+	}
+	roles, err := s.validateRoles(input.Roles)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrant(actor, roles); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepo.GetUserByEmail(input.Email); err == nil {
+		return nil, ErrEmailExists
+	}
+
+	user := models.User{
+		Email:   strings.ToLower(input.Email),
+		Name:    input.Name,
+		Roles:   roles,
+		Active:  active,
+		Pending: true,
+	}
+	entry := auditEntry(actor, AuditUserInvited, 0, creationChanges(&user))
+	if err := s.userRepo.CreateUser(&user, entry); err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	if err := s.sendInvitation(&user); err != nil {
+		return nil, err
+	}
+	return toUserResponse(&user), nil
+}
+
+// ResendInvite issues a fresh link; links sent earlier stop working.
+func (s *UserService) ResendInvite(actor Actor, userID uint) error {
+	user, err := s.getPendingUser(actor, userID)
+	if err != nil {
+		return err
+	}
+	return s.sendInvitation(user)
+}
+
+// RevokeInvite invalidates every outstanding link. The account stays pending
+// and can be invited again or deleted.
+func (s *UserService) RevokeInvite(actor Actor, userID uint) error {
+	user, err := s.getPendingUser(actor, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.tokenRepo.RevokeUserInvitations(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke invitation: %v", err)
+	}
+	return nil
+}
+
+// AcceptInvite sets the invitee's password and activates the account. The
+// invite is consumed in the same transaction, so a failure leaves it usable.
+func (s *UserService) AcceptInvite(input AcceptInviteInput) (*UserResponse, error) {
+	if len(input.Password) < 8 {
+		return nil, ErrPasswordTooShort
+	}
+
+	jti, err := s.parseInviteToken(input.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.tokenRepo.GetInvitationByJTI(jti)
+	if err != nil || invitation.AcceptedAt != nil || invitation.RevokedAt != nil ||
+		time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+
+	user, err := s.userRepo.GetUserByID(invitation.UserID)
+	if err != nil || !user.Pending {
+		return nil, ErrInvalidInvitation
+	}
+
+	user.Password = input.Password
+	if err := user.HashPassword(); err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+	accepted, err := s.tokenRepo.AcceptInvitation(invitation, user, map[string]interface{}{
+		"password": user.Password,
+		"pending":  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %v", err)
+	}
+	if !accepted {
+		return nil, ErrInvalidInvitation
+	}
+
+	user.Pending = false
+	return toUserResponse(user), nil
+}
+
+// getPendingUser loads a pending user the actor is allowed to manage.
+func (s *UserService) getPendingUser(actor Actor, userID uint) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
+	}
+	if !user.Pending {
+		return nil, ErrNoPendingInvitation
+	}
+	return user, nil
+}
+
+func (s *UserService) sendInvitation(user *models.User) error {
+	lifespan := intSetting(s.cfg.InviteTokenHours, 72)
+	expiresAt := time.Now().Add(time.Hour * time.Duration(lifespan))
+
+	jti, err := utils.RandomString(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate invitation: %v", err)
+	}
+
+	claims := jwt.MapClaims{}
+	claims["id"] = user.ID
+	claims["jti"] = jti
+	claims["typ"] = "invite"
+	claims["exp"] = expiresAt.Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.InviteTokenSecret))
+	if err != nil {
+		return fmt.Errorf("failed to sign invitation: %v", err)
+	}
+
+	if err := s.tokenRepo.CreateInvitation(&models.Invitation{
+		UserID:    user.ID,
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to store invitation: %v", err)
+	}
+
+	link := s.cfg.InviteURL + "?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hello, %s!\n\nAn account has been created for you. Use the link below to set your password. "+
+			"It expires in %d hours.\n\n%s\n", user.Name, lifespan, link),
+	}
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Failed to send invitation email: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *UserService) parseInviteToken(token string) (string, error) {
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.cfg.InviteTokenSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsed.Valid {
+		return "", ErrInvalidInvitation
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != "invite" {
+		return "", ErrInvalidInvitation
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", ErrInvalidInvitation
+	}
+	return jti, nil
+}
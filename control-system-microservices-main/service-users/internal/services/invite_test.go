@@ -0,0 +1,229 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// inviteToken extracts the token from the link in an invitation email.
+func inviteToken(t *testing.T, sent chanMailer) string {
+	select {
+	case msg := <-sent:
+		i := strings.Index(msg.Body, "http://localhost/invite?token=")
+		assert.GreaterOrEqual(t, i, 0)
+		link, err := url.Parse(strings.Fields(msg.Body[i:])[0])
+		assert.NoError(t, err)
+		return link.Query().Get("token")
+	case <-time.After(time.Second):
+		t.Fatal("invitation email was not sent")
+		return ""
+	}
+}
+
+func TestUserService_InviteUser(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	sent := make(chanMailer, 1)
+	service.mailer = sent
+	service.cfg.InviteURL = "http://localhost/invite"
+	service.cfg.InviteTokenHours = "72"
+
+	tests := []struct {
+		name        string
+		input       InviteUserInput
+		setupMock   func()
+		expectedErr string
+	}{
+		{
+			name:  "успешное приглашение",
+			input: InviteUserInput{Email: "New@Example.com", Name: "New User", Roles: []string{userroles.RoleManager}},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUserByEmail("New@Example.com").Return((*models.User)(nil), assert.AnError)
+				mockRepo.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(user *models.User, entry *models.AuditEntry) error {
+						assert.Equal(t, AuditUserInvited, entry.Action)
+						assert.Equal(t, "new@example.com", user.Email)
+						assert.True(t, user.Pending)
+						assert.Empty(t, user.Password)
+						user.ID = 5
+						return nil
+					})
+				mockTokenRepo.EXPECT().
+					CreateInvitation(gomock.Any()).
+					DoAndReturn(func(invitation *models.Invitation) error {
+						assert.Equal(t, uint(5), invitation.UserID)
+						assert.NotEmpty(t, invitation.JTI)
+						assert.WithinDuration(t, time.Now().Add(72*time.Hour), invitation.ExpiresAt, 5*time.Second)
+						return nil
+					})
+			},
+		},
+		{
+			name:  "email уже существует",
+			input: InviteUserInput{Email: "exists@example.com", Name: "Exists"},
+			setupMock: func() {
+				mockRepo.EXPECT().
+					GetUserByEmail("exists@example.com").
+					Return(&models.User{Email: "exists@example.com"}, nil)
+			},
+			expectedErr: "email already exists",
+		},
+		{
+			name:        "невалидная роль",
+			input:       InviteUserInput{Email: "new@example.com", Name: "New", Roles: []string{"pilot"}},
+			setupMock:   func() {},
+			expectedErr: "invalid role: pilot",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			got, err := service.InviteUser(testAdmin, tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, got.Pending)
+				assert.NotEmpty(t, inviteToken(t, sent))
+			}
+		})
+	}
+}
+
+func TestUserService_AcceptInvite(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	sent := make(chanMailer, 1)
+	service.mailer = sent
+	service.cfg.InviteURL = "http://localhost/invite"
+
+	pending := newTestUser(5, "new@example.com", "New User", userroles.RoleEngineer)
+	pending.Pending = true
+
+	var stored *models.Invitation
+	mockTokenRepo.EXPECT().
+		CreateInvitation(gomock.Any()).
+		DoAndReturn(func(invitation *models.Invitation) error {
+			invitation.ID = 1
+			stored = invitation
+			return nil
+		})
+	assert.NoError(t, service.sendInvitation(pending))
+	token := inviteToken(t, sent)
+
+	refreshSigned, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":  5,
+		"jti": stored.JTI,
+		"typ": "invite",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(service.cfg.RefreshTokenSecret))
+	assert.NoError(t, err)
+
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name        string
+		input       AcceptInviteInput
+		setupMock   func()
+		expectedErr string
+	}{
+		{
+			name:  "отозванное приглашение",
+			input: AcceptInviteInput{Token: token, Password: "password123"},
+			setupMock: func() {
+				revoked := *stored
+				revoked.RevokedAt = &revokedAt
+				mockTokenRepo.EXPECT().GetInvitationByJTI(stored.JTI).Return(&revoked, nil)
+			},
+			expectedErr: "invalid or expired invitation",
+		},
+		{
+			name:        "подделанный токен",
+			input:       AcceptInviteInput{Token: token + "x", Password: "password123"},
+			setupMock:   func() {},
+			expectedErr: "invalid or expired invitation",
+		},
+		{
+			name:        "короткий пароль",
+			input:       AcceptInviteInput{Token: token, Password: "short"},
+			setupMock:   func() {},
+			expectedErr: "password must be at least 8 characters",
+		},
+		{
+			name:  "приглашение уже принято параллельно",
+			input: AcceptInviteInput{Token: token, Password: "password123"},
+			setupMock: func() {
+				mockTokenRepo.EXPECT().GetInvitationByJTI(stored.JTI).Return(stored, nil)
+				mockRepo.EXPECT().GetUserByID(uint(5)).Return(pending, nil)
+				mockTokenRepo.EXPECT().AcceptInvitation(stored, pending, gomock.Any()).Return(false, nil)
+			},
+			expectedErr: "invalid or expired invitation",
+		},
+		{
+			name:  "успешное принятие",
+			input: AcceptInviteInput{Token: token, Password: "password123"},
+			setupMock: func() {
+				mockTokenRepo.EXPECT().GetInvitationByJTI(stored.JTI).Return(stored, nil)
+				mockRepo.EXPECT().GetUserByID(uint(5)).Return(pending, nil)
+				mockTokenRepo.EXPECT().
+					AcceptInvitation(stored, pending, gomock.Any()).
+					DoAndReturn(func(_ *models.Invitation, _ *models.User, updates map[string]interface{}) (bool, error) {
+						assert.Equal(t, false, updates["pending"])
+						assert.True(t, passwordMatches(t, updates["password"].(string), "password123"))
+						return true, nil
+					})
+			},
+		},
+		{
+			name:        "токен подписан секретом refresh-токенов",
+			input:       AcceptInviteInput{Token: refreshSigned, Password: "password123"},
+			setupMock:   func() {},
+			expectedErr: "invalid or expired invitation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			got, err := service.AcceptInvite(tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, got.Pending)
+			}
+		})
+	}
+}
+
+func TestUserService_RevokeInvite(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	pending := newTestUser(5, "new@example.com", "New User", userroles.RoleEngineer)
+	pending.Pending = true
+
+	mockRepo.EXPECT().GetUserByID(uint(5)).Return(pending, nil)
+	mockTokenRepo.EXPECT().RevokeUserInvitations(uint(5)).Return(nil)
+	assert.NoError(t, service.RevokeInvite(testAdmin, 5))
+
+	active := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(active, nil)
+	assert.EqualError(t, service.RevokeInvite(testAdmin, 1), "user has no pending invitation")
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+)
+
+type LockStatus struct {
+	Locked              bool       `json:"locked"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+	FailedLoginAttempts int        `json:"failed_login_attempts"`
+	LockoutCount        int        `json:"lockout_count"`
+}
+
+func intSetting(value string, fallback int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// lockoutDuration doubles the base lock time for every previous lockout and
+// caps it at LOGIN_LOCKOUT_MAX_MINUTES.
+func (s *UserService) lockoutDuration(previousLockouts int) time.Duration {
+	base := time.Duration(intSetting(s.cfg.LoginLockoutMinutes, 5)) * time.Minute
+	limit := time.Duration(intSetting(s.cfg.LoginLockoutMaxMinutes, 1440)) * time.Minute
+
+	duration := base
+	for i := 0; i < previousLockouts && duration < limit; i++ {
+		duration *= 2
+	}
+	if duration > limit {
+		duration = limit
+	}
+	return duration
+}
+
+// recordFailedLogin counts a wrong password and locks the account once
+// LOGIN_MAX_ATTEMPTS consecutive failures are reached.
+func (s *UserService) recordFailedLogin(user *models.User) error {
+	if err := s.userRepo.IncrementFailedLoginAttempts(user); err != nil {
+		return fmt.Errorf("failed to record login attempt: %v", err)
+	}
+	if user.FailedLoginAttempts < intSetting(s.cfg.LoginMaxAttempts, 5) {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(s.lockoutDuration(user.LockoutCount))
+	if err := s.userRepo.UpdateUser(user, map[string]interface{}{
+		"failed_login_attempts": 0,
+		"lockout_count":         user.LockoutCount + 1,
+		"locked_until":          lockedUntil,
+	}); err != nil {
+		return fmt.Errorf("failed to lock account: %v", err)
+	}
+	return nil
+}
+
+func hasFailedLogins(user *models.User) bool {
+	return user.FailedLoginAttempts > 0 || user.LockoutCount > 0 || user.LockedUntil != nil
+}
+
+// failedLoginsReset is the update that forgets failed logins and lifts a lock.
+func failedLoginsReset() map[string]interface{} {
+	return map[string]interface{}{
+		"failed_login_attempts": 0,
+		"lockout_count":         0,
+		"locked_until":          nil,
+	}
+}
+
+func (s *UserService) clearFailedLogins(user *models.User) error {
+	if !hasFailedLogins(user) {
+		return nil
+	}
+	if err := s.userRepo.UpdateUser(user, failedLoginsReset()); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %v", err)
+	}
+	return nil
+}
+
+func toLockStatus(user *models.User) *LockStatus {
+	status := &LockStatus{
+		Locked:              user.IsLocked(),
+		FailedLoginAttempts: user.FailedLoginAttempts,
+		LockoutCount:        user.LockoutCount,
+	}
+	if status.Locked {
+		status.LockedUntil = user.LockedUntil
+	}
+	return status
+}
+
+func (s *UserService) GetLockStatus(id uint) (*LockStatus, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return toLockStatus(user), nil
+}
+
+// UnlockUser lifts a lock and forgets previous failures, so the next lockout
+// starts again from the base duration.
+func (s *UserService) UnlockUser(actor Actor, id uint) (*LockStatus, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
+	}
+	if !hasFailedLogins(user) {
+		return toLockStatus(user), nil
+	}
+
+	entry := auditEntry(actor, AuditUserUnlocked, user.ID, models.AuditChanges{
+		"locked": {Before: user.IsLocked(), After: false},
+	})
+	if err := s.userRepo.UpdateUserAudited(user, failedLoginsReset(), entry, false); err != nil {
+		return nil, fmt.Errorf("failed to reset login attempts: %v", err)
+	}
+	return toLockStatus(user), nil
+}
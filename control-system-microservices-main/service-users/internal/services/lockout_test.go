@@ -0,0 +1,151 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUserService_LoginLockout(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	service.cfg.LoginMaxAttempts = "3"
+	service.cfg.LoginLockoutMinutes = "5"
+	service.cfg.LoginLockoutMaxMinutes = "60"
+
+	hashed := hashedPassword(t, "password123")
+
+	tests := []struct {
+		name        string
+		password    string
+		prepare     func(user *models.User)
+		setupMock   func(user *models.User)
+		expectedErr string
+	}{
+		{
+			name:     "блокировка после порога",
+			password: "wrong",
+			prepare: func(user *models.User) {
+				user.FailedLoginAttempts = 2
+				user.LockoutCount = 1
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+				mockRepo.EXPECT().
+					IncrementFailedLoginAttempts(user).
+					DoAndReturn(func(u *models.User) error {
+						u.FailedLoginAttempts++
+						return nil
+					})
+				mockRepo.EXPECT().
+					UpdateUser(user, gomock.Any()).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}) error {
+						assert.Equal(t, 0, updates["failed_login_attempts"])
+						assert.Equal(t, 2, updates["lockout_count"])
+						lockedUntil := updates["locked_until"].(time.Time)
+						assert.WithinDuration(t, time.Now().Add(10*time.Minute), lockedUntil, 5*time.Second)
+						return nil
+					})
+			},
+			expectedErr: "invalid email or password",
+		},
+		{
+			name:     "заблокированный аккаунт не проверяет пароль",
+			password: "password123",
+			prepare: func(user *models.User) {
+				lockedUntil := time.Now().Add(time.Minute)
+				user.LockedUntil = &lockedUntil
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+			},
+			expectedErr: "account is locked",
+		},
+		{
+			name:     "успешный вход сбрасывает счётчики",
+			password: "password123",
+			prepare: func(user *models.User) {
+				lockedUntil := time.Now().Add(-time.Minute)
+				user.LockedUntil = &lockedUntil
+				user.FailedLoginAttempts = 1
+				user.LockoutCount = 2
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+				mockRepo.EXPECT().UpdateUser(user, map[string]interface{}{
+					"failed_login_attempts": 0,
+					"lockout_count":         0,
+					"locked_until":          nil,
+				}).Return(nil)
+				mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+			user.Password = hashed
+			tt.prepare(user)
+			tt.setupMock(user)
+
+			result, err := service.LoginUser("test@example.com", tt.password, ClientInfo{})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, result.Tokens)
+			}
+		})
+	}
+}
+
+func TestUserService_LockoutDuration(t *testing.T) {
+	service, _, finish := setupTest(t)
+	defer finish()
+
+	service.cfg.LoginLockoutMinutes = "5"
+	service.cfg.LoginLockoutMaxMinutes = "30"
+
+	assert.Equal(t, 5*time.Minute, service.lockoutDuration(0))
+	assert.Equal(t, 10*time.Minute, service.lockoutDuration(1))
+	assert.Equal(t, 20*time.Minute, service.lockoutDuration(2))
+	assert.Equal(t, 30*time.Minute, service.lockoutDuration(3))
+	assert.Equal(t, 30*time.Minute, service.lockoutDuration(50))
+}
+
+func TestUserService_UnlockUser(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	lockedUntil := time.Now().Add(time.Hour)
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	user.LockedUntil = &lockedUntil
+	user.LockoutCount = 3
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().
+		UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).
+		DoAndReturn(func(u *models.User, _ map[string]interface{}, entry *models.AuditEntry, _ bool) error {
+			assert.Equal(t, AuditUserUnlocked, entry.Action)
+			u.LockedUntil = nil
+			u.LockoutCount = 0
+			return nil
+		})
+
+	status, err := service.UnlockUser(testAdmin, 1)
+	assert.NoError(t, err)
+	assert.False(t, status.Locked)
+	assert.Equal(t, 0, status.LockoutCount)
+
+	mockRepo.EXPECT().GetUserByID(uint(2)).Return((*models.User)(nil), assert.AnError)
+	_, err = service.UnlockUser(testAdmin, 2)
+	assert.ErrorContains(t, err, "user not found")
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const recoveryCodeCount = 10
+
+type MFAChallenge struct {
+	MFAToken string `json:"mfa_token"`
+	// EnrollmentRequired is set for privileged users who must set up TOTP
+	// before their first login can complete.
+	EnrollmentRequired bool `json:"enrollment_required"`
+}
+
+type MFALoginInput struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAEnrollInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type TOTPCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// mfaRequired reports whether config forces MFA on the user's roles.
+func (s *UserService) mfaRequired(user *models.User) bool {
+	required, _ := strconv.ParseBool(s.cfg.MFARequiredForPrivileged)
+	return required && (user.HasRole(userroles.RoleAdmin) || user.HasRole(userroles.RoleSuperadmin))
+}
+
+// newMFAChallenge issues a short-lived token proving the password step has
+// passed. It is signed with its own secret, so it never verifies as any
+// other token, and its JTI is stored so that it can be used only once. The
+// ver claim ties it to the user's sessions: a password reset or a session
+// revocation invalidates it.
+func (s *UserService) newMFAChallenge(user *models.User) (*MFAChallenge, error) {
+	lifespan := intSetting(s.cfg.MFATokenMinutes, 5)
+	expiresAt := time.Now().Add(time.Minute * time.Duration(lifespan))
+
+	jti, err := utils.RandomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MFA challenge: %v", err)
+	}
+
+	claims := jwt.MapClaims{}
+	claims["id"] = user.ID
+	claims["jti"] = jti
+	claims["ver"] = user.TokenVersion
+	claims["typ"] = "mfa"
+	claims["exp"] = expiresAt.Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.MFATokenSecret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MFA challenge: %v", err)
+	}
+	if err := s.tokenRepo.CreateMFAChallenge(&models.MFAChallenge{
+		UserID:    user.ID,
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store MFA challenge: %v", err)
+	}
+	return &MFAChallenge{MFAToken: token, EnrollmentRequired: s.mustEnrollMFA(user)}, nil
+}
+
+// mustEnrollMFA reports whether the user has to set up TOTP before a login
+// can complete. It is worked out from the account, never from the challenge.
+func (s *UserService) mustEnrollMFA(user *models.User) bool {
+	return s.mfaRequired(user) && !user.TOTPEnabled
+}
+
+func (s *UserService) parseMFAChallenge(mfaToken string) (*models.User, *models.MFAChallenge, error) {
+	parsed, err := jwt.Parse(mfaToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.cfg.MFATokenSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsed.Valid {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != "mfa" {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	userID, _ := claims["id"].(float64)
+	version, _ := claims["ver"].(float64)
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	challenge, err := s.tokenRepo.GetMFAChallengeByJTI(jti)
+	if err != nil || challenge.UsedAt != nil || challenge.UserID != uint(userID) ||
+		time.Now().After(challenge.ExpiresAt) {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.userRepo.GetUserByID(challenge.UserID)
+	if err != nil || user.TokenVersion != int(version) {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	return user, challenge, nil
+}
+
+// verifyTOTP accepts each code only once by remembering the last used step.
+func (s *UserService) verifyTOTP(user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastUsedStep {
+		return false, nil
+	}
+	if err := s.userRepo.UpdateUser(user, map[string]interface{}{"totp_last_used_step": step}); err != nil {
+		return false, fmt.Errorf("failed to store TOTP step: %v", err)
+	}
+	return true, nil
+}
+
+func (s *UserService) newRecoveryCodes(user *models.User) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %v", err)
+	}
+
+	stored := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		stored[i] = models.RecoveryCode{UserID: user.ID, CodeHash: utils.HashToken(code)}
+	}
+	if err := s.tokenRepo.ReplaceRecoveryCodes(user.ID, stored); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %v", err)
+	}
+	return codes, nil
+}
+
+func (s *UserService) enableTOTP(user *models.User) ([]string, error) {
+	if err := s.userRepo.UpdateUser(user, map[string]interface{}{"totp_enabled": true}); err != nil {
+		return nil, fmt.Errorf("failed to enable MFA: %v", err)
+	}
+	return s.newRecoveryCodes(user)
+}
+
+func (s *UserService) startTOTPEnrollment(user *models.User) (*TOTPEnrollment, error) {
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %v", err)
+	}
+	if err := s.userRepo.UpdateUser(user, map[string]interface{}{
+		"totp_secret":         secret,
+		"totp_last_used_step": 0,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to start MFA enrollment: %v", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// CompleteMFALogin finishes a login that returned an MFA challenge. For users
+// who were forced to enroll, a valid code also confirms the enrollment and the
+// result carries their recovery codes. Wrong codes count towards the lockout
+// either way, and the challenge is consumed once a code has passed.
+func (s *UserService) CompleteMFALogin(input MFALoginInput, client ClientInfo) (*LoginResult, error) {
+	user, challenge, err := s.parseMFAChallenge(input.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsLocked() {
+		return nil, ErrAccountLocked
+	}
+	if !user.Active {
+		return nil, ErrAccountDeactivated
+	}
+
+	enroll := s.mustEnrollMFA(user)
+	var ok bool
+	switch {
+	case user.TOTPEnabled:
+		ok, err = s.verifySecondFactor(user, input)
+	case enroll:
+		ok, err = s.verifyTOTP(user, strings.TrimSpace(input.Code))
+	default:
+		return nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.recordFailedLogin(user); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+
+	used, err := s.tokenRepo.MarkMFAChallengeUsed(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume MFA challenge: %v", err)
+	}
+	if !used {
+		return nil, ErrInvalidMFAToken
+	}
+
+	var recoveryCodes []string
+	if enroll {
+		if recoveryCodes, err = s.enableTOTP(user); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := s.completeLogin(user, client)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+func (s *UserService) verifySecondFactor(user *models.User, input MFALoginInput) (bool, error) {
+	if input.RecoveryCode != "" {
+		code := utils.NormalizeRecoveryCode(input.RecoveryCode)
+		used, err := s.tokenRepo.UseRecoveryCode(user.ID, utils.HashToken(code))
+		if err != nil {
+			return false, fmt.Errorf("failed to check recovery code: %v", err)
+		}
+		return used, nil
+	}
+	return s.verifyTOTP(user, strings.TrimSpace(input.Code))
+}
+
+// EnrollMFAFromChallenge lets a privileged user without TOTP set it up in the
+// middle of a login, before they hold an access token. Each challenge can
+// start an enrollment only once.
+func (s *UserService) EnrollMFAFromChallenge(mfaToken string) (*TOTPEnrollment, error) {
+	user, challenge, err := s.parseMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !s.mustEnrollMFA(user) {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	enrolled, err := s.tokenRepo.MarkMFAChallengeEnrolled(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume MFA challenge: %v", err)
+	}
+	if !enrolled {
+		return nil, ErrInvalidMFAToken
+	}
+	return s.startTOTPEnrollment(user)
+}
+
+func (s *UserService) StartTOTPEnrollment(userID uint) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.startTOTPEnrollment(user)
+}
+
+func (s *UserService) ConfirmTOTPEnrollment(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFAEnrollmentNotStarted
+	}
+
+	ok, err := s.verifyTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	return s.enableTOTP(user)
+}
+
+func (s *UserService) DisableTOTP(userID uint, code string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !user.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+	if s.mfaRequired(user) {
+		return ErrMFARequiredForRole
+	}
+
+	ok, err := s.verifyTOTP(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	if err := s.userRepo.UpdateUser(user, map[string]interface{}{
+		"totp_enabled":        false,
+		"totp_secret":         "",
+		"totp_last_used_step": 0,
+	}); err != nil {
+		return fmt.Errorf("failed to disable MFA: %v", err)
+	}
+	if err := s.tokenRepo.ReplaceRecoveryCodes(user.ID, nil); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	return nil
+}
+
+func (s *UserService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	ok, err := s.verifyTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	return s.newRecoveryCodes(user)
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories/mocks"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// stubMFAChallenges keeps issued MFA challenges in memory and consumes them
+// the way the token repository does.
+func stubMFAChallenges(mockTokenRepo *mocks.MockTokenRepositoryInterface) {
+	challenges := make(map[string]*models.MFAChallenge)
+	mockTokenRepo.EXPECT().
+		CreateMFAChallenge(gomock.Any()).
+		DoAndReturn(func(challenge *models.MFAChallenge) error {
+			challenge.ID = uint(len(challenges) + 1)
+			challenges[challenge.JTI] = challenge
+			return nil
+		}).
+		AnyTimes()
+	mockTokenRepo.EXPECT().
+		GetMFAChallengeByJTI(gomock.Any()).
+		DoAndReturn(func(jti string) (*models.MFAChallenge, error) {
+			challenge, ok := challenges[jti]
+			if !ok {
+				return nil, gorm.ErrRecordNotFound
+			}
+			stored := *challenge
+			return &stored, nil
+		}).
+		AnyTimes()
+	mockTokenRepo.EXPECT().
+		MarkMFAChallengeEnrolled(gomock.Any()).
+		DoAndReturn(func(challenge *models.MFAChallenge) (bool, error) {
+			stored := challenges[challenge.JTI]
+			if stored.EnrolledAt != nil || stored.UsedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			stored.EnrolledAt = &now
+			return true, nil
+		}).
+		AnyTimes()
+	mockTokenRepo.EXPECT().
+		MarkMFAChallengeUsed(gomock.Any()).
+		DoAndReturn(func(challenge *models.MFAChallenge) (bool, error) {
+			stored := challenges[challenge.JTI]
+			if stored.UsedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			stored.UsedAt = &now
+			return true, nil
+		}).
+		AnyTimes()
+}
+
+func TestUserService_LoginUserMFAChallenge(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+	stubMFAChallenges(mockTokenRepo)
+
+	service.cfg.MFATokenMinutes = "5"
+
+	hashed := hashedPassword(t, "password123")
+
+	tests := []struct {
+		name         string
+		roles        []string
+		totpEnabled  bool
+		requireMFA   string
+		expectEnroll bool
+	}{
+		{
+			name:        "пользователь с включённым TOTP",
+			roles:       []string{userroles.RoleEngineer},
+			totpEnabled: true,
+			requireMFA:  "false",
+		},
+		{
+			name:         "администратор без TOTP при обязательной MFA",
+			roles:        []string{userroles.RoleAdmin},
+			requireMFA:   "true",
+			expectEnroll: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service.cfg.MFARequiredForPrivileged = tt.requireMFA
+
+			user := newTestUser(1, "test@example.com", "Test User", tt.roles...)
+			user.Password = hashed
+			user.TOTPEnabled = tt.totpEnabled
+			mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+
+			result, err := service.LoginUser("test@example.com", "password123", ClientInfo{})
+			assert.NoError(t, err)
+			assert.Nil(t, result.Tokens)
+			if assert.NotNil(t, result.MFA) {
+				assert.NotEmpty(t, result.MFA.MFAToken)
+				assert.Equal(t, tt.expectEnroll, result.MFA.EnrollmentRequired)
+			}
+		})
+	}
+}
+
+func TestUserService_CompleteMFALogin(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+	stubMFAChallenges(mockTokenRepo)
+
+	service.cfg.MFATokenMinutes = "5"
+	service.cfg.LoginMaxAttempts = "5"
+
+	newUser := func() *models.User {
+		user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+		user.TOTPEnabled = true
+		user.TOTPSecret = testTOTPSecret
+		return user
+	}
+	validCode, err := utils.TOTPCode(testTOTPSecret, time.Now())
+	assert.NoError(t, err)
+	currentStep, _ := utils.ValidateTOTP(testTOTPSecret, validCode, time.Now())
+
+	tests := []struct {
+		name        string
+		input       func(token string) MFALoginInput
+		prepare     func(user *models.User)
+		setupMock   func(user *models.User)
+		expectedErr string
+	}{
+		{
+			name: "верный TOTP код",
+			input: func(token string) MFALoginInput {
+				return MFALoginInput{MFAToken: token, Code: validCode}
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().
+					UpdateUser(user, gomock.Any()).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}) error {
+						assert.Equal(t, currentStep, updates["totp_last_used_step"])
+						return nil
+					})
+				mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "повторное использование кода",
+			input: func(token string) MFALoginInput {
+				return MFALoginInput{MFAToken: token, Code: validCode}
+			},
+			prepare: func(user *models.User) {
+				user.TOTPLastUsedStep = currentStep
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().IncrementFailedLoginAttempts(user).Return(nil)
+			},
+			expectedErr: "invalid mfa code",
+		},
+		{
+			name: "код восстановления",
+			input: func(token string) MFALoginInput {
+				return MFALoginInput{MFAToken: token, RecoveryCode: "ABCD-1234"}
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockTokenRepo.EXPECT().
+					UseRecoveryCode(uint(1), utils.HashToken("abcd-1234")).
+					Return(true, nil)
+				mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "неверный код",
+			input: func(token string) MFALoginInput {
+				return MFALoginInput{MFAToken: token, Code: "000000"}
+			},
+			prepare: func(user *models.User) {
+				// Make sure the fixed code can't accidentally be valid.
+				user.TOTPLastUsedStep = currentStep + 1
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().IncrementFailedLoginAttempts(user).Return(nil)
+			},
+			expectedErr: "invalid mfa code",
+		},
+		{
+			name: "неверный mfa токен",
+			input: func(token string) MFALoginInput {
+				return MFALoginInput{MFAToken: "invalid", Code: validCode}
+			},
+			setupMock:   func(user *models.User) {},
+			expectedErr: "invalid mfa token",
+		},
+		{
+			name: "токен подписан секретом refresh-токенов",
+			input: func(token string) MFALoginInput {
+				forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"id":  1,
+					"typ": "mfa",
+					"exp": time.Now().Add(time.Minute).Unix(),
+				}).SignedString([]byte(service.cfg.RefreshTokenSecret))
+				assert.NoError(t, err)
+				return MFALoginInput{MFAToken: forged, Code: validCode}
+			},
+			setupMock:   func(user *models.User) {},
+			expectedErr: "invalid mfa token",
+		},
+		{
+			name: "сессии отозваны после выдачи токена",
+			input: func(token string) MFALoginInput {
+				return MFALoginInput{MFAToken: token, Code: validCode}
+			},
+			setupMock: func(user *models.User) {
+				reset := *user
+				reset.TokenVersion++
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(&reset, nil)
+			},
+			expectedErr: "invalid mfa token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newUser()
+			if tt.prepare != nil {
+				tt.prepare(user)
+			}
+			challenge, err := service.newMFAChallenge(user)
+			assert.NoError(t, err)
+			tt.setupMock(user)
+
+			result, err := service.CompleteMFALogin(tt.input(challenge.MFAToken), ClientInfo{})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, result.Tokens.AccessToken)
+				assert.Empty(t, result.RecoveryCodes)
+			}
+		})
+	}
+}
+
+func TestUserService_CompleteMFALoginEnrollment(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+	stubMFAChallenges(mockTokenRepo)
+
+	service.cfg.MFATokenMinutes = "5"
+	service.cfg.MFARequiredForPrivileged = "true"
+	service.cfg.LoginMaxAttempts = "5"
+
+	user := newTestUser(1, "admin@example.com", "Admin", userroles.RoleAdmin)
+	challenge, err := service.newMFAChallenge(user)
+	assert.NoError(t, err)
+	assert.True(t, challenge.EnrollmentRequired)
+
+	// Enrollment stores the pending secret on the user.
+	user.TOTPSecret = testTOTPSecret
+
+	// A wrong code during enrollment counts towards the lockout.
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().IncrementFailedLoginAttempts(user).Return(nil)
+	_, err = service.CompleteMFALogin(MFALoginInput{MFAToken: challenge.MFAToken, Code: "000000"}, ClientInfo{})
+	assert.EqualError(t, err, "invalid mfa code")
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().UpdateUser(user, gomock.Any()).Return(nil).Times(2)
+	mockTokenRepo.EXPECT().
+		ReplaceRecoveryCodes(uint(1), gomock.Any()).
+		DoAndReturn(func(userID uint, codes []models.RecoveryCode) error {
+			assert.Len(t, codes, recoveryCodeCount)
+			return nil
+		})
+	mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+	mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+
+	code, err := utils.TOTPCode(testTOTPSecret, time.Now())
+	assert.NoError(t, err)
+
+	result, err := service.CompleteMFALogin(MFALoginInput{
+		MFAToken: challenge.MFAToken,
+		Code:     code,
+	}, ClientInfo{})
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Tokens)
+	assert.Len(t, result.RecoveryCodes, recoveryCodeCount)
+
+	// The challenge is single-use.
+	_, err = service.CompleteMFALogin(MFALoginInput{MFAToken: challenge.MFAToken, Code: code}, ClientInfo{})
+	assert.EqualError(t, err, "invalid mfa token")
+}
+
+func TestUserService_EnrollMFAFromChallenge(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+	stubMFAChallenges(mockTokenRepo)
+
+	service.cfg.MFATokenMinutes = "5"
+	service.cfg.MFARequiredForPrivileged = "true"
+
+	admin := newTestUser(1, "admin@example.com", "Admin", userroles.RoleAdmin)
+	challenge, err := service.newMFAChallenge(admin)
+	assert.NoError(t, err)
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(admin, nil).Times(2)
+	mockRepo.EXPECT().UpdateUser(admin, gomock.Any()).Return(nil)
+	enrollment, err := service.EnrollMFAFromChallenge(challenge.MFAToken)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+
+	_, err = service.EnrollMFAFromChallenge(challenge.MFAToken)
+	assert.EqualError(t, err, "invalid mfa token", "одна постановка на учёт на токен")
+
+	// Enrollment is decided by the account, not by the token: an engineer
+	// is never asked to enroll, whatever the challenge says.
+	engineer := newTestUser(2, "test@example.com", "Test User", userroles.RoleEngineer)
+	challenge, err = service.newMFAChallenge(engineer)
+	assert.NoError(t, err)
+	assert.False(t, challenge.EnrollmentRequired)
+
+	mockRepo.EXPECT().GetUserByID(uint(2)).Return(engineer, nil)
+	_, err = service.EnrollMFAFromChallenge(challenge.MFAToken)
+	assert.EqualError(t, err, "mfa is already enabled")
+}
+
+func TestUserService_DisableTOTP(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	service.cfg.MFARequiredForPrivileged = "true"
+
+	admin := newTestUser(1, "admin@example.com", "Admin", userroles.RoleAdmin)
+	admin.TOTPEnabled = true
+	admin.TOTPSecret = testTOTPSecret
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(admin, nil)
+
+	code, err := utils.TOTPCode(testTOTPSecret, time.Now())
+	assert.NoError(t, err)
+
+	err = service.DisableTOTP(1, code)
+	assert.EqualError(t, err, "mfa is required for your role")
+}
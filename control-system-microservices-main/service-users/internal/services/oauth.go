@@ -0,0 +1,335 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AuditOAuthClientCreated = "oauth_client.created"
+	AuditOAuthClientRevoked = "oauth_client.revoked"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+	oauthClientIDMarker        = "cli_"
+)
+
+// OAuth errors carry the RFC 6749 error codes as their messages.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+)
+
+type CreateOAuthClientInput struct {
+	Name string `json:"name" binding:"required"`
+	// Scopes limit what the client can request. A client without scopes can
+	// request every permission of the account's roles.
+	Scopes []string `json:"scopes"`
+}
+
+type OAuthClientResponse struct {
+	ClientID  string     `json:"client_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreatedOAuthClient is the only response that ever contains the secret.
+type CreatedOAuthClient struct {
+	OAuthClientResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+// OAuthTokenRequest is a token endpoint request after the client credentials
+// have been taken from the Authorization header or the form.
+type OAuthTokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	// Only used by the authorization_code grant.
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// OAuthTokenResponse follows RFC 6749 section 5.1.
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// IntrospectionResponse follows RFC 7662 section 2.2. Revoked is an extension
+// that tells a token that was revoked apart from one that is merely expired
+// or malformed.
+type IntrospectionResponse struct {
+	Active      bool     `json:"active"`
+	Revoked     bool     `json:"revoked"`
+	Sub         string   `json:"sub,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	TokenType   string   `json:"token_type,omitempty"`
+	Exp         int64    `json:"exp,omitempty"`
+	Iat         int64    `json:"iat,omitempty"`
+	Jti         string   `json:"jti,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+}
+
+func toOAuthClientResponse(client *models.OAuthClient) OAuthClientResponse {
+	scopes := []string(client.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return OAuthClientResponse{
+		ClientID:  client.ClientID,
+		Name:      client.Name,
+		Scopes:    scopes,
+		RevokedAt: client.RevokedAt,
+		CreatedAt: client.CreatedAt,
+	}
+}
+
+// CreateOAuthClient registers a client_credentials client for a service
+// account. The secret is returned once and cannot be recovered afterwards.
+func (s *UserService) CreateOAuthClient(actor Actor, userID uint, input CreateOAuthClientInput) (*CreatedOAuthClient, error) {
+	user, err := s.getServiceAccount(actor, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkScopes(user, input.Scopes); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate client id: %v", err)
+	}
+	secret, err := utils.RandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate client secret: %v", err)
+	}
+
+	client := models.OAuthClient{
+		ClientID:   oauthClientIDMarker + hex.EncodeToString(raw),
+		SecretHash: utils.HashToken(secret),
+		Name:       input.Name,
+		UserID:     user.ID,
+		Scopes:     input.Scopes,
+	}
+	entry := auditEntry(actor, AuditOAuthClientCreated, user.ID, models.AuditChanges{
+		"oauth_client": {Before: nil, After: client.ClientID},
+	})
+	if err := s.tokenRepo.CreateOAuthClient(&client, entry); err != nil {
+		return nil, fmt.Errorf("failed to create oauth client: %v", err)
+	}
+
+	return &CreatedOAuthClient{OAuthClientResponse: toOAuthClientResponse(&client), ClientSecret: secret}, nil
+}
+
+func (s *UserService) ListOAuthClients(userID uint) ([]OAuthClientResponse, error) {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	clients, err := s.tokenRepo.GetUserOAuthClients(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth clients: %v", err)
+	}
+	response := make([]OAuthClientResponse, 0, len(clients))
+	for i := range clients {
+		response = append(response, toOAuthClientResponse(&clients[i]))
+	}
+	return response, nil
+}
+
+// RevokeOAuthClient stops the client from getting new tokens. Tokens it
+// already holds fail introspection at once, and the gateway's token status
+// check rejects them by cid once its revocation cache expires.
+func (s *UserService) RevokeOAuthClient(actor Actor, userID uint, clientID string) error {
+	user, err := s.getServiceAccount(actor, userID)
+	if err != nil {
+		return err
+	}
+	client, err := s.tokenRepo.GetOAuthClientByClientID(clientID)
+	if err != nil || client.UserID != user.ID || client.RevokedAt != nil {
+		return ErrOAuthClientNotFound
+	}
+	entry := auditEntry(actor, AuditOAuthClientRevoked, user.ID, models.AuditChanges{
+		"oauth_client": {Before: client.ClientID, After: nil},
+	})
+	if err := s.tokenRepo.RevokeOAuthClient(client, entry); err != nil {
+		return fmt.Errorf("failed to revoke oauth client: %v", err)
+	}
+	return nil
+}
+
+// authenticateClient checks the credentials of a service account client and
+// returns the client with its account. OIDC login clients have no account
+// and are rejected.
+func (s *UserService) authenticateClient(clientID, secret string) (*models.OAuthClient, *models.User, error) {
+	invalid := errors.New(OAuthInvalidClient)
+	if clientID == "" || secret == "" {
+		return nil, nil, invalid
+	}
+	client, err := s.tokenRepo.GetOAuthClientByClientID(clientID)
+	if err != nil || client.RevokedAt != nil || client.IsOIDC() {
+		return nil, nil, invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(utils.HashToken(secret))) != 1 {
+		return nil, nil, invalid
+	}
+	user, err := s.userRepo.GetUserByID(client.UserID)
+	if err != nil || !user.Active || !user.ServiceAccount {
+		return nil, nil, invalid
+	}
+	return client, user, nil
+}
+
+// IssueToken serves the token endpoint.
+func (s *UserService) IssueToken(input OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	switch input.GrantType {
+	case "":
+		return nil, errors.New(OAuthInvalidRequest)
+	case GrantTypeClientCredentials:
+		return s.clientCredentialsToken(input)
+	case GrantTypeAuthorizationCode:
+		return s.authorizationCodeToken(input)
+	default:
+		return nil, errors.New(OAuthUnsupportedGrantType)
+	}
+}
+
+// clientCredentialsToken implements the client_credentials grant. The token
+// is an ordinary access token of the client's service account, limited to
+// the requested scope. It stays valid only while the client is not revoked.
+func (s *UserService) clientCredentialsToken(input OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	client, user, err := s.authenticateClient(input.ClientID, input.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.scopedPermissions(user, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	granted := allowed
+	if requested := strings.Fields(input.Scope); len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(allowed, scope) {
+				return nil, errors.New(OAuthInvalidScope)
+			}
+		}
+		granted = requested
+	}
+
+	// Like a scoped API key, the token only carries the roles its scope
+	// covers, since some checks still go by role.
+	roles, err := s.scopedRoles(user, granted)
+	if err != nil {
+		return nil, err
+	}
+	subject := *user
+	subject.Roles = roles
+
+	token, expiresAt, err := utils.GenerateClientToken(subject, granted, client.ClientID, s.keys, s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %v", err)
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Round(time.Second).Seconds()),
+		Scope:       strings.Join(granted, " "),
+	}, nil
+}
+
+// Introspect reports on an access token to an authenticated client. Any
+// token that cannot be used is reported as inactive.
+func (s *UserService) Introspect(clientID, secret, token string) (*IntrospectionResponse, error) {
+	if _, _, err := s.authenticateClient(clientID, secret); err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errors.New(OAuthInvalidRequest)
+	}
+
+	parsed, err := utils.ParseToken(token, s.keys)
+	if err != nil || !parsed.Valid {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	userID, _ := claims["id"].(float64)
+	version, _ := claims["ver"].(float64)
+	sessionID, _ := claims["sid"].(string)
+	tokenClientID, _ := claims["cid"].(string)
+	if jti == "" || userID == 0 {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	revoked, err := s.IsAccessTokenRevoked(jti, uint(userID), int(version), sessionID, tokenClientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token: %v", err)
+	}
+	if revoked {
+		return &IntrospectionResponse{Active: false, Revoked: true}, nil
+	}
+
+	permissions := claimStrings(claims["permissions"])
+	response := &IntrospectionResponse{
+		Active:      true,
+		Sub:         strconv.FormatUint(uint64(userID), 10),
+		ClientID:    tokenClientID,
+		Roles:       claimStrings(claims["roles"]),
+		Permissions: permissions,
+		Scope:       strings.Join(permissions, " "),
+		TokenType:   "Bearer",
+		Jti:         jti,
+		SessionID:   sessionID,
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		response.Exp = exp.Unix()
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		response.Iat = iat.Unix()
+	}
+	return response, nil
+}
+
+func claimStrings(claim interface{}) []string {
+	raw, _ := claim.([]interface{})
+	values := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if s, ok := r.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
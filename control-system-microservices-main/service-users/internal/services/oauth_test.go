@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+const testClientSecret = "client-secret"
+
+func newTestOAuthClient(scopes ...string) *models.OAuthClient {
+	return &models.OAuthClient{
+		ID:         1,
+		ClientID:   "cli_0011223344556677",
+		SecretHash: utils.HashToken(testClientSecret),
+		Name:       "reports",
+		UserID:     7,
+		Scopes:     scopes,
+	}
+}
+
+func TestUserService_IssueToken_ClientCredentials(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name                string
+		input               OAuthTokenRequest
+		client              *models.OAuthClient
+		expectedPermissions []string
+		expectedRoles       []string
+		expectedErr         string
+	}{
+		{
+			name:                "все права клиента",
+			input:               OAuthTokenRequest{GrantType: "client_credentials", ClientID: "cli_0011223344556677", ClientSecret: testClientSecret},
+			client:              newTestOAuthClient(),
+			expectedPermissions: models.DefaultRolePermissions[userroles.RoleObserver],
+			expectedRoles:       []string{userroles.RoleObserver},
+		},
+		{
+			name:                "запрошенный scope",
+			input:               OAuthTokenRequest{GrantType: "client_credentials", ClientID: "cli_0011223344556677", ClientSecret: testClientSecret, Scope: models.PermissionOrdersRead},
+			client:              newTestOAuthClient(),
+			expectedPermissions: []string{models.PermissionOrdersRead},
+			expectedRoles:       []string{},
+		},
+		{
+			name:        "scope шире разрешённого клиенту",
+			input:       OAuthTokenRequest{GrantType: "client_credentials", ClientID: "cli_0011223344556677", ClientSecret: testClientSecret, Scope: models.PermissionOrdersCreate},
+			client:      newTestOAuthClient(models.PermissionOrdersRead),
+			expectedErr: "invalid_scope",
+		},
+		{
+			name:        "неверный секрет",
+			input:       OAuthTokenRequest{GrantType: "client_credentials", ClientID: "cli_0011223344556677", ClientSecret: "wrong"},
+			client:      newTestOAuthClient(),
+			expectedErr: "invalid_client",
+		},
+		{
+			name:        "отозванный клиент",
+			input:       OAuthTokenRequest{GrantType: "client_credentials", ClientID: "cli_0011223344556677", ClientSecret: testClientSecret},
+			client:      func() *models.OAuthClient { c := newTestOAuthClient(); c.RevokedAt = &revokedAt; return c }(),
+			expectedErr: "invalid_client",
+		},
+		{
+			name:        "другой grant",
+			input:       OAuthTokenRequest{GrantType: "password", ClientID: "cli_0011223344556677", ClientSecret: testClientSecret},
+			expectedErr: "unsupported_grant_type",
+		},
+		{
+			name:        "без grant_type",
+			input:       OAuthTokenRequest{ClientID: "cli_0011223344556677", ClientSecret: testClientSecret},
+			expectedErr: "invalid_request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.client != nil {
+				mockTokenRepo.EXPECT().GetOAuthClientByClientID("cli_0011223344556677").Return(tt.client, nil)
+				if tt.client.RevokedAt == nil && tt.input.ClientSecret == testClientSecret {
+					mockRepo.EXPECT().GetUserByID(uint(7)).Return(newTestServiceAccount(7, userroles.RoleObserver), nil)
+				}
+			}
+
+			got, err := service.IssueToken(tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "Bearer", got.TokenType)
+			assert.Equal(t, int64(5*60), got.ExpiresIn)
+
+			parsed, err := utils.ParseToken(got.AccessToken, service.keys)
+			assert.NoError(t, err)
+			claims := parsed.Claims.(jwt.MapClaims)
+			assert.Equal(t, "cli_0011223344556677", claims["cid"])
+			assert.Nil(t, claims["sid"])
+			assert.Equal(t, tt.expectedPermissions, claimStrings(claims["permissions"]))
+			assert.ElementsMatch(t, tt.expectedRoles, claimStrings(claims["roles"]))
+		})
+	}
+}
+
+func TestUserService_Introspect(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	account := newTestServiceAccount(7, userroles.RoleObserver)
+	token, _, err := utils.GenerateClientToken(*account, []string{models.PermissionOrdersRead}, "cli_0011223344556677", service.keys, service.cfg)
+	assert.NoError(t, err)
+
+	revokedAt := time.Now()
+
+	expectCaller := func() {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID("cli_caller").Return(&models.OAuthClient{
+			ID: 2, ClientID: "cli_caller", SecretHash: utils.HashToken(testClientSecret), UserID: 9,
+		}, nil)
+		mockRepo.EXPECT().GetUserByID(uint(9)).Return(newTestServiceAccount(9, userroles.RoleObserver), nil)
+	}
+
+	t.Run("активный токен", func(t *testing.T) {
+		expectCaller()
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(false, nil)
+		mockRepo.EXPECT().GetUserByID(uint(7)).Return(account, nil)
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID("cli_0011223344556677").Return(newTestOAuthClient(), nil)
+
+		got, err := service.Introspect("cli_caller", testClientSecret, token)
+		assert.NoError(t, err)
+		assert.True(t, got.Active)
+		assert.False(t, got.Revoked)
+		assert.Equal(t, "7", got.Sub)
+		assert.Equal(t, []string{userroles.RoleObserver}, got.Roles)
+		assert.Equal(t, models.PermissionOrdersRead, got.Scope)
+		assert.Equal(t, "cli_0011223344556677", got.ClientID)
+		assert.NotZero(t, got.Exp)
+	})
+
+	t.Run("клиент токена отозван", func(t *testing.T) {
+		expectCaller()
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(false, nil)
+		revoked := newTestOAuthClient()
+		revoked.RevokedAt = &revokedAt
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID("cli_0011223344556677").Return(revoked, nil)
+
+		got, err := service.Introspect("cli_caller", testClientSecret, token)
+		assert.NoError(t, err)
+		assert.Equal(t, &IntrospectionResponse{Active: false, Revoked: true}, got)
+	})
+
+	t.Run("токен после выхода", func(t *testing.T) {
+		expectCaller()
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(true, nil)
+
+		got, err := service.Introspect("cli_caller", testClientSecret, token)
+		assert.NoError(t, err)
+		assert.Equal(t, &IntrospectionResponse{Active: false, Revoked: true}, got)
+	})
+
+	t.Run("не токен", func(t *testing.T) {
+		expectCaller()
+
+		got, err := service.Introspect("cli_caller", testClientSecret, "garbage")
+		assert.NoError(t, err)
+		assert.Equal(t, &IntrospectionResponse{Active: false}, got)
+	})
+
+	t.Run("неизвестный клиент", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID("cli_caller").Return(nil, errors.New("not found"))
+
+		got, err := service.Introspect("cli_caller", testClientSecret, token)
+		assert.EqualError(t, err, "invalid_client")
+		assert.Nil(t, got)
+	})
+}
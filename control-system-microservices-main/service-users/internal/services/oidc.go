@@ -0,0 +1,517 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeEmail   = "email"
+	ScopeProfile = "profile"
+	ScopeRoles   = "roles"
+)
+
+var oidcScopes = []string{ScopeOpenID, ScopeEmail, ScopeProfile, ScopeRoles}
+
+const (
+	authorizationCodeLifetime = 2 * time.Minute
+	// PKCE code verifiers are 43 to 128 characters long (RFC 7636 4.1).
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+// Authorization request errors that must not be sent to the redirect URI,
+// because the URI itself can't be trusted.
+const (
+	AuthorizeUnknownClient      = "unknown client"
+	AuthorizeInvalidRedirectURI = "invalid redirect_uri"
+)
+
+type CreateOIDCClientInput struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required"`
+	// Public clients get no secret and have to rely on PKCE alone.
+	Public bool `json:"public"`
+}
+
+type OIDCClientResponse struct {
+	ClientID     string     `json:"client_id"`
+	Name         string     `json:"name"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Public       bool       `json:"public"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CreatedOIDCClient is the only response that ever contains the secret.
+type CreatedOIDCClient struct {
+	OIDCClientResponse
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizeRequest holds the parameters of an authorization request. PKCE
+// with S256 is required of every client.
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// DiscoveryDocument is the OpenID Provider Metadata.
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// UserInfoResponse only has the claims the granted scopes cover.
+type UserInfoResponse struct {
+	Sub   string   `json:"sub"`
+	Email string   `json:"email,omitempty"`
+	Name  string   `json:"name,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+func toOIDCClientResponse(client *models.OAuthClient) OIDCClientResponse {
+	return OIDCClientResponse{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Public:       client.Public,
+		RevokedAt:    client.RevokedAt,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	return u.Scheme == "https" || u.Scheme == "http"
+}
+
+// CreateOIDCClient registers a single sign-on client, such as Grafana.
+func (s *UserService) CreateOIDCClient(input CreateOIDCClientInput) (*CreatedOIDCClient, error) {
+	if len(input.RedirectURIs) == 0 {
+		return nil, ErrRedirectURIRequired
+	}
+	for _, uri := range input.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return nil, withValue(ErrInvalidRedirectURI, uri, "redirect_uris")
+		}
+	}
+
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate client id: %v", err)
+	}
+	client := models.OAuthClient{
+		ClientID:     oauthClientIDMarker + hex.EncodeToString(raw),
+		Name:         input.Name,
+		RedirectURIs: input.RedirectURIs,
+		Public:       input.Public,
+	}
+
+	var secret string
+	if !input.Public {
+		var err error
+		if secret, err = utils.RandomString(32); err != nil {
+			return nil, fmt.Errorf("failed to generate client secret: %v", err)
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := s.tokenRepo.CreateOAuthClient(&client, nil); err != nil {
+		return nil, fmt.Errorf("failed to create oauth client: %v", err)
+	}
+	return &CreatedOIDCClient{OIDCClientResponse: toOIDCClientResponse(&client), ClientSecret: secret}, nil
+}
+
+func (s *UserService) ListOIDCClients() ([]OIDCClientResponse, error) {
+	clients, err := s.tokenRepo.GetOIDCClients()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth clients: %v", err)
+	}
+	response := make([]OIDCClientResponse, 0, len(clients))
+	for i := range clients {
+		response = append(response, toOIDCClientResponse(&clients[i]))
+	}
+	return response, nil
+}
+
+// RevokeOIDCClient stops the client from signing users in. The userinfo
+// tokens it already obtained stop working as well.
+func (s *UserService) RevokeOIDCClient(clientID string) error {
+	client, err := s.tokenRepo.GetOAuthClientByClientID(clientID)
+	if err != nil || !client.IsOIDC() || client.RevokedAt != nil {
+		return ErrOAuthClientNotFound
+	}
+	if err := s.tokenRepo.RevokeOAuthClient(client, nil); err != nil {
+		return fmt.Errorf("failed to revoke oauth client: %v", err)
+	}
+	return nil
+}
+
+func (s *UserService) Discovery() DiscoveryDocument {
+	issuer := s.cfg.OIDCIssuer
+	var algs []string
+	for _, key := range s.keys.JWKS().Keys {
+		if !slices.Contains(algs, key.Alg) {
+			algs = append(algs, key.Alg)
+		}
+	}
+	return DiscoveryDocument{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   oidcScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algs,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "name", "roles"},
+	}
+}
+
+// ValidateAuthorizeRequest checks an authorization request before the login
+// page is shown. AuthorizeUnknownClient and AuthorizeInvalidRedirectURI must
+// be shown to the user; any other error is an RFC 6749 error code to be sent
+// back to the redirect URI.
+func (s *UserService) ValidateAuthorizeRequest(req AuthorizeRequest) (*models.OAuthClient, error) {
+	client, err := s.tokenRepo.GetOAuthClientByClientID(req.ClientID)
+	if err != nil || !client.IsOIDC() || client.RevokedAt != nil {
+		return nil, errors.New(AuthorizeUnknownClient)
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, errors.New(AuthorizeInvalidRedirectURI)
+	}
+
+	if req.ResponseType != "code" {
+		return nil, errors.New(OAuthUnsupportedResponseType)
+	}
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return nil, errors.New(OAuthInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(oidcScopes, scope) {
+			return nil, errors.New(OAuthInvalidScope)
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, errors.New(OAuthInvalidRequest)
+	}
+	return client, nil
+}
+
+// AuthorizationRedirect builds the URI the browser is sent back to.
+func AuthorizationRedirect(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// AuthorizeWithPassword signs the user in on the login page and returns the
+// redirect that hands the authorization code to the client. Users with TOTP
+// also have to enter a code; users who still have to enroll must first do
+// so in the regular login.
+func (s *UserService) AuthorizeWithPassword(req AuthorizeRequest, email, password, otp string) (string, error) {
+	client, err := s.ValidateAuthorizeRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.verifyCredentials(email, password)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case user.TOTPEnabled:
+		if strings.TrimSpace(otp) == "" {
+			return "", ErrMFACodeRequired
+		}
+		ok, err := s.verifySecondFactor(user, MFALoginInput{Code: otp})
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			if err := s.recordFailedLogin(user); err != nil {
+				return "", err
+			}
+			return "", ErrInvalidMFACode
+		}
+	case s.mfaRequired(user):
+		return "", ErrMFAEnrollmentRequired
+	}
+	if err := s.clearFailedLogins(user); err != nil {
+		return "", err
+	}
+
+	code, err := utils.RandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %v", err)
+	}
+	now := time.Now()
+	if err := s.tokenRepo.CreateAuthorizationCode(&models.AuthorizationCode{
+		CodeHash:      utils.HashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(strings.Fields(req.Scope), " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(authorizationCodeLifetime),
+	}); err != nil {
+		return "", fmt.Errorf("failed to store authorization code: %v", err)
+	}
+
+	return AuthorizationRedirect(req.RedirectURI, url.Values{"code": {code}, "state": {req.State}}), nil
+}
+
+// authenticateOIDCClient checks the credentials of a login client. Public
+// clients only identify themselves.
+func (s *UserService) authenticateOIDCClient(clientID, secret string) (*models.OAuthClient, error) {
+	invalid := errors.New(OAuthInvalidClient)
+	client, err := s.tokenRepo.GetOAuthClientByClientID(clientID)
+	if err != nil || !client.IsOIDC() || client.RevokedAt != nil {
+		return nil, invalid
+	}
+	if client.Public {
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(utils.HashToken(secret))) != 1 {
+		return nil, invalid
+	}
+	return client, nil
+}
+
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// authorizationCodeToken redeems an authorization code for an access token
+// and an ID token. The access token is only good for the userinfo endpoint:
+// relying parties sign users in, they do not get to call the API on their
+// behalf.
+func (s *UserService) authorizationCodeToken(input OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	client, err := s.authenticateOIDCClient(input.ClientID, input.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if input.Code == "" || input.CodeVerifier == "" {
+		return nil, errors.New(OAuthInvalidRequest)
+	}
+
+	invalid := errors.New(OAuthInvalidGrant)
+	code, err := s.tokenRepo.GetAuthorizationCode(utils.HashToken(input.Code))
+	if err != nil || code.UsedAt != nil || time.Now().After(code.ExpiresAt) {
+		return nil, invalid
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != input.RedirectURI {
+		return nil, invalid
+	}
+	if !verifyCodeChallenge(input.CodeVerifier, code.CodeChallenge) {
+		return nil, invalid
+	}
+	redeemed, err := s.tokenRepo.MarkAuthorizationCodeUsed(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem authorization code: %v", err)
+	}
+	if !redeemed {
+		return nil, invalid
+	}
+
+	user, err := s.userRepo.GetUserByID(code.UserID)
+	if err != nil || !user.Active || user.Pending {
+		return nil, invalid
+	}
+
+	accessToken, err := s.userInfoToken(user, client.ClientID, code)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.idToken(user, client.ClientID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(intSetting(s.cfg.TokenMinuteLifespan, 15) * 60),
+		Scope:       code.Scope,
+		IDToken:     idToken,
+	}, nil
+}
+
+// userInfoAudience is the aud of the access tokens handed to relying parties.
+func (s *UserService) userInfoAudience() string {
+	return s.cfg.OIDCIssuer + "/oauth/userinfo"
+}
+
+// userInfoToken signs the access token of an authorization code grant. Its
+// audience is the userinfo endpoint and it has no id claim, so the gateway
+// and introspection never accept it; cid lets it be revoked with the client.
+func (s *UserService) userInfoToken(user *models.User, clientID string, code *models.AuthorizationCode) (string, error) {
+	jti, err := utils.RandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate access token: %v", err)
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   s.cfg.OIDCIssuer,
+		"sub":   strconv.FormatUint(uint64(user.ID), 10),
+		"aud":   s.userInfoAudience(),
+		"cid":   clientID,
+		"scope": code.Scope,
+		"jti":   jti,
+		"ver":   user.TokenVersion,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute * time.Duration(intSetting(s.cfg.TokenMinuteLifespan, 15))).Unix(),
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate access token: %v", err)
+	}
+	return token, nil
+}
+
+// idToken signs the OIDC ID token. It has no jti and no id claim, so the
+// gateway and introspection never accept it as an access token.
+func (s *UserService) idToken(user *models.User, clientID string, code *models.AuthorizationCode) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       s.cfg.OIDCIssuer,
+		"sub":       strconv.FormatUint(uint64(user.ID), 10),
+		"aud":       clientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(time.Minute * time.Duration(intSetting(s.cfg.TokenMinuteLifespan, 15))).Unix(),
+		"auth_time": code.AuthTime.Unix(),
+	}
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+	scopes := strings.Fields(code.Scope)
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		claims["name"] = user.Name
+	}
+	if slices.Contains(scopes, ScopeRoles) {
+		claims["roles"] = []string(user.Roles)
+	}
+
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ID token: %v", err)
+	}
+	return token, nil
+}
+
+// UserInfo answers the userinfo endpoint for the holder of an access token
+// from the authorization code grant, with the claims its scope covers.
+func (s *UserService) UserInfo(accessToken string) (*UserInfoResponse, error) {
+	invalid := errors.New("invalid_token")
+
+	parsed, err := utils.ParseToken(accessToken, s.keys)
+	if err != nil || !parsed.Valid {
+		return nil, invalid
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, invalid
+	}
+	audience, err := claims.GetAudience()
+	if err != nil || !slices.Contains(audience, s.userInfoAudience()) {
+		return nil, invalid
+	}
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims["sub"].(string)
+	version, _ := claims["ver"].(float64)
+	clientID, _ := claims["cid"].(string)
+	scope, _ := claims["scope"].(string)
+	userID, err := strconv.ParseUint(sub, 10, 32)
+	if jti == "" || clientID == "" || err != nil || userID == 0 {
+		return nil, invalid
+	}
+
+	revoked, err := s.IsAccessTokenRevoked(jti, uint(userID), int(version), "", clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token: %v", err)
+	}
+	if revoked {
+		return nil, invalid
+	}
+
+	user, err := s.userRepo.GetUserByID(uint(userID))
+	if err != nil {
+		return nil, invalid
+	}
+	info := &UserInfoResponse{Sub: sub}
+	scopes := strings.Fields(scope)
+	if slices.Contains(scopes, ScopeEmail) {
+		info.Email = user.Email
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		info.Name = user.Name
+	}
+	if slices.Contains(scopes, ScopeRoles) {
+		info.Roles = user.Roles
+	}
+	return info, nil
+}
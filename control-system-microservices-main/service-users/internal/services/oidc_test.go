@@ -0,0 +1,373 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	testRedirectURI  = "http://localhost:3000/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-verifier"
+)
+
+func newTestOIDCClient() *models.OAuthClient {
+	return &models.OAuthClient{
+		ID:           3,
+		ClientID:     "cli_8899aabbccddeeff",
+		SecretHash:   utils.HashToken(testClientSecret),
+		Name:         "grafana",
+		RedirectURIs: []string{testRedirectURI},
+	}
+}
+
+func testCodeChallenge() string {
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newTestAuthorizeRequest() AuthorizeRequest {
+	return AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "cli_8899aabbccddeeff",
+		RedirectURI:         testRedirectURI,
+		Scope:               "openid email profile roles",
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       testCodeChallenge(),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func TestUserService_CreateOIDCClient(t *testing.T) {
+	service, _, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	tests := []struct {
+		name        string
+		input       CreateOIDCClientInput
+		wantSecret  bool
+		expectedErr string
+	}{
+		{
+			name:       "конфиденциальный клиент",
+			input:      CreateOIDCClientInput{Name: "grafana", RedirectURIs: []string{testRedirectURI}},
+			wantSecret: true,
+		},
+		{
+			name:  "публичный клиент",
+			input: CreateOIDCClientInput{Name: "spa", RedirectURIs: []string{"https://app.example.com/cb"}, Public: true},
+		},
+		{
+			name:        "redirect uri с фрагментом",
+			input:       CreateOIDCClientInput{Name: "grafana", RedirectURIs: []string{"https://app.example.com/cb#x"}},
+			expectedErr: "invalid redirect uri: https://app.example.com/cb#x",
+		},
+		{
+			name:        "относительный redirect uri",
+			input:       CreateOIDCClientInput{Name: "grafana", RedirectURIs: []string{"/callback"}},
+			expectedErr: "invalid redirect uri: /callback",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expectedErr == "" {
+				mockTokenRepo.EXPECT().CreateOAuthClient(gomock.Any(), gomock.Nil()).DoAndReturn(func(client *models.OAuthClient, _ *models.AuditEntry) error {
+					assert.True(t, client.IsOIDC())
+					assert.Zero(t, client.UserID)
+					assert.Equal(t, tt.input.Public, client.SecretHash == "")
+					return nil
+				})
+			}
+
+			got, err := service.CreateOIDCClient(tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(got.ClientID, "cli_"))
+			assert.Equal(t, tt.wantSecret, got.ClientSecret != "")
+		})
+	}
+}
+
+func TestUserService_ValidateAuthorizeRequest(t *testing.T) {
+	service, _, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	tests := []struct {
+		name        string
+		modify      func(req *AuthorizeRequest)
+		client      *models.OAuthClient
+		expectedErr string
+	}{
+		{
+			name:   "корректный запрос",
+			modify: func(req *AuthorizeRequest) {},
+			client: newTestOIDCClient(),
+		},
+		{
+			name:        "клиент без redirect uri",
+			modify:      func(req *AuthorizeRequest) {},
+			client:      newTestOAuthClient(),
+			expectedErr: AuthorizeUnknownClient,
+		},
+		{
+			name:        "незарегистрированный redirect uri",
+			modify:      func(req *AuthorizeRequest) { req.RedirectURI = "http://evil.example.com/callback" },
+			client:      newTestOIDCClient(),
+			expectedErr: AuthorizeInvalidRedirectURI,
+		},
+		{
+			name:        "implicit flow",
+			modify:      func(req *AuthorizeRequest) { req.ResponseType = "token" },
+			client:      newTestOIDCClient(),
+			expectedErr: "unsupported_response_type",
+		},
+		{
+			name:        "без scope openid",
+			modify:      func(req *AuthorizeRequest) { req.Scope = "email" },
+			client:      newTestOIDCClient(),
+			expectedErr: "invalid_scope",
+		},
+		{
+			name:        "неизвестный scope",
+			modify:      func(req *AuthorizeRequest) { req.Scope = "openid admin" },
+			client:      newTestOIDCClient(),
+			expectedErr: "invalid_scope",
+		},
+		{
+			name:        "без PKCE",
+			modify:      func(req *AuthorizeRequest) { req.CodeChallenge = "" },
+			client:      newTestOIDCClient(),
+			expectedErr: "invalid_request",
+		},
+		{
+			name:        "PKCE plain",
+			modify:      func(req *AuthorizeRequest) { req.CodeChallengeMethod = "plain" },
+			client:      newTestOIDCClient(),
+			expectedErr: "invalid_request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestAuthorizeRequest()
+			tt.modify(&req)
+			mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(tt.client, nil)
+
+			got, err := service.ValidateAuthorizeRequest(req)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "grafana", got.Name)
+		})
+	}
+}
+
+// TestUserService_AuthorizationCodeFlow walks a local test client through the
+// whole flow: sign-in, code exchange with PKCE and the userinfo endpoint.
+func TestUserService_AuthorizationCodeFlow(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	user.Password = hashedPassword(t, "password123")
+	req := newTestAuthorizeRequest()
+
+	t.Run("неверный пароль", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+		mockRepo.EXPECT().IncrementFailedLoginAttempts(user).Return(nil)
+
+		got, err := service.AuthorizeWithPassword(req, "test@example.com", "wrong", "")
+		assert.EqualError(t, err, "invalid email or password")
+		assert.Empty(t, got)
+	})
+
+	var stored *models.AuthorizationCode
+	var code string
+	t.Run("вход и выдача кода", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+		mockTokenRepo.EXPECT().CreateAuthorizationCode(gomock.Any()).DoAndReturn(func(c *models.AuthorizationCode) error {
+			stored = c
+			return nil
+		})
+
+		redirect, err := service.AuthorizeWithPassword(req, "Test@Example.com", "password123", "")
+		assert.NoError(t, err)
+
+		u, err := url.Parse(redirect)
+		assert.NoError(t, err)
+		assert.Equal(t, "localhost:3000", u.Host)
+		assert.Equal(t, "xyz", u.Query().Get("state"))
+		code = u.Query().Get("code")
+		assert.NotEmpty(t, code)
+
+		assert.Equal(t, utils.HashToken(code), stored.CodeHash)
+		assert.Equal(t, uint(1), stored.UserID)
+		assert.Equal(t, req.Nonce, stored.Nonce)
+		assert.WithinDuration(t, time.Now().Add(authorizationCodeLifetime), stored.ExpiresAt, 5*time.Second)
+	})
+
+	exchange := OAuthTokenRequest{
+		GrantType:    "authorization_code",
+		ClientID:     req.ClientID,
+		ClientSecret: testClientSecret,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	}
+
+	t.Run("неверный code_verifier", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockTokenRepo.EXPECT().GetAuthorizationCode(utils.HashToken(code)).Return(stored, nil)
+
+		wrong := exchange
+		wrong.CodeVerifier = strings.Repeat("a", 43)
+		got, err := service.IssueToken(wrong)
+		assert.EqualError(t, err, "invalid_grant")
+		assert.Nil(t, got)
+	})
+
+	t.Run("другой redirect uri", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockTokenRepo.EXPECT().GetAuthorizationCode(utils.HashToken(code)).Return(stored, nil)
+
+		wrong := exchange
+		wrong.RedirectURI = "http://localhost:3000/other"
+		got, err := service.IssueToken(wrong)
+		assert.EqualError(t, err, "invalid_grant")
+		assert.Nil(t, got)
+	})
+
+	t.Run("неверный секрет клиента", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+
+		wrong := exchange
+		wrong.ClientSecret = "wrong"
+		got, err := service.IssueToken(wrong)
+		assert.EqualError(t, err, "invalid_client")
+		assert.Nil(t, got)
+	})
+
+	var accessToken, idToken string
+	t.Run("обмен кода на токены", func(t *testing.T) {
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockTokenRepo.EXPECT().GetAuthorizationCode(utils.HashToken(code)).Return(stored, nil)
+		mockTokenRepo.EXPECT().MarkAuthorizationCodeUsed(stored).Return(true, nil)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+
+		got, err := service.IssueToken(exchange)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", got.TokenType)
+		assert.Equal(t, "openid email profile roles", got.Scope)
+		accessToken, idToken = got.AccessToken, got.IDToken
+
+		parsed, err := utils.ParseToken(got.IDToken, service.keys)
+		assert.NoError(t, err)
+		claims := parsed.Claims.(jwt.MapClaims)
+		assert.Equal(t, service.cfg.OIDCIssuer, claims["iss"])
+		assert.Equal(t, "1", claims["sub"])
+		assert.Equal(t, req.ClientID, claims["aud"])
+		assert.Equal(t, req.Nonce, claims["nonce"])
+		assert.Equal(t, "test@example.com", claims["email"])
+		assert.Equal(t, "Test User", claims["name"])
+		assert.Equal(t, []string{userroles.RoleEngineer}, claimStrings(claims["roles"]))
+		assert.Nil(t, claims["jti"])
+
+		// The access token is bound to userinfo and unusable at the gateway.
+		parsed, err = utils.ParseToken(got.AccessToken, service.keys)
+		assert.NoError(t, err)
+		claims = parsed.Claims.(jwt.MapClaims)
+		assert.Equal(t, service.cfg.OIDCIssuer+"/oauth/userinfo", claims["aud"])
+		assert.Equal(t, req.ClientID, claims["cid"])
+		assert.Nil(t, claims["id"])
+		assert.Nil(t, claims["roles"])
+		assert.Nil(t, claims["permissions"])
+	})
+
+	t.Run("повторное использование кода", func(t *testing.T) {
+		used := *stored
+		usedAt := time.Now()
+		used.UsedAt = &usedAt
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockTokenRepo.EXPECT().GetAuthorizationCode(utils.HashToken(code)).Return(&used, nil)
+
+		got, err := service.IssueToken(exchange)
+		assert.EqualError(t, err, "invalid_grant")
+		assert.Nil(t, got)
+	})
+
+	t.Run("userinfo", func(t *testing.T) {
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(false, nil)
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(2)
+
+		got, err := service.UserInfo(accessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, &UserInfoResponse{
+			Sub:   "1",
+			Email: "test@example.com",
+			Name:  "Test User",
+			Roles: []string{userroles.RoleEngineer},
+		}, got)
+	})
+
+	t.Run("userinfo только по выданным scopes", func(t *testing.T) {
+		narrow := *stored
+		narrow.Scope = "openid email"
+		token, err := service.userInfoToken(user, req.ClientID, &narrow)
+		assert.NoError(t, err)
+		mockTokenRepo.EXPECT().IsAccessTokenRevoked(gomock.Any()).Return(false, nil)
+		mockTokenRepo.EXPECT().GetOAuthClientByClientID(req.ClientID).Return(newTestOIDCClient(), nil)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(2)
+
+		got, err := service.UserInfo(token)
+		assert.NoError(t, err)
+		assert.Equal(t, &UserInfoResponse{Sub: "1", Email: "test@example.com"}, got)
+	})
+
+	t.Run("userinfo с токеном API", func(t *testing.T) {
+		token, err := utils.GenerateToken(*user, nil, "", service.keys, service.cfg)
+		assert.NoError(t, err)
+
+		got, err := service.UserInfo(token)
+		assert.EqualError(t, err, "invalid_token")
+		assert.Nil(t, got)
+	})
+
+	t.Run("userinfo с ID token", func(t *testing.T) {
+		got, err := service.UserInfo(idToken)
+		assert.EqualError(t, err, "invalid_token")
+		assert.Nil(t, got)
+	})
+}
+
+func TestUserService_Discovery(t *testing.T) {
+	service, _, _, finish := setupAuthTest(t)
+	defer finish()
+
+	got := service.Discovery()
+	assert.Equal(t, service.cfg.OIDCIssuer, got.Issuer)
+	assert.Equal(t, service.cfg.OIDCIssuer+"/.well-known/jwks.json", got.JWKSURI)
+	assert.Equal(t, []string{"RS256"}, got.IDTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, got.CodeChallengeMethodsSupported)
+}
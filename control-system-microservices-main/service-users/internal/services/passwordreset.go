@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+)
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// Password reset requests are handled by a fixed number of workers, so that
+// a flood of requests cannot start unbounded work or mail.
+const (
+	passwordResetWorkers   = 2
+	passwordResetQueueSize = 100
+)
+
+// ForgotPassword emails a one-time reset link if the account exists. The
+// lookup, the token write and the mail all happen in the background, so the
+// caller returns before any per-account work and neither the response nor
+// its timing reveals whether an account exists. Requests that find the queue
+// full are dropped.
+func (s *UserService) ForgotPassword(email string) {
+	s.passwordResetsOnce.Do(func() {
+		s.passwordResets = make(chan string, passwordResetQueueSize)
+		for i := 0; i < passwordResetWorkers; i++ {
+			go func() {
+				for email := range s.passwordResets {
+					s.sendPasswordReset(email)
+				}
+			}()
+		}
+	})
+
+	select {
+	case s.passwordResets <- email:
+	default:
+		log.Printf("Password reset queue is full, dropping request")
+	}
+}
+
+// sendPasswordReset issues a reset link unless the account was sent one in
+// the last PASSWORD_RESET_THROTTLE_MINUTES. Issuing a link revokes the
+// account's earlier ones.
+func (s *UserService) sendPasswordReset(email string) {
+	user, err := s.userRepo.GetUserByEmail(strings.ToLower(email))
+	if err != nil || !user.Active || user.Pending || user.ServiceAccount {
+		return
+	}
+
+	token, err := utils.RandomString(32)
+	if err != nil {
+		log.Printf("Failed to generate password reset token: %v", err)
+		return
+	}
+
+	lifespan := intSetting(s.cfg.PasswordResetTokenMinutes, 30)
+	throttle := time.Duration(intSetting(s.cfg.PasswordResetThrottleMinutes, 5)) * time.Minute
+
+	created, err := s.tokenRepo.CreatePasswordResetToken(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(time.Minute * time.Duration(lifespan)),
+	}, time.Now().Add(-throttle))
+	if err != nil {
+		log.Printf("Failed to store password reset token: %v", err)
+		return
+	}
+	if !created {
+		return
+	}
+
+	link := s.cfg.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nUse the link below to set a new password. It expires in %d minutes.\n\n%s\n\n"+
+			"If you did not request a password reset, ignore this email.\n", user.Name, lifespan, link),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+}
+
+// ResetPassword consumes a reset token, stores the new password and revokes
+// every existing session of the user.
+func (s *UserService) ResetPassword(input ResetPasswordInput, requestID string) error {
+	if len(input.NewPassword) < 8 {
+		return ErrPasswordTooShort
+	}
+
+	token, err := s.tokenRepo.GetPasswordResetTokenByHash(utils.HashToken(input.Token))
+	if err != nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	consumed, err := s.tokenRepo.MarkPasswordResetTokenUsed(token)
+	if err != nil {
+		return fmt.Errorf("failed to consume reset token: %v", err)
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.GetUserByID(token.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	user.Password = input.NewPassword
+	if err := user.HashPassword(); err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	entry := auditEntry(Actor{ID: user.ID, RequestID: requestID}, AuditPasswordReset, user.ID, models.AuditChanges{})
+	if err := s.userRepo.UpdateUserAudited(user, map[string]interface{}{"password": user.Password}, entry, false); err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+
+	return s.revokeSessions(user)
+}
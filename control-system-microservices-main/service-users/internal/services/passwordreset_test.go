@@ -0,0 +1,177 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+type chanMailer chan mailer.Message
+
+func (m chanMailer) Send(msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+func TestUserService_ForgotPassword(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	sent := make(chanMailer, 1)
+	service.mailer = sent
+	service.cfg.PasswordResetURL = "http://localhost/reset"
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+
+	var storedHash string
+	mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+	mockTokenRepo.EXPECT().
+		CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(token *models.PasswordResetToken, since time.Time) (bool, error) {
+			assert.Equal(t, uint(1), token.UserID)
+			assert.True(t, token.ExpiresAt.After(time.Now()))
+			assert.WithinDuration(t, time.Now().Add(-5*time.Minute), since, time.Second)
+			storedHash = token.TokenHash
+			return true, nil
+		})
+
+	service.ForgotPassword("Test@Example.com")
+
+	select {
+	case msg := <-sent:
+		assert.Equal(t, "test@example.com", msg.To)
+		i := strings.Index(msg.Body, "http://localhost/reset?token=")
+		assert.GreaterOrEqual(t, i, 0)
+		link, err := url.Parse(strings.Fields(msg.Body[i:])[0])
+		assert.NoError(t, err)
+		token := link.Query().Get("token")
+		assert.NotEmpty(t, token)
+		assert.Equal(t, storedHash, utils.HashToken(token), "only the hash of the emailed token is stored")
+	case <-time.After(time.Second):
+		t.Fatal("reset email was not sent")
+	}
+
+	mockRepo.EXPECT().GetUserByEmail("unknown@example.com").Return((*models.User)(nil), assert.AnError)
+	service.sendPasswordReset("unknown@example.com")
+
+	select {
+	case <-sent:
+		t.Fatal("no email must be sent for unknown accounts")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// An account that was just sent a link gets no other.
+	mockRepo.EXPECT().GetUserByEmail("test@example.com").Return(user, nil)
+	mockTokenRepo.EXPECT().CreatePasswordResetToken(gomock.Any(), gomock.Any()).Return(false, nil)
+	service.sendPasswordReset("test@example.com")
+
+	select {
+	case <-sent:
+		t.Fatal("no email must be sent while the account is throttled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUserService_ForgotPassword_QueueFull(t *testing.T) {
+	service, _, _, finish := setupAuthTest(t)
+	defer finish()
+
+	// No workers read the queue, so it stays full.
+	service.passwordResetsOnce.Do(func() {})
+	service.passwordResets = make(chan string, 1)
+
+	done := make(chan struct{})
+	go func() {
+		service.ForgotPassword("first@example.com")
+		service.ForgotPassword("second@example.com")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ForgotPassword must not wait for a full queue")
+	}
+	assert.Equal(t, "first@example.com", <-service.passwordResets)
+	assert.Empty(t, service.passwordResets)
+}
+
+func TestUserService_ResetPassword(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	usedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		input       ResetPasswordInput
+		setupMock   func(user *models.User)
+		expectedErr string
+	}{
+		{
+			name:  "успешный сброс",
+			input: ResetPasswordInput{Token: "reset-token", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				token := &models.PasswordResetToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}
+				mockTokenRepo.EXPECT().GetPasswordResetTokenByHash(utils.HashToken("reset-token")).Return(token, nil)
+				mockTokenRepo.EXPECT().MarkPasswordResetTokenUsed(token).Return(true, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().
+					UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}, _ *models.AuditEntry, _ bool) error {
+						hash := updates["password"].(string)
+						assert.True(t, passwordMatches(t, hash, "newpassword123"))
+						return nil
+					})
+				mockRepo.EXPECT().IncrementTokenVersion(user).Return(nil)
+				mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+			},
+		},
+		{
+			name:  "истёкший токен",
+			input: ResetPasswordInput{Token: "reset-token", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				token := &models.PasswordResetToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}
+				mockTokenRepo.EXPECT().GetPasswordResetTokenByHash(utils.HashToken("reset-token")).Return(token, nil)
+			},
+			expectedErr: "invalid or expired reset token",
+		},
+		{
+			name:  "повторное использование",
+			input: ResetPasswordInput{Token: "reset-token", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				token := &models.PasswordResetToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Minute), UsedAt: &usedAt}
+				mockTokenRepo.EXPECT().GetPasswordResetTokenByHash(utils.HashToken("reset-token")).Return(token, nil)
+			},
+			expectedErr: "invalid or expired reset token",
+		},
+		{
+			name:        "короткий пароль",
+			input:       ResetPasswordInput{Token: "reset-token", NewPassword: "123"},
+			setupMock:   func(user *models.User) {},
+			expectedErr: "password must be at least 8 characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+			tt.setupMock(user)
+			err := service.ResetPassword(tt.input, "")
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
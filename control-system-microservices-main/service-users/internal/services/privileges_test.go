@@ -0,0 +1,166 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+var (
+	testSuperadmin = Actor{ID: 101, Roles: []string{userroles.RoleSuperadmin}}
+	testEngineer   = Actor{ID: 102, Roles: []string{userroles.RoleEngineer}}
+)
+
+func TestUserService_UpdateUser_Privileges(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	superadminRoles := []string{userroles.RoleSuperadmin}
+	adminRoles := []string{userroles.RoleAdmin}
+	engineerRoles := []string{userroles.RoleEngineer}
+
+	tests := []struct {
+		name        string
+		actor       Actor
+		target      *models.User
+		roles       []string
+		setupMock   func(target *models.User)
+		expectedErr string
+	}{
+		{
+			name:        "админ не может выдать superadmin",
+			actor:       testAdmin,
+			target:      newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer),
+			roles:       superadminRoles,
+			setupMock:   func(*models.User) {},
+			expectedErr: "insufficient privileges to grant role: superadmin",
+		},
+		{
+			name:        "админ не может изменить superadmin",
+			actor:       testAdmin,
+			target:      newTestUser(1, "root@example.com", "Root", userroles.RoleSuperadmin),
+			roles:       engineerRoles,
+			setupMock:   func(*models.User) {},
+			expectedErr: "insufficient privileges to manage this user",
+		},
+		{
+			name:        "инженер не может выдать admin",
+			actor:       testEngineer,
+			target:      newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer),
+			roles:       adminRoles,
+			setupMock:   func(*models.User) {},
+			expectedErr: "insufficient privileges to grant role: admin",
+		},
+		{
+			name:   "админ может выдать admin",
+			actor:  testAdmin,
+			target: newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer),
+			roles:  adminRoles,
+			setupMock: func(target *models.User) {
+				mockRepo.EXPECT().UpdateUserAudited(target, gomock.Any(), gomock.Any(), false).Return(nil)
+			},
+		},
+		{
+			name:   "нельзя разжаловать последнего superadmin",
+			actor:  testSuperadmin,
+			target: newTestUser(1, "root@example.com", "Root", userroles.RoleSuperadmin),
+			roles:  adminRoles,
+			setupMock: func(target *models.User) {
+				mockRepo.EXPECT().UpdateUserAudited(target, gomock.Any(), gomock.Any(), true).Return(repositories.ErrLastSuperadmin)
+			},
+			expectedErr: "cannot remove the last active superadmin",
+		},
+		{
+			name:   "superadmin разжалует другого superadmin",
+			actor:  testSuperadmin,
+			target: newTestUser(1, "root@example.com", "Root", userroles.RoleSuperadmin),
+			roles:  adminRoles,
+			setupMock: func(target *models.User) {
+				mockRepo.EXPECT().UpdateUserAudited(target, gomock.Any(), gomock.Any(), true).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetUserByID(uint(1)).Return(tt.target, nil)
+			tt.setupMock(tt.target)
+
+			_, err := service.UpdateUser(tt.actor, 1, EditUserInput{Roles: &tt.roles})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_RegisterUser_Privileges(t *testing.T) {
+	service, _, finish := setupTest(t)
+	defer finish()
+
+	_, err := service.RegisterUser(testAdmin, RegisterUserInput{
+		Email:    "root@example.com",
+		Password: "password123",
+		Name:     "Root",
+		Roles:    []string{userroles.RoleEngineer, userroles.RoleSuperadmin},
+	})
+	assert.EqualError(t, err, "insufficient privileges to grant role: superadmin")
+}
+
+func TestUserService_DeactivateUser_LastSuperadmin(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	root := newTestUser(1, "root@example.com", "Root", userroles.RoleSuperadmin)
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(root, nil)
+	_, err := service.DeactivateUser(testAdmin, 1)
+	assert.EqualError(t, err, "insufficient privileges to manage this user")
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(root, nil)
+	mockRepo.EXPECT().UpdateUserAudited(root, gomock.Any(), gomock.Any(), true).Return(repositories.ErrLastSuperadmin)
+	_, err = service.DeactivateUser(testSuperadmin, 1)
+	assert.EqualError(t, err, "cannot remove the last active superadmin")
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(root, nil)
+	mockRepo.EXPECT().DeleteUser(root, gomock.Any(), true).Return(repositories.ErrLastSuperadmin)
+	assert.EqualError(t, service.DeleteUser(testSuperadmin, 1), "cannot remove the last active superadmin")
+}
+
+func TestUserService_ManageSuperadmin(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	root := newTestUser(1, "root@example.com", "Root", userroles.RoleSuperadmin)
+	root.Active = false
+	root.Pending = true
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "активация", call: func() error { _, err := service.ActivateUser(testAdmin, 1); return err }},
+		{name: "разблокировка", call: func() error { _, err := service.UnlockUser(testAdmin, 1); return err }},
+		{name: "отзыв всех сессий", call: func() error { return service.RevokeAllSessions(testAdmin, 1) }},
+		{name: "отзыв сессии", call: func() error { return service.RevokeUserSession(testAdmin, 1, 7) }},
+		{name: "повторное приглашение", call: func() error { return service.ResendInvite(testAdmin, 1) }},
+		{name: "отзыв приглашения", call: func() error { return service.RevokeInvite(testAdmin, 1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetUserByID(uint(1)).Return(root, nil)
+			assert.EqualError(t, tt.call(), "insufficient privileges to manage this user")
+		})
+	}
+
+	mockRepo.EXPECT().GetDeletedUserByID(uint(1)).Return(root, nil)
+	_, err := service.RestoreUser(testAdmin, 1)
+	assert.EqualError(t, err, "insufficient privileges to manage this user")
+}
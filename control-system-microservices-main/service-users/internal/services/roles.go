@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+)
+
+type RoleResponse struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	System      bool      `json:"system"`
+	Permissions []string  `json:"permissions"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type PermissionResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CreateRoleInput struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleInput struct {
+	Description *string `json:"description"`
+}
+
+type RolePermissionsInput struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+func toRoleResponse(role *models.Role) *RoleResponse {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	sort.Strings(permissions)
+	return &RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		System:      role.System,
+		Permissions: permissions,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+func (s *UserService) ListRoles() ([]RoleResponse, error) {
+	roles, err := s.roleRepo.GetRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %v", err)
+	}
+	response := make([]RoleResponse, 0, len(roles))
+	for i := range roles {
+		response = append(response, *toRoleResponse(&roles[i]))
+	}
+	return response, nil
+}
+
+func (s *UserService) GetRole(name string) (*RoleResponse, error) {
+	role, err := s.roleRepo.GetRoleByName(name)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return toRoleResponse(role), nil
+}
+
+func (s *UserService) CreateRole(input CreateRoleInput) (*RoleResponse, error) {
+	if !slugPattern.MatchString(input.Name) {
+		return nil, ErrInvalidRoleName
+	}
+	if _, err := s.roleRepo.GetRoleByName(input.Name); err == nil {
+		return nil, ErrRoleExists
+	}
+
+	permissions, err := s.resolvePermissions(input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: permissions,
+	}
+	if err := s.roleRepo.CreateRole(&role); err != nil {
+		return nil, fmt.Errorf("failed to create role: %v", err)
+	}
+	return toRoleResponse(&role), nil
+}
+
+// UpdateRole only edits the description; the name is what users reference.
+func (s *UserService) UpdateRole(name string, input UpdateRoleInput) (*RoleResponse, error) {
+	role, err := s.roleRepo.GetRoleByName(name)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	if input.Description == nil {
+		return toRoleResponse(role), nil
+	}
+	if err := s.roleRepo.UpdateRole(role, map[string]interface{}{"description": *input.Description}); err != nil {
+		return nil, fmt.Errorf("failed to update role: %v", err)
+	}
+	role.Description = *input.Description
+	return toRoleResponse(role), nil
+}
+
+// SetRolePermissions replaces the role's permissions. Tokens that are already
+// issued keep the old set until they are refreshed.
+func (s *UserService) SetRolePermissions(name string, input RolePermissionsInput) (*RoleResponse, error) {
+	role, err := s.roleRepo.GetRoleByName(name)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	permissions, err := s.resolvePermissions(input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.SetRolePermissions(role, permissions); err != nil {
+		return nil, fmt.Errorf("failed to update role permissions: %v", err)
+	}
+	role.Permissions = permissions
+	return toRoleResponse(role), nil
+}
+
+func (s *UserService) DeleteRole(name string) error {
+	role, err := s.roleRepo.GetRoleByName(name)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+	if role.System {
+		return ErrSystemRole
+	}
+	count, err := s.roleRepo.CountUsersWithRole(role.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check role usage: %v", err)
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+	if err := s.roleRepo.DeleteRole(role); err != nil {
+		return fmt.Errorf("failed to delete role: %v", err)
+	}
+	return nil
+}
+
+func (s *UserService) ListPermissions() ([]PermissionResponse, error) {
+	permissions, err := s.roleRepo.GetPermissions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %v", err)
+	}
+	response := make([]PermissionResponse, 0, len(permissions))
+	for _, permission := range permissions {
+		response = append(response, PermissionResponse{Name: permission.Name, Description: permission.Description})
+	}
+	return response, nil
+}
+
+// resolvePermissions loads the named permissions and fails on the first name
+// that is not in the catalog.
+func (s *UserService) resolvePermissions(names []string) ([]models.Permission, error) {
+	permissions, err := s.roleRepo.GetPermissionsByNames(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %v", err)
+	}
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, withValue(ErrUnknownPermission, name, "permissions")
+		}
+	}
+	return permissions, nil
+}
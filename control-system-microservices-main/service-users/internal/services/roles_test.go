@@ -0,0 +1,238 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUserService_CreateRole(t *testing.T) {
+	service, _, _, mockRoleRepo, finish := setupRoleTest(t)
+	defer finish()
+
+	read := models.Permission{ID: 4, Name: models.PermissionOrdersRead}
+	update := models.Permission{ID: 6, Name: models.PermissionOrdersUpdateStatus}
+
+	tests := []struct {
+		name        string
+		input       CreateRoleInput
+		setupMock   func()
+		expected    *RoleResponse
+		expectedErr string
+	}{
+		{
+			name: "успешное создание",
+			input: CreateRoleInput{
+				Name:        "dispatcher",
+				Description: "Dispatches orders",
+				Permissions: []string{models.PermissionOrdersUpdateStatus, models.PermissionOrdersRead},
+			},
+			setupMock: func() {
+				mockRoleRepo.EXPECT().GetRoleByName("dispatcher").Return((*models.Role)(nil), assert.AnError)
+				mockRoleRepo.EXPECT().
+					GetPermissionsByNames([]string{models.PermissionOrdersUpdateStatus, models.PermissionOrdersRead}).
+					Return([]models.Permission{read, update}, nil)
+				mockRoleRepo.EXPECT().
+					CreateRole(gomock.Any()).
+					DoAndReturn(func(role *models.Role) error {
+						assert.False(t, role.System)
+						assert.Len(t, role.Permissions, 2)
+						return nil
+					})
+			},
+			expected: &RoleResponse{
+				Name:        "dispatcher",
+				Description: "Dispatches orders",
+				Permissions: []string{models.PermissionOrdersRead, models.PermissionOrdersUpdateStatus},
+			},
+		},
+		{
+			name:        "невалидное имя",
+			input:       CreateRoleInput{Name: "Dispatcher!"},
+			setupMock:   func() {},
+			expectedErr: "invalid role name",
+		},
+		{
+			name:  "роль уже существует",
+			input: CreateRoleInput{Name: userroles.RoleManager},
+			setupMock: func() {
+				mockRoleRepo.EXPECT().GetRoleByName(userroles.RoleManager).Return(&models.Role{Name: userroles.RoleManager}, nil)
+			},
+			expectedErr: "role already exists",
+		},
+		{
+			name:  "неизвестное разрешение",
+			input: CreateRoleInput{Name: "dispatcher", Permissions: []string{models.PermissionOrdersRead, "orders:fly"}},
+			setupMock: func() {
+				mockRoleRepo.EXPECT().GetRoleByName("dispatcher").Return((*models.Role)(nil), assert.AnError)
+				mockRoleRepo.EXPECT().
+					GetPermissionsByNames([]string{models.PermissionOrdersRead, "orders:fly"}).
+					Return([]models.Permission{read}, nil)
+			},
+			expectedErr: "unknown permission: orders:fly",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			got, err := service.CreateRole(tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUserService_SetRolePermissions(t *testing.T) {
+	service, _, _, mockRoleRepo, finish := setupRoleTest(t)
+	defer finish()
+
+	role := &models.Role{ID: 1, Name: userroles.RoleObserver, System: true}
+	read := models.Permission{ID: 4, Name: models.PermissionOrdersRead}
+
+	mockRoleRepo.EXPECT().GetRoleByName(userroles.RoleObserver).Return(role, nil)
+	mockRoleRepo.EXPECT().GetPermissionsByNames([]string{models.PermissionOrdersRead}).Return([]models.Permission{read}, nil)
+	mockRoleRepo.EXPECT().SetRolePermissions(role, []models.Permission{read}).Return(nil)
+
+	got, err := service.SetRolePermissions(userroles.RoleObserver, RolePermissionsInput{Permissions: []string{models.PermissionOrdersRead}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.PermissionOrdersRead}, got.Permissions)
+
+	mockRoleRepo.EXPECT().GetRoleByName("pilot").Return((*models.Role)(nil), assert.AnError)
+	_, err = service.SetRolePermissions("pilot", RolePermissionsInput{})
+	assert.EqualError(t, err, "role not found")
+}
+
+func TestUserService_DeleteRole(t *testing.T) {
+	service, _, _, mockRoleRepo, finish := setupRoleTest(t)
+	defer finish()
+
+	custom := &models.Role{ID: 6, Name: "dispatcher"}
+
+	tests := []struct {
+		name        string
+		role        string
+		setupMock   func()
+		expectedErr string
+	}{
+		{
+			name: "успешное удаление",
+			role: "dispatcher",
+			setupMock: func() {
+				mockRoleRepo.EXPECT().GetRoleByName("dispatcher").Return(custom, nil)
+				mockRoleRepo.EXPECT().CountUsersWithRole("dispatcher").Return(int64(0), nil)
+				mockRoleRepo.EXPECT().DeleteRole(custom).Return(nil)
+			},
+		},
+		{
+			name: "системная роль",
+			role: userroles.RoleEngineer,
+			setupMock: func() {
+				mockRoleRepo.EXPECT().
+					GetRoleByName(userroles.RoleEngineer).
+					Return(&models.Role{ID: 1, Name: userroles.RoleEngineer, System: true}, nil)
+			},
+			expectedErr: "system roles cannot be deleted",
+		},
+		{
+			name: "роль назначена пользователям",
+			role: "dispatcher",
+			setupMock: func() {
+				mockRoleRepo.EXPECT().GetRoleByName("dispatcher").Return(custom, nil)
+				mockRoleRepo.EXPECT().CountUsersWithRole("dispatcher").Return(int64(3), nil)
+			},
+			expectedErr: "role is assigned to users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			err := service.DeleteRole(tt.role)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_UpdateUser_CustomRole(t *testing.T) {
+	service, mockRepo, _, mockRoleRepo, finish := setupRoleTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	roles := []string{"dispatcher", "pilot"}
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRoleRepo.EXPECT().GetPermissionsForRoles(testAdmin.Roles).Return(models.DefaultRolePermissions[userroles.RoleAdmin], nil)
+	mockRoleRepo.EXPECT().GetPermissionsForRoles(user.Roles).Return(models.DefaultRolePermissions[userroles.RoleEngineer], nil)
+	mockRoleRepo.EXPECT().FindExistingRoles(roles).Return([]string{"dispatcher"}, nil)
+
+	_, err := service.UpdateUser(testAdmin, 1, EditUserInput{Roles: &roles})
+	assert.EqualError(t, err, "invalid role: pilot")
+}
+
+func TestUserService_UpdateUser_CustomRolePermissions(t *testing.T) {
+	adminPermissions := models.DefaultRolePermissions[userroles.RoleAdmin]
+
+	tests := []struct {
+		name        string
+		target      *models.User
+		permissions []string
+		expectedErr string
+	}{
+		{
+			name:        "роль с правами внутри прав админа",
+			target:      newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer),
+			permissions: []string{models.PermissionOrdersRead, models.PermissionOrdersReadAll},
+		},
+		{
+			name:        "роль с roles:manage выдать нельзя",
+			target:      newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer),
+			permissions: []string{models.PermissionOrdersRead, models.PermissionRolesManage},
+			expectedErr: "insufficient privileges to grant role: dispatcher",
+		},
+		{
+			name:        "владелец более сильной роли недоступен для управления",
+			target:      newTestUser(1, "test@example.com", "Test User", "dispatcher"),
+			permissions: []string{models.PermissionRolesManage},
+			expectedErr: "insufficient privileges to manage this user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, mockRepo, _, mockRoleRepo, finish := setupRoleTest(t)
+			defer finish()
+
+			roles := []string{"dispatcher"}
+			mockRepo.EXPECT().GetUserByID(uint(1)).Return(tt.target, nil)
+			mockRoleRepo.EXPECT().GetPermissionsForRoles(testAdmin.Roles).Return(adminPermissions, nil).AnyTimes()
+			mockRoleRepo.EXPECT().GetPermissionsForRoles([]string{userroles.RoleEngineer}).Return(models.DefaultRolePermissions[userroles.RoleEngineer], nil).AnyTimes()
+			mockRoleRepo.EXPECT().GetPermissionsForRoles(roles).Return(tt.permissions, nil).AnyTimes()
+			mockRoleRepo.EXPECT().FindExistingRoles(roles).Return(roles, nil).AnyTimes()
+			if tt.expectedErr == "" {
+				mockRepo.EXPECT().UpdateUserAudited(tt.target, gomock.Any(), gomock.Any(), false).Return(nil)
+			}
+
+			_, err := service.UpdateUser(testAdmin, 1, EditUserInput{Roles: &roles})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+)
+
+const (
+	SCIMUserSchema     = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMListSchema     = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchOpSchema  = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema    = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimDefaultCount   = 100
+	scimMaxCount       = 200
+	scimUserNameFilter = "username"
+	scimActiveFilter   = "active"
+)
+
+// SCIMUser is the SCIM 2.0 core User resource (RFC 7643 section 4.1) mapped
+// onto models.User. userName is the account's email address.
+type SCIMUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	UserName    string      `json:"userName"`
+	Name        *SCIMName   `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []SCIMValue `json:"emails,omitempty"`
+	Active      bool        `json:"active"`
+	Roles       []SCIMValue `json:"roles,omitempty"`
+	Meta        SCIMMeta    `json:"meta"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMValue is an entry of a multi-valued attribute such as emails or roles.
+type SCIMValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// SCIMUserInput is the body of a create or replace request. Users created
+// without a password are invited and set one themselves.
+type SCIMUserInput struct {
+	UserName    string      `json:"userName"`
+	Name        *SCIMName   `json:"name"`
+	DisplayName string      `json:"displayName"`
+	Emails      []SCIMValue `json:"emails"`
+	Active      *bool       `json:"active"`
+	Roles       []SCIMValue `json:"roles"`
+	Password    string      `json:"password"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string   `json:"schemas"`
+	TotalResults int64      `json:"totalResults"`
+	StartIndex   int        `json:"startIndex"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	Resources    []SCIMUser `json:"Resources"`
+}
+
+// SCIMListInput holds the query of a list request. StartIndex is 1-based.
+type SCIMListInput struct {
+	Filter     string
+	StartIndex int
+	Count      *int
+}
+
+type SCIMPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+func toSCIMUser(user *models.User) SCIMUser {
+	roles := make([]SCIMValue, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, SCIMValue{Value: role})
+	}
+	return SCIMUser{
+		Schemas:     []string{SCIMUserSchema},
+		ID:          strconv.FormatUint(uint64(user.ID), 10),
+		UserName:    user.Email,
+		Name:        &SCIMName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []SCIMValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      user.Active,
+		Roles:       roles,
+		Meta: SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+		},
+	}
+}
+
+// displayName picks the name to store from whatever the client sent.
+func (input SCIMUserInput) displayName() string {
+	if input.Name != nil {
+		if name := strings.TrimSpace(input.Name.Formatted); name != "" {
+			return name
+		}
+		if name := strings.TrimSpace(input.Name.GivenName + " " + input.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return strings.TrimSpace(input.DisplayName)
+}
+
+func scimRoles(values []SCIMValue) []string {
+	roles := make([]string, 0, len(values))
+	for _, value := range values {
+		if role := strings.TrimSpace(value.Value); role != "" && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+var (
+	scimFilterPattern = regexp.MustCompile(`^(?i)(\w+)\s+eq\s+(.+)$`)
+	scimAndPattern    = regexp.MustCompile(`(?i)\s+and\s+`)
+)
+
+// parseSCIMFilter supports the equality filters provisioning clients use to
+// look an account up: userName eq "x" and active eq true|false, optionally
+// joined with "and".
+func parseSCIMFilter(filter string) (repositories.UserFilter, error) {
+	var result repositories.UserFilter
+	if strings.TrimSpace(filter) == "" {
+		return result, nil
+	}
+
+	for _, clause := range scimAndPattern.Split(strings.TrimSpace(filter), -1) {
+		match := scimFilterPattern.FindStringSubmatch(strings.TrimSpace(clause))
+		if match == nil {
+			return result, withValue(ErrUnsupportedFilter, clause, "filter")
+		}
+		attribute, value := strings.ToLower(match[1]), strings.TrimSpace(match[2])
+
+		switch attribute {
+		case scimUserNameFilter:
+			unquoted, err := strconv.Unquote(value)
+			if err != nil || unquoted == "" {
+				return result, withValue(ErrInvalidFilterValue, value, "filter")
+			}
+			result.ExactEmail = unquoted
+		case scimActiveFilter:
+			active, err := strconv.ParseBool(value)
+			if err != nil {
+				return result, withValue(ErrInvalidFilterValue, value, "filter")
+			}
+			result.Active = &active
+		default:
+			return result, withValue(ErrUnsupportedFilter, clause, "filter")
+		}
+	}
+	return result, nil
+}
+
+// SCIMListUsers lists count users from the 1-based start index (RFC 7644
+// section 3.4.2.4).
+func (s *UserService) SCIMListUsers(input SCIMListInput) (*SCIMListResponse, error) {
+	filter, err := parseSCIMFilter(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count := scimDefaultCount
+	if input.Count != nil {
+		count = max(*input.Count, 0)
+	}
+	count = min(count, scimMaxCount)
+	startIndex := max(input.StartIndex, 1)
+
+	if count == 0 {
+		total, err := s.userRepo.CountUsers(filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count users: %v", err)
+		}
+		return &SCIMListResponse{
+			Schemas:      []string{SCIMListSchema},
+			TotalResults: total,
+			StartIndex:   startIndex,
+			Resources:    []SCIMUser{},
+		}, nil
+	}
+
+	users, total, err := s.userRepo.GetUsers(startIndex-1, count, filter, repositories.UserSort{})
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]SCIMUser, 0, len(users))
+	for i := range users {
+		resources = append(resources, toSCIMUser(&users[i]))
+	}
+	return &SCIMListResponse{
+		Schemas:      []string{SCIMListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *UserService) SCIMGetUser(id uint) (*SCIMUser, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	resource := toSCIMUser(user)
+	return &resource, nil
+}
+
+// SCIMCreateUser provisions an account. With a password the account can log
+// in right away; without one the user is invited by email. An account sent
+// with active false is created deactivated.
+func (s *UserService) SCIMCreateUser(actor Actor, input SCIMUserInput) (*SCIMUser, error) {
+	if input.UserName == "" {
+		return nil, ErrSCIMUserNameRequired
+	}
+	name := input.displayName()
+	if name == "" {
+		return nil, ErrSCIMNameRequired
+	}
+
+	active := input.Active == nil || *input.Active
+	var created *UserResponse
+	var err error
+	if input.Password != "" {
+		created, err = s.registerUser(actor, RegisterUserInput{
+			Email:    input.UserName,
+			Password: input.Password,
+			Name:     name,
+			Roles:    scimRoles(input.Roles),
+		}, active)
+	} else {
+		created, err = s.inviteUser(actor, InviteUserInput{
+			Email: input.UserName,
+			Name:  name,
+			Roles: scimRoles(input.Roles),
+		}, active)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.SCIMGetUser(created.ID)
+}
+
+// scimChanges is a SCIM modification reduced to what models.User can hold.
+type scimChanges struct {
+	name   *string
+	roles  *[]string
+	active *bool
+}
+
+func (s *UserService) applySCIMChanges(actor Actor, user *models.User, changes scimChanges) (*SCIMUser, error) {
+	if changes.name != nil || changes.roles != nil {
+		if _, err := s.UpdateUser(actor, user.ID, EditUserInput{Name: changes.name, Roles: changes.roles}); err != nil {
+			return nil, err
+		}
+	}
+
+	if changes.active != nil && *changes.active != user.Active {
+		if *changes.active {
+			if _, err := s.ActivateUser(actor, user.ID); err != nil {
+				return nil, err
+			}
+		} else if _, err := s.DeactivateUser(actor, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return s.SCIMGetUser(user.ID)
+}
+
+// SCIMReplaceUser implements PUT. The userName cannot change, since it is
+// the email address other records refer to.
+func (s *UserService) SCIMReplaceUser(actor Actor, id uint, input SCIMUserInput) (*SCIMUser, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if input.UserName != "" && !strings.EqualFold(input.UserName, user.Email) {
+		return nil, ErrUserNameImmutable
+	}
+
+	changes := scimChanges{active: input.Active}
+	if name := input.displayName(); name != "" {
+		changes.name = &name
+	}
+	if input.Roles != nil {
+		roles := scimRoles(input.Roles)
+		changes.roles = &roles
+	}
+	return s.applySCIMChanges(actor, user, changes)
+}
+
+// SCIMPatchUser implements PATCH for the attributes that map onto
+// models.User: active, the name and roles.
+func (s *UserService) SCIMPatchUser(actor Actor, id uint, input SCIMPatchRequest) (*SCIMUser, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if len(input.Operations) == 0 {
+		return nil, ErrNoPatchOperations
+	}
+
+	roles := slices.Clone([]string(user.Roles))
+	changes := scimChanges{}
+	for _, operation := range input.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return nil, withValue(ErrInvalidPatchOperation, operation.Op, "op")
+		}
+
+		// A replace without a path carries the attributes in its value.
+		if operation.Path == "" {
+			attributes, ok := operation.Value.(map[string]interface{})
+			if !ok || op == "remove" {
+				return nil, ErrInvalidPatchValue
+			}
+			for path, value := range attributes {
+				if err := patchAttribute(&changes, &roles, op, path, value); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := patchAttribute(&changes, &roles, op, operation.Path, operation.Value); err != nil {
+			return nil, err
+		}
+	}
+	if changes.roles != nil {
+		changes.roles = &roles
+	}
+	return s.applySCIMChanges(actor, user, changes)
+}
+
+var scimRoleValuePath = regexp.MustCompile(`^(?i)roles\[value eq "([^"]+)"\]$`)
+
+// patchAttribute applies one operation to the pending changes. Roles are
+// edited in place so that several operations on them add up.
+func patchAttribute(changes *scimChanges, roles *[]string, op, path string, value interface{}) error {
+	if match := scimRoleValuePath.FindStringSubmatch(path); match != nil {
+		if op != "remove" {
+			return withValue(ErrInvalidPatchPath, path, "path")
+		}
+		*roles = slices.DeleteFunc(*roles, func(role string) bool { return role == match[1] })
+		changes.roles = roles
+		return nil
+	}
+
+	switch strings.ToLower(path) {
+	case "active":
+		active, ok := value.(bool)
+		if !ok {
+			// Some clients send booleans as strings.
+			parsed, err := strconv.ParseBool(fmt.Sprint(value))
+			if err != nil || op == "remove" {
+				return ErrInvalidPatchValue
+			}
+			active = parsed
+		}
+		changes.active = &active
+	case "displayname", "name.formatted":
+		name, ok := value.(string)
+		if !ok || op == "remove" || strings.TrimSpace(name) == "" {
+			return ErrInvalidPatchValue
+		}
+		name = strings.TrimSpace(name)
+		changes.name = &name
+	case "roles":
+		values, err := patchRoleValues(value, op)
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "replace":
+			*roles = values
+		case "add":
+			for _, role := range values {
+				if !slices.Contains(*roles, role) {
+					*roles = append(*roles, role)
+				}
+			}
+		case "remove":
+			if value == nil {
+				*roles = []string{}
+			}
+			*roles = slices.DeleteFunc(*roles, func(role string) bool { return slices.Contains(values, role) })
+		}
+		changes.roles = roles
+	default:
+		return withValue(ErrInvalidPatchPath, path, "path")
+	}
+	return nil
+}
+
+// patchRoleValues reads the roles of an operation, given as a list of
+// {"value": "..."} objects.
+func patchRoleValues(value interface{}, op string) ([]string, error) {
+	if value == nil && op == "remove" {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, ErrInvalidPatchValue
+	}
+	roles := make([]string, 0, len(items))
+	for _, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, ErrInvalidPatchValue
+		}
+		role, ok := object["value"].(string)
+		if !ok || strings.TrimSpace(role) == "" {
+			return nil, ErrInvalidPatchValue
+		}
+		roles = append(roles, strings.TrimSpace(role))
+	}
+	return roles, nil
+}
+
+// SCIMDeleteUser deprovisions an account by deactivating it. The account and
+// its history stay in place, and deprovisioning twice is not an error.
+func (s *UserService) SCIMDeleteUser(actor Actor, id uint) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !user.Active {
+		return nil
+	}
+	_, err = s.DeactivateUser(actor, id)
+	return err
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	active, inactive := true, false
+
+	tests := []struct {
+		name        string
+		filter      string
+		expected    repositories.UserFilter
+		expectedErr string
+	}{
+		{
+			name:     "пустой фильтр",
+			filter:   "",
+			expected: repositories.UserFilter{},
+		},
+		{
+			name:     "userName eq",
+			filter:   `userName eq "Jane@Example.com"`,
+			expected: repositories.UserFilter{ExactEmail: "Jane@Example.com"},
+		},
+		{
+			name:     "active eq",
+			filter:   "active eq false",
+			expected: repositories.UserFilter{Active: &inactive},
+		},
+		{
+			name:     "оба условия через and",
+			filter:   `username EQ "jane@example.com" and active eq true`,
+			expected: repositories.UserFilter{ExactEmail: "jane@example.com", Active: &active},
+		},
+		{
+			name:        "неподдерживаемый оператор",
+			filter:      `userName co "jane"`,
+			expectedErr: `unsupported filter: userName co "jane"`,
+		},
+		{
+			name:        "неподдерживаемый атрибут",
+			filter:      `title eq "engineer"`,
+			expectedErr: `unsupported filter: title eq "engineer"`,
+		},
+		{
+			name:        "значение без кавычек",
+			filter:      "userName eq jane",
+			expectedErr: "invalid filter value: jane",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSCIMFilter(tt.filter)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestUserService_SCIMListUsers(t *testing.T) {
+	service, mockRepo, _, finish := setupAuthTest(t)
+	defer finish()
+
+	users := []models.User{
+		*newTestUser(11, "a@example.com", "A", userroles.RoleEngineer),
+		*newTestUser(12, "b@example.com", "B", userroles.RoleManager),
+	}
+	ten, zero := 10, 0
+
+	tests := []struct {
+		name               string
+		input              SCIMListInput
+		setupMock          func()
+		expectedStartIndex int
+		expectedItems      int
+		expectedErr        string
+	}{
+		{
+			name:  "вторая страница",
+			input: SCIMListInput{StartIndex: 11, Count: &ten},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUsers(10, 10, repositories.UserFilter{}, repositories.UserSort{}).Return(users, int64(12), nil)
+			},
+			expectedStartIndex: 11,
+			expectedItems:      2,
+		},
+		{
+			name:  "startIndex не на границе страницы",
+			input: SCIMListInput{StartIndex: 12, Count: &ten},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUsers(11, 10, repositories.UserFilter{}, repositories.UserSort{}).Return(users[1:], int64(12), nil)
+			},
+			expectedStartIndex: 12,
+			expectedItems:      1,
+		},
+		{
+			name:  "поиск по userName",
+			input: SCIMListInput{Filter: `userName eq "a@example.com"`, StartIndex: 1},
+			setupMock: func() {
+				mockRepo.EXPECT().
+					GetUsers(0, scimDefaultCount, repositories.UserFilter{ExactEmail: "a@example.com"}, repositories.UserSort{}).
+					Return(users[:1], int64(1), nil)
+			},
+			expectedStartIndex: 1,
+			expectedItems:      1,
+		},
+		{
+			name:  "count=0 возвращает только количество",
+			input: SCIMListInput{StartIndex: 1, Count: &zero},
+			setupMock: func() {
+				mockRepo.EXPECT().CountUsers(repositories.UserFilter{}).Return(int64(12), nil)
+			},
+			expectedStartIndex: 1,
+		},
+		{
+			name:        "неверный фильтр",
+			input:       SCIMListInput{Filter: "name sw \"A\"", StartIndex: 1},
+			setupMock:   func() {},
+			expectedErr: `unsupported filter: name sw "A"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			got, err := service.SCIMListUsers(tt.input)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{SCIMListSchema}, got.Schemas)
+			assert.Equal(t, tt.expectedStartIndex, got.StartIndex)
+			assert.Len(t, got.Resources, tt.expectedItems)
+			assert.Equal(t, tt.expectedItems, got.ItemsPerPage)
+		})
+	}
+}
+
+func TestUserService_SCIMCreateUser(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	sent := make(chanMailer, 1)
+	service.mailer = sent
+	service.cfg.InviteURL = "http://localhost/invite"
+
+	t.Run("без пароля пользователь приглашается", func(t *testing.T) {
+		var created *models.User
+		mockRepo.EXPECT().GetUserByEmail("jane@example.com").Return((*models.User)(nil), assert.AnError)
+		mockRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(user *models.User, _ *models.AuditEntry) error {
+			user.ID = 20
+			created = user
+			return nil
+		})
+		mockTokenRepo.EXPECT().CreateInvitation(gomock.Any()).Return(nil)
+		mockRepo.EXPECT().GetUserByID(uint(20)).DoAndReturn(func(uint) (*models.User, error) { return created, nil })
+
+		got, err := service.SCIMCreateUser(testAdmin, SCIMUserInput{
+			UserName: "jane@example.com",
+			Name:     &SCIMName{GivenName: "Jane", FamilyName: "Doe"},
+			Roles:    []SCIMValue{{Value: userroles.RoleManager}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "20", got.ID)
+		assert.Equal(t, "jane@example.com", got.UserName)
+		assert.Equal(t, "Jane Doe", got.DisplayName)
+		assert.Equal(t, []SCIMValue{{Value: userroles.RoleManager}}, got.Roles)
+		assert.True(t, created.Pending)
+		<-sent
+	})
+
+	t.Run("неактивный пользователь создаётся одной записью", func(t *testing.T) {
+		var created *models.User
+		mockRepo.EXPECT().GetUserByEmail("john@example.com").Return((*models.User)(nil), assert.AnError)
+		mockRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(user *models.User, entry *models.AuditEntry) error {
+			assert.False(t, user.Active)
+			assert.Equal(t, models.FieldChange{Before: nil, After: false}, entry.Changes["active"])
+			user.ID = 21
+			created = user
+			return nil
+		})
+		mockRepo.EXPECT().GetUserByID(uint(21)).DoAndReturn(func(uint) (*models.User, error) { return created, nil })
+
+		inactive := false
+		got, err := service.SCIMCreateUser(testAdmin, SCIMUserInput{
+			UserName: "john@example.com",
+			Password: "password123",
+			Name:     &SCIMName{GivenName: "John", FamilyName: "Doe"},
+			Active:   &inactive,
+		})
+		assert.NoError(t, err)
+		assert.False(t, got.Active)
+		assert.False(t, created.Pending)
+	})
+
+	t.Run("неактивный приглашённый пользователь", func(t *testing.T) {
+		var created *models.User
+		mockRepo.EXPECT().GetUserByEmail("jim@example.com").Return((*models.User)(nil), assert.AnError)
+		mockRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(user *models.User, entry *models.AuditEntry) error {
+			assert.False(t, user.Active)
+			assert.Equal(t, AuditUserInvited, entry.Action)
+			user.ID = 22
+			created = user
+			return nil
+		})
+		mockTokenRepo.EXPECT().CreateInvitation(gomock.Any()).Return(nil)
+		mockRepo.EXPECT().GetUserByID(uint(22)).DoAndReturn(func(uint) (*models.User, error) { return created, nil })
+
+		inactive := false
+		got, err := service.SCIMCreateUser(testAdmin, SCIMUserInput{UserName: "jim@example.com", DisplayName: "Jim", Active: &inactive})
+		assert.NoError(t, err)
+		assert.False(t, got.Active)
+		assert.True(t, created.Pending)
+		<-sent
+	})
+
+	t.Run("email уже существует", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserByEmail("jane@example.com").Return(newTestUser(20, "jane@example.com", "Jane"), nil)
+
+		got, err := service.SCIMCreateUser(testAdmin, SCIMUserInput{UserName: "jane@example.com", DisplayName: "Jane"})
+		assert.EqualError(t, err, "email already exists")
+		assert.Nil(t, got)
+	})
+
+	t.Run("без имени", func(t *testing.T) {
+		got, err := service.SCIMCreateUser(testAdmin, SCIMUserInput{UserName: "jane@example.com"})
+		assert.EqualError(t, err, "name is required")
+		assert.Nil(t, got)
+	})
+}
+
+func TestUserService_SCIMPatchUser(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	tests := []struct {
+		name          string
+		operations    []SCIMPatchOperation
+		setupMock     func(user *models.User)
+		expectedRoles []string
+		expectActive  bool
+		expectedErr   string
+	}{
+		{
+			name: "добавление роли",
+			operations: []SCIMPatchOperation{
+				{Op: "add", Path: "roles", Value: []interface{}{map[string]interface{}{"value": userroles.RoleManager}}},
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(3)
+				mockRepo.EXPECT().
+					UpdateUserAudited(user, map[string]interface{}{"roles": []string{userroles.RoleEngineer, userroles.RoleManager}}, gomock.Any(), false).
+					Return(nil)
+			},
+			expectedRoles: []string{userroles.RoleEngineer, userroles.RoleManager},
+			expectActive:  true,
+		},
+		{
+			name: "удаление роли по фильтру",
+			operations: []SCIMPatchOperation{
+				{Op: "remove", Path: `roles[value eq "engineer"]`},
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(3)
+				mockRepo.EXPECT().UpdateUserAudited(user, map[string]interface{}{"roles": []string{}}, gomock.Any(), false).Return(nil)
+			},
+			expectedRoles: []string{},
+			expectActive:  true,
+		},
+		{
+			name: "деактивация через replace без path",
+			operations: []SCIMPatchOperation{
+				{Op: "Replace", Value: map[string]interface{}{"active": false}},
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(3)
+				mockRepo.EXPECT().UpdateUserAudited(user, map[string]interface{}{"active": false}, gomock.Any(), false).Return(nil)
+				mockRepo.EXPECT().IncrementTokenVersion(user).Return(nil)
+				mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+			},
+			expectedRoles: []string{userroles.RoleEngineer},
+		},
+		{
+			name: "роль выше своей",
+			operations: []SCIMPatchOperation{
+				{Op: "replace", Path: "roles", Value: []interface{}{map[string]interface{}{"value": userroles.RoleSuperadmin}}},
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(2)
+			},
+			expectedErr: "insufficient privileges to grant role: superadmin",
+		},
+		{
+			name: "неизвестный атрибут",
+			operations: []SCIMPatchOperation{
+				{Op: "replace", Path: "userName", Value: "x@example.com"},
+			},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expectedErr: "invalid patch path: userName",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+			tt.setupMock(user)
+
+			got, err := service.SCIMPatchUser(testAdmin, 1, SCIMPatchRequest{
+				Schemas:    []string{SCIMPatchOpSchema},
+				Operations: tt.operations,
+			})
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectActive, got.Active)
+			roles := make([]string, 0, len(got.Roles))
+			for _, role := range got.Roles {
+				roles = append(roles, role.Value)
+			}
+			assert.Equal(t, tt.expectedRoles, roles)
+		})
+	}
+}
+
+func TestUserService_SCIMDeleteUser(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	t.Run("деактивирует вместо удаления", func(t *testing.T) {
+		user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil).Times(2)
+		mockRepo.EXPECT().UpdateUserAudited(user, map[string]interface{}{"active": false}, gomock.Any(), false).Return(nil)
+		mockRepo.EXPECT().IncrementTokenVersion(user).Return(nil)
+		mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+
+		assert.NoError(t, service.SCIMDeleteUser(testAdmin, 1))
+		assert.False(t, user.Active)
+	})
+
+	t.Run("повторное удаление", func(t *testing.T) {
+		user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+		user.Active = false
+		mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+
+		assert.NoError(t, service.SCIMDeleteUser(testAdmin, 1))
+	})
+
+	t.Run("не найден", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserByID(uint(2)).Return((*models.User)(nil), assert.AnError)
+
+		assert.EqualError(t, service.SCIMDeleteUser(testAdmin, 2), "user not found")
+	})
+}
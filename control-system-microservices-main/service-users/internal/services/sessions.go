@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+)
+
+// ClientInfo describes the device a session is opened from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request was made with.
+	Current bool `json:"current"`
+}
+
+func newSession(user *models.User, client ClientInfo) (*models.Session, error) {
+	familyID, err := utils.RandomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start token family: %v", err)
+	}
+	return &models.Session{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+	}, nil
+}
+
+// sessionIdle reports whether the session has gone unused for longer than
+// SESSION_IDLE_TIMEOUT_MINUTES. Use means a login or token refresh, so the
+// timeout should be well above the access token lifespan.
+func (s *UserService) sessionIdle(session *models.Session) bool {
+	idle := intSetting(s.cfg.SessionIdleTimeoutMinutes, 0)
+	if idle == 0 {
+		return false
+	}
+	return time.Since(session.LastUsedAt) > time.Duration(idle)*time.Minute
+}
+
+// ListSessions returns the user's live sessions. currentSessionID is the sid
+// of the caller's access token, or empty when listing on someone's behalf.
+func (s *UserService) ListSessions(userID uint, currentSessionID string) ([]SessionResponse, error) {
+	sessions, err := s.tokenRepo.GetUserSessions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %v", err)
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for i := range sessions {
+		session := &sessions[i]
+		if s.sessionIdle(session) {
+			continue
+		}
+		response = append(response, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    currentSessionID != "" && session.FamilyID == currentSessionID,
+		})
+	}
+	return response, nil
+}
+
+// RevokeUserSession is RevokeSession for an admin acting on another user.
+func (s *UserService) RevokeUserSession(actor Actor, userID, sessionID uint) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return err
+	}
+	return s.RevokeSession(userID, sessionID)
+}
+
+// RevokeSession ends one session of the user. Its refresh tokens stop working
+// at once and its access tokens fail the gateway's next status check.
+func (s *UserService) RevokeSession(userID, sessionID uint) error {
+	session, err := s.tokenRepo.GetSessionByID(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	if err := s.tokenRepo.RevokeSession(session); err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	return nil
+}
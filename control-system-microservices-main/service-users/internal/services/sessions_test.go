@@ -0,0 +1,100 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserService_ListSessions(t *testing.T) {
+	service, _, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	service.cfg.SessionIdleTimeoutMinutes = "60"
+	now := time.Now()
+
+	mockTokenRepo.EXPECT().GetUserSessions(uint(1)).Return([]models.Session{
+		{ID: 2, UserID: 1, FamilyID: "family-2", UserAgent: "phone", LastUsedAt: now},
+		{ID: 1, UserID: 1, FamilyID: "family-1", UserAgent: "laptop", LastUsedAt: now.Add(-30 * time.Minute)},
+		{ID: 3, UserID: 1, FamilyID: "family-3", UserAgent: "old tablet", LastUsedAt: now.Add(-2 * time.Hour)},
+	}, nil)
+
+	got, err := service.ListSessions(1, "family-1")
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, uint(2), got[0].ID)
+	assert.False(t, got[0].Current)
+	assert.Equal(t, uint(1), got[1].ID)
+	assert.True(t, got[1].Current)
+}
+
+func TestUserService_RevokeSession(t *testing.T) {
+	service, _, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name        string
+		session     *models.Session
+		expectedErr string
+	}{
+		{
+			name:    "успешный отзыв",
+			session: &models.Session{ID: 5, UserID: 1, FamilyID: "family-5"},
+		},
+		{
+			name:        "чужая сессия",
+			session:     &models.Session{ID: 5, UserID: 2, FamilyID: "family-5"},
+			expectedErr: "session not found",
+		},
+		{
+			name:        "уже отозвана",
+			session:     &models.Session{ID: 5, UserID: 1, FamilyID: "family-5", RevokedAt: &revokedAt},
+			expectedErr: "session not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTokenRepo.EXPECT().GetSessionByID(uint(5)).Return(tt.session, nil)
+			if tt.expectedErr == "" {
+				mockTokenRepo.EXPECT().RevokeSession(tt.session).Return(nil)
+			}
+
+			err := service.RevokeSession(1, 5)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_RefreshToken_IdleSession(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	service.cfg.SessionIdleTimeoutMinutes = "60"
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	refreshToken, _, err := utils.GenerateRefreshToken(*user, "family-1", "jti-1", service.cfg)
+	assert.NoError(t, err)
+
+	stored := &models.RefreshToken{ID: 1, JTI: "jti-1", FamilyID: "family-1", UserID: 1}
+	session := &models.Session{ID: 1, UserID: 1, FamilyID: "family-1", LastUsedAt: time.Now().Add(-2 * time.Hour)}
+	mockTokenRepo.EXPECT().GetRefreshTokenByJTI("jti-1").Return(stored, nil)
+	mockTokenRepo.EXPECT().MarkRefreshTokenUsed(stored).Return(true, nil)
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockTokenRepo.EXPECT().GetSessionByFamilyID("family-1").Return(session, nil)
+	mockTokenRepo.EXPECT().RevokeSession(session).Return(nil)
+
+	tokens, err := service.RefreshToken(refreshToken, testClient)
+	assert.EqualError(t, err, "session expired")
+	assert.Nil(t, tokens)
+}
@@ -3,26 +3,48 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
+	"slices"
 
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/pagination"
 	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type UserService struct {
-	userRepo repositories.UserRepositoryInterface
-	cfg      *config.Config
+	userRepo  repositories.UserRepositoryInterface
+	tokenRepo repositories.TokenRepositoryInterface
+	roleRepo  repositories.RoleRepositoryInterface
+	auditRepo repositories.AuditRepositoryInterface
+	keys      *utils.KeySet
+	mailer    mailer.Mailer
+	cfg       *config.Config
+
+	// passwordResets queues ForgotPassword requests for a fixed number of
+	// workers, which are started on first use.
+	passwordResets     chan string
+	passwordResetsOnce sync.Once
 }
 
-func NewUserService(userRepo repositories.UserRepositoryInterface, cfg *config.Config) *UserService {
+func NewUserService(userRepo repositories.UserRepositoryInterface, tokenRepo repositories.TokenRepositoryInterface, roleRepo repositories.RoleRepositoryInterface, auditRepo repositories.AuditRepositoryInterface, keys *utils.KeySet, mailer mailer.Mailer, cfg *config.Config) *UserService {
 	return &UserService{
-		userRepo: userRepo,
-		cfg:      cfg,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		roleRepo:  roleRepo,
+		auditRepo: auditRepo,
+		keys:      keys,
+		mailer:    mailer,
+		cfg:       cfg,
 	}
 }
 
@@ -31,6 +53,14 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type RegisterUserInput struct {
 	Email    string   `json:"email"`
 	Password string   `json:"password"`
@@ -43,18 +73,76 @@ type EditUserInput struct {
 	Roles *[]string `json:"roles"`
 }
 
+type UpdateProfileInput struct {
+	Name     *string `json:"name"`
+	Phone    *string `json:"phone"`
+	Position *string `json:"position"`
+}
+
+type ChangePasswordInput struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 type UserListInput struct {
 	Page        int    `json:"page"`
 	Limit       int    `json:"limit"`
 	EmailFilter string `json:"email_filter"`
 	RoleFilter  string `json:"role_filter"`
+	StateFilter string `json:"state_filter"`
+	NameFilter  string `json:"name_filter"`
+	// RolesFilter lists users with any of the roles, or with all of them
+	// when RoleMatch is "all".
+	RolesFilter  []string   `json:"roles_filter"`
+	RoleMatch    string     `json:"role_match"`
+	ActiveFilter *bool      `json:"active_filter"`
+	CreatedFrom  *time.Time `json:"created_from"`
+	CreatedTo    *time.Time `json:"created_to"`
+	UpdatedFrom  *time.Time `json:"updated_from"`
+	UpdatedTo    *time.Time `json:"updated_to"`
+	// SortBy is name, email or created_at; SortOrder is asc or desc.
+	SortBy    string `json:"sort_by"`
+	SortOrder string `json:"sort_order"`
+	// Cursor and IncludeTotal only apply to GetUsersByCursor.
+	Cursor       string `json:"cursor"`
+	IncludeTotal bool   `json:"include_total"`
 }
 
 type UserResponse struct {
-	ID    uint     `json:"id"`
-	Email string   `json:"email"`
-	Name  string   `json:"name"`
-	Roles []string `json:"roles"`
+	ID       uint     `json:"id"`
+	Email    string   `json:"email"`
+	Name     string   `json:"name"`
+	Roles    []string `json:"roles"`
+	Phone    string   `json:"phone,omitempty"`
+	Position string   `json:"position,omitempty"`
+	Active   bool     `json:"active"`
+	Pending  bool     `json:"pending,omitempty"`
+	// ServiceAccount users authenticate with API keys instead of a password.
+	ServiceAccount bool `json:"service_account,omitempty"`
+	// DeletedAt is only set when deleted users are listed explicitly.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UserSummary is what other services need to know about a user they refer to.
+type UserSummary struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Active bool   `json:"active"`
+}
+
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LoginResult carries either a finished login or, when the user has to pass
+// a second factor first, an MFA challenge.
+type LoginResult struct {
+	Tokens        *AuthTokens
+	User          *UserResponse
+	MFA           *MFAChallenge
+	RecoveryCodes []string
 }
 
 type UserListResult struct {
@@ -65,50 +153,133 @@ type UserListResult struct {
 	TotalPages int            `json:"total_pages"`
 }
 
+// UserCursorResult is a page of a keyset-paginated user list.
+type UserCursorResult struct {
+	Users []UserResponse `json:"users"`
+	Limit int            `json:"limit"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is only counted when asked for.
+	Total *int64 `json:"total,omitempty"`
+}
+
+func toUserResponse(user *models.User) *UserResponse {
+	response := &UserResponse{
+		ID:             user.ID,
+		Email:          user.Email,
+		Name:           user.Name,
+		Roles:          user.Roles,
+		Phone:          user.Phone,
+		Position:       user.Position,
+		Active:         user.Active,
+		Pending:        user.Pending,
+		ServiceAccount: user.ServiceAccount,
+	}
+	if user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+	return response
+}
+
 func isValidEmail(email string) bool {
 	re := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	return re.MatchString(email)
 }
 
-func isValidRole(role string) bool {
-	validRoles := []string{
-		userroles.RoleEngineer,
-		userroles.RoleManager,
-		userroles.RoleObserver,
-		userroles.RoleAdmin,
-		userroles.RoleSuperadmin,
+func isValidSort(field, order string) bool {
+	switch field {
+	case "", repositories.UserSortName, repositories.UserSortEmail, repositories.UserSortCreatedAt:
+	default:
+		return false
 	}
-	for _, validRole := range validRoles {
-		if role == validRole {
-			return true
-		}
+	return order == "" || order == "asc" || order == "desc"
+}
+
+func isValidRange(from, to *time.Time) bool {
+	return from == nil || to == nil || !from.After(*to)
+}
+
+func isValidStateFilter(state string) bool {
+	switch state {
+	case "", repositories.UserStateActive, repositories.UserStateInactive, repositories.UserStatePending,
+		repositories.UserStateDeleted, repositories.UserStateAll:
+		return true
 	}
 	return false
 }
 
-func (s *UserService) RegisterUser(input RegisterUserInput) (*UserResponse, error) {
+// knownRoles reports which of the given role names exist in the roles table.
+func (s *UserService) knownRoles(roles []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(roles))
+	if len(roles) == 0 {
+		return known, nil
+	}
+	existing, err := s.roleRepo.FindExistingRoles(roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check roles: %v", err)
+	}
+	for _, role := range existing {
+		known[role] = true
+	}
+	return known, nil
+}
+
+func unknownRole(roles []string, known map[string]bool) error {
+	for _, role := range roles {
+		if !known[role] {
+			return withValue(ErrInvalidRole, role, "roles")
+		}
+	}
+	return nil
+}
+
+// checkRoles fails unless every role exists.
+func (s *UserService) checkRoles(roles []string) error {
+	known, err := s.knownRoles(roles)
+	if err != nil {
+		return err
+	}
+	return unknownRole(roles, known)
+}
+
+// validateRoles checks every role and falls back to engineer when none is given.
+func (s *UserService) validateRoles(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{userroles.RoleEngineer}, nil
+	}
+	if err := s.checkRoles(roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (s *UserService) RegisterUser(actor Actor, input RegisterUserInput) (*UserResponse, error) {
+	return s.registerUser(actor, input, true)
+}
 
-	if input.Email == "" || input.Password == "" || input.Name == "" {
-		return nil, errors.New("email, password, and name are required")
+// registerUser creates an account that is active or deactivated from the
+// start, so the account is written once either way.
+func (s *UserService) registerUser(actor Actor, input RegisterUserInput, active bool) (*UserResponse, error) {
+	if err := missingFields(ErrRegisterFieldsRequired, input.Email, input.Password, input.Name); err != nil {
+		return nil, err
 	}
 	if !isValidEmail(input.Email) {
-		return nil, errors.New("invalid email format")
+		return nil, ErrInvalidEmail
 	}
 	if len(input.Password) < 8 {
-		return nil, errors.New("password must be at least 8 characters")
+		return nil, ErrPasswordTooShort
 	}
-	if len(input.Roles) == 0 {
-		input.Roles = []string{userroles.RoleEngineer}
+	roles, err := s.validateRoles(input.Roles)
+	if err != nil {
+		return nil, err
 	}
-
-	for _, role := range input.Roles {
-		if !isValidRole(role) {
-			return nil, fmt.Errorf("invalid role: %s", role)
-		}
+	if err := s.checkGrant(actor, roles); err != nil {
+		return nil, err
 	}
+	input.Roles = roles
 
 	if _, err := s.userRepo.GetUserByEmail(input.Email); err == nil {
-		return nil, errors.New("email already exists")
+		return nil, ErrEmailExists
 	}
 
 	user := models.User{
@@ -116,72 +287,387 @@ func (s *UserService) RegisterUser(input RegisterUserInput) (*UserResponse, erro
 		Password: input.Password,
 		Name:     input.Name,
 		Roles:    input.Roles,
+		Active:   active,
 	}
 
 	if err := user.HashPassword(); err != nil {
 		return nil, fmt.Errorf("failed to hash password: %v", err)
 	}
 
-	if err := s.userRepo.CreateUser(&user); err != nil {
+	entry := auditEntry(actor, AuditUserRegistered, 0, creationChanges(&user))
+	if err := s.userRepo.CreateUser(&user, entry); err != nil {
 		return nil, fmt.Errorf("failed to create user: %v", err)
 	}
 
-	return &UserResponse{
-		ID:    user.ID,
-		Email: user.Email,
-		Name:  user.Name,
-		Roles: user.Roles,
-	}, nil
+	return toUserResponse(&user), nil
 }
 
-func (s *UserService) LoginUser(email, password string) (string, *UserResponse, error) {
-	user, err := s.userRepo.GetUserByEmail(strings.ToLower(email))
+func (s *UserService) LoginUser(email, password string, client ClientInfo) (*LoginResult, error) {
+	user, err := s.verifyCredentials(email, password)
 	if err != nil {
-		return "", nil, errors.New("invalid email or password")
+		return nil, err
+	}
+
+	// Failed attempts are only cleared once every factor has passed, so the
+	// password alone can't be used to reset the counter between code guesses.
+	if user.TOTPEnabled || s.mfaRequired(user) {
+		challenge, err := s.newMFAChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFA: challenge}, nil
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// verifyCredentials is the password step of a login. It counts failures
+// towards the lockout and only returns a user who may sign in.
+func (s *UserService) verifyCredentials(email, password string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByEmail(strings.ToLower(email))
+	if err != nil || user.ServiceAccount {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.IsLocked() {
+		return nil, ErrAccountLocked
 	}
 
 	if err := user.VerifyPassword(password); err != nil {
-		return "", nil, errors.New("invalid email or password")
+		if err := s.recordFailedLogin(user); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.PasswordNeedsRehash() {
+		s.rehashPassword(user, password)
 	}
 
-	token, err := utils.GenerateToken(*user, s.cfg)
+	// Checked only after the password so the state of an account isn't
+	// revealed to someone who doesn't know its credentials.
+	if !user.Active {
+		return nil, ErrAccountDeactivated
+	}
+	if user.Pending {
+		return nil, ErrAccountPending
+	}
+	return user, nil
+}
+
+// rehashPassword upgrades a stored hash while the plaintext is at hand. A
+// failure only means the upgrade is retried on the next login.
+func (s *UserService) rehashPassword(user *models.User, password string) {
+	rehashed := *user
+	rehashed.Password = password
+	if err := rehashed.HashPassword(); err != nil {
+		log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		return
+	}
+	if err := s.userRepo.UpdateUser(user, map[string]interface{}{"password": rehashed.Password}); err != nil {
+		log.Printf("Failed to store rehashed password for user %d: %v", user.ID, err)
+		return
+	}
+	user.Password = rehashed.Password
+}
+
+func (s *UserService) completeLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	if err := s.clearFailedLogins(user); err != nil {
+		return nil, err
+	}
+
+	session, err := newSession(user, client)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate JWT: %v", err)
+		return nil, err
 	}
 
-	return token, &UserResponse{
-		ID:    user.ID,
-		Email: user.Email,
-		Name:  user.Name,
-		Roles: user.Roles,
+	tokens, err := s.issueTokens(user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		Tokens: tokens,
+		User:   toUserResponse(user),
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh pair within
+// the same token family. Presenting a token that was already rotated revokes
+// the whole family, so a stolen token stops working for both parties.
+func (s *UserService) RefreshToken(refreshToken string, client ClientInfo) (*AuthTokens, error) {
+	parsed, err := utils.ParseRefreshToken(refreshToken, s.cfg)
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	stored, err := s.tokenRepo.GetRefreshTokenByJTI(jti)
+	if err != nil || stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		return nil, s.revokeReusedFamily(stored.FamilyID)
+	}
+
+	rotated, err := s.tokenRepo.MarkRefreshTokenUsed(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	if !rotated {
+		return nil, s.revokeReusedFamily(stored.FamilyID)
+	}
+
+	user, err := s.userRepo.GetUserByID(stored.UserID)
+	if err != nil || !user.Active {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.tokenRepo.GetSessionByFamilyID(stored.FamilyID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if s.sessionIdle(session) {
+		if err := s.tokenRepo.RevokeSession(session); err != nil {
+			return nil, fmt.Errorf("failed to end idle session: %v", err)
+		}
+		return nil, ErrSessionExpired
+	}
+	if client.IP != "" {
+		session.IP = client.IP
+	}
+
+	return s.issueTokens(user, session)
+}
+
+func (s *UserService) revokeReusedFamily(familyID string) error {
+	if err := s.tokenRepo.RevokeRefreshTokenFamily(familyID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %v", err)
+	}
+	return ErrRefreshTokenReused
+}
+
+// Logout denylists the presented access token until it expires and, when a
+// refresh token is supplied, revokes the session it belongs to.
+func (s *UserService) Logout(accessToken, refreshToken string) error {
+	parsed, err := utils.ParseToken(accessToken, s.keys)
+	if err != nil || !parsed.Valid {
+		return ErrInvalidToken
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return ErrInvalidToken
+	}
+	jti, _ := claims["jti"].(string)
+	userID, _ := claims["id"].(float64)
+	exp, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || exp == nil {
+		return ErrInvalidToken
+	}
+
+	if err := s.tokenRepo.RevokeAccessToken(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    uint(userID),
+		ExpiresAt: exp.Time,
+	}); err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	parsedRefresh, err := utils.ParseRefreshToken(refreshToken, s.cfg)
+	if err != nil || !parsedRefresh.Valid {
+		return nil
+	}
+	refreshClaims, ok := parsedRefresh.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+	refreshUserID, _ := refreshClaims["id"].(float64)
+	familyID, _ := refreshClaims["fam"].(string)
+	if familyID == "" || refreshUserID != userID {
+		return nil
+	}
+
+	if err := s.tokenRepo.RevokeRefreshTokenFamily(familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %v", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions invalidates every access and refresh token issued to the
+// user so far.
+func (s *UserService) RevokeAllSessions(actor Actor, userID uint) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return err
+	}
+	return s.revokeSessions(user)
+}
+
+func (s *UserService) revokeSessions(user *models.User) error {
+	if err := s.userRepo.IncrementTokenVersion(user); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	if err := s.tokenRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	return nil
+}
+
+func (s *UserService) JWKS() utils.JWKS {
+	return s.keys.JWKS()
+}
+
+// IsAccessTokenRevoked reports whether an otherwise valid access token must be
+// rejected, either because it was logged out, because its session or the
+// OAuth client it was issued to ended, or because the user's sessions were
+// revoked after it was issued.
+func (s *UserService) IsAccessTokenRevoked(jti string, userID uint, version int, sessionID, clientID string) (bool, error) {
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		return true, nil
+	}
+
+	// Tokens issued before sessions existed carry no sid and are only
+	// checked against the user.
+	if sessionID != "" {
+		session, err := s.tokenRepo.GetSessionByFamilyID(sessionID)
+		if err != nil || session.UserID != userID || session.RevokedAt != nil || s.sessionIdle(session) {
+			return true, nil
+		}
+	}
+	if clientID != "" {
+		client, err := s.tokenRepo.GetOAuthClientByClientID(clientID)
+		if err != nil || client.RevokedAt != nil {
+			return true, nil
+		}
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil || !user.Active {
+		return true, nil
+	}
+	return user.TokenVersion != version, nil
+}
+
+// issueTokens signs a token pair for the session and records the use. A
+// session that has no ID yet is created here.
+func (s *UserService) issueTokens(user *models.User, session *models.Session) (*AuthTokens, error) {
+	// Permissions are resolved at issue time, so role changes reach a user
+	// with their next token refresh.
+	permissions, err := s.roleRepo.GetPermissionsForRoles(user.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve permissions: %v", err)
+	}
+
+	accessToken, err := utils.GenerateToken(*user, permissions, session.FamilyID, s.keys, s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %v", err)
+	}
+
+	jti, err := utils.RandomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+
+	refreshToken, expiresAt, err := utils.GenerateRefreshToken(*user, session.FamilyID, jti, s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = expiresAt
+	if session.ID == 0 {
+		err = s.tokenRepo.CreateSession(session)
+	} else {
+		err = s.tokenRepo.TouchSession(session)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store session: %v", err)
+	}
+
+	if err := s.tokenRepo.CreateRefreshToken(&models.RefreshToken{
+		JTI:       jti,
+		FamilyID:  session.FamilyID,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	}, nil
 }
 
 func (s *UserService) GetUserByID(id uint) (*UserResponse, error) {
 	user, err := s.userRepo.GetUserByID(id)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 
-	return &UserResponse{
-		ID:    user.ID,
-		Email: user.Email,
-		Name:  user.Name,
-		Roles: user.Roles,
-	}, nil
+	return toUserResponse(user), nil
 }
 
-func (s *UserService) UpdateUser(id uint, input EditUserInput) (*UserResponse, error) {
+// maxLookupIDs bounds a single LookupUsers call.
+const maxLookupIDs = 100
+
+// LookupUsers returns summaries of the given users for other services. IDs
+// that name no user are left out, so callers can tell which ones are unknown.
+func (s *UserService) LookupUsers(ids []uint) ([]UserSummary, error) {
+	if len(ids) == 0 {
+		return []UserSummary{}, nil
+	}
+	if len(ids) > maxLookupIDs {
+		return nil, ErrTooManyIDs
+	}
+
+	users, err := s.userRepo.GetUsersByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up users: %v", err)
+	}
+	summaries := make([]UserSummary, len(users))
+	for i, user := range users {
+		summaries[i] = UserSummary{ID: user.ID, Name: user.Name, Email: user.Email, Active: user.Active}
+	}
+	return summaries, nil
+}
+
+func (s *UserService) UpdateUser(actor Actor, id uint, input EditUserInput) (*UserResponse, error) {
 	user, err := s.userRepo.GetUserByID(id)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkManage(actor, user); err != nil {
+		return nil, err
 	}
 
 	if input.Roles != nil {
-		for _, role := range *input.Roles {
-			if !isValidRole(role) {
-				return nil, fmt.Errorf("invalid role: %s", role)
-			}
+		if err := s.checkRoles(*input.Roles); err != nil {
+			return nil, err
+		}
+		if err := s.checkGrant(actor, *input.Roles); err != nil {
+			return nil, err
 		}
 	}
 
@@ -194,47 +680,172 @@ func (s *UserService) UpdateUser(id uint, input EditUserInput) (*UserResponse, e
 	}
 
 	if len(updates) == 0 {
-		return &UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
-			Roles: user.Roles,
-		}, nil
+		return toUserResponse(user), nil
+	}
+
+	afterName, afterRoles := user.Name, []string(user.Roles)
+	if name, ok := updates["name"].(string); ok {
+		afterName = name
+	}
+	if input.Roles != nil {
+		afterRoles = *input.Roles
+	}
+
+	var entry *models.AuditEntry
+	if changes := profileChanges(user.Name, user.Roles, afterName, afterRoles); len(changes) > 0 {
+		entry = auditEntry(actor, AuditUserUpdated, user.ID, changes)
+	}
+	demotes := input.Roles != nil && !slices.Contains(*input.Roles, userroles.RoleSuperadmin)
+	if err := s.userRepo.UpdateUserAudited(user, updates, entry, demotes && holdsSuperadmin(user)); err != nil {
+		if errors.Is(err, repositories.ErrLastSuperadmin) {
+			return nil, ErrLastSuperadmin
+		}
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+	user.Name, user.Roles = afterName, afterRoles
+	return toUserResponse(user), nil
+}
+
+// UpdateProfile lets a user edit their own name and profile fields. Roles and
+// email stay under admin control.
+func (s *UserService) UpdateProfile(id uint, input UpdateProfileInput) (*UserResponse, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	updates := make(map[string]interface{})
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, ErrNameEmpty
+		}
+		updates["name"] = name
+	}
+	if input.Phone != nil {
+		updates["phone"] = strings.TrimSpace(*input.Phone)
+	}
+	if input.Position != nil {
+		updates["position"] = strings.TrimSpace(*input.Position)
+	}
+
+	if len(updates) == 0 {
+		return toUserResponse(user), nil
 	}
 
 	if err := s.userRepo.UpdateUser(user, updates); err != nil {
 		return nil, fmt.Errorf("failed to update user: %v", err)
 	}
 
-	return &UserResponse{
-		ID:    user.ID,
-		Email: user.Email,
-		Name:  user.Name,
-		Roles: user.Roles,
-	}, nil
+	return toUserResponse(user), nil
+}
+
+// ChangePassword replaces the user's password after checking the old one.
+// Wrong old passwords count towards the lockout like failed logins, so a
+// stolen session cannot be used to guess the password. All existing sessions
+// are revoked and a fresh token pair is returned for the client that made the
+// change.
+func (s *UserService) ChangePassword(id uint, input ChangePasswordInput, client ClientInfo, requestID string) (*AuthTokens, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.IsLocked() {
+		return nil, ErrAccountLocked
+	}
+	if err := user.VerifyPassword(input.OldPassword); err != nil {
+		if err := s.recordFailedLogin(user); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidOldPassword
+	}
+	if len(input.NewPassword) < 8 {
+		return nil, ErrPasswordTooShort
+	}
+
+	user.Password = input.NewPassword
+	if err := user.HashPassword(); err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+	updates := map[string]interface{}{"password": user.Password}
+	if hasFailedLogins(user) {
+		for column, value := range failedLoginsReset() {
+			updates[column] = value
+		}
+	}
+	entry := auditEntry(Actor{ID: user.ID, RequestID: requestID}, AuditPasswordChanged, user.ID, models.AuditChanges{})
+	if err := s.userRepo.UpdateUserAudited(user, updates, entry, false); err != nil {
+		return nil, fmt.Errorf("failed to update password: %v", err)
+	}
+
+	// Revoke through the same user: the new tokens must carry the bumped
+	// token version, or the session would be revoked along with the others.
+	if err := s.revokeSessions(user); err != nil {
+		return nil, err
+	}
+
+	session, err := newSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user, session)
+}
+
+// userListQuery checks the filters and sort of a user list.
+func userListQuery(input UserListInput) (repositories.UserFilter, repositories.UserSort, error) {
+	if !isValidStateFilter(input.StateFilter) {
+		return repositories.UserFilter{}, repositories.UserSort{}, ErrInvalidStateFilter
+	}
+	if input.RoleMatch != "" && input.RoleMatch != repositories.RoleMatchAny && input.RoleMatch != repositories.RoleMatchAll {
+		return repositories.UserFilter{}, repositories.UserSort{}, ErrInvalidRoleMatch
+	}
+	if !isValidSort(input.SortBy, input.SortOrder) {
+		return repositories.UserFilter{}, repositories.UserSort{}, ErrInvalidSort
+	}
+	if !isValidRange(input.CreatedFrom, input.CreatedTo) || !isValidRange(input.UpdatedFrom, input.UpdatedTo) {
+		return repositories.UserFilter{}, repositories.UserSort{}, ErrInvalidDateRange
+	}
+
+	filter := repositories.UserFilter{
+		Email:       input.EmailFilter,
+		Role:        input.RoleFilter,
+		State:       input.StateFilter,
+		Active:      input.ActiveFilter,
+		Name:        strings.TrimSpace(input.NameFilter),
+		Roles:       input.RolesFilter,
+		RoleMatch:   input.RoleMatch,
+		CreatedFrom: input.CreatedFrom,
+		CreatedTo:   input.CreatedTo,
+		UpdatedFrom: input.UpdatedFrom,
+		UpdatedTo:   input.UpdatedTo,
+	}
+	// The ID order ignores the direction.
+	sort := repositories.UserSort{Field: input.SortBy, Desc: input.SortBy != "" && input.SortOrder == "desc"}
+	return filter, sort, nil
 }
 
 func (s *UserService) GetUsers(input UserListInput) (*UserListResult, error) {
 	if input.Page < 1 {
-		return nil, errors.New("invalid page number")
+		return nil, ErrInvalidPage
 	}
 	if input.Limit < 1 {
-		return nil, errors.New("invalid limit value")
+		return nil, ErrInvalidLimit
 	}
 
-	users, total, err := s.userRepo.GetUsers(input.Page, input.Limit, input.EmailFilter, input.RoleFilter)
+	filter, sort, err := userListQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.userRepo.GetUsers((input.Page-1)*input.Limit, input.Limit, filter, sort)
 	if err != nil {
 		return nil, err
 	}
 
 	response := make([]UserResponse, 0, len(users))
 	for _, user := range users {
-		response = append(response, UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
-			Roles: user.Roles,
-		})
+		response = append(response, *toUserResponse(&user))
 	}
 
 	totalPages := int((total + int64(input.Limit) - 1) / int64(input.Limit))
@@ -247,3 +858,86 @@ func (s *UserService) GetUsers(input UserListInput) (*UserListResult, error) {
 		TotalPages: totalPages,
 	}, nil
 }
+
+// userSortKey is the value of the sort column of a user, as kept in cursors.
+func userSortKey(user *models.User, field string) interface{} {
+	switch field {
+	case repositories.UserSortName:
+		return user.Name
+	case repositories.UserSortEmail:
+		return user.Email
+	case repositories.UserSortCreatedAt:
+		return user.CreatedAt
+	}
+	return nil
+}
+
+// userCursor turns a cursor back into a position in the user list.
+func userCursor(raw string, sort repositories.UserSort) (*repositories.UserCursor, error) {
+	cursor, err := pagination.DecodeCursor(raw, sort.Field, sort.Desc)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	after := &repositories.UserCursor{ID: cursor.ID}
+	switch sort.Field {
+	case repositories.UserSortName, repositories.UserSortEmail:
+		var value string
+		err = cursor.ReadKey(&value)
+		after.Value = value
+	case repositories.UserSortCreatedAt:
+		var value time.Time
+		err = cursor.ReadKey(&value)
+		after.Value = value
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return after, nil
+}
+
+// GetUsersByCursor lists users with keyset pagination. An empty cursor
+// starts at the first page; the total is only counted when asked for.
+func (s *UserService) GetUsersByCursor(input UserListInput) (*UserCursorResult, error) {
+	if input.Limit < 1 {
+		return nil, ErrInvalidLimit
+	}
+	input.Limit = min(input.Limit, pagination.MaxLimit)
+
+	filter, sort, err := userListQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *repositories.UserCursor
+	if input.Cursor != "" {
+		if after, err = userCursor(input.Cursor, sort); err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra row tells whether there is a next page.
+	users, err := s.userRepo.GetUsersAfter(after, input.Limit+1, filter, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UserCursorResult{Users: make([]UserResponse, 0, len(users)), Limit: input.Limit}
+	if len(users) > input.Limit {
+		users = users[:input.Limit]
+		last := &users[len(users)-1]
+		result.NextCursor = pagination.EncodeCursor(sort.Field, sort.Desc, last.ID, userSortKey(last, sort.Field))
+	}
+	for _, user := range users {
+		result.Users = append(result.Users, *toUserResponse(&user))
+	}
+
+	if input.IncludeTotal {
+		total, err := s.userRepo.CountUsers(filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
@@ -1,47 +1,140 @@
 package services
 
 import (
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/mailer"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/models"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/passwords"
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/repositories/mocks"
 	"github.com/SpiritFoxo/control-system-microservices/service-users/utils"
+	"github.com/SpiritFoxo/control-system-microservices/shared/pagination"
 	"github.com/SpiritFoxo/control-system-microservices/shared/userroles"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// testAdmin is the caller of admin operations in tests that do not check
+// privileges themselves.
+var testAdmin = Actor{ID: 100, Roles: []string{userroles.RoleAdmin}}
+
+var testClient = ClientInfo{UserAgent: "test-agent", IP: "203.0.113.7"}
+
 func newTestUser(id uint, email, name string, roles ...string) *models.User {
 	return &models.User{
-		Model: gorm.Model{ID: id},
-		Email: email,
-		Name:  name,
-		Roles: roles,
+		Model:  gorm.Model{ID: id},
+		Email:  email,
+		Name:   name,
+		Roles:  roles,
+		Active: true,
 	}
 }
 
+func hashedPassword(t *testing.T, password string) string {
+	hashed, err := passwords.Default().Hash(password)
+	assert.NoError(t, err)
+	return hashed
+}
+
+func passwordMatches(t *testing.T, hash, password string) bool {
+	ok, err := passwords.Default().Verify(hash, password)
+	assert.NoError(t, err)
+	return ok
+}
+
 func setupTest(t *testing.T) (*UserService, *mocks.MockUserRepositoryInterface, func()) {
+	service, mockRepo, _, finish := setupAuthTest(t)
+	return service, mockRepo, finish
+}
+
+func setupAuthTest(t *testing.T) (*UserService, *mocks.MockUserRepositoryInterface, *mocks.MockTokenRepositoryInterface, func()) {
+	service, mockRepo, mockTokenRepo, mockRoleRepo, _, finish := newTestService(t)
+	stubSeededRoles(mockRoleRepo)
+	return service, mockRepo, mockTokenRepo, finish
+}
+
+// setupRoleTest leaves the role repository without expectations, for tests
+// that check role lookups themselves.
+func setupRoleTest(t *testing.T) (*UserService, *mocks.MockUserRepositoryInterface, *mocks.MockTokenRepositoryInterface, *mocks.MockRoleRepositoryInterface, func()) {
+	service, mockRepo, mockTokenRepo, mockRoleRepo, _, finish := newTestService(t)
+	return service, mockRepo, mockTokenRepo, mockRoleRepo, finish
+}
+
+// setupAuditTest leaves the audit repository without expectations.
+func setupAuditTest(t *testing.T) (*UserService, *mocks.MockUserRepositoryInterface, *mocks.MockTokenRepositoryInterface, *mocks.MockAuditRepositoryInterface, func()) {
+	service, mockRepo, mockTokenRepo, mockRoleRepo, mockAuditRepo, finish := newTestService(t)
+	stubSeededRoles(mockRoleRepo)
+	return service, mockRepo, mockTokenRepo, mockAuditRepo, finish
+}
+
+func newTestService(t *testing.T) (*UserService, *mocks.MockUserRepositoryInterface, *mocks.MockTokenRepositoryInterface, *mocks.MockRoleRepositoryInterface, *mocks.MockAuditRepositoryInterface, func()) {
 	ctrl := gomock.NewController(t)
 	mockRepo := mocks.NewMockUserRepositoryInterface(ctrl)
+	mockTokenRepo := mocks.NewMockTokenRepositoryInterface(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepositoryInterface(ctrl)
+	mockAuditRepo := mocks.NewMockAuditRepositoryInterface(ctrl)
 
 	cfg := &config.Config{
-		TokenSecret:         "test-secret",
-		TokenMinuteLifespan: "5",
+		RefreshTokenSecret:       "test-refresh-secret",
+		InviteTokenSecret:        "test-invite-secret",
+		MFATokenSecret:           "test-mfa-secret",
+		TokenMinuteLifespan:      "5",
+		RefreshTokenHourLifespan: "24",
 	}
 
-	service := NewUserService(mockRepo, cfg)
-	return service, mockRepo, ctrl.Finish
+	key, err := utils.GenerateSigningKey("test", "RS256")
+	assert.NoError(t, err)
+	keys, err := utils.NewKeySet(key.ID, key)
+	assert.NoError(t, err)
+
+	service := NewUserService(mockRepo, mockTokenRepo, mockRoleRepo, mockAuditRepo, keys, mailer.NewLogMailer(""), cfg)
+	return service, mockRepo, mockTokenRepo, mockRoleRepo, mockAuditRepo, ctrl.Finish
+}
+
+// stubSeededRoles makes the role repository answer like a freshly seeded
+// database.
+func stubSeededRoles(mockRoleRepo *mocks.MockRoleRepositoryInterface) {
+	mockRoleRepo.EXPECT().
+		FindExistingRoles(gomock.Any()).
+		DoAndReturn(func(names []string) ([]string, error) {
+			var existing []string
+			for _, name := range names {
+				if _, ok := models.DefaultRolePermissions[name]; ok {
+					existing = append(existing, name)
+				}
+			}
+			return existing, nil
+		}).
+		AnyTimes()
+	mockRoleRepo.EXPECT().
+		GetPermissionsForRoles(gomock.Any()).
+		DoAndReturn(func(roles []string) ([]string, error) {
+			// Like the repository, list each permission once.
+			var permissions []string
+			for _, role := range roles {
+				for _, permission := range models.DefaultRolePermissions[role] {
+					if !slices.Contains(permissions, permission) {
+						permissions = append(permissions, permission)
+					}
+				}
+			}
+			return permissions, nil
+		}).
+		AnyTimes()
 }
 
 func TestUserService_RegisterUser(t *testing.T) {
 	service, mockRepo, finish := setupTest(t)
 	defer finish()
 
-	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	hashed := hashedPassword(t, "password123")
 
 	tests := []struct {
 		name        string
@@ -64,18 +157,19 @@ func TestUserService_RegisterUser(t *testing.T) {
 					Return((*models.User)(nil), assert.AnError)
 
 				mockRepo.EXPECT().
-					CreateUser(gomock.Any()).
-					DoAndReturn(func(u *models.User) error {
+					CreateUser(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(u *models.User, _ *models.AuditEntry) error {
 						u.ID = 1
-						u.Password = string(hashed)
+						u.Password = hashed
 						return nil
 					})
 			},
 			expected: &UserResponse{
-				ID:    1,
-				Email: "test@example.com",
-				Name:  "Test User",
-				Roles: []string{userroles.RoleEngineer},
+				ID:     1,
+				Email:  "test@example.com",
+				Name:   "Test User",
+				Roles:  []string{userroles.RoleEngineer},
+				Active: true,
 			},
 		},
 		{
@@ -143,7 +237,7 @@ func TestUserService_RegisterUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
-			got, err := service.RegisterUser(tt.input)
+			got, err := service.RegisterUser(testAdmin, tt.input)
 
 			if tt.expectedErr != "" {
 				assert.ErrorContains(t, err, tt.expectedErr)
@@ -157,12 +251,12 @@ func TestUserService_RegisterUser(t *testing.T) {
 }
 
 func TestUserService_LoginUser(t *testing.T) {
-	service, mockRepo, finish := setupTest(t)
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
 	defer finish()
 
-	hashed, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	hashed := hashedPassword(t, "password123")
 	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
-	user.Password = string(hashed)
+	user.Password = hashed
 
 	tests := []struct {
 		name        string
@@ -181,13 +275,67 @@ func TestUserService_LoginUser(t *testing.T) {
 				mockRepo.EXPECT().
 					GetUserByEmail("test@example.com").
 					Return(user, nil)
+				var familyID string
+				mockTokenRepo.EXPECT().
+					CreateSession(gomock.Any()).
+					DoAndReturn(func(session *models.Session) error {
+						assert.Equal(t, uint(1), session.UserID)
+						assert.Equal(t, testClient.UserAgent, session.UserAgent)
+						assert.Equal(t, testClient.IP, session.IP)
+						assert.WithinDuration(t, time.Now().Add(24*time.Hour), session.ExpiresAt, 5*time.Second)
+						familyID = session.FamilyID
+						return nil
+					})
+				mockTokenRepo.EXPECT().
+					CreateRefreshToken(gomock.Any()).
+					DoAndReturn(func(token *models.RefreshToken) error {
+						assert.Equal(t, familyID, token.FamilyID)
+						assert.Equal(t, uint(1), token.UserID)
+						assert.NotEmpty(t, token.FamilyID)
+						assert.NotEmpty(t, token.JTI)
+						return nil
+					})
 			},
 			wantToken: true,
 			expected: &UserResponse{
-				ID:    1,
-				Email: "test@example.com",
-				Name:  "Test User",
-				Roles: []string{userroles.RoleEngineer},
+				ID:     1,
+				Email:  "test@example.com",
+				Name:   "Test User",
+				Roles:  []string{userroles.RoleEngineer},
+				Active: true,
+			},
+		},
+		{
+			name:     "bcrypt хэш обновляется до argon2id",
+			email:    "legacy@example.com",
+			password: "password123",
+			setupMock: func() {
+				legacyHash, err := passwords.NewBcryptHasher(4).Hash("password123")
+				assert.NoError(t, err)
+				legacy := newTestUser(1, "legacy@example.com", "Legacy", userroles.RoleEngineer)
+				legacy.Password = legacyHash
+
+				mockRepo.EXPECT().
+					GetUserByEmail("legacy@example.com").
+					Return(legacy, nil)
+				mockRepo.EXPECT().
+					UpdateUser(legacy, gomock.Any()).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}) error {
+						hash := updates["password"].(string)
+						assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+						assert.True(t, passwordMatches(t, hash, "password123"))
+						return nil
+					})
+				mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+			},
+			wantToken: true,
+			expected: &UserResponse{
+				ID:     1,
+				Email:  "legacy@example.com",
+				Name:   "Legacy",
+				Roles:  []string{userroles.RoleEngineer},
+				Active: true,
 			},
 		},
 		{
@@ -198,9 +346,29 @@ func TestUserService_LoginUser(t *testing.T) {
 				mockRepo.EXPECT().
 					GetUserByEmail("test@example.com").
 					Return(user, nil)
+				mockRepo.EXPECT().
+					IncrementFailedLoginAttempts(user).
+					DoAndReturn(func(u *models.User) error {
+						u.FailedLoginAttempts = 1
+						return nil
+					})
 			},
 			expectedErr: "invalid email or password",
 		},
+		{
+			name:     "деактивированный пользователь",
+			email:    "inactive@example.com",
+			password: "password123",
+			setupMock: func() {
+				inactive := newTestUser(2, "inactive@example.com", "Inactive", userroles.RoleEngineer)
+				inactive.Password = hashed
+				inactive.Active = false
+				mockRepo.EXPECT().
+					GetUserByEmail("inactive@example.com").
+					Return(inactive, nil)
+			},
+			expectedErr: "account is deactivated",
+		},
 		{
 			name:     "пользователь не найден",
 			email:    "unknown@example.com",
@@ -217,33 +385,355 @@ func TestUserService_LoginUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
-			token, got, err := service.LoginUser(tt.email, tt.password)
+			result, err := service.LoginUser(tt.email, tt.password, testClient)
 
 			if tt.expectedErr != "" {
 				assert.ErrorContains(t, err, tt.expectedErr)
-				assert.Empty(t, token)
-				assert.Nil(t, got)
+				assert.Nil(t, result)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expected, got)
+				assert.Nil(t, result.MFA)
+				assert.Equal(t, tt.expected, result.User)
 
 				if tt.wantToken {
-					assert.NotEmpty(t, token)
+					tokens := result.Tokens
+					assert.NotEmpty(t, tokens.AccessToken)
+					assert.NotEmpty(t, tokens.RefreshToken)
 
-					parsed, err := utils.ParseToken(token, service.cfg)
+					parsed, err := utils.ParseToken(tokens.AccessToken, service.keys)
 					assert.NoError(t, err)
 					assert.True(t, parsed.Valid)
 
+					assert.Equal(t, "RS256", parsed.Method.Alg())
+					assert.Equal(t, "test", parsed.Header["kid"])
+
 					claims, ok := parsed.Claims.(jwt.MapClaims)
 					assert.True(t, ok)
 					assert.Equal(t, float64(1), claims["id"])
 					assert.Contains(t, claims["roles"], userroles.RoleEngineer)
+					assert.Contains(t, claims["permissions"], models.PermissionOrdersCreate)
 				}
 			}
 		})
 	}
 }
 
+func TestUserService_RefreshToken(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	refreshToken, _, err := utils.GenerateRefreshToken(*user, "family-1", "jti-1", service.cfg)
+	assert.NoError(t, err)
+
+	usedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		token       string
+		setupMock   func()
+		expectedErr string
+	}{
+		{
+			name:  "успешная ротация",
+			token: refreshToken,
+			setupMock: func() {
+				stored := &models.RefreshToken{ID: 1, JTI: "jti-1", FamilyID: "family-1", UserID: 1}
+				mockTokenRepo.EXPECT().GetRefreshTokenByJTI("jti-1").Return(stored, nil)
+				mockTokenRepo.EXPECT().MarkRefreshTokenUsed(stored).Return(true, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				session := &models.Session{ID: 1, UserID: 1, FamilyID: "family-1", IP: "10.0.0.1", LastUsedAt: time.Now().Add(-time.Hour)}
+				mockTokenRepo.EXPECT().GetSessionByFamilyID("family-1").Return(session, nil)
+				mockTokenRepo.EXPECT().
+					TouchSession(session).
+					DoAndReturn(func(session *models.Session) error {
+						assert.Equal(t, testClient.IP, session.IP)
+						assert.WithinDuration(t, time.Now(), session.LastUsedAt, 5*time.Second)
+						return nil
+					})
+				mockTokenRepo.EXPECT().
+					CreateRefreshToken(gomock.Any()).
+					DoAndReturn(func(token *models.RefreshToken) error {
+						assert.Equal(t, "family-1", token.FamilyID)
+						assert.NotEqual(t, "jti-1", token.JTI)
+						return nil
+					})
+			},
+		},
+		{
+			name:  "повторное использование отзывает семейство",
+			token: refreshToken,
+			setupMock: func() {
+				stored := &models.RefreshToken{ID: 1, JTI: "jti-1", FamilyID: "family-1", UserID: 1, UsedAt: &usedAt}
+				mockTokenRepo.EXPECT().GetRefreshTokenByJTI("jti-1").Return(stored, nil)
+				mockTokenRepo.EXPECT().RevokeRefreshTokenFamily("family-1").Return(nil)
+			},
+			expectedErr: "refresh token reuse detected",
+		},
+		{
+			name:  "гонка при ротации",
+			token: refreshToken,
+			setupMock: func() {
+				stored := &models.RefreshToken{ID: 1, JTI: "jti-1", FamilyID: "family-1", UserID: 1}
+				mockTokenRepo.EXPECT().GetRefreshTokenByJTI("jti-1").Return(stored, nil)
+				mockTokenRepo.EXPECT().MarkRefreshTokenUsed(stored).Return(false, nil)
+				mockTokenRepo.EXPECT().RevokeRefreshTokenFamily("family-1").Return(nil)
+			},
+			expectedErr: "refresh token reuse detected",
+		},
+		{
+			name:  "отозванное семейство",
+			token: refreshToken,
+			setupMock: func() {
+				revokedAt := time.Now()
+				stored := &models.RefreshToken{ID: 1, JTI: "jti-1", FamilyID: "family-1", UserID: 1, RevokedAt: &revokedAt}
+				mockTokenRepo.EXPECT().GetRefreshTokenByJTI("jti-1").Return(stored, nil)
+			},
+			expectedErr: "invalid refresh token",
+		},
+		{
+			name:        "невалидная подпись",
+			token:       refreshToken + "x",
+			setupMock:   func() {},
+			expectedErr: "invalid refresh token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			tokens, err := service.RefreshToken(tt.token, testClient)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				assert.Nil(t, tokens)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEmpty(t, tokens.RefreshToken)
+				assert.NotEqual(t, refreshToken, tokens.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestUserService_Logout(t *testing.T) {
+	service, _, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	accessToken, err := utils.GenerateToken(*user, nil, "family-1", service.keys, service.cfg)
+	assert.NoError(t, err)
+	refreshToken, _, err := utils.GenerateRefreshToken(*user, "family-1", "jti-1", service.cfg)
+	assert.NoError(t, err)
+	otherUser := newTestUser(2, "other@example.com", "Other User", userroles.RoleEngineer)
+	foreignRefreshToken, _, err := utils.GenerateRefreshToken(*otherUser, "family-2", "jti-2", service.cfg)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		accessToken  string
+		refreshToken string
+		setupMock    func()
+		expectedErr  string
+	}{
+		{
+			name:        "выход без refresh токена",
+			accessToken: accessToken,
+			setupMock: func() {
+				mockTokenRepo.EXPECT().
+					RevokeAccessToken(gomock.Any()).
+					DoAndReturn(func(token *models.RevokedToken) error {
+						assert.NotEmpty(t, token.JTI)
+						assert.Equal(t, uint(1), token.UserID)
+						assert.True(t, token.ExpiresAt.After(time.Now()))
+						return nil
+					})
+			},
+		},
+		{
+			name:         "выход с refresh токеном",
+			accessToken:  accessToken,
+			refreshToken: refreshToken,
+			setupMock: func() {
+				mockTokenRepo.EXPECT().RevokeAccessToken(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().RevokeRefreshTokenFamily("family-1").Return(nil)
+			},
+		},
+		{
+			name:         "чужой refresh токен не отзывается",
+			accessToken:  accessToken,
+			refreshToken: foreignRefreshToken,
+			setupMock: func() {
+				mockTokenRepo.EXPECT().RevokeAccessToken(gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:        "невалидный токен",
+			accessToken: "broken",
+			setupMock:   func() {},
+			expectedErr: "invalid token provided",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			err := service.Logout(tt.accessToken, tt.refreshToken)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserService_RevokeAllSessions(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+
+	mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+	mockRepo.EXPECT().IncrementTokenVersion(user).Return(nil)
+	mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+	assert.NoError(t, service.RevokeAllSessions(testAdmin, 1))
+
+	mockRepo.EXPECT().GetUserByID(uint(999)).Return((*models.User)(nil), assert.AnError)
+	assert.ErrorContains(t, service.RevokeAllSessions(testAdmin, 999), "user not found")
+}
+
+func TestUserService_IsAccessTokenRevoked(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	user.TokenVersion = 2
+
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name      string
+		version   int
+		sessionID string
+		clientID  string
+		setupMock func()
+		expected  bool
+	}{
+		{
+			name:    "действующий токен",
+			version: 2,
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expected: false,
+		},
+		{
+			name:    "токен в denylist",
+			version: 2,
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(true, nil)
+			},
+			expected: true,
+		},
+		{
+			name:    "устаревшая версия",
+			version: 1,
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expected: true,
+		},
+		{
+			name:    "деактивированный пользователь",
+			version: 2,
+			setupMock: func() {
+				inactive := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+				inactive.TokenVersion = 2
+				inactive.Active = false
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(inactive, nil)
+			},
+			expected: true,
+		},
+		{
+			name:      "действующая сессия",
+			version:   2,
+			sessionID: "family-1",
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockTokenRepo.EXPECT().
+					GetSessionByFamilyID("family-1").
+					Return(&models.Session{ID: 1, UserID: 1, FamilyID: "family-1", LastUsedAt: time.Now()}, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expected: false,
+		},
+		{
+			name:      "отозванная сессия",
+			version:   2,
+			sessionID: "family-1",
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockTokenRepo.EXPECT().
+					GetSessionByFamilyID("family-1").
+					Return(&models.Session{ID: 1, UserID: 1, FamilyID: "family-1", RevokedAt: &revokedAt}, nil)
+			},
+			expected: true,
+		},
+		{
+			name:     "действующий OAuth-клиент",
+			version:  2,
+			clientID: "client-1",
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockTokenRepo.EXPECT().
+					GetOAuthClientByClientID("client-1").
+					Return(&models.OAuthClient{ID: 1, UserID: 1, ClientID: "client-1"}, nil)
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expected: false,
+		},
+		{
+			name:     "отозванный OAuth-клиент",
+			version:  2,
+			clientID: "client-1",
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockTokenRepo.EXPECT().
+					GetOAuthClientByClientID("client-1").
+					Return(&models.OAuthClient{ID: 1, UserID: 1, ClientID: "client-1", RevokedAt: &revokedAt}, nil)
+			},
+			expected: true,
+		},
+		{
+			name:     "удалённый OAuth-клиент",
+			version:  2,
+			clientID: "client-1",
+			setupMock: func() {
+				mockTokenRepo.EXPECT().IsAccessTokenRevoked("jti").Return(false, nil)
+				mockTokenRepo.EXPECT().
+					GetOAuthClientByClientID("client-1").
+					Return((*models.OAuthClient)(nil), assert.AnError)
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			revoked, err := service.IsAccessTokenRevoked("jti", 1, tt.version, tt.sessionID, tt.clientID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, revoked)
+		})
+	}
+}
+
 func TestUserService_GetUserByID(t *testing.T) {
 	service, mockRepo, finish := setupTest(t)
 	defer finish()
@@ -264,10 +754,11 @@ func TestUserService_GetUserByID(t *testing.T) {
 				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
 			},
 			expected: &UserResponse{
-				ID:    1,
-				Email: "test@example.com",
-				Name:  "Test User",
-				Roles: []string{userroles.RoleEngineer},
+				ID:     1,
+				Email:  "test@example.com",
+				Name:   "Test User",
+				Roles:  []string{userroles.RoleEngineer},
+				Active: true,
 			},
 		},
 		{
@@ -295,6 +786,74 @@ func TestUserService_GetUserByID(t *testing.T) {
 	}
 }
 
+func TestUserService_LookupUsers(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	active := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+	inactive := newTestUser(2, "old@example.com", "Old User", userroles.RoleEngineer)
+	inactive.Active = false
+
+	tooMany := make([]uint, maxLookupIDs+1)
+	for i := range tooMany {
+		tooMany[i] = uint(i + 1)
+	}
+
+	tests := []struct {
+		name        string
+		ids         []uint
+		setupMock   func()
+		expected    []UserSummary
+		expectedErr string
+	}{
+		{
+			name: "неизвестные ID пропускаются",
+			ids:  []uint{1, 2, 999},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUsersByIDs([]uint{1, 2, 999}).Return([]models.User{*active, *inactive}, nil)
+			},
+			expected: []UserSummary{
+				{ID: 1, Name: "Test User", Email: "test@example.com", Active: true},
+				{ID: 2, Name: "Old User", Email: "old@example.com", Active: false},
+			},
+		},
+		{
+			name:      "пустой список",
+			ids:       nil,
+			setupMock: func() {},
+			expected:  []UserSummary{},
+		},
+		{
+			name:        "слишком много ID",
+			ids:         tooMany,
+			setupMock:   func() {},
+			expectedErr: "at most 100 ids",
+		},
+		{
+			name: "ошибка репозитория",
+			ids:  []uint{1},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUsersByIDs([]uint{1}).Return(nil, assert.AnError)
+			},
+			expectedErr: "failed to look up users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			got, err := service.LookupUsers(tt.ids)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
 func TestUserService_UpdateUser(t *testing.T) {
 	service, mockRepo, finish := setupTest(t)
 	defer finish()
@@ -316,7 +875,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 			},
 			setupMock: func() {
 				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
-				mockRepo.EXPECT().UpdateUser(user, gomock.Any()).DoAndReturn(func(u *models.User, updates map[string]interface{}) error {
+				mockRepo.EXPECT().UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).DoAndReturn(func(u *models.User, updates map[string]interface{}, _ *models.AuditEntry, _ bool) error {
 					if name, ok := updates["name"]; ok {
 						u.Name = name.(string)
 					}
@@ -327,10 +886,11 @@ func TestUserService_UpdateUser(t *testing.T) {
 				})
 			},
 			expected: &UserResponse{
-				ID:    1,
-				Email: "test@example.com",
-				Name:  "Newname",
-				Roles: []string{userroles.RoleEngineer},
+				ID:     1,
+				Email:  "test@example.com",
+				Name:   "Newname",
+				Roles:  []string{userroles.RoleEngineer},
+				Active: true,
 			},
 		},
 		{
@@ -351,10 +911,11 @@ func TestUserService_UpdateUser(t *testing.T) {
 				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
 			},
 			expected: &UserResponse{
-				ID:    1,
-				Email: "test@example.com",
-				Name:  "Newname",
-				Roles: []string{userroles.RoleEngineer},
+				ID:     1,
+				Email:  "test@example.com",
+				Name:   "Newname",
+				Roles:  []string{userroles.RoleEngineer},
+				Active: true,
 			},
 		},
 	}
@@ -362,7 +923,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMock()
-			got, err := service.UpdateUser(1, tt.input)
+			got, err := service.UpdateUser(testAdmin, 1, tt.input)
 
 			if tt.expectedErr != "" {
 				assert.ErrorContains(t, err, tt.expectedErr)
@@ -377,6 +938,213 @@ func TestUserService_UpdateUser(t *testing.T) {
 	}
 }
 
+func TestUserService_UpdateProfile(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	newName := "Newname"
+	phone := " +7 900 000-00-00 "
+	empty := "  "
+
+	tests := []struct {
+		name        string
+		input       UpdateProfileInput
+		setupMock   func(user *models.User)
+		expected    *UserResponse
+		expectedErr string
+	}{
+		{
+			name:  "обновление имени и телефона",
+			input: UpdateProfileInput{Name: &newName, Phone: &phone},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().UpdateUser(user, map[string]interface{}{
+					"name":  "Newname",
+					"phone": "+7 900 000-00-00",
+				}).DoAndReturn(func(u *models.User, updates map[string]interface{}) error {
+					u.Name = updates["name"].(string)
+					u.Phone = updates["phone"].(string)
+					return nil
+				})
+			},
+			expected: &UserResponse{
+				ID:     1,
+				Email:  "test@example.com",
+				Name:   "Newname",
+				Roles:  []string{userroles.RoleEngineer},
+				Phone:  "+7 900 000-00-00",
+				Active: true,
+			},
+		},
+		{
+			name:  "пустое имя",
+			input: UpdateProfileInput{Name: &empty},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expectedErr: "name cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(1, "test@example.com", "Oldname", userroles.RoleEngineer)
+			tt.setupMock(user)
+			got, err := service.UpdateProfile(1, tt.input)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	service, mockRepo, mockTokenRepo, finish := setupAuthTest(t)
+	defer finish()
+
+	hashed := hashedPassword(t, "password123")
+
+	tests := []struct {
+		name        string
+		input       ChangePasswordInput
+		setupMock   func(user *models.User)
+		expectedErr string
+	}{
+		{
+			name:  "успешная смена пароля",
+			input: ChangePasswordInput{OldPassword: "password123", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().
+					UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}, entry *models.AuditEntry, _ bool) error {
+						hash := updates["password"].(string)
+						assert.True(t, passwordMatches(t, hash, "newpassword123"))
+						assert.Equal(t, AuditPasswordChanged, entry.Action)
+						return nil
+					})
+				// The repository reloads the version into the user it is given.
+				mockRepo.EXPECT().
+					IncrementTokenVersion(user).
+					DoAndReturn(func(u *models.User) error {
+						u.TokenVersion++
+						return nil
+					})
+				mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+				mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:  "неверный старый пароль",
+			input: ChangePasswordInput{OldPassword: "wrong", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().
+					IncrementFailedLoginAttempts(user).
+					DoAndReturn(func(u *models.User) error {
+						u.FailedLoginAttempts = 1
+						return nil
+					})
+			},
+			expectedErr: "invalid old password",
+		},
+		{
+			name:  "неверный старый пароль блокирует аккаунт",
+			input: ChangePasswordInput{OldPassword: "wrong", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				user.FailedLoginAttempts = 4
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().
+					IncrementFailedLoginAttempts(user).
+					DoAndReturn(func(u *models.User) error {
+						u.FailedLoginAttempts++
+						return nil
+					})
+				mockRepo.EXPECT().
+					UpdateUser(user, gomock.Any()).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}) error {
+						assert.Equal(t, 1, updates["lockout_count"])
+						assert.IsType(t, time.Time{}, updates["locked_until"])
+						return nil
+					})
+			},
+			expectedErr: "invalid old password",
+		},
+		{
+			name:  "заблокированный аккаунт",
+			input: ChangePasswordInput{OldPassword: "password123", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				lockedUntil := time.Now().Add(time.Minute)
+				user.LockedUntil = &lockedUntil
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expectedErr: "account is locked",
+		},
+		{
+			name:  "успешная смена сбрасывает неудачные попытки",
+			input: ChangePasswordInput{OldPassword: "password123", NewPassword: "newpassword123"},
+			setupMock: func(user *models.User) {
+				user.FailedLoginAttempts = 2
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+				mockRepo.EXPECT().
+					UpdateUserAudited(user, gomock.Any(), gomock.Any(), false).
+					DoAndReturn(func(u *models.User, updates map[string]interface{}, entry *models.AuditEntry, _ bool) error {
+						assert.Equal(t, 0, updates["failed_login_attempts"])
+						assert.Contains(t, updates, "locked_until")
+						return nil
+					})
+				mockRepo.EXPECT().
+					IncrementTokenVersion(user).
+					DoAndReturn(func(u *models.User) error {
+						u.TokenVersion++
+						return nil
+					})
+				mockTokenRepo.EXPECT().RevokeUserRefreshTokens(uint(1)).Return(nil)
+				mockTokenRepo.EXPECT().CreateSession(gomock.Any()).Return(nil)
+				mockTokenRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:  "короткий новый пароль",
+			input: ChangePasswordInput{OldPassword: "password123", NewPassword: "123"},
+			setupMock: func(user *models.User) {
+				mockRepo.EXPECT().GetUserByID(uint(1)).Return(user, nil)
+			},
+			expectedErr: "password must be at least 8 characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(1, "test@example.com", "Test User", userroles.RoleEngineer)
+			user.Password = hashed
+			user.TokenVersion = 3
+			tt.setupMock(user)
+			tokens, err := service.ChangePassword(1, tt.input, testClient, "")
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				assert.Nil(t, tokens)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEmpty(t, tokens.RefreshToken)
+
+				// A token with the old version would be rejected as revoked.
+				parsed, err := utils.ParseToken(tokens.AccessToken, service.keys)
+				assert.NoError(t, err)
+				claims := parsed.Claims.(jwt.MapClaims)
+				assert.Equal(t, float64(4), claims["ver"])
+			}
+		})
+	}
+}
+
 func TestUserService_GetUsers(t *testing.T) {
 	service, mockRepo, finish := setupTest(t)
 	defer finish()
@@ -385,6 +1153,8 @@ func TestUserService_GetUsers(t *testing.T) {
 		*newTestUser(1, "a@example.com", "A", userroles.RoleEngineer),
 		*newTestUser(2, "b@example.com", "B", userroles.RoleManager),
 	}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC)
 
 	tests := []struct {
 		name        string
@@ -397,16 +1167,81 @@ func TestUserService_GetUsers(t *testing.T) {
 			name:  "успешно",
 			input: UserListInput{Page: 1, Limit: 10},
 			setupMock: func() {
-				mockRepo.EXPECT().GetUsers(1, 10, "", "").Return(users, int64(2), nil)
+				mockRepo.EXPECT().GetUsers(0, 10, repositories.UserFilter{}, repositories.UserSort{}).Return(users, int64(2), nil)
+			},
+			expectedLen: 2,
+		},
+		{
+			name:  "фильтр по состоянию",
+			input: UserListInput{Page: 1, Limit: 10, StateFilter: "deleted"},
+			setupMock: func() {
+				mockRepo.EXPECT().
+					GetUsers(0, 10, repositories.UserFilter{State: repositories.UserStateDeleted}, repositories.UserSort{}).
+					Return(users, int64(2), nil)
 			},
 			expectedLen: 2,
 		},
+		{
+			name: "поиск по имени, всем ролям и датам с сортировкой",
+			input: UserListInput{
+				Page: 1, Limit: 10,
+				NameFilter:  " Ivan ",
+				RolesFilter: []string{userroles.RoleEngineer, userroles.RoleManager},
+				RoleMatch:   repositories.RoleMatchAll,
+				CreatedFrom: &from,
+				CreatedTo:   &to,
+				SortBy:      repositories.UserSortName,
+				SortOrder:   "desc",
+			},
+			setupMock: func() {
+				mockRepo.EXPECT().
+					GetUsers(0, 10, repositories.UserFilter{
+						Name:        "Ivan",
+						Roles:       []string{userroles.RoleEngineer, userroles.RoleManager},
+						RoleMatch:   repositories.RoleMatchAll,
+						CreatedFrom: &from,
+						CreatedTo:   &to,
+					}, repositories.UserSort{Field: repositories.UserSortName, Desc: true}).
+					Return(users, int64(2), nil)
+			},
+			expectedLen: 2,
+		},
+		{
+			name:        "невалидный режим ролей",
+			input:       UserListInput{Page: 1, Limit: 10, RoleMatch: "some"},
+			setupMock:   func() {},
+			expectedErr: "invalid role match",
+		},
+		{
+			name:        "невалидная сортировка",
+			input:       UserListInput{Page: 1, Limit: 10, SortBy: "password"},
+			setupMock:   func() {},
+			expectedErr: "invalid sort",
+		},
+		{
+			name:        "невалидный порядок",
+			input:       UserListInput{Page: 1, Limit: 10, SortBy: repositories.UserSortEmail, SortOrder: "up"},
+			setupMock:   func() {},
+			expectedErr: "invalid sort",
+		},
+		{
+			name:        "начало диапазона позже конца",
+			input:       UserListInput{Page: 1, Limit: 10, UpdatedFrom: &to, UpdatedTo: &from},
+			setupMock:   func() {},
+			expectedErr: "invalid date range",
+		},
 		{
 			name:        "невалидная страница",
 			input:       UserListInput{Page: 0, Limit: 10},
 			setupMock:   func() {},
 			expectedErr: "invalid page number",
 		},
+		{
+			name:        "невалидное состояние",
+			input:       UserListInput{Page: 1, Limit: 10, StateFilter: "banned"},
+			setupMock:   func() {},
+			expectedErr: "invalid state filter",
+		},
 	}
 
 	for _, tt := range tests {
@@ -424,3 +1259,131 @@ func TestUserService_GetUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_GetUsersByCursor(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	users := []models.User{
+		*newTestUser(1, "a@example.com", "A", userroles.RoleEngineer),
+		*newTestUser(2, "b@example.com", "B", userroles.RoleManager),
+		*newTestUser(3, "c@example.com", "C", userroles.RoleManager),
+	}
+	byName := repositories.UserSort{Field: repositories.UserSortName}
+	nameCursor := pagination.EncodeCursor(repositories.UserSortName, false, 2, "B")
+	total := int64(3)
+
+	tests := []struct {
+		name          string
+		input         UserListInput
+		setupMock     func()
+		expectedIDs   []uint
+		expectedNext  string
+		expectedTotal *int64
+		expectedErr   string
+	}{
+		{
+			name:  "первая страница",
+			input: UserListInput{Limit: 2, SortBy: repositories.UserSortName},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUsersAfter(nil, 3, repositories.UserFilter{}, byName).Return(users, nil)
+			},
+			expectedIDs:  []uint{1, 2},
+			expectedNext: nameCursor,
+		},
+		{
+			name:  "следующая страница по курсору",
+			input: UserListInput{Limit: 2, SortBy: repositories.UserSortName, Cursor: nameCursor},
+			setupMock: func() {
+				mockRepo.EXPECT().
+					GetUsersAfter(&repositories.UserCursor{ID: 2, Value: "B"}, 3, repositories.UserFilter{}, byName).
+					Return(users[2:], nil)
+			},
+			expectedIDs: []uint{3},
+		},
+		{
+			name:  "по ID с подсчётом",
+			input: UserListInput{Limit: 2, StateFilter: "all", Cursor: pagination.EncodeCursor("", false, 1, nil), IncludeTotal: true},
+			setupMock: func() {
+				filter := repositories.UserFilter{State: repositories.UserStateAll}
+				mockRepo.EXPECT().GetUsersAfter(&repositories.UserCursor{ID: 1}, 3, filter, repositories.UserSort{}).Return(users[1:], nil)
+				mockRepo.EXPECT().CountUsers(filter).Return(total, nil)
+			},
+			expectedIDs:   []uint{2, 3},
+			expectedTotal: &total,
+		},
+		{
+			name:        "испорченный курсор",
+			input:       UserListInput{Limit: 2, Cursor: "not-a-cursor"},
+			setupMock:   func() {},
+			expectedErr: "invalid cursor",
+		},
+		{
+			name:        "курсор другой сортировки",
+			input:       UserListInput{Limit: 2, SortBy: repositories.UserSortEmail, Cursor: nameCursor},
+			setupMock:   func() {},
+			expectedErr: "invalid cursor",
+		},
+		{
+			name:        "курсор без значения сортировки",
+			input:       UserListInput{Limit: 2, SortBy: repositories.UserSortCreatedAt, Cursor: pagination.EncodeCursor(repositories.UserSortCreatedAt, false, 2, nil)},
+			setupMock:   func() {},
+			expectedErr: "invalid cursor",
+		},
+		{
+			name:  "лимит больше максимального",
+			input: UserListInput{Limit: 500},
+			setupMock: func() {
+				mockRepo.EXPECT().GetUsersAfter(nil, pagination.MaxLimit+1, repositories.UserFilter{}, repositories.UserSort{}).Return(users, nil)
+			},
+			expectedIDs: []uint{1, 2, 3},
+		},
+		{
+			name:        "невалидный лимит",
+			input:       UserListInput{Limit: 0},
+			setupMock:   func() {},
+			expectedErr: "invalid limit value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			result, err := service.GetUsersByCursor(tt.input)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]uint, 0, len(result.Users))
+			for _, user := range result.Users {
+				ids = append(ids, user.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, tt.expectedNext, result.NextCursor)
+			assert.Equal(t, tt.expectedTotal, result.Total)
+		})
+	}
+}
+
+func TestUserService_GetUsersByCursor_CreatedAt(t *testing.T) {
+	service, mockRepo, finish := setupTest(t)
+	defer finish()
+
+	created := time.Date(2025, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	users := []models.User{*newTestUser(1, "a@example.com", "A"), *newTestUser(2, "b@example.com", "B")}
+	users[0].CreatedAt = created
+	sort := repositories.UserSort{Field: repositories.UserSortCreatedAt, Desc: true}
+
+	mockRepo.EXPECT().GetUsersAfter(nil, 2, repositories.UserFilter{}, sort).Return(users, nil)
+	first, err := service.GetUsersByCursor(UserListInput{Limit: 1, SortBy: "created_at", SortOrder: "desc"})
+	assert.NoError(t, err)
+
+	mockRepo.EXPECT().
+		GetUsersAfter(&repositories.UserCursor{ID: 1, Value: created}, 2, repositories.UserFilter{}, sort).
+		Return(users[1:], nil)
+	second, err := service.GetUsersByCursor(UserListInput{Limit: 1, SortBy: "created_at", SortOrder: "desc", Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Empty(t, second.NextCursor)
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpiritFoxo/control-system-microservices/service-users/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKey(t *testing.T, dir, kid string, key *SigningKey, publicOnly bool) {
+	var block *pem.Block
+	if publicOnly {
+		der, err := x509.MarshalPKIXPublicKey(key.Public)
+		assert.NoError(t, err)
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+		assert.NoError(t, err)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600))
+}
+
+func TestLoadKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldKey, err := GenerateSigningKey("2024-01", "RS256")
+	assert.NoError(t, err)
+	newKey, err := GenerateSigningKey("2024-02", "EdDSA")
+	assert.NoError(t, err)
+	writeKey(t, dir, "2024-01", oldKey, true)
+	writeKey(t, dir, "2024-02", newKey, false)
+
+	oldSet, err := NewKeySet(oldKey.ID, oldKey)
+	assert.NoError(t, err)
+	oldToken, err := oldSet.Sign(jwt.MapClaims{"id": 1})
+	assert.NoError(t, err)
+
+	keys, err := LoadKeySet(&config.Config{JWTKeysDir: dir})
+	assert.NoError(t, err)
+
+	newToken, err := keys.Sign(jwt.MapClaims{"id": 2})
+	assert.NoError(t, err)
+
+	parsed, err := ParseToken(newToken, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	assert.Equal(t, "2024-02", parsed.Header["kid"])
+
+	parsed, err = ParseToken(oldToken, keys)
+	assert.NoError(t, err, "tokens signed by a retired key stay valid while it is published")
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+
+	jwks := keys.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+
+	_, err = LoadKeySet(&config.Config{JWTKeysDir: dir, JWTActiveKid: "2024-01"})
+	assert.ErrorContains(t, err, "has no private key")
+}
+
+func TestParseToken_RejectsForeignKeys(t *testing.T) {
+	key, err := GenerateSigningKey("a", "RS256")
+	assert.NoError(t, err)
+	keys, err := NewKeySet(key.ID, key)
+	assert.NoError(t, err)
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = ParseToken(hmacToken, keys)
+	assert.Error(t, err)
+
+	other, err := GenerateSigningKey("a", "RS256")
+	assert.NoError(t, err)
+	otherSet, err := NewKeySet(other.ID, other)
+	assert.NoError(t, err)
+	forged, err := otherSet.Sign(jwt.MapClaims{"id": 1})
+	assert.NoError(t, err)
+	_, err = ParseToken(forged, keys)
+	assert.Error(t, err)
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strconv"
@@ -13,42 +17,107 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(user models.User, cfg *config.Config) (string, error) {
+func GenerateToken(user models.User, permissions []string, sessionID string, keys *KeySet, cfg *config.Config) (string, error) {
+	claims, _, err := accessTokenClaims(user, permissions, cfg)
+	if err != nil {
+		return "", err
+	}
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
+	return keys.Sign(claims)
+}
+
+// GenerateClientToken signs an access token for a client_credentials grant.
+// The token has no session; cid names the client it was issued to.
+func GenerateClientToken(user models.User, permissions []string, clientID string, keys *KeySet, cfg *config.Config) (string, time.Time, error) {
+	claims, expiresAt, err := accessTokenClaims(user, permissions, cfg)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claims["cid"] = clientID
+	signed, err := keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
 
+func accessTokenClaims(user models.User, permissions []string, cfg *config.Config) (jwt.MapClaims, time.Time, error) {
 	tokenLifespan, err := strconv.Atoi(strings.TrimSpace(cfg.TokenMinuteLifespan))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 
+	jti, err := RandomString(16)
 	if err != nil {
-		return "", err
+		return nil, time.Time{}, err
 	}
 
+	now := time.Now()
+	expiresAt := now.Add(time.Minute * time.Duration(tokenLifespan))
+
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["id"] = user.ID
 	claims["roles"] = user.Roles
-	claims["exp"] = time.Now().Add(time.Minute * time.Duration(tokenLifespan)).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString([]byte(cfg.TokenSecret))
-
+	claims["permissions"] = permissions
+	claims["jti"] = jti
+	claims["ver"] = user.TokenVersion
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	return claims, expiresAt, nil
 }
 
-func GenerateRefreshToken(user models.User, cfg *config.Config) (string, error) {
+func GenerateRefreshToken(user models.User, familyID, jti string, cfg *config.Config) (string, time.Time, error) {
 	tokenLifespan, err := strconv.Atoi(strings.TrimSpace(cfg.RefreshTokenHourLifespan))
 	if err != nil {
 		tokenLifespan = 72
 		fmt.Println("Warning: REFRESH_TOKEN_HOUR_LIFESPAN not set or invalid. Using default 72 hours.")
 	}
 
+	expiresAt := time.Now().Add(time.Hour * time.Duration(tokenLifespan))
+
 	claims := jwt.MapClaims{}
 	claims["id"] = user.ID
-	claims["roles"] = user.Roles
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(tokenLifespan)).Unix()
+	claims["fam"] = familyID
+	claims["jti"] = jti
+	claims["exp"] = expiresAt.Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.RefreshTokenSecret))
+
+	signed, err := token.SignedString([]byte(cfg.RefreshTokenSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
 
-func ValidateToken(c *gin.Context, cfg *config.Config) error {
-	token, err := GetToken(c, cfg)
+func ParseRefreshToken(tokenString string, cfg *config.Config) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(cfg.RefreshTokenSecret), nil
+	})
+}
+
+// HashToken returns the hex SHA-256 of an opaque token for storage.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomString returns a URL-safe random string built from n random bytes.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func ValidateToken(c *gin.Context, keys *KeySet) error {
+	token, err := GetToken(c, keys)
 
 	if err != nil {
 		return err
@@ -62,16 +131,9 @@ func ValidateToken(c *gin.Context, cfg *config.Config) error {
 	return errors.New("invalid token provided")
 }
 
-func GetToken(c *gin.Context, cfg *config.Config) (*jwt.Token, error) {
+func GetToken(c *gin.Context, keys *KeySet) (*jwt.Token, error) {
 	tokenString := getTokenFromRequest(c)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		return []byte(cfg.TokenSecret), nil
-	})
-	return token, err
+	return ParseToken(tokenString, keys)
 }
 
 func getTokenFromRequest(c *gin.Context) string {
@@ -84,11 +146,6 @@ func getTokenFromRequest(c *gin.Context) string {
 	return ""
 }
 
-func ParseToken(tokenString string, cfg *config.Config) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(cfg.TokenSecret), nil
-	})
+func ParseToken(tokenString string, keys *KeySet) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, keys.Keyfunc)
 }
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
+	"github.com/gin-gonic/gin"
+)
+
+// InternalTokenHeader carries the secret shared by the services on their
+// service-to-service calls.
+const InternalTokenHeader = "X-Internal-Token"
+
+// InternalAuth admits only requests that present the shared secret, so that
+// internal endpoints stay closed even on a port that is published. An empty
+// secret admits nobody.
+func InternalAuth(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(InternalTokenHeader)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			problems.Abort(c, http.StatusUnauthorized, "unauthenticated", "missing or invalid "+InternalTokenHeader)
+			return
+		}
+		c.Next()
+	}
+}
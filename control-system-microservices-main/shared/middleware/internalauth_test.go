@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInternalAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		secret   string
+		token    string
+		expected int
+	}{
+		{name: "верный секрет", secret: "s3cret", token: "s3cret", expected: http.StatusOK},
+		{name: "неверный секрет", secret: "s3cret", token: "guess", expected: http.StatusUnauthorized},
+		{name: "нет заголовка", secret: "s3cret", expected: http.StatusUnauthorized},
+		{name: "секрет не настроен", expected: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", InternalAuth(tt.secret), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set(InternalTokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Fatalf("status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
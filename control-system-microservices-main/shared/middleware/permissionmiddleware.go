@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SpiritFoxo/control-system-microservices/shared/problems"
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions returns the permissions the gateway forwarded in
+// X-User-Permissions, leaving out blanks.
+func Permissions(c *gin.Context) []string {
+	var permissions []string
+	for _, p := range strings.Split(c.GetHeader("X-User-Permissions"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+// HasPermission tells whether the caller holds the permission.
+func HasPermission(c *gin.Context, permission string) bool {
+	for _, p := range Permissions(c) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionMiddleware lets a request through only when the caller holds
+// every required permission. No role is special: an admin gets in because
+// their role grants the permission.
+func PermissionMiddleware(required ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// A user whose roles grant nothing gets no header at all, so only a
+		// request without a user is unauthenticated.
+		granted := Permissions(c)
+		if len(granted) == 0 && c.GetHeader("X-User-ID") == "" {
+			problems.Abort(c, http.StatusUnauthorized, "unauthenticated", "missing X-User-ID")
+			return
+		}
+
+		for _, permission := range required {
+			found := false
+			for _, p := range granted {
+				if p == permission {
+					found = true
+					break
+				}
+			}
+			if !found {
+				problems.Abort(c, http.StatusForbidden, "missing_permission", "missing permission "+permission)
+				return
+			}
+		}
+		c.Next()
+	}
+}
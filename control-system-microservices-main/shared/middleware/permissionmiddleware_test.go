@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPermissionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", PermissionMiddleware("orders:read", "orders:delete"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name        string
+		userID      string
+		permissions string
+		expected    int
+	}{
+		{name: "все права есть", userID: "1", permissions: "orders:read, orders:delete,orders:create", expected: http.StatusOK},
+		{name: "не хватает одного права", userID: "1", permissions: "orders:read", expected: http.StatusForbidden},
+		{name: "роли без прав", userID: "1", permissions: " , ", expected: http.StatusForbidden},
+		{name: "нет пользователя", expected: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.userID != "" {
+				req.Header.Set("X-User-ID", tt.userID)
+			}
+			if tt.permissions != "" {
+				req.Header.Set("X-User-Permissions", tt.permissions)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Fatalf("status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package pagination holds the keyset pagination shared by the services.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// MaxLimit bounds the page size of a keyset page. Larger limits are lowered
+// to it.
+const MaxLimit = 100
+
+// ErrInvalidCursor is returned for a cursor that cannot be read or was issued
+// for another sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is where a keyset page continues: the sort the list was requested
+// with and the sort key of the last row returned. Clients get it
+// base64-encoded and should treat it as opaque.
+type Cursor struct {
+	Sort string          `json:"s,omitempty"`
+	Desc bool            `json:"d,omitempty"`
+	ID   uint            `json:"id"`
+	Key  json.RawMessage `json:"v,omitempty"`
+}
+
+// EncodeCursor builds the cursor of the page after the row with the given ID
+// and sort key. A nil key means the list is sorted by ID alone.
+func EncodeCursor(sort string, desc bool, id uint, key interface{}) string {
+	cursor := Cursor{Sort: sort, Desc: desc, ID: id}
+	if key != nil {
+		cursor.Key, _ = json.Marshal(key)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor and checks that it was issued for the same
+// sort, since a position in one order means nothing in another.
+func DecodeCursor(raw, sort string, desc bool) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Desc != desc {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// ReadKey reads the sort key of the cursor into target.
+func (c *Cursor) ReadKey(target interface{}) error {
+	if len(c.Key) == 0 || json.Unmarshal(c.Key, target) != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	byDate := EncodeCursor("created_at", true, 7, createdAt)
+
+	tests := []struct {
+		name    string
+		raw     string
+		sort    string
+		desc    bool
+		wantErr bool
+	}{
+		{name: "тот же порядок", raw: byDate, sort: "created_at", desc: true},
+		{name: "другое поле сортировки", raw: byDate, sort: "name", desc: true, wantErr: true},
+		{name: "другое направление", raw: byDate, sort: "created_at", wantErr: true},
+		{name: "не base64", raw: "not a cursor!", sort: "created_at", desc: true, wantErr: true},
+		{name: "без ID", raw: EncodeCursor("", false, 0, nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(tt.raw, tt.sort, tt.desc)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Fatalf("expected ErrInvalidCursor, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var key time.Time
+			if err := cursor.ReadKey(&key); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cursor.ID != 7 || !key.Equal(createdAt) {
+				t.Fatalf("got id %d key %v", cursor.ID, key)
+			}
+		})
+	}
+}
+
+func TestCursor_ReadKey_Missing(t *testing.T) {
+	cursor, err := DecodeCursor(EncodeCursor("", false, 3, nil), "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var key string
+	if err := cursor.ReadKey(&key); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
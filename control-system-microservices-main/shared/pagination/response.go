@@ -0,0 +1,16 @@
+package pagination
+
+import "github.com/gin-gonic/gin"
+
+// Response describes a keyset page. next_cursor is null on the last page,
+// and total is only present when it was counted.
+func Response(limit int, next string, total *int64) gin.H {
+	pagination := gin.H{"limit": limit, "next_cursor": nil}
+	if next != "" {
+		pagination["next_cursor"] = next
+	}
+	if total != nil {
+		pagination["total"] = *total
+	}
+	return pagination
+}
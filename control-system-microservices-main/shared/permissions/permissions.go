@@ -0,0 +1,20 @@
+// Package permissions names the permissions roles can grant. The services
+// check these names, so a role is only a set of them and new roles need no
+// code change.
+package permissions
+
+const (
+	UsersRead     = "users:read"
+	UsersWrite    = "users:write"
+	RolesManage   = "roles:manage"
+	ClientsManage = "clients:manage"
+	AuditRead     = "audit:read"
+
+	OrdersRead         = "orders:read"
+	OrdersReadAll      = "orders:read_all"
+	OrdersCreate       = "orders:create"
+	OrdersUpdateStatus = "orders:update_status"
+	OrdersCancel       = "orders:cancel"
+	OrdersCancelAll    = "orders:cancel_all"
+	OrdersDelete       = "orders:delete"
+)
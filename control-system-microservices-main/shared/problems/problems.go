@@ -0,0 +1,124 @@
+// Package problems writes RFC 7807 error responses in the one format the
+// gateway and the services share.
+package problems
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ContentType = "application/problem+json"
+	TypePrefix  = "urn:control-system:problem:"
+)
+
+// Problem is an RFC 7807 error response. Code is stable and meant for
+// programs; Detail is meant for people.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError names an input field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New describes a failed request.
+func New(r *http.Request, status int, code, detail string, fields ...FieldError) Problem {
+	return Problem{
+		Type:      TypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: r.Header.Get("X-Request-ID"),
+		Errors:    fields,
+	}
+}
+
+// Abort ends a gin request with a problem response.
+func Abort(c *gin.Context, status int, code, detail string, fields ...FieldError) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, New(c.Request, status, code, detail, fields...))
+}
+
+// Write writes a problem response outside of gin, for example from a reverse
+// proxy's ErrorHandler.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(New(r, status, code, detail))
+}
+
+// Report validation failures under the JSON names clients actually send.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// Binding explains why a request body could not be bound, listing every
+// field that failed validation.
+func Binding(err error) (code, detail string, fields []FieldError) {
+	var validation validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validation):
+		for _, field := range validation {
+			fields = append(fields, FieldError{
+				Field:   field.Field(),
+				Message: validationMessage(field),
+			})
+		}
+		return "validation_failed", "request body failed validation", fields
+	case errors.As(err, &typeErr):
+		return "invalid_body", "invalid request body", []FieldError{{
+			Field:   typeErr.Field,
+			Message: "must be " + typeErr.Type.String(),
+		}}
+	default:
+		return "invalid_body", "invalid request body", nil
+	}
+}
+
+func validationMessage(field validator.FieldError) string {
+	switch field.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email"
+	case "min":
+		return "must be at least " + field.Param()
+	case "max":
+		return "must be at most " + field.Param()
+	case "oneof":
+		return "must be one of " + field.Param()
+	default:
+		return "is invalid"
+	}
+}
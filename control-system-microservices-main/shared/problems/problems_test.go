@@ -0,0 +1,95 @@
+package problems
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbort(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/users/1", nil)
+	c.Request.Header.Set("X-Request-ID", "req-1")
+
+	Abort(c, http.StatusForbidden, "missing_permission", "missing permission users:read")
+
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("content type %q, want %q", got, ContentType)
+	}
+	var body Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	expected := Problem{
+		Type:      TypePrefix + "missing_permission",
+		Title:     "Forbidden",
+		Status:    http.StatusForbidden,
+		Detail:    "missing permission users:read",
+		Instance:  "/users/1",
+		Code:      "missing_permission",
+		RequestID: "req-1",
+	}
+	if !reflect.DeepEqual(body, expected) || !c.IsAborted() {
+		t.Fatalf("got %+v, want %+v", body, expected)
+	}
+}
+
+func TestBinding(t *testing.T) {
+	type input struct {
+		Email string `json:"email" binding:"required,email"`
+		Name  string `json:"name" binding:"required,max=3"`
+		Age   int    `json:"age"`
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedCode   string
+		expectedFields []FieldError
+	}{
+		{
+			name:         "ошибки валидации под JSON-именами",
+			body:         `{"email":"nope","name":"long"}`,
+			expectedCode: "validation_failed",
+			expectedFields: []FieldError{
+				{Field: "email", Message: "must be a valid email"},
+				{Field: "name", Message: "must be at most 3"},
+			},
+		},
+		{
+			name:           "неверный тип поля",
+			body:           `{"email":"a@b.c","name":"ab","age":"x"}`,
+			expectedCode:   "invalid_body",
+			expectedFields: []FieldError{{Field: "age", Message: "must be int"}},
+		},
+		{
+			name:         "нечитаемое тело",
+			body:         `{`,
+			expectedCode: "invalid_body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			var in input
+			err := c.ShouldBindJSON(&in)
+			if err == nil {
+				t.Fatal("expected a binding error")
+			}
+			code, _, fields := Binding(err)
+			if code != tt.expectedCode || !reflect.DeepEqual(fields, tt.expectedFields) {
+				t.Fatalf("got %s %+v, want %s %+v", code, fields, tt.expectedCode, tt.expectedFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package queryparams
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	return c
+}
+
+func TestList(t *testing.T) {
+	got := List(newContext("status=Created,%20Accepted&status=Closed&status="), "status")
+	if expected := []string{"Created", "Accepted", "Closed"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v, want %v", got, expected)
+	}
+}
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		endOfDay bool
+		expected *time.Time
+		wantErr  bool
+	}{
+		{name: "нет параметра"},
+		{
+			name:     "RFC 3339",
+			query:    "at=2026-03-01T10:00:00Z",
+			expected: ptr(time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "дата как нижняя граница",
+			query:    "at=2026-03-01",
+			expected: ptr(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "дата как верхняя граница",
+			query:    "at=2026-03-01",
+			endOfDay: true,
+			expected: ptr(time.Date(2026, 3, 1, 23, 59, 59, 999999999, time.UTC)),
+		},
+		{name: "не дата", query: "at=yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Time(newContext(tt.query), "at", tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("got %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,16 @@
+// Package search builds the patterns of the case-insensitive substring
+// filters of list endpoints.
+package search
+
+import "strings"
+
+// LikeEscape is the ESCAPE clause that goes with Contains patterns.
+const LikeEscape = `ESCAPE '\'`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Contains returns a LIKE pattern for lowercased values containing s. The
+// wildcards % and _ in s match themselves.
+func Contains(s string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(s)) + "%"
+}
@@ -0,0 +1,23 @@
+package search
+
+import "testing"
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "обычный текст", input: "Ann", expected: "%ann%"},
+		{name: "процент", input: "100%", expected: `%100\%%`},
+		{name: "подчёркивание", input: "a_b", expected: `%a\_b%`},
+		{name: "обратная косая черта", input: `a\b`, expected: `%a\\b%`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Contains(tt.input); got != tt.expected {
+				t.Fatalf("Contains(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}